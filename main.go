@@ -1,22 +1,33 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 
 	"endpoint_health_checker/pkg/config"
 	"endpoint_health_checker/pkg/controller"
+	"endpoint_health_checker/pkg/metrics"
 )
 
 var (
@@ -70,11 +81,6 @@ func main() {
 		klog.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
-		klog.Fatalf("Invalid configuration: %v", err)
-	}
-
 	// Initialize k8s client
 	var k8sConfig *rest.Config
 	if kubeconfig == "" {
@@ -97,6 +103,35 @@ func main() {
 		klog.Fatalf("Failed to create clientset: %v", err)
 	}
 
+	// Settings from a ConfigMap override the environment, some of them reloaded live below
+	if name := cfg.GetConfigMapName(); name != "" {
+		cfg, err = config.LoadFromConfigMap(clientset, cfg.GetPodNamespace(), name)
+		if err != nil {
+			klog.Fatalf("Failed to load configuration: %v", err)
+		}
+	}
+
+	// Validate configuration
+	if err := cfg.Validate(); err != nil {
+		klog.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if window := cfg.GetAvailabilityWindow(); window > 0 {
+		metrics.EnableAvailability(window)
+	}
+
+	// Serve metrics on every replica, standby instances simply report no checks
+	mux := http.NewServeMux()
+	if addr := cfg.GetMetricsAddr(); addr != "" {
+		go func() {
+			mux.Handle("/metrics", metrics.Handler())
+			klog.Infof("Serving metrics on %s", addr)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				klog.Errorf("Metrics server stopped: %v", err)
+			}
+		}()
+	}
+
 	// Create/ensure Lease object exists
 	leaseLock := &resourcelock.LeaseLock{
 		LeaseMeta: metav1.ObjectMeta{
@@ -109,10 +144,48 @@ func main() {
 		},
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	// SIGTERM stops the scheduler, which drains in-flight checks before the lease is released.
+	// An instance running checks first marks itself draining, so the pause isn't read as failures.
+	var checking atomic.Bool
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGTERM, os.Interrupt)
+	ctx, cancel := controller.ShutdownContext(context.Background(), sigCh, func() {
+		if !cfg.GetDrainingMarker() || !checking.Load() {
+			return
+		}
+		markCtx, markCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer markCancel()
+		if err := controller.MarkDraining(markCtx, clientset, cfg.GetPodNamespace(), cfg.GetPodName()); err != nil {
+			klog.Warningf("%v", err)
+		}
+	})
 	defer cancel()
 
+	// Push as well as serve metrics where the checker can't be scraped
+	if url := cfg.GetPushgatewayURL(); url != "" {
+		metrics.StartPusher(ctx, url, cfg.GetPodName(), cfg.GetPushInterval())
+	}
+
 	podSet := controller.NewPodSet()
+	podSet.SetNamespacePolicy(cfg.GetNamespacePolicy())
+	podSet.SetNamespaceScope(cfg.GetNamespaceScope())
+	podSet.SetOptInKeys(cfg.GetAnnotationKey(), cfg.GetReadinessGateType())
+	podSet.SetProbeAllContainers(cfg.GetProbeAllContainers())
+	podSet.SetMaxPortsPerPod(cfg.GetMaxPortsPerPod())
+	podSet.SetMaxDetailedPods(cfg.GetMaxDetailedPods())
+	podSet.SetRespectInitialDelay(cfg.GetRespectInitialDelay())
+	if keyFile := cfg.GetStatusSnapshotKeyFile(); keyFile != "" {
+		key, err := os.ReadFile(keyFile)
+		if err != nil {
+			klog.Fatalf("Failed to read STATUS_SNAPSHOT_KEY_FILE: %v", err)
+		}
+		podSet.SetSnapshotKey(bytes.TrimSpace(key))
+	}
+	if path := cfg.GetStatusSnapshotPath(); path != "" {
+		if err := podSet.LoadSnapshot(path); err != nil {
+			klog.Warningf("Starting without status snapshot: %v", err)
+		}
+	}
 
 	// Create health check configuration and scheduler directly in main
 	healthConfig := controller.NewHealthChecker()
@@ -120,27 +193,204 @@ func main() {
 	healthConfig.SetHealthCheckTimeout(cfg.GetHealthCheckTimeout())
 	healthConfig.SetWorkerCount(cfg.GetHealthCheckConcurrency())
 	healthConfig.SetRetryCount(cfg.GetHealthCheckRetryCount())
+	healthConfig.SetRejectUnsafeTargets(cfg.GetRejectUnsafeProbeTargets())
+	healthConfig.SetStartupDelay(cfg.GetStartupDelay())
+	healthConfig.SetStatusPatchType(cfg.GetStatusPatchType())
+	healthConfig.SetReadinessGateType(cfg.GetReadinessGateType())
+	healthConfig.SetHedgedProbes(cfg.GetHedgedProbes())
+	healthConfig.SetPatchTerminating(cfg.GetPatchTerminatingPods())
+	healthConfig.SetVerifyIPOwnership(cfg.GetVerifyIPOwnership())
+	healthConfig.SetStatusReassertInterval(cfg.GetStatusReassertInterval())
+	healthConfig.SetNamespaceMaxInFlight(cfg.GetNamespaceMaxInFlight())
+	healthConfig.SetSampleRate(cfg.GetSampleRate())
+	healthConfig.SetRecoveryGuard(cfg.GetRecoveryGuardDuration())
+	healthConfig.SetServiceCheckBudget(cfg.GetServiceCheckBudget())
+	healthConfig.SetHealthyIntervalBackoff(cfg.GetHealthyIntervalMultiplier(), cfg.GetHealthyIntervalMax())
+	healthConfig.SetIdleBackoff(cfg.GetIdleAfter(), cfg.GetIdleInterval())
+	healthConfig.SetShutdownGrace(cfg.GetShutdownGrace())
+	healthConfig.SetMaxInFlightICMP(cfg.GetMaxInFlightICMP())
+	healthConfig.SetICMPPackets(cfg.GetICMPPackets())
+	healthConfig.SetICMPPrivileged(cfg.GetICMPPrivileged())
+	klog.Infof("ICMP probes use %s", healthConfig.GetICMPSocketMode())
+	healthConfig.SetTCPHalfOpenCheck(cfg.GetTCPHalfOpenCheck())
+	healthConfig.SetTLSCertMinTTL(cfg.GetTLSCertMinTTL())
+	healthConfig.SetAutoStretchInterval(cfg.GetAutoStretchInterval())
+	healthConfig.SetMaxTransitionsPerHour(cfg.GetMaxTransitionsPerHour())
+	healthConfig.SetCheckHistorySize(cfg.GetCheckHistorySize())
+	healthConfig.SetTimeoutEscalation(cfg.GetTimeoutEscalation())
+	healthConfig.SetProbeTimeoutJitter(cfg.GetProbeTimeoutJitter())
+	healthConfig.SetUnknownOnUncertainty(cfg.GetUnknownOnUncertainty())
+	healthConfig.SetDualStackConditions(cfg.GetDualStackConditions())
+	healthConfig.SetDualStackPolicy(cfg.GetDualStackPolicy())
+	healthConfig.SetEndpointSliceConditions(cfg.GetEndpointSliceConditions())
+	healthConfig.SetPortRemap(cfg.GetPortRemap())
+	healthConfig.SetRequireAllPortsOnAdoption(cfg.GetRequireAllPortsOnAdoption())
+	healthConfig.SetProbeEgressRate(cfg.GetProbeEgressRate())
+	healthConfig.SetRequireKubeletReady(cfg.GetRequireKubeletReady())
+	healthConfig.SetAdoptionWarmup(cfg.GetAdoptionWarmup())
+	healthConfig.SetAllowedProbeCIDRs(cfg.GetAllowedProbeCIDRs())
+	if cfg.GetFailureRateThreshold() > 0 {
+		healthConfig.SetFailureRateBreaker(controller.NewFailureRateBreaker(
+			cfg.GetFailureRateThreshold(), cfg.GetFailureRateWindow(), cfg.GetFailureRateMinSamples()))
+	}
+	if cfg.GetControlPlaneErrorThreshold() > 0 {
+		healthConfig.SetControlPlaneGuard(controller.NewControlPlaneGuard(
+			cfg.GetControlPlaneErrorThreshold(), cfg.GetControlPlaneErrorWindow(), cfg.GetControlPlaneErrorMinSamples()))
+	}
+	if cfg.GetResultsStdout() {
+		healthConfig.SetResultWriter(controller.NewResultWriter(os.Stdout))
+	}
+
+	// Pods opted in to mutual reachability probes call back on their own listener, reachable from pods
+	if addr, url := cfg.GetCallback(); addr != "" {
+		listener := controller.NewCallbackListener(url)
+		healthConfig.SetCallbackListener(listener)
+		go func() {
+			klog.Infof("Serving probe callbacks on %s, advertised as %s", addr, url)
+			if err := http.ListenAndServe(addr, listener); err != nil {
+				klog.Errorf("Callback server stopped: %v", err)
+			}
+		}()
+	}
+	if cfg.GetPodConfigAPI() {
+		mux.Handle("/api/v1/pods/", controller.PodConfigHandler(podSet, healthConfig))
+	}
+
+	failurePolicy, err := controller.ParseFailurePolicy(cfg.GetFailurePolicy())
+	if err != nil {
+		klog.Fatalf("Invalid FAILURE_POLICY: %v", err)
+	}
+	healthConfig.SetFailurePolicy(failurePolicy)
+	if cfg.GetHTTPProbeViaAPIProxy() {
+		healthConfig.SetProxyClient(clientset.CoreV1().RESTClient())
+	}
+	if target := cfg.GetEventTarget(); target != "none" {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientset.CoreV1().Events("")})
+		recorder := broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "endpoint-health-checker"})
+		healthConfig.SetEventRecorder(recorder, target)
+	}
+
+	// Apply reloadable settings when the ConfigMap changes, the scheduler picks up a new interval
+	// on its next tick. Other settings need a restart.
+	if name := cfg.GetConfigMapName(); name != "" {
+		go config.WatchConfigMap(ctx, clientset, cfg.GetPodNamespace(), name, func(updated *config.Config) {
+			healthConfig.SetHealthCheckInterval(updated.GetHealthCheckInterval())
+			healthConfig.SetHealthCheckTimeout(updated.GetHealthCheckTimeout())
+			healthConfig.SetRetryCount(updated.GetHealthCheckRetryCount())
+			klog.Infof("Applied configuration from ConfigMap %s: interval=%v, timeout=%v, retryCount=%d",
+				name, updated.GetHealthCheckInterval(), updated.GetHealthCheckTimeout(), updated.GetHealthCheckRetryCount())
+		})
+	}
+
+	ctrl := controller.NewController(clientset, 0, podSet, cfg.GetPodLabelSelector())
+	ctrl.SetReadinessRecheckInterval(cfg.GetReadinessRecheckInterval())
+	ctrl.SetReconcileInterval(cfg.GetReconcileInterval())
+	ctrl.EnableOwnerRollup(cfg.GetOwnerRollupInterval())
+	debounceThreshold, debounceWindow, debounceDelay := cfg.GetServiceDebounce()
+	if cfg.GetServiceCheckBudget() > 0 || cfg.GetMinHealthyPerService() > 0 || cfg.GetServiceCheckInterval() > 0 || debounceThreshold > 0 {
+		ctrl.EnableServiceGrouping()
+	}
+	if minHealthy := cfg.GetMinHealthyPerService(); minHealthy > 0 {
+		healthConfig.SetMinHealthyPerService(ctrl.GetServiceLister(), podSet, minHealthy)
+	}
+	if debounceThreshold > 0 {
+		healthConfig.SetServiceDebounce(ctrl.GetServiceLister(), debounceThreshold, debounceWindow, debounceDelay)
+	}
+	if quorum := cfg.GetReachabilityQuorum(); quorum > 0 {
+		healthConfig.SetReachabilityQuorum(ctrl.GetPodLister(), cfg.GetNodeName(), quorum, cfg.GetReachabilityReportMaxAge())
+	}
+	zoneTimeouts := cfg.GetSameZoneTimeout() > 0 || cfg.GetCrossZoneTimeout() > 0
+	if cfg.GetSkipNotReadyNodes() || zoneTimeouts {
+		ctrl.EnableNodeReadiness()
+	}
+	if zoneTimeouts {
+		healthConfig.SetZoneTimeouts(ctrl.GetNodeLister(), cfg.GetNodeName(), cfg.GetSameZoneTimeout(), cfg.GetCrossZoneTimeout())
+	}
 
 	// Create scheduler with configuration
 	scheduler := controller.NewScheduler(clientset, podSet)
 	scheduler.SetConfig(healthConfig)
+	scheduler.SetPodLister(ctrl.GetPodLister())
+	scheduler.SetServiceLister(ctrl.GetServiceLister())
+	if cfg.GetSkipNotReadyNodes() {
+		scheduler.SetNodeLister(ctrl.GetNodeLister())
+	}
+
+	// Lets the checker's own Deployment probe whether it is wedged
+	if addr := cfg.GetHealthAddr(); addr != "" {
+		handler := controller.SelfHealthHandler(ctrl, scheduler, checking.Load)
+		go func() {
+			klog.Infof("Serving /healthz and /readyz on %s", addr)
+			if err := http.ListenAndServe(addr, handler); err != nil {
+				klog.Errorf("Health server stopped: %v", err)
+			}
+		}()
+	}
+
+	// A failed controller can't be restarted within the process, its informers are stopped
+	var controllerFailed atomic.Bool
+	exitIfControllerFailed := func() {
+		if controllerFailed.Load() {
+			klog.Flush()
+			os.Exit(1)
+		}
+	}
+
+	run := func(ctx context.Context) {
+		checking.Store(true)
+		defer checking.Store(false)
+		stopCh := make(chan struct{})
+		go func() {
+			if err := ctrl.Run(stopCh); err != nil {
+				// Stop the process, releasing the lease on the way so a standby replica can take
+				// over while this one restarts
+				klog.Errorf("%s: controller failed: %v, releasing leadership and exiting", cfg.GetPodName(), err)
+				controllerFailed.Store(true)
+				cancel()
+			}
+		}()
+		saveSnapshot := func() {
+			if err := podSet.SaveSnapshot(cfg.GetStatusSnapshotPath()); err != nil {
+				klog.Warningf("%v", err)
+			}
+		}
+		if cfg.GetStatusSnapshotPath() != "" {
+			go wait.Until(saveSnapshot, cfg.GetStatusSnapshotInterval(), ctx.Done())
+		}
+		if interval := cfg.GetServiceCheckInterval(); interval > 0 {
+			checker := controller.NewServiceChecker(ctrl.GetServiceLister(), podSet, cfg.GetServiceCheckProbes(), healthConfig.GetHealthCheckTimeout)
+			go checker.Run(ctx, interval)
+		}
+		// Returns once the context is done and in-flight checks have drained
+		scheduler.StartHealthCheckWorkers(ctx)
+		close(stopCh)
+		if cfg.GetStatusSnapshotPath() != "" {
+			saveSnapshot()
+		}
+		// Another leader may change conditions meanwhile, the next term must not skip them as unchanged
+		podSet.ClearHealthStatus()
+	}
 
-	ctrl := controller.NewController(clientset, 0, podSet)
+	// Every DaemonSet instance probes and reports, the quorum replaces a single leader
+	if quorum := cfg.GetReachabilityQuorum(); quorum > 0 {
+		klog.Infof("%s: reporting reachability for node %s, quorum %d, start health check loop",
+			cfg.GetPodName(), cfg.GetNodeName(), quorum)
+		run(ctx)
+		exitIfControllerFailed()
+		return
+	}
 
 	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
-		Lock:            leaseLock,
+		Lock:            metrics.InstrumentLock(leaseLock),
 		ReleaseOnCancel: true,
 		LeaseDuration:   cfg.GetLeaseDuration(),
 		RenewDeadline:   cfg.GetRenewDeadline(),
 		RetryPeriod:     cfg.GetRetryPeriod(),
-		Callbacks: leaderelection.LeaderCallbacks{
+		Callbacks: metrics.InstrumentLeaderCallbacks(leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
 				klog.Infof("%s: I am the leader, start health check loop", cfg.GetPodName())
-				stopCh := make(chan struct{})
-				go ctrl.Run(stopCh)
-				go scheduler.StartHealthCheckWorkers(ctx)
-				<-ctx.Done()
-				close(stopCh)
+				run(ctx)
 			},
 			OnStoppedLeading: func() {
 				klog.Warningf("%s: lost leadership, now standby", cfg.GetPodName())
@@ -152,6 +402,7 @@ func main() {
 					klog.Infof("%s: new leader is %s", cfg.GetPodName(), identity)
 				}
 			},
-		},
+		}),
 	})
+	exitIfControllerFailed()
 }
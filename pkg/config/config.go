@@ -2,9 +2,14 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/klog/v2"
 )
 
@@ -16,15 +21,194 @@ type Config struct {
 	HealthCheckRetryCount  int
 	PodName                string
 	PodNamespace           string
+	NodeName               string
 	LeaseLockName          string
 	LeaseLockNamespace     string
 	LeaseDuration          time.Duration
 	RenewDeadline          time.Duration
 	RetryPeriod            time.Duration
+	// RejectUnsafeProbeTargets refuses to probe loopback, link-local and unspecified pod IPs
+	RejectUnsafeProbeTargets bool
+	// FailureRateThreshold pauses status updates when the rolling failure rate exceeds it, 0 disables
+	FailureRateThreshold  float64
+	FailureRateWindow     time.Duration
+	FailureRateMinSamples int
+	// ControlPlaneErrorThreshold pauses status updates while the rolling API error rate exceeds it, 0 disables
+	ControlPlaneErrorThreshold  float64
+	ControlPlaneErrorWindow     time.Duration
+	ControlPlaneErrorMinSamples int
+	// ResultsStdout emits one JSON line per check result to stdout
+	ResultsStdout bool
+	// NamespacePolicy forces health checking on (true) or off (false) for whole namespaces
+	NamespacePolicy map[string]bool
+	// PodLabelSelector narrows the pod informer to matching pods, empty watches all pods
+	PodLabelSelector string
+	// NamespaceAllowlist limits health checking to these namespaces, empty allows all
+	NamespaceAllowlist []string
+	// NamespaceDenylist keeps pods in these namespaces untracked, taking precedence over the allowlist
+	NamespaceDenylist []string
+	// AllowedProbeCIDRs restricts probe targets to these networks, empty allows any address
+	AllowedProbeCIDRs []*net.IPNet
+	// AdoptionWarmup only records metrics for newly adopted pods for this long, 0 disables
+	AdoptionWarmup time.Duration
+	// RequireKubeletReady only considers a pod healthy when kubelet reports its containers Ready and the probe passes
+	RequireKubeletReady bool
+	// SameZoneTimeout is the probe timeout of pods in the checker's zone, 0 uses HealthCheckTimeout
+	SameZoneTimeout time.Duration
+	// CrossZoneTimeout is the probe timeout of pods in other zones, 0 uses HealthCheckTimeout
+	CrossZoneTimeout time.Duration
+	// DrainingMarker annotates the checker pod as draining on shutdown, before the lease is released
+	DrainingMarker bool
+	// ProbeEgressRate is the steady rate per second checks are started at, 0 disables shaping
+	ProbeEgressRate float64
+	// RequireAllPortsOnAdoption keeps newly tracked pods pending until every declared port passed once
+	RequireAllPortsOnAdoption bool
+	// PortRemap maps declared container ports to the port actually probed, e.g. a uniform sidecar admin port
+	PortRemap map[int32]int32
+	// StartupDelay is the minimum pod age before it is probed
+	StartupDelay time.Duration
+	// AnnotationKey is the pod annotation that opts a pod in to health checking when "true"
+	AnnotationKey string
+	// ReadinessGateType is the readiness gate condition driven by health checks, also opting pods in
+	ReadinessGateType string
+	// StatusPatchType is merge (replace the conditions list) or strategic (merge conditions by type)
+	StatusPatchType string
+	// HedgedProbes is the number of concurrent probes per attempt, 1 disables hedging
+	HedgedProbes int
+	// PatchTerminatingPods keeps updating status of pods that have a deletion timestamp
+	PatchTerminatingPods bool
+	// MetricsAddr is the listen address of the metrics endpoint, empty disables it
+	MetricsAddr string
+	// HealthAddr is the listen address of the checker's own /healthz and /readyz, empty disables them
+	HealthAddr string
+	// PodConfigAPI serves the effective per-pod configuration on the metrics listener, so it requires MetricsAddr
+	PodConfigAPI bool
+	// CallbackAddr is the listen address pods call back for mutual reachability probes, empty disables them
+	CallbackAddr string
+	// CallbackURL is the base URL pods reach the callback listener on, e.g. http://checker.ns.svc:8090
+	CallbackURL string
+	// PushgatewayURL is the Prometheus Pushgateway metrics are pushed to, empty disables pushing
+	PushgatewayURL string
+	// PushInterval is how often metrics are pushed to the Pushgateway
+	PushInterval time.Duration
+	// ReadinessRecheckInterval re-evaluates enabled pods that are not yet ready, 0 disables
+	ReadinessRecheckInterval time.Duration
+	// ReconcileInterval is how often tracked pods are reconciled against the informer cache, 0 disables
+	ReconcileInterval time.Duration
+	// VerifyIPOwnership confirms via the informer cache that a tracked pod still owns its IP before probing
+	VerifyIPOwnership bool
+	// OwnerRollupInterval publishes per-owner healthy ratios at this interval, 0 disables
+	OwnerRollupInterval time.Duration
+	// StatusReassertInterval re-verifies and re-patches unchanged conditions at least this often, 0 disables
+	StatusReassertInterval time.Duration
+	// NamespaceMaxInFlight bounds concurrent checks per namespace and dispatches round-robin across namespaces, 0 disables
+	NamespaceMaxInFlight int
+	// SampleRate is the fraction of pods checked per tick, rotating so every pod is covered within 1/SampleRate ticks
+	SampleRate float64
+	// RecoveryGuardDuration is how long probes must pass continuously before a failed pod is marked healthy, 0 disables
+	RecoveryGuardDuration time.Duration
+	// ServiceCheckBudget caps checks per tick, prioritizing one healthy endpoint per Service, 0 disables
+	ServiceCheckBudget int
+	// EventTarget records health transition events on the pod or its controller owner, none disables events
+	EventTarget string
+	// HealthyIntervalMultiplier stretches a pod's check interval on each consecutive success, 1 disables
+	HealthyIntervalMultiplier float64
+	// HealthyIntervalMax caps the stretched check interval
+	HealthyIntervalMax time.Duration
+	// IdleAfter is how long no pod must be tracked before the scheduler backs off to IdleInterval
+	IdleAfter time.Duration
+	// IdleInterval is the scheduler tick interval while no pod is tracked, 0 disables the backoff
+	IdleInterval time.Duration
+	// HTTPProbeViaAPIProxy sends HTTP probes through the API server pod proxy instead of dialing pod IPs
+	HTTPProbeViaAPIProxy bool
+	// ProbeAllContainers also probes the declared ports of containers that have no probes
+	ProbeAllContainers bool
+	// MaxPortsPerPod caps the ports probed per pod to the lowest ones, 0 probes all
+	MaxPortsPerPod int
+	// CheckHistorySize is how many of its latest checks each pod keeps for the history API, 0 keeps none
+	CheckHistorySize int
+	// MaxDetailedPods caps the pods keeping check history and transition times, 0 for no cap
+	MaxDetailedPods int
+	// RespectInitialDelay defers the first probe until readinessProbe initialDelaySeconds passed since container start
+	RespectInitialDelay bool
+	// StatusSnapshotPath is a file the pod statuses are periodically saved to and seeded from on restart, empty disables
+	StatusSnapshotPath string
+	// StatusSnapshotInterval is how often the status snapshot is written
+	StatusSnapshotInterval time.Duration
+	// StatusSnapshotKeyFile holds the HMAC key snapshots are signed with, empty leaves them unsigned
+	StatusSnapshotKeyFile string
+	// DualStackConditions probes dual-stack pods on both IP families and sets a condition per family
+	DualStackConditions bool
+	// DualStackPolicy decides the health of dual-stack pods: primary (primary IP only), any or all families
+	DualStackPolicy string
+	// EndpointSliceConditions writes health into the pod's EndpointSlice endpoints: off or also (besides its conditions)
+	EndpointSliceConditions string
+	// UnknownOnUncertainty reports failed pods as Unknown instead of False while the failure-rate
+	// breaker is open or shortly after the API server was unreachable
+	UnknownOnUncertainty bool
+	// ProbeTimeoutJitter randomizes each TCP and ICMP attempt timeout within ±this fraction of HealthCheckTimeout
+	ProbeTimeoutJitter float64
+	// MaxTransitionsPerHour pins a pod to its current status once it flipped this often within an hour, 0 disables
+	MaxTransitionsPerHour int
+	// TimeoutEscalationAfter is the number of consecutive timeouts after which a pod's probe timeout is escalated, 0 disables
+	TimeoutEscalationAfter int
+	// TimeoutEscalationMax caps the escalated probe timeout
+	TimeoutEscalationMax time.Duration
+	// ServiceCheckInterval is how often opted-in Services are probed through their ClusterIP, 0 disables
+	ServiceCheckInterval time.Duration
+	// ServiceCheckProbes is the number of requests sent per Service check to verify session affinity
+	ServiceCheckProbes int
+	// AutoStretchInterval stretches the effective interval when scans consistently take longer than it
+	AutoStretchInterval bool
+	// TCPHalfOpenCheck makes TCP probes send a byte and require a response or close, detecting apps stuck in the accept backlog
+	TCPHalfOpenCheck bool
+	// TLSCertMinTTL fails TLS and HTTPS probes whose certificate expires within this window, 0 disables
+	TLSCertMinTTL time.Duration
+	// MaxInFlightICMP bounds concurrent ICMP operations separately from TCP, 0 disables
+	MaxInFlightICMP int
+	// ICMPPacketCount is the number of echo requests each ICMP probe attempt sends
+	ICMPPacketCount int
+	// ICMPLossThreshold is the packet loss percentage at which an ICMP probe attempt fails, 0 fails only without any reply
+	ICMPLossThreshold float64
+	// ICMPPrivileged selects raw ("true") or datagram ("false") ICMP sockets, "auto" falls back to datagram
+	ICMPPrivileged string
+	// MinHealthyPerService defers unhealthy transitions that would leave a Service with fewer healthy endpoints, 0 disables
+	MinHealthyPerService int
+	// ServiceDebounceThreshold is the number of endpoints of a Service failing within ServiceDebounceWindow
+	// that holds back their unhealthy transitions for ServiceDebounceDelay, 0 disables
+	ServiceDebounceThreshold int
+	ServiceDebounceWindow    time.Duration
+	ServiceDebounceDelay     time.Duration
+	// ReachabilityQuorum runs every DaemonSet instance without leader election and marks a pod
+	// unhealthy only when this many nodes report it unreachable, 0 disables
+	ReachabilityQuorum int
+	// ReachabilityReportMaxAge is how long a node's reachability report counts toward the quorum
+	ReachabilityReportMaxAge time.Duration
+	// AvailabilityWindow is the rolling window of the availability ratio metrics, 0 disables them
+	AvailabilityWindow time.Duration
+	// SkipNotReadyNodes skips probing pods whose node is NotReady, keeping their current status
+	SkipNotReadyNodes bool
+	// ShutdownGrace lets in-flight checks finish their status patch at shutdown before they are canceled
+	ShutdownGrace time.Duration
+	// FailurePolicy sets per failure class hysteresis, e.g. "timeout=3/30s,refused=1,tls_error=warn"
+	FailurePolicy string
+	// ConfigMapName is a ConfigMap in the checker's namespace whose keys override the environment, empty disables it
+	ConfigMapName string
 }
 
 // LoadFromEnv loads configuration from environment variables
 func LoadFromEnv() (*Config, error) {
+	return load(os.LookupEnv)
+}
+
+// load builds the configuration from the settings lookupEnv finds, named like the environment
+// variables
+func load(lookupEnv func(key string) (string, bool)) (*Config, error) {
+	getenv := func(key string) string {
+		value, _ := lookupEnv(key)
+		return value
+	}
+
 	config := &Config{}
 
 	// Set default values
@@ -36,9 +220,37 @@ func LoadFromEnv() (*Config, error) {
 	config.LeaseDuration = 4 * time.Second
 	config.RenewDeadline = 2 * time.Second
 	config.RetryPeriod = 500 * time.Millisecond
+	config.RejectUnsafeProbeTargets = true
+	config.FailureRateWindow = 30 * time.Second
+	config.FailureRateMinSamples = 20
+	config.ControlPlaneErrorWindow = time.Minute
+	config.ControlPlaneErrorMinSamples = 10
+	config.StatusPatchType = "merge"
+	config.AnnotationKey = "endpoint-health-checker.io/enabled"
+	config.ReadinessGateType = "endpointHealthCheckSuccess"
+	config.ReconcileInterval = 5 * time.Minute
+	config.DualStackPolicy = "primary"
+	config.EndpointSliceConditions = "off"
+	config.ICMPPacketCount = 1
+	config.ICMPLossThreshold = 100
+	config.ICMPPrivileged = "auto"
+	config.HedgedProbes = 1
+	config.MetricsAddr = ":8080"
+	config.PushInterval = 30 * time.Second
+	config.SampleRate = 1
+	config.EventTarget = "none"
+	config.HealthyIntervalMultiplier = 1
+	config.HealthyIntervalMax = 30 * time.Second
+	config.IdleAfter = time.Minute
+	config.TimeoutEscalationMax = 10 * time.Second
+	config.ServiceDebounceWindow = 10 * time.Second
+	config.ServiceDebounceDelay = 30 * time.Second
+	config.ReachabilityReportMaxAge = time.Minute
+	config.StatusSnapshotInterval = 30 * time.Second
+	config.ServiceCheckProbes = 8
 
 	// Parse health check interval
-	if intervalStr := os.Getenv("HEALTH_CHECK_INTERVAL"); intervalStr != "" {
+	if intervalStr := getenv("HEALTH_CHECK_INTERVAL"); intervalStr != "" {
 		if interval, err := time.ParseDuration(intervalStr); err != nil {
 			return nil, fmt.Errorf("invalid HEALTH_CHECK_INTERVAL: %v", err)
 		} else {
@@ -47,7 +259,7 @@ func LoadFromEnv() (*Config, error) {
 	}
 
 	// Parse health check timeout
-	if timeoutStr := os.Getenv("HEALTH_CHECK_TIMEOUT"); timeoutStr != "" {
+	if timeoutStr := getenv("HEALTH_CHECK_TIMEOUT"); timeoutStr != "" {
 		if timeout, err := time.ParseDuration(timeoutStr); err != nil {
 			return nil, fmt.Errorf("invalid HEALTH_CHECK_TIMEOUT: %v", err)
 		} else {
@@ -56,7 +268,7 @@ func LoadFromEnv() (*Config, error) {
 	}
 
 	// Parse health check concurrency
-	if concurrencyStr := os.Getenv("HEALTH_CHECK_CONCURRENCY"); concurrencyStr != "" {
+	if concurrencyStr := getenv("HEALTH_CHECK_CONCURRENCY"); concurrencyStr != "" {
 		var concurrency int
 		if count, err := fmt.Sscanf(concurrencyStr, "%d", &concurrency); err != nil || count != 1 {
 			klog.Warningf("Invalid HEALTH_CHECK_CONCURRENCY: %s, using default: %d", concurrencyStr, config.HealthCheckConcurrency)
@@ -66,7 +278,7 @@ func LoadFromEnv() (*Config, error) {
 	}
 
 	// Parse health check retry count
-	if retryCountStr := os.Getenv("HEALTH_CHECK_RETRY_COUNT"); retryCountStr != "" {
+	if retryCountStr := getenv("HEALTH_CHECK_RETRY_COUNT"); retryCountStr != "" {
 		var retryCount int
 		if count, err := fmt.Sscanf(retryCountStr, "%d", &retryCount); err != nil || count != 1 {
 			klog.Warningf("Invalid HEALTH_CHECK_RETRY_COUNT: %s, using default: %d", retryCountStr, config.HealthCheckRetryCount)
@@ -75,31 +287,692 @@ func LoadFromEnv() (*Config, error) {
 		}
 	}
 
+	// Parse unsafe probe target handling
+	if rejectStr := getenv("REJECT_UNSAFE_PROBE_TARGETS"); rejectStr != "" {
+		if reject, err := strconv.ParseBool(rejectStr); err != nil {
+			klog.Warningf("Invalid REJECT_UNSAFE_PROBE_TARGETS: %s, using default: %v", rejectStr, config.RejectUnsafeProbeTargets)
+		} else {
+			config.RejectUnsafeProbeTargets = reject
+		}
+	}
+
+	// Parse failure-rate breaker configuration
+	if thresholdStr := getenv("FAILURE_RATE_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.ParseFloat(thresholdStr, 64); err != nil {
+			return nil, fmt.Errorf("invalid FAILURE_RATE_THRESHOLD: %v", err)
+		} else {
+			config.FailureRateThreshold = threshold
+		}
+	}
+
+	if windowStr := getenv("FAILURE_RATE_WINDOW"); windowStr != "" {
+		if window, err := time.ParseDuration(windowStr); err != nil {
+			return nil, fmt.Errorf("invalid FAILURE_RATE_WINDOW: %v", err)
+		} else {
+			config.FailureRateWindow = window
+		}
+	}
+
+	if minSamplesStr := getenv("FAILURE_RATE_MIN_SAMPLES"); minSamplesStr != "" {
+		var minSamples int
+		if count, err := fmt.Sscanf(minSamplesStr, "%d", &minSamples); err != nil || count != 1 {
+			klog.Warningf("Invalid FAILURE_RATE_MIN_SAMPLES: %s, using default: %d", minSamplesStr, config.FailureRateMinSamples)
+		} else if minSamples > 0 {
+			config.FailureRateMinSamples = minSamples
+		}
+	}
+
+	// Parse control-plane degraded mode configuration
+	if thresholdStr := getenv("CONTROL_PLANE_ERROR_THRESHOLD"); thresholdStr != "" {
+		threshold, err := strconv.ParseFloat(thresholdStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONTROL_PLANE_ERROR_THRESHOLD: %v", err)
+		}
+		config.ControlPlaneErrorThreshold = threshold
+	}
+
+	if windowStr := getenv("CONTROL_PLANE_ERROR_WINDOW"); windowStr != "" {
+		window, err := time.ParseDuration(windowStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CONTROL_PLANE_ERROR_WINDOW: %v", err)
+		}
+		config.ControlPlaneErrorWindow = window
+	}
+
+	if minSamplesStr := getenv("CONTROL_PLANE_ERROR_MIN_SAMPLES"); minSamplesStr != "" {
+		var minSamples int
+		if count, err := fmt.Sscanf(minSamplesStr, "%d", &minSamples); err != nil || count != 1 {
+			klog.Warningf("Invalid CONTROL_PLANE_ERROR_MIN_SAMPLES: %s, using default: %d", minSamplesStr, config.ControlPlaneErrorMinSamples)
+		} else if minSamples > 0 {
+			config.ControlPlaneErrorMinSamples = minSamples
+		}
+	}
+
+	// Parse results output configuration
+	if resultsStdoutStr := getenv("RESULTS_STDOUT"); resultsStdoutStr != "" {
+		if resultsStdout, err := strconv.ParseBool(resultsStdoutStr); err != nil {
+			klog.Warningf("Invalid RESULTS_STDOUT: %s, using default: %v", resultsStdoutStr, config.ResultsStdout)
+		} else {
+			config.ResultsStdout = resultsStdout
+		}
+	}
+
+	// Parse namespace policy
+	if policyStr := getenv("NAMESPACE_POLICY"); policyStr != "" {
+		policy, err := ParseNamespacePolicy(policyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NAMESPACE_POLICY: %v", err)
+		}
+		config.NamespacePolicy = policy
+	}
+
+	// Parse pod informer label selector
+	config.PodLabelSelector = strings.TrimSpace(getenv("POD_LABEL_SELECTOR"))
+
+	// Parse namespace allowlist and denylist
+	if allowStr := getenv("NAMESPACE_ALLOWLIST"); allowStr != "" {
+		allow, err := ParseNamespaces(allowStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NAMESPACE_ALLOWLIST: %v", err)
+		}
+		config.NamespaceAllowlist = allow
+	}
+	if denyStr := getenv("NAMESPACE_DENYLIST"); denyStr != "" {
+		deny, err := ParseNamespaces(denyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NAMESPACE_DENYLIST: %v", err)
+		}
+		config.NamespaceDenylist = deny
+	}
+
+	// Parse startup delay
+	if startupDelayStr := getenv("STARTUP_DELAY"); startupDelayStr != "" {
+		if startupDelay, err := time.ParseDuration(startupDelayStr); err != nil {
+			return nil, fmt.Errorf("invalid STARTUP_DELAY: %v", err)
+		} else {
+			config.StartupDelay = startupDelay
+		}
+	}
+
+	// Parse opt-in annotation key and readiness gate type
+	if annotationKey := getenv("ANNOTATION_KEY"); annotationKey != "" {
+		config.AnnotationKey = annotationKey
+	}
+	if gateType := getenv("READINESS_GATE_TYPE"); gateType != "" {
+		config.ReadinessGateType = gateType
+	}
+
+	// Parse status patch type
+	if patchType := getenv("STATUS_PATCH_TYPE"); patchType != "" {
+		config.StatusPatchType = patchType
+	}
+
+	// Parse hedged probe count
+	if hedgedStr := getenv("HEDGED_PROBES"); hedgedStr != "" {
+		var hedged int
+		if count, err := fmt.Sscanf(hedgedStr, "%d", &hedged); err != nil || count != 1 {
+			klog.Warningf("Invalid HEDGED_PROBES: %s, using default: %d", hedgedStr, config.HedgedProbes)
+		} else if hedged > 0 {
+			config.HedgedProbes = hedged
+		}
+	}
+
+	// Parse terminating pod handling
+	if patchTerminatingStr := getenv("PATCH_TERMINATING_PODS"); patchTerminatingStr != "" {
+		if patchTerminating, err := strconv.ParseBool(patchTerminatingStr); err != nil {
+			klog.Warningf("Invalid PATCH_TERMINATING_PODS: %s, using default: %v", patchTerminatingStr, config.PatchTerminatingPods)
+		} else {
+			config.PatchTerminatingPods = patchTerminating
+		}
+	}
+
+	// Parse metrics address, an empty value disables the endpoint
+	if metricsAddr, ok := lookupEnv("METRICS_ADDR"); ok {
+		config.MetricsAddr = metricsAddr
+	}
+	config.HealthAddr = getenv("HEALTH_ADDR")
+
+	// Parse effective pod config API
+	if apiStr := getenv("POD_CONFIG_API"); apiStr != "" {
+		if api, err := strconv.ParseBool(apiStr); err != nil {
+			klog.Warningf("Invalid POD_CONFIG_API: %s, using default: %v", apiStr, config.PodConfigAPI)
+		} else {
+			config.PodConfigAPI = api
+		}
+	}
+
+	// Parse readiness recheck interval
+	if recheckStr := getenv("READINESS_RECHECK_INTERVAL"); recheckStr != "" {
+		if recheck, err := time.ParseDuration(recheckStr); err != nil {
+			return nil, fmt.Errorf("invalid READINESS_RECHECK_INTERVAL: %v", err)
+		} else {
+			config.ReadinessRecheckInterval = recheck
+		}
+	}
+
+	// Parse orphaned pod reconcile interval
+	if reconcileStr := getenv("RECONCILE_INTERVAL"); reconcileStr != "" {
+		if reconcile, err := time.ParseDuration(reconcileStr); err != nil {
+			return nil, fmt.Errorf("invalid RECONCILE_INTERVAL: %v", err)
+		} else {
+			config.ReconcileInterval = reconcile
+		}
+	}
+
+	// Parse IP ownership verification
+	if verifyStr := getenv("VERIFY_IP_OWNERSHIP"); verifyStr != "" {
+		if verify, err := strconv.ParseBool(verifyStr); err != nil {
+			klog.Warningf("Invalid VERIFY_IP_OWNERSHIP: %s, using default: %v", verifyStr, config.VerifyIPOwnership)
+		} else {
+			config.VerifyIPOwnership = verify
+		}
+	}
+
+	// Parse owner rollup interval
+	if rollupStr := getenv("OWNER_ROLLUP_INTERVAL"); rollupStr != "" {
+		if rollup, err := time.ParseDuration(rollupStr); err != nil {
+			return nil, fmt.Errorf("invalid OWNER_ROLLUP_INTERVAL: %v", err)
+		} else {
+			config.OwnerRollupInterval = rollup
+		}
+	}
+
+	// Parse status reassert interval
+	if reassertStr := getenv("STATUS_REASSERT_INTERVAL"); reassertStr != "" {
+		if reassert, err := time.ParseDuration(reassertStr); err != nil {
+			return nil, fmt.Errorf("invalid STATUS_REASSERT_INTERVAL: %v", err)
+		} else {
+			config.StatusReassertInterval = reassert
+		}
+	}
+
+	// Parse per-namespace in-flight bound
+	if maxInFlightStr := getenv("NAMESPACE_MAX_IN_FLIGHT"); maxInFlightStr != "" {
+		var maxInFlight int
+		if count, err := fmt.Sscanf(maxInFlightStr, "%d", &maxInFlight); err != nil || count != 1 {
+			klog.Warningf("Invalid NAMESPACE_MAX_IN_FLIGHT: %s, using default: %d", maxInFlightStr, config.NamespaceMaxInFlight)
+		} else {
+			config.NamespaceMaxInFlight = maxInFlight
+		}
+	}
+
+	// Parse sample rate
+	if sampleRateStr := getenv("SAMPLE_RATE"); sampleRateStr != "" {
+		if sampleRate, err := strconv.ParseFloat(sampleRateStr, 64); err != nil {
+			return nil, fmt.Errorf("invalid SAMPLE_RATE: %v", err)
+		} else {
+			config.SampleRate = sampleRate
+		}
+	}
+
+	// Parse recovery guard duration
+	if guardStr := getenv("RECOVERY_GUARD_DURATION"); guardStr != "" {
+		if guard, err := time.ParseDuration(guardStr); err != nil {
+			return nil, fmt.Errorf("invalid RECOVERY_GUARD_DURATION: %v", err)
+		} else {
+			config.RecoveryGuardDuration = guard
+		}
+	}
+
+	// Parse service coalescing budget
+	if budgetStr := getenv("SERVICE_CHECK_BUDGET"); budgetStr != "" {
+		var budget int
+		if count, err := fmt.Sscanf(budgetStr, "%d", &budget); err != nil || count != 1 {
+			klog.Warningf("Invalid SERVICE_CHECK_BUDGET: %s, using default: %d", budgetStr, config.ServiceCheckBudget)
+		} else {
+			config.ServiceCheckBudget = budget
+		}
+	}
+
+	// Parse event target
+	if eventTarget := getenv("EVENT_TARGET"); eventTarget != "" {
+		config.EventTarget = eventTarget
+	}
+
+	// Parse healthy interval backoff
+	if multiplierStr := getenv("HEALTHY_INTERVAL_MULTIPLIER"); multiplierStr != "" {
+		if multiplier, err := strconv.ParseFloat(multiplierStr, 64); err != nil {
+			return nil, fmt.Errorf("invalid HEALTHY_INTERVAL_MULTIPLIER: %v", err)
+		} else {
+			config.HealthyIntervalMultiplier = multiplier
+		}
+	}
+
+	if maxStr := getenv("HEALTHY_INTERVAL_MAX"); maxStr != "" {
+		if max, err := time.ParseDuration(maxStr); err != nil {
+			return nil, fmt.Errorf("invalid HEALTHY_INTERVAL_MAX: %v", err)
+		} else {
+			config.HealthyIntervalMax = max
+		}
+	}
+
+	// Parse idle scheduler backoff
+	if afterStr := getenv("IDLE_AFTER"); afterStr != "" {
+		after, err := time.ParseDuration(afterStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IDLE_AFTER: %v", err)
+		}
+		config.IdleAfter = after
+	}
+
+	if intervalStr := getenv("IDLE_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IDLE_INTERVAL: %v", err)
+		}
+		config.IdleInterval = interval
+	}
+
+	// Parse API server proxy mode
+	if viaProxyStr := getenv("HTTP_PROBE_VIA_API_PROXY"); viaProxyStr != "" {
+		if viaProxy, err := strconv.ParseBool(viaProxyStr); err != nil {
+			klog.Warningf("Invalid HTTP_PROBE_VIA_API_PROXY: %s, using default: %v", viaProxyStr, config.HTTPProbeViaAPIProxy)
+		} else {
+			config.HTTPProbeViaAPIProxy = viaProxy
+		}
+	}
+
+	// Parse container port selection
+	if allContainersStr := getenv("PROBE_ALL_CONTAINERS"); allContainersStr != "" {
+		if allContainers, err := strconv.ParseBool(allContainersStr); err != nil {
+			klog.Warningf("Invalid PROBE_ALL_CONTAINERS: %s, using default: %v", allContainersStr, config.ProbeAllContainers)
+		} else {
+			config.ProbeAllContainers = allContainers
+		}
+	}
+
+	if maxPortsStr := getenv("MAX_PORTS_PER_POD"); maxPortsStr != "" {
+		var maxPorts int
+		if count, err := fmt.Sscanf(maxPortsStr, "%d", &maxPorts); err != nil || count != 1 || maxPorts < 0 {
+			klog.Warningf("Invalid MAX_PORTS_PER_POD: %s, using default: %d", maxPortsStr, config.MaxPortsPerPod)
+		} else {
+			config.MaxPortsPerPod = maxPorts
+		}
+	}
+
+	// Parse per-pod detail bounds
+	if sizeStr := getenv("CHECK_HISTORY_SIZE"); sizeStr != "" {
+		var size int
+		if count, err := fmt.Sscanf(sizeStr, "%d", &size); err != nil || count != 1 || size < 0 {
+			klog.Warningf("Invalid CHECK_HISTORY_SIZE: %s, using default: %d", sizeStr, config.CheckHistorySize)
+		} else {
+			config.CheckHistorySize = size
+		}
+	}
+	if maxStr := getenv("MAX_DETAILED_PODS"); maxStr != "" {
+		var maxPods int
+		if count, err := fmt.Sscanf(maxStr, "%d", &maxPods); err != nil || count != 1 || maxPods < 0 {
+			klog.Warningf("Invalid MAX_DETAILED_PODS: %s, using default: %d", maxStr, config.MaxDetailedPods)
+		} else {
+			config.MaxDetailedPods = maxPods
+		}
+	}
+
+	// Parse status snapshot
+	config.StatusSnapshotPath = getenv("STATUS_SNAPSHOT_PATH")
+	if intervalStr := getenv("STATUS_SNAPSHOT_INTERVAL"); intervalStr != "" {
+		if interval, err := time.ParseDuration(intervalStr); err != nil {
+			return nil, fmt.Errorf("invalid STATUS_SNAPSHOT_INTERVAL: %v", err)
+		} else {
+			config.StatusSnapshotInterval = interval
+		}
+	}
+	config.StatusSnapshotKeyFile = getenv("STATUS_SNAPSHOT_KEY_FILE")
+
+	// Parse readiness probe initial delay
+	if delayStr := getenv("RESPECT_INITIAL_DELAY"); delayStr != "" {
+		if respect, err := strconv.ParseBool(delayStr); err != nil {
+			klog.Warningf("Invalid RESPECT_INITIAL_DELAY: %s, using default: %v", delayStr, config.RespectInitialDelay)
+		} else {
+			config.RespectInitialDelay = respect
+		}
+	}
+
+	// Parse allowed probe CIDRs
+	if cidrsStr := getenv("ALLOWED_PROBE_CIDRS"); cidrsStr != "" {
+		cidrs, err := ParseCIDRs(cidrsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALLOWED_PROBE_CIDRS: %v", err)
+		}
+		config.AllowedProbeCIDRs = cidrs
+	}
+
+	// Parse adoption warmup
+	if warmupStr := getenv("ADOPTION_WARMUP"); warmupStr != "" {
+		warmup, err := time.ParseDuration(warmupStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ADOPTION_WARMUP: %v", err)
+		}
+		config.AdoptionWarmup = warmup
+	}
+
+	// Parse Pushgateway export
+	config.PushgatewayURL = getenv("PUSHGATEWAY_URL")
+	config.CallbackAddr = getenv("CALLBACK_ADDR")
+	config.CallbackURL = getenv("CALLBACK_URL")
+	if intervalStr := getenv("PUSH_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PUSH_INTERVAL: %v", err)
+		}
+		config.PushInterval = interval
+	}
+
+	// Parse kubelet readiness combination
+	if kubeletStr := getenv("REQUIRE_KUBELET_READY"); kubeletStr != "" {
+		if require, err := strconv.ParseBool(kubeletStr); err != nil {
+			klog.Warningf("Invalid REQUIRE_KUBELET_READY: %s, using default: %v", kubeletStr, config.RequireKubeletReady)
+		} else {
+			config.RequireKubeletReady = require
+		}
+	}
+
+	// Parse zone-aware probe timeouts
+	if timeoutStr := getenv("SAME_ZONE_TIMEOUT"); timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SAME_ZONE_TIMEOUT: %v", err)
+		}
+		config.SameZoneTimeout = timeout
+	}
+	if timeoutStr := getenv("CROSS_ZONE_TIMEOUT"); timeoutStr != "" {
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CROSS_ZONE_TIMEOUT: %v", err)
+		}
+		config.CrossZoneTimeout = timeout
+	}
+
+	// Parse draining marker
+	if drainingStr := getenv("DRAINING_MARKER"); drainingStr != "" {
+		if draining, err := strconv.ParseBool(drainingStr); err != nil {
+			klog.Warningf("Invalid DRAINING_MARKER: %s, using default: %v", drainingStr, config.DrainingMarker)
+		} else {
+			config.DrainingMarker = draining
+		}
+	}
+
+	// Parse probe egress rate
+	if rateStr := getenv("PROBE_EGRESS_RATE"); rateStr != "" {
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROBE_EGRESS_RATE: %v", err)
+		}
+		config.ProbeEgressRate = rate
+	}
+
+	// Parse adoption port requirement
+	if adoptionStr := getenv("REQUIRE_ALL_PORTS_ON_ADOPTION"); adoptionStr != "" {
+		if require, err := strconv.ParseBool(adoptionStr); err != nil {
+			klog.Warningf("Invalid REQUIRE_ALL_PORTS_ON_ADOPTION: %s, using default: %v", adoptionStr, config.RequireAllPortsOnAdoption)
+		} else {
+			config.RequireAllPortsOnAdoption = require
+		}
+	}
+
+	// Parse probe port remapping table
+	if remapStr := getenv("PORT_REMAP"); remapStr != "" {
+		remap, err := ParsePortRemap(remapStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PORT_REMAP: %v", err)
+		}
+		config.PortRemap = remap
+	}
+
+	// Parse dual-stack family conditions
+	if dualStackStr := getenv("DUAL_STACK_CONDITIONS"); dualStackStr != "" {
+		if dualStack, err := strconv.ParseBool(dualStackStr); err != nil {
+			klog.Warningf("Invalid DUAL_STACK_CONDITIONS: %s, using default: %v", dualStackStr, config.DualStackConditions)
+		} else {
+			config.DualStackConditions = dualStack
+		}
+	}
+
+	// Parse dual-stack health policy
+	if policy := getenv("DUAL_STACK_POLICY"); policy != "" {
+		config.DualStackPolicy = policy
+	}
+
+	// Parse EndpointSlice condition mode
+	if mode := getenv("ENDPOINTSLICE_CONDITIONS"); mode != "" {
+		config.EndpointSliceConditions = mode
+	}
+
+	// Parse Unknown status on uncertainty
+	if unknownStr := getenv("UNKNOWN_ON_UNCERTAINTY"); unknownStr != "" {
+		if unknown, err := strconv.ParseBool(unknownStr); err != nil {
+			klog.Warningf("Invalid UNKNOWN_ON_UNCERTAINTY: %s, using default: %v", unknownStr, config.UnknownOnUncertainty)
+		} else {
+			config.UnknownOnUncertainty = unknown
+		}
+	}
+
+	// Parse probe timeout jitter
+	if jitterStr := getenv("PROBE_TIMEOUT_JITTER"); jitterStr != "" {
+		jitter, err := strconv.ParseFloat(jitterStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROBE_TIMEOUT_JITTER: %v", err)
+		}
+		config.ProbeTimeoutJitter = jitter
+	}
+
+	// Parse per-pod transition budget
+	if maxStr := getenv("MAX_TRANSITIONS_PER_HOUR"); maxStr != "" {
+		var maxTransitions int
+		if count, err := fmt.Sscanf(maxStr, "%d", &maxTransitions); err != nil || count != 1 {
+			klog.Warningf("Invalid MAX_TRANSITIONS_PER_HOUR: %s, using default: %d", maxStr, config.MaxTransitionsPerHour)
+		} else {
+			config.MaxTransitionsPerHour = maxTransitions
+		}
+	}
+
+	// Parse per-pod timeout escalation
+	if afterStr := getenv("TIMEOUT_ESCALATION_AFTER"); afterStr != "" {
+		var after int
+		if count, err := fmt.Sscanf(afterStr, "%d", &after); err != nil || count != 1 {
+			klog.Warningf("Invalid TIMEOUT_ESCALATION_AFTER: %s, using default: %d", afterStr, config.TimeoutEscalationAfter)
+		} else {
+			config.TimeoutEscalationAfter = after
+		}
+	}
+
+	if maxStr := getenv("TIMEOUT_ESCALATION_MAX"); maxStr != "" {
+		max, err := time.ParseDuration(maxStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TIMEOUT_ESCALATION_MAX: %v", err)
+		}
+		config.TimeoutEscalationMax = max
+	}
+
+	// Parse Service ClusterIP check
+	if intervalStr := getenv("SERVICE_CHECK_INTERVAL"); intervalStr != "" {
+		interval, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SERVICE_CHECK_INTERVAL: %v", err)
+		}
+		config.ServiceCheckInterval = interval
+	}
+	if probesStr := getenv("SERVICE_CHECK_PROBES"); probesStr != "" {
+		var probes int
+		if count, err := fmt.Sscanf(probesStr, "%d", &probes); err != nil || count != 1 {
+			klog.Warningf("Invalid SERVICE_CHECK_PROBES: %s, using default: %d", probesStr, config.ServiceCheckProbes)
+		} else {
+			config.ServiceCheckProbes = probes
+		}
+	}
+
+	// Parse interval auto-stretch
+	if stretchStr := getenv("AUTO_STRETCH_INTERVAL"); stretchStr != "" {
+		if stretch, err := strconv.ParseBool(stretchStr); err != nil {
+			klog.Warningf("Invalid AUTO_STRETCH_INTERVAL: %s, using default: %v", stretchStr, config.AutoStretchInterval)
+		} else {
+			config.AutoStretchInterval = stretch
+		}
+	}
+
+	// Parse TCP half-open check
+	if halfOpenStr := getenv("TCP_HALF_OPEN_CHECK"); halfOpenStr != "" {
+		if halfOpen, err := strconv.ParseBool(halfOpenStr); err != nil {
+			klog.Warningf("Invalid TCP_HALF_OPEN_CHECK: %s, using default: %v", halfOpenStr, config.TCPHalfOpenCheck)
+		} else {
+			config.TCPHalfOpenCheck = halfOpen
+		}
+	}
+
+	// Parse TLS certificate minimum TTL
+	if ttlStr := getenv("TLS_CERT_MIN_TTL"); ttlStr != "" {
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS_CERT_MIN_TTL: %v", err)
+		}
+		config.TLSCertMinTTL = ttl
+	}
+
+	// Parse ICMP concurrency limit
+	if maxStr := getenv("MAX_INFLIGHT_ICMP"); maxStr != "" {
+		var maxICMP int
+		if count, err := fmt.Sscanf(maxStr, "%d", &maxICMP); err != nil || count != 1 {
+			klog.Warningf("Invalid MAX_INFLIGHT_ICMP: %s, using default: %d", maxStr, config.MaxInFlightICMP)
+		} else {
+			config.MaxInFlightICMP = maxICMP
+		}
+	}
+
+	// Parse ICMP packets per probe attempt and their tolerated loss
+	if countStr := getenv("ICMP_PACKET_COUNT"); countStr != "" {
+		var packets int
+		if count, err := fmt.Sscanf(countStr, "%d", &packets); err != nil || count != 1 {
+			klog.Warningf("Invalid ICMP_PACKET_COUNT: %s, using default: %d", countStr, config.ICMPPacketCount)
+		} else {
+			config.ICMPPacketCount = packets
+		}
+	}
+	if lossStr := getenv("ICMP_LOSS_THRESHOLD"); lossStr != "" {
+		loss, err := strconv.ParseFloat(lossStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ICMP_LOSS_THRESHOLD: %v", err)
+		}
+		config.ICMPLossThreshold = loss
+	}
+
+	// Parse ICMP socket mode
+	if privileged := getenv("ICMP_PRIVILEGED"); privileged != "" {
+		config.ICMPPrivileged = strings.ToLower(privileged)
+	}
+
+	// Parse minimum healthy endpoints per Service
+	if minStr := getenv("MIN_HEALTHY_PER_SERVICE"); minStr != "" {
+		var minHealthy int
+		if count, err := fmt.Sscanf(minStr, "%d", &minHealthy); err != nil || count != 1 {
+			klog.Warningf("Invalid MIN_HEALTHY_PER_SERVICE: %s, using default: %d", minStr, config.MinHealthyPerService)
+		} else {
+			config.MinHealthyPerService = minHealthy
+		}
+	}
+
+	// Parse Service-wide failure debouncing
+	if thresholdStr := getenv("SERVICE_DEBOUNCE_THRESHOLD"); thresholdStr != "" {
+		var threshold int
+		if count, err := fmt.Sscanf(thresholdStr, "%d", &threshold); err != nil || count != 1 {
+			klog.Warningf("Invalid SERVICE_DEBOUNCE_THRESHOLD: %s, using default: %d", thresholdStr, config.ServiceDebounceThreshold)
+		} else {
+			config.ServiceDebounceThreshold = threshold
+		}
+	}
+
+	if windowStr := getenv("SERVICE_DEBOUNCE_WINDOW"); windowStr != "" {
+		window, err := time.ParseDuration(windowStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SERVICE_DEBOUNCE_WINDOW: %v", err)
+		}
+		config.ServiceDebounceWindow = window
+	}
+
+	if delayStr := getenv("SERVICE_DEBOUNCE_DELAY"); delayStr != "" {
+		delay, err := time.ParseDuration(delayStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SERVICE_DEBOUNCE_DELAY: %v", err)
+		}
+		config.ServiceDebounceDelay = delay
+	}
+
+	// Parse reachability quorum
+	if quorumStr := getenv("REACHABILITY_QUORUM"); quorumStr != "" {
+		var quorum int
+		if count, err := fmt.Sscanf(quorumStr, "%d", &quorum); err != nil || count != 1 {
+			klog.Warningf("Invalid REACHABILITY_QUORUM: %s, using default: %d", quorumStr, config.ReachabilityQuorum)
+		} else {
+			config.ReachabilityQuorum = quorum
+		}
+	}
+	if maxAgeStr := getenv("REACHABILITY_REPORT_MAX_AGE"); maxAgeStr != "" {
+		if maxAge, err := time.ParseDuration(maxAgeStr); err != nil {
+			return nil, fmt.Errorf("invalid REACHABILITY_REPORT_MAX_AGE: %v", err)
+		} else {
+			config.ReachabilityReportMaxAge = maxAge
+		}
+	}
+
+	// Parse availability window
+	if windowStr := getenv("AVAILABILITY_WINDOW"); windowStr != "" {
+		if window, err := time.ParseDuration(windowStr); err != nil {
+			return nil, fmt.Errorf("invalid AVAILABILITY_WINDOW: %v", err)
+		} else {
+			config.AvailabilityWindow = window
+		}
+	}
+
+	// Parse node readiness filter
+	if skipStr := getenv("SKIP_NOT_READY_NODES"); skipStr != "" {
+		if skip, err := strconv.ParseBool(skipStr); err != nil {
+			klog.Warningf("Invalid SKIP_NOT_READY_NODES: %s, using default: %v", skipStr, config.SkipNotReadyNodes)
+		} else {
+			config.SkipNotReadyNodes = skip
+		}
+	}
+
+	// Parse shutdown grace
+	if graceStr := getenv("SHUTDOWN_GRACE"); graceStr != "" {
+		if grace, err := time.ParseDuration(graceStr); err != nil {
+			return nil, fmt.Errorf("invalid SHUTDOWN_GRACE: %v", err)
+		} else {
+			config.ShutdownGrace = grace
+		}
+	}
+
+	// Parse failure class policy
+	config.FailurePolicy = getenv("FAILURE_POLICY")
+
+	// Parse configuration ConfigMap
+	config.ConfigMapName = getenv("CONFIG_MAP_NAME")
+
 	// Parse Pod information
-	config.PodName = os.Getenv("POD_NAME")
+	config.PodName = getenv("POD_NAME")
 	if config.PodName == "" {
 		if hostname, err := os.Hostname(); err == nil {
 			config.PodName = hostname
 		}
 	}
 
-	config.PodNamespace = os.Getenv("POD_NAMESPACE")
+	config.PodNamespace = getenv("POD_NAMESPACE")
 	if config.PodNamespace == "" {
 		config.PodNamespace = "kube-system"
 	}
 
+	config.NodeName = getenv("NODE_NAME")
+
 	// Parse Lease configuration
-	config.LeaseLockNamespace = os.Getenv("POD_NAMESPACE")
+	config.LeaseLockNamespace = getenv("POD_NAMESPACE")
 	if config.LeaseLockNamespace == "" {
 		config.LeaseLockNamespace = "kube-system"
 	}
 
 	// Parse leader election configuration
-	if leaseName := os.Getenv("LEASE_NAME"); leaseName != "" {
+	if leaseName := getenv("LEASE_NAME"); leaseName != "" {
 		config.LeaseLockName = leaseName
 	}
 
-	if leaseDurationStr := os.Getenv("LEASE_DURATION"); leaseDurationStr != "" {
+	if leaseDurationStr := getenv("LEASE_DURATION"); leaseDurationStr != "" {
 		if leaseDuration, err := time.ParseDuration(leaseDurationStr); err != nil {
 			klog.Warningf("Invalid LEASE_DURATION: %s, using default: %v", leaseDurationStr, config.LeaseDuration)
 		} else {
@@ -107,7 +980,7 @@ func LoadFromEnv() (*Config, error) {
 		}
 	}
 
-	if renewDeadlineStr := os.Getenv("RENEW_DEADLINE"); renewDeadlineStr != "" {
+	if renewDeadlineStr := getenv("RENEW_DEADLINE"); renewDeadlineStr != "" {
 		if renewDeadline, err := time.ParseDuration(renewDeadlineStr); err != nil {
 			klog.Warningf("Invalid RENEW_DEADLINE: %s, using default: %v", renewDeadlineStr, config.RenewDeadline)
 		} else {
@@ -115,7 +988,7 @@ func LoadFromEnv() (*Config, error) {
 		}
 	}
 
-	if retryPeriodStr := os.Getenv("RETRY_PERIOD"); retryPeriodStr != "" {
+	if retryPeriodStr := getenv("RETRY_PERIOD"); retryPeriodStr != "" {
 		if retryPeriod, err := time.ParseDuration(retryPeriodStr); err != nil {
 			klog.Warningf("Invalid RETRY_PERIOD: %s, using default: %v", retryPeriodStr, config.RetryPeriod)
 		} else {
@@ -130,6 +1003,107 @@ func LoadFromEnv() (*Config, error) {
 	return config, nil
 }
 
+// ParseNamespacePolicy parses a policy such as "kube-system=disable,prod=enable"
+func ParseNamespacePolicy(s string) (map[string]bool, error) {
+	policy := make(map[string]bool)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		namespace, action, found := strings.Cut(entry, "=")
+		namespace = strings.TrimSpace(namespace)
+		if !found || namespace == "" {
+			return nil, fmt.Errorf("entry %q must be in namespace=enable|disable format", entry)
+		}
+		if _, exists := policy[namespace]; exists {
+			return nil, fmt.Errorf("duplicate entry for namespace %q", namespace)
+		}
+		switch strings.TrimSpace(action) {
+		case "enable":
+			policy[namespace] = true
+		case "disable":
+			policy[namespace] = false
+		default:
+			return nil, fmt.Errorf("entry %q has unknown action %q, expected enable or disable", entry, action)
+		}
+	}
+	return policy, nil
+}
+
+// ParseNamespaces parses a list of namespaces such as "prod,staging"
+func ParseNamespaces(s string) ([]string, error) {
+	var namespaces []string
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(entry); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid namespace %q: %s", entry, strings.Join(errs, "; "))
+		}
+		namespaces = append(namespaces, entry)
+	}
+	return namespaces, nil
+}
+
+// ParsePortRemap parses a remapping table such as "8080:15020,9090:15020"
+func ParsePortRemap(s string) (map[int32]int32, error) {
+	remap := make(map[int32]int32)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		fromStr, toStr, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, fmt.Errorf("entry %q must be in from:to format", entry)
+		}
+		from, err := parsePort(fromStr)
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: %v", entry, err)
+		}
+		to, err := parsePort(toStr)
+		if err != nil {
+			return nil, fmt.Errorf("entry %q: %v", entry, err)
+		}
+		if _, exists := remap[from]; exists {
+			return nil, fmt.Errorf("duplicate entry for port %d", from)
+		}
+		remap[from] = to
+	}
+	return remap, nil
+}
+
+// ParseCIDRs parses a comma-separated list of networks such as "10.16.0.0/16,fd00:10:16::/64"
+func ParseCIDRs(s string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("no CIDRs in %q", s)
+	}
+	return cidrs, nil
+}
+
+// parsePort parses a TCP port number in 1-65535
+func parsePort(s string) (int32, error) {
+	port, err := strconv.ParseInt(strings.TrimSpace(s), 10, 32)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q, must be 1-65535", s)
+	}
+	return int32(port), nil
+}
+
 // Validate validates configuration
 func (c *Config) Validate() error {
 	if c.HealthCheckInterval <= 0 {
@@ -162,6 +1136,161 @@ func (c *Config) Validate() error {
 	if c.RenewDeadline >= c.LeaseDuration {
 		return fmt.Errorf("renew deadline must be less than lease duration")
 	}
+	if errs := validation.IsQualifiedName(c.AnnotationKey); len(errs) > 0 {
+		return fmt.Errorf("annotation key %q is invalid: %s", c.AnnotationKey, strings.Join(errs, "; "))
+	}
+	if errs := validation.IsQualifiedName(c.ReadinessGateType); len(errs) > 0 {
+		return fmt.Errorf("readiness gate type %q is invalid: %s", c.ReadinessGateType, strings.Join(errs, "; "))
+	}
+	if _, err := labels.Parse(c.PodLabelSelector); err != nil {
+		return fmt.Errorf("invalid pod label selector %q: %v", c.PodLabelSelector, err)
+	}
+	if c.StatusPatchType != "merge" && c.StatusPatchType != "strategic" {
+		return fmt.Errorf("status patch type must be merge or strategic, got %q", c.StatusPatchType)
+	}
+
+	if c.PodConfigAPI && c.MetricsAddr == "" {
+		return fmt.Errorf("pod config API is served on the metrics listener, METRICS_ADDR must be set")
+	}
+
+	if c.DualStackPolicy != "primary" && c.DualStackPolicy != "any" && c.DualStackPolicy != "all" {
+		return fmt.Errorf("dual-stack policy must be primary, any or all, got %q", c.DualStackPolicy)
+	}
+	if c.EndpointSliceConditions != "off" && c.EndpointSliceConditions != "also" {
+		return fmt.Errorf("EndpointSlice conditions must be off or also, got %q", c.EndpointSliceConditions)
+	}
+	if c.ReadinessRecheckInterval < 0 {
+		return fmt.Errorf("readiness recheck interval must be non-negative")
+	}
+	if c.ReconcileInterval < 0 {
+		return fmt.Errorf("reconcile interval must be non-negative")
+	}
+	if c.OwnerRollupInterval < 0 {
+		return fmt.Errorf("owner rollup interval must be non-negative")
+	}
+	if c.RecoveryGuardDuration < 0 {
+		return fmt.Errorf("recovery guard duration must be non-negative")
+	}
+	if c.StatusSnapshotPath != "" && c.StatusSnapshotInterval <= 0 {
+		return fmt.Errorf("status snapshot interval must be positive")
+	}
+	if c.MaxInFlightICMP < 0 {
+		return fmt.Errorf("max in-flight ICMP operations must be non-negative")
+	}
+	if c.ICMPPacketCount < 1 {
+		return fmt.Errorf("ICMP packet count must be positive")
+	}
+	if c.ICMPLossThreshold < 0 || c.ICMPLossThreshold > 100 {
+		return fmt.Errorf("ICMP loss threshold must be in [0, 100], got %v", c.ICMPLossThreshold)
+	}
+	if c.ICMPPrivileged != "auto" && c.ICMPPrivileged != "true" && c.ICMPPrivileged != "false" {
+		return fmt.Errorf("ICMP privileged mode must be auto, true or false, got %q", c.ICMPPrivileged)
+	}
+	if (c.CallbackAddr == "") != (c.CallbackURL == "") {
+		return fmt.Errorf("CALLBACK_ADDR and CALLBACK_URL must be set together")
+	}
+	if c.PushgatewayURL != "" && c.PushInterval <= 0 {
+		return fmt.Errorf("push interval must be positive")
+	}
+	if c.AdoptionWarmup < 0 {
+		return fmt.Errorf("adoption warmup must be non-negative")
+	}
+	if c.SameZoneTimeout < 0 || c.CrossZoneTimeout < 0 {
+		return fmt.Errorf("zone timeouts must be non-negative")
+	}
+	if (c.SameZoneTimeout > 0 || c.CrossZoneTimeout > 0) && c.NodeName == "" {
+		return fmt.Errorf("NODE_NAME is required when zone timeouts are set")
+	}
+	if c.ProbeEgressRate < 0 {
+		return fmt.Errorf("probe egress rate must be non-negative")
+	}
+	if c.TLSCertMinTTL < 0 {
+		return fmt.Errorf("TLS certificate minimum TTL must be non-negative")
+	}
+	if c.ProbeTimeoutJitter < 0 || c.ProbeTimeoutJitter >= 1 {
+		return fmt.Errorf("probe timeout jitter must be in [0, 1)")
+	}
+	if c.TimeoutEscalationAfter < 0 {
+		return fmt.Errorf("timeout escalation after must be non-negative")
+	}
+	if c.TimeoutEscalationAfter > 0 && c.TimeoutEscalationMax <= c.HealthCheckTimeout {
+		return fmt.Errorf("timeout escalation max must be greater than the health check timeout")
+	}
+	if c.MaxTransitionsPerHour < 0 {
+		return fmt.Errorf("max transitions per hour must be non-negative")
+	}
+	if c.ServiceCheckInterval < 0 {
+		return fmt.Errorf("service check interval must be non-negative")
+	}
+	if c.ServiceCheckProbes <= 0 {
+		return fmt.Errorf("service check probes must be positive")
+	}
+	if c.ServiceDebounceThreshold < 0 {
+		return fmt.Errorf("service debounce threshold must be non-negative")
+	}
+	if c.ServiceDebounceThreshold > 0 && (c.ServiceDebounceWindow <= 0 || c.ServiceDebounceDelay <= 0) {
+		return fmt.Errorf("service debounce window and delay must be positive")
+	}
+	if c.MinHealthyPerService < 0 {
+		return fmt.Errorf("minimum healthy endpoints per service must be non-negative")
+	}
+	if c.ReachabilityQuorum < 0 {
+		return fmt.Errorf("reachability quorum must be non-negative")
+	}
+	if c.ReachabilityQuorum > 0 && c.NodeName == "" {
+		return fmt.Errorf("NODE_NAME is required when the reachability quorum is enabled")
+	}
+	if c.ReachabilityReportMaxAge < 0 {
+		return fmt.Errorf("reachability report max age must be non-negative")
+	}
+	if c.AvailabilityWindow < 0 {
+		return fmt.Errorf("availability window must be non-negative")
+	}
+	if c.ShutdownGrace < 0 {
+		return fmt.Errorf("shutdown grace must be non-negative")
+	}
+	if c.StatusReassertInterval < 0 {
+		return fmt.Errorf("status reassert interval must be non-negative")
+	}
+	if c.HealthyIntervalMultiplier < 1 {
+		return fmt.Errorf("healthy interval multiplier must be at least 1")
+	}
+	if c.HealthyIntervalMultiplier > 1 && c.HealthyIntervalMax < c.HealthCheckInterval {
+		return fmt.Errorf("healthy interval max must not be less than the health check interval")
+	}
+	if c.IdleAfter < 0 || c.IdleInterval < 0 {
+		return fmt.Errorf("idle after and idle interval must be non-negative")
+	}
+	if c.IdleInterval > 0 && c.IdleInterval < c.HealthCheckInterval {
+		return fmt.Errorf("idle interval must not be less than the health check interval")
+	}
+	if c.EventTarget != "none" && c.EventTarget != "pod" && c.EventTarget != "owner" {
+		return fmt.Errorf("event target must be none, pod or owner, got %q", c.EventTarget)
+	}
+	if c.ServiceCheckBudget < 0 {
+		return fmt.Errorf("service check budget must be non-negative")
+	}
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample rate must be greater than 0 and at most 1")
+	}
+	if c.NamespaceMaxInFlight < 0 {
+		return fmt.Errorf("namespace max in flight must be non-negative")
+	}
+	if c.StartupDelay < 0 {
+		return fmt.Errorf("startup delay must be non-negative")
+	}
+	if c.FailureRateThreshold < 0 || c.FailureRateThreshold > 1 {
+		return fmt.Errorf("failure rate threshold must be between 0 and 1")
+	}
+	if c.FailureRateThreshold > 0 && c.FailureRateWindow <= 0 {
+		return fmt.Errorf("failure rate window must be positive")
+	}
+	if c.ControlPlaneErrorThreshold < 0 || c.ControlPlaneErrorThreshold > 1 {
+		return fmt.Errorf("control plane error threshold must be between 0 and 1")
+	}
+	if c.ControlPlaneErrorThreshold > 0 && c.ControlPlaneErrorWindow <= 0 {
+		return fmt.Errorf("control plane error window must be positive")
+	}
 	return nil
 }
 
@@ -219,3 +1348,404 @@ func (c *Config) GetRenewDeadline() time.Duration {
 func (c *Config) GetRetryPeriod() time.Duration {
 	return c.RetryPeriod
 }
+
+// GetRejectUnsafeProbeTargets gets whether unsafe probe targets are refused
+func (c *Config) GetRejectUnsafeProbeTargets() bool {
+	return c.RejectUnsafeProbeTargets
+}
+
+// GetFailureRateThreshold gets the failure-rate breaker threshold
+func (c *Config) GetFailureRateThreshold() float64 {
+	return c.FailureRateThreshold
+}
+
+// GetFailureRateWindow gets the failure-rate breaker rolling window
+func (c *Config) GetFailureRateWindow() time.Duration {
+	return c.FailureRateWindow
+}
+
+// GetFailureRateMinSamples gets the minimum samples before the breaker can open
+func (c *Config) GetFailureRateMinSamples() int {
+	return c.FailureRateMinSamples
+}
+
+// GetControlPlaneErrorThreshold gets the API error rate that pauses status updates
+func (c *Config) GetControlPlaneErrorThreshold() float64 {
+	return c.ControlPlaneErrorThreshold
+}
+
+// GetControlPlaneErrorWindow gets the rolling window of the API error rate
+func (c *Config) GetControlPlaneErrorWindow() time.Duration {
+	return c.ControlPlaneErrorWindow
+}
+
+// GetControlPlaneErrorMinSamples gets the minimum API calls before degraded mode can be entered
+func (c *Config) GetControlPlaneErrorMinSamples() int {
+	return c.ControlPlaneErrorMinSamples
+}
+
+// GetResultsStdout gets whether check results are written to stdout
+func (c *Config) GetResultsStdout() bool {
+	return c.ResultsStdout
+}
+
+// GetNamespacePolicy gets per-namespace enable/disable overrides
+func (c *Config) GetNamespacePolicy() map[string]bool {
+	return c.NamespacePolicy
+}
+
+// GetConfigMapName gets the ConfigMap overriding the environment
+func (c *Config) GetConfigMapName() string {
+	return c.ConfigMapName
+}
+
+// GetPodLabelSelector gets the label selector narrowing the pod informer
+func (c *Config) GetPodLabelSelector() string {
+	return c.PodLabelSelector
+}
+
+// GetNamespaceScope gets the namespaces health checking is limited to and those it never covers
+func (c *Config) GetNamespaceScope() (allow, deny []string) {
+	return c.NamespaceAllowlist, c.NamespaceDenylist
+}
+
+// GetStartupDelay gets the minimum pod age before it is probed
+func (c *Config) GetStartupDelay() time.Duration {
+	return c.StartupDelay
+}
+
+// GetAnnotationKey gets the pod annotation that opts a pod in to health checking
+func (c *Config) GetAnnotationKey() string {
+	return c.AnnotationKey
+}
+
+// GetReadinessGateType gets the readiness gate condition driven by health checks
+func (c *Config) GetReadinessGateType() string {
+	return c.ReadinessGateType
+}
+
+// GetStatusPatchType gets how condition updates are sent
+func (c *Config) GetStatusPatchType() string {
+	return c.StatusPatchType
+}
+
+// GetHedgedProbes gets the number of concurrent probes per attempt
+func (c *Config) GetHedgedProbes() int {
+	return c.HedgedProbes
+}
+
+// GetPatchTerminatingPods gets whether terminating pods still get status updates
+func (c *Config) GetPatchTerminatingPods() bool {
+	return c.PatchTerminatingPods
+}
+
+// GetHealthAddr gets the listen address of the checker's own health endpoints
+func (c *Config) GetHealthAddr() string {
+	return c.HealthAddr
+}
+
+// GetMetricsAddr gets the metrics endpoint listen address
+func (c *Config) GetMetricsAddr() string {
+	return c.MetricsAddr
+}
+
+// GetPodConfigAPI gets whether the effective per-pod configuration is served
+func (c *Config) GetPodConfigAPI() bool {
+	return c.PodConfigAPI
+}
+
+// GetReconcileInterval gets how often tracked pods are reconciled against the informer cache
+func (c *Config) GetReconcileInterval() time.Duration {
+	return c.ReconcileInterval
+}
+
+// GetReadinessRecheckInterval gets how often pods waiting for readiness are re-evaluated
+func (c *Config) GetReadinessRecheckInterval() time.Duration {
+	return c.ReadinessRecheckInterval
+}
+
+// GetVerifyIPOwnership gets whether IP ownership is confirmed before probing
+func (c *Config) GetVerifyIPOwnership() bool {
+	return c.VerifyIPOwnership
+}
+
+// GetOwnerRollupInterval gets how often per-owner healthy ratios are published
+func (c *Config) GetOwnerRollupInterval() time.Duration {
+	return c.OwnerRollupInterval
+}
+
+// GetStatusReassertInterval gets how often unchanged conditions are re-verified
+func (c *Config) GetStatusReassertInterval() time.Duration {
+	return c.StatusReassertInterval
+}
+
+// GetNamespaceMaxInFlight gets the per-namespace bound on concurrent checks
+func (c *Config) GetNamespaceMaxInFlight() int {
+	return c.NamespaceMaxInFlight
+}
+
+// GetSampleRate gets the fraction of pods checked per tick
+func (c *Config) GetSampleRate() float64 {
+	return c.SampleRate
+}
+
+// GetRecoveryGuardDuration gets how long probes must pass continuously before recovery
+func (c *Config) GetRecoveryGuardDuration() time.Duration {
+	return c.RecoveryGuardDuration
+}
+
+// GetServiceCheckBudget gets the per-tick check budget used for Service coalescing
+func (c *Config) GetServiceCheckBudget() int {
+	return c.ServiceCheckBudget
+}
+
+// GetEventTarget gets where health transition events are recorded
+func (c *Config) GetEventTarget() string {
+	return c.EventTarget
+}
+
+// GetHealthyIntervalMultiplier gets how much each consecutive success stretches the check interval
+func (c *Config) GetHealthyIntervalMultiplier() float64 {
+	return c.HealthyIntervalMultiplier
+}
+
+// GetHealthyIntervalMax gets the cap on the stretched check interval
+func (c *Config) GetHealthyIntervalMax() time.Duration {
+	return c.HealthyIntervalMax
+}
+
+// GetIdleAfter gets how long no pod must be tracked before the scheduler backs off
+func (c *Config) GetIdleAfter() time.Duration {
+	return c.IdleAfter
+}
+
+// GetIdleInterval gets the scheduler tick interval while no pod is tracked
+func (c *Config) GetIdleInterval() time.Duration {
+	return c.IdleInterval
+}
+
+// GetHTTPProbeViaAPIProxy gets whether HTTP probes go through the API server pod proxy
+func (c *Config) GetHTTPProbeViaAPIProxy() bool {
+	return c.HTTPProbeViaAPIProxy
+}
+
+// GetProbeAllContainers gets whether containers without probes are probed on their declared ports
+func (c *Config) GetProbeAllContainers() bool {
+	return c.ProbeAllContainers
+}
+
+// GetMaxPortsPerPod gets the cap on ports probed per pod, 0 for no cap
+func (c *Config) GetMaxPortsPerPod() int {
+	return c.MaxPortsPerPod
+}
+
+// GetCheckHistorySize gets how many of its latest checks each pod keeps, 0 for none
+func (c *Config) GetCheckHistorySize() int {
+	return c.CheckHistorySize
+}
+
+// GetMaxDetailedPods gets the cap on pods keeping check history and transition times, 0 for no cap
+func (c *Config) GetMaxDetailedPods() int {
+	return c.MaxDetailedPods
+}
+
+// GetFailurePolicy gets the per failure class hysteresis policy
+func (c *Config) GetFailurePolicy() string {
+	return c.FailurePolicy
+}
+
+// GetShutdownGrace gets how long in-flight checks may finish at shutdown
+func (c *Config) GetShutdownGrace() time.Duration {
+	return c.ShutdownGrace
+}
+
+// GetSkipNotReadyNodes gets whether pods on NotReady nodes are skipped
+func (c *Config) GetSkipNotReadyNodes() bool {
+	return c.SkipNotReadyNodes
+}
+
+// GetAvailabilityWindow gets the rolling window of the availability ratio metrics
+func (c *Config) GetAvailabilityWindow() time.Duration {
+	return c.AvailabilityWindow
+}
+
+// GetNodeName gets the name of the node this instance runs on
+func (c *Config) GetNodeName() string {
+	return c.NodeName
+}
+
+// GetReachabilityQuorum gets how many nodes must report a pod unreachable to mark it unhealthy
+func (c *Config) GetReachabilityQuorum() int {
+	return c.ReachabilityQuorum
+}
+
+// GetReachabilityReportMaxAge gets how long a node's reachability report stays valid
+func (c *Config) GetReachabilityReportMaxAge() time.Duration {
+	return c.ReachabilityReportMaxAge
+}
+
+// GetMinHealthyPerService gets the minimum healthy endpoints kept per Service
+func (c *Config) GetMinHealthyPerService() int {
+	return c.MinHealthyPerService
+}
+
+// GetServiceDebounce gets the synchronized failure threshold, window and delay of Service-wide debouncing
+func (c *Config) GetServiceDebounce() (int, time.Duration, time.Duration) {
+	return c.ServiceDebounceThreshold, c.ServiceDebounceWindow, c.ServiceDebounceDelay
+}
+
+// GetMaxInFlightICMP gets the bound on concurrent ICMP operations
+func (c *Config) GetMaxInFlightICMP() int {
+	return c.MaxInFlightICMP
+}
+
+// GetICMPPrivileged gets the ICMP socket mode, auto, true or false
+func (c *Config) GetICMPPrivileged() string {
+	return c.ICMPPrivileged
+}
+
+// GetICMPPackets gets the echo requests sent per ICMP probe attempt and the loss percentage
+// at which the attempt fails
+func (c *Config) GetICMPPackets() (int, float64) {
+	return c.ICMPPacketCount, c.ICMPLossThreshold
+}
+
+// GetStatusSnapshotPath gets the file pod statuses are persisted to
+func (c *Config) GetStatusSnapshotPath() string {
+	return c.StatusSnapshotPath
+}
+
+// GetStatusSnapshotInterval gets how often the status snapshot is written
+func (c *Config) GetStatusSnapshotInterval() time.Duration {
+	return c.StatusSnapshotInterval
+}
+
+// GetStatusSnapshotKeyFile gets the file holding the status snapshot HMAC key
+func (c *Config) GetStatusSnapshotKeyFile() string {
+	return c.StatusSnapshotKeyFile
+}
+
+// GetTCPHalfOpenCheck gets whether TCP probes verify the app accepted the connection
+func (c *Config) GetTCPHalfOpenCheck() bool {
+	return c.TCPHalfOpenCheck
+}
+
+// GetTLSCertMinTTL gets the minimum remaining validity of certificates served to TLS and HTTPS probes
+func (c *Config) GetTLSCertMinTTL() time.Duration {
+	return c.TLSCertMinTTL
+}
+
+// GetAutoStretchInterval gets whether the effective interval is stretched under load
+func (c *Config) GetAutoStretchInterval() bool {
+	return c.AutoStretchInterval
+}
+
+// GetServiceCheckInterval gets how often opted-in Services are probed through their ClusterIP
+func (c *Config) GetServiceCheckInterval() time.Duration {
+	return c.ServiceCheckInterval
+}
+
+// GetServiceCheckProbes gets the number of requests sent per Service check
+func (c *Config) GetServiceCheckProbes() int {
+	return c.ServiceCheckProbes
+}
+
+// GetMaxTransitionsPerHour gets the per-pod status transition budget
+func (c *Config) GetMaxTransitionsPerHour() int {
+	return c.MaxTransitionsPerHour
+}
+
+// GetTimeoutEscalation gets after how many consecutive timeouts a pod's probe timeout is escalated, and its cap
+func (c *Config) GetTimeoutEscalation() (int, time.Duration) {
+	return c.TimeoutEscalationAfter, c.TimeoutEscalationMax
+}
+
+// GetProbeTimeoutJitter gets the fraction each probe attempt timeout is randomized by
+func (c *Config) GetProbeTimeoutJitter() float64 {
+	return c.ProbeTimeoutJitter
+}
+
+// GetUnknownOnUncertainty gets whether untrusted failures are reported as Unknown
+func (c *Config) GetUnknownOnUncertainty() bool {
+	return c.UnknownOnUncertainty
+}
+
+// GetRespectInitialDelay gets whether readiness probe initial delays defer the first probe
+func (c *Config) GetRespectInitialDelay() bool {
+	return c.RespectInitialDelay
+}
+
+// GetDualStackConditions gets whether dual-stack pods get a condition per IP family
+func (c *Config) GetDualStackConditions() bool {
+	return c.DualStackConditions
+}
+
+// GetEndpointSliceConditions gets whether health is written into EndpointSlice endpoint conditions
+func (c *Config) GetEndpointSliceConditions() string {
+	return c.EndpointSliceConditions
+}
+
+// GetDualStackPolicy gets how the families of a dual-stack pod combine into its health
+func (c *Config) GetDualStackPolicy() string {
+	return c.DualStackPolicy
+}
+
+// GetPortRemap gets the declared to probed port remapping table
+func (c *Config) GetPortRemap() map[int32]int32 {
+	return c.PortRemap
+}
+
+// GetRequireAllPortsOnAdoption gets whether adoption waits for every declared port
+func (c *Config) GetRequireAllPortsOnAdoption() bool {
+	return c.RequireAllPortsOnAdoption
+}
+
+// GetProbeEgressRate gets the steady rate per second checks are started at
+func (c *Config) GetProbeEgressRate() float64 {
+	return c.ProbeEgressRate
+}
+
+// GetDrainingMarker gets whether the checker pod is annotated as draining on shutdown
+func (c *Config) GetDrainingMarker() bool {
+	return c.DrainingMarker
+}
+
+// GetSameZoneTimeout gets the probe timeout of pods in the checker's zone
+func (c *Config) GetSameZoneTimeout() time.Duration {
+	return c.SameZoneTimeout
+}
+
+// GetCrossZoneTimeout gets the probe timeout of pods in other zones
+func (c *Config) GetCrossZoneTimeout() time.Duration {
+	return c.CrossZoneTimeout
+}
+
+// GetRequireKubeletReady gets whether kubelet container readiness is combined with the probe
+func (c *Config) GetRequireKubeletReady() bool {
+	return c.RequireKubeletReady
+}
+
+// GetCallback gets the listen address and advertised URL of the mutual reachability callback listener
+func (c *Config) GetCallback() (string, string) {
+	return c.CallbackAddr, c.CallbackURL
+}
+
+// GetPushgatewayURL gets the Pushgateway metrics are pushed to
+func (c *Config) GetPushgatewayURL() string {
+	return c.PushgatewayURL
+}
+
+// GetPushInterval gets how often metrics are pushed to the Pushgateway
+func (c *Config) GetPushInterval() time.Duration {
+	return c.PushInterval
+}
+
+// GetAdoptionWarmup gets how long after adoption results only feed the metrics
+func (c *Config) GetAdoptionWarmup() time.Duration {
+	return c.AdoptionWarmup
+}
+
+// GetAllowedProbeCIDRs gets the networks probe targets are restricted to
+func (c *Config) GetAllowedProbeCIDRs() []*net.IPNet {
+	return c.AllowedProbeCIDRs
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePortRemap(t *testing.T) {
+	remap, err := ParsePortRemap("8080:15020, 9090:15020,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int32]int32{8080: 15020, 9090: 15020}, remap)
+
+	for _, invalid := range []string{"8080", "8080:", "http:15020", "0:15020", "8080:65536", "8080:15020,8080:15021"} {
+		_, err := ParsePortRemap(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseCIDRs(t *testing.T) {
+	cidrs, err := ParseCIDRs("10.16.0.0/16, fd00:10:16::/64,")
+	assert.NoError(t, err)
+	if assert.Len(t, cidrs, 2) {
+		assert.Equal(t, "10.16.0.0/16", cidrs[0].String())
+		assert.Equal(t, "fd00:10:16::/64", cidrs[1].String())
+	}
+
+	for _, invalid := range []string{"10.16.0.0", "10.16.0.0/33", ",", "pods"} {
+		_, err := ParseCIDRs(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseNamespacePolicy(t *testing.T) {
+	policy, err := ParseNamespacePolicy("kube-system=disable, prod=enable,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"kube-system": false, "prod": true}, policy)
+
+	for _, invalid := range []string{"prod", "=enable", "prod=on", "prod=enable,prod=disable"} {
+		_, err := ParseNamespacePolicy(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestParseNamespaces(t *testing.T) {
+	namespaces, err := ParseNamespaces("prod, staging,")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"prod", "staging"}, namespaces)
+
+	for _, invalid := range []string{"Prod", "prod,kube_system", "-dev"} {
+		_, err := ParseNamespaces(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestValidateICMPLossThresholdAndPodConfigAPI(t *testing.T) {
+	validate := func(env map[string]string) error {
+		cfg, err := load(func(key string) (string, bool) {
+			value, ok := env[key]
+			return value, ok
+		})
+		if err != nil {
+			return err
+		}
+		return cfg.Validate()
+	}
+
+	assert.NoError(t, validate(map[string]string{"ICMP_LOSS_THRESHOLD": "0"}))
+	assert.Error(t, validate(map[string]string{"ICMP_LOSS_THRESHOLD": "-1"}))
+	assert.Error(t, validate(map[string]string{"ICMP_LOSS_THRESHOLD": "101"}))
+
+	// The pod config API has nowhere to be served without the metrics listener
+	assert.NoError(t, validate(map[string]string{"POD_CONFIG_API": "true"}))
+	assert.Error(t, validate(map[string]string{"POD_CONFIG_API": "true", "METRICS_ADDR": ""}))
+}
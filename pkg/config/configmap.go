@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// LoadFromConfigMap loads configuration from a ConfigMap whose keys are named like the environment
+// variables, falling back to the environment for keys it doesn't set
+func LoadFromConfigMap(clientset kubernetes.Interface, namespace, name string) (*Config, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ConfigMap %s/%s: %w", namespace, name, err)
+	}
+	return loadFromData(cm.Data)
+}
+
+// loadFromData builds the configuration from ConfigMap data overlaid on the environment
+func loadFromData(data map[string]string) (*Config, error) {
+	return load(func(key string) (string, bool) {
+		if value, ok := data[key]; ok {
+			return value, true
+		}
+		return os.LookupEnv(key)
+	})
+}
+
+// WatchConfigMap calls onChange with the reloaded configuration whenever the ConfigMap's data
+// changes, until ctx is done. A change that doesn't load or validate is logged and skipped, the
+// previous configuration stays in effect.
+func WatchConfigMap(ctx context.Context, clientset kubernetes.Interface, namespace, name string, onChange func(*Config)) {
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = "metadata.name=" + name
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+
+	var applied map[string]string
+	reload := func(obj interface{}) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok || cm.Name != name || reflect.DeepEqual(cm.Data, applied) {
+			return
+		}
+		cfg, err := loadFromData(cm.Data)
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err != nil {
+			klog.Errorf("Ignoring change of ConfigMap %s/%s, keeping the previous configuration: %v", namespace, name, err)
+			return
+		}
+		applied = cm.Data
+		onChange(cfg)
+	}
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    reload,
+		UpdateFunc: func(_, obj interface{}) { reload(obj) },
+		DeleteFunc: func(interface{}) {
+			klog.Warningf("ConfigMap %s/%s was deleted, keeping the current configuration", namespace, name)
+		},
+	}); err != nil {
+		klog.Errorf("Failed to watch ConfigMap %s/%s: %v", namespace, name, err)
+		return
+	}
+
+	klog.Infof("Watching ConfigMap %s/%s for configuration changes", namespace, name)
+	informer.Run(ctx.Done())
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadFromConfigMapOverridesEnv(t *testing.T) {
+	t.Setenv("HEALTH_CHECK_INTERVAL", "2s")
+	t.Setenv("HEALTH_CHECK_TIMEOUT", "500ms")
+	clientset := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "checker", Namespace: "kube-system"},
+		Data:       map[string]string{"HEALTH_CHECK_INTERVAL": "5s"},
+	})
+
+	cfg, err := LoadFromConfigMap(clientset, "kube-system", "checker")
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, cfg.GetHealthCheckInterval(), "the ConfigMap wins")
+	assert.Equal(t, 500*time.Millisecond, cfg.GetHealthCheckTimeout(), "unset keys come from the environment")
+
+	_, err = LoadFromConfigMap(clientset, "kube-system", "missing")
+	assert.Error(t, err)
+}
+
+func TestWatchConfigMapReloads(t *testing.T) {
+	t.Setenv("POD_NAME", "checker-0")
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "checker", Namespace: "kube-system"},
+		Data:       map[string]string{"HEALTH_CHECK_INTERVAL": "2s"},
+	}
+	clientset := fake.NewSimpleClientset(cm)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	intervals := make(chan time.Duration, 10)
+	go WatchConfigMap(ctx, clientset, "kube-system", "checker", func(cfg *Config) {
+		intervals <- cfg.GetHealthCheckInterval()
+	})
+	next := func() time.Duration {
+		select {
+		case interval := <-intervals:
+			return interval
+		case <-time.After(5 * time.Second):
+			t.Fatal("configuration not reloaded")
+			return 0
+		}
+	}
+	assert.Equal(t, 2*time.Second, next())
+
+	update := func(interval string) {
+		cm = cm.DeepCopy()
+		cm.Data = map[string]string{"HEALTH_CHECK_INTERVAL": interval}
+		_, err := clientset.CoreV1().ConfigMaps("kube-system").Update(ctx, cm, metav1.UpdateOptions{})
+		assert.NoError(t, err)
+	}
+
+	// Invalid changes are skipped, the next valid one applies
+	update("soon")
+	update("3s")
+	assert.Equal(t, 3*time.Second, next())
+	assert.Empty(t, intervals)
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const breakerBucketCount = 10
+
+// rateBucket counts check results that fell into one slice of the rolling window
+type rateBucket struct {
+	start  time.Time
+	total  int
+	failed int
+}
+
+// rollingRate counts outcomes within a rolling window split into buckets
+type rollingRate struct {
+	window  time.Duration
+	buckets [breakerBucketCount]rateBucket
+}
+
+// add records one outcome at now and returns the totals within the window
+func (r *rollingRate) add(now time.Time, failed bool) (total, failures int) {
+	width := r.window / breakerBucketCount
+	if width <= 0 {
+		width = time.Nanosecond
+	}
+	start := now.Truncate(width)
+	bucket := &r.buckets[(start.UnixNano()/int64(width))%breakerBucketCount]
+	if !bucket.start.Equal(start) {
+		*bucket = rateBucket{start: start}
+	}
+	bucket.total++
+	if failed {
+		bucket.failed++
+	}
+
+	for _, bkt := range r.buckets {
+		if now.Sub(bkt.start) < r.window {
+			total += bkt.total
+			failures += bkt.failed
+		}
+	}
+	return total, failures
+}
+
+// reset forgets all recorded outcomes
+func (r *rollingRate) reset() {
+	r.buckets = [breakerBucketCount]rateBucket{}
+}
+
+// FailureRateBreaker tracks the rolling failure rate across all health checks and
+// opens when it exceeds a threshold, which usually means a network-wide issue
+// rather than individual pod failures. While open, status patching is paused.
+type FailureRateBreaker struct {
+	mu         sync.Mutex
+	threshold  float64
+	window     time.Duration
+	minSamples int
+	rate       rollingRate
+	open       bool
+	now        func() time.Time
+}
+
+// NewFailureRateBreaker creates a breaker that opens when the failure rate within
+// window exceeds threshold, once at least minSamples results have been recorded
+func NewFailureRateBreaker(threshold float64, window time.Duration, minSamples int) *FailureRateBreaker {
+	return &FailureRateBreaker{
+		threshold:  threshold,
+		window:     window,
+		minSamples: minSamples,
+		rate:       rollingRate{window: window},
+		now:        time.Now,
+	}
+}
+
+// Record adds a check result to the rolling window and re-evaluates the breaker state
+func (b *FailureRateBreaker) Record(healthy bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total, failed := b.rate.add(b.now(), !healthy)
+	if total < b.minSamples {
+		return
+	}
+
+	rate := float64(failed) / float64(total)
+	if !b.open && rate > b.threshold {
+		b.open = true
+		klog.Errorf("Failure-rate breaker OPEN: %d/%d checks failed (%.1f%%) in the last %v, exceeding threshold %.1f%%; pausing pod status updates",
+			failed, total, rate*100, b.window, b.threshold*100)
+	} else if b.open && rate <= b.threshold {
+		b.open = false
+		klog.Warningf("Failure-rate breaker CLOSED: failure rate recovered to %.1f%% (%d/%d), resuming pod status updates",
+			rate*100, failed, total)
+	}
+}
+
+// IsOpen reports whether status patching is currently suspended
+func (b *FailureRateBreaker) IsOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// callbackPathPrefix is where pods call back, followed by the token of the probe
+const callbackPathPrefix = "/callback/"
+
+// callbackURLHeader carries the URL the pod must call back, also sent as the callback query parameter
+const callbackURLHeader = "X-Callback-URL"
+
+// errNoCallback reports a pod that accepted the probe but never reached the checker back
+var errNoCallback = stderrors.New("pod did not call back")
+
+// CallbackListener receives the requests pods make back to the checker during mutual
+// reachability probes. Each probe waits on a random token, so pods can only answer their own.
+type CallbackListener struct {
+	baseURL string
+
+	mu      sync.Mutex
+	pending map[string]chan struct{}
+}
+
+// NewCallbackListener returns a listener advertising baseURL, the address pods reach it on
+func NewCallbackListener(baseURL string) *CallbackListener {
+	return &CallbackListener{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		pending: make(map[string]chan struct{}),
+	}
+}
+
+// ServeHTTP completes the probe waiting on the token of the request path
+func (l *CallbackListener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.URL.Path, callbackPathPrefix)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	l.mu.Lock()
+	done, exists := l.pending[token]
+	if exists {
+		delete(l.pending, token)
+	}
+	l.mu.Unlock()
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	close(done)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expect registers a new probe and returns the URL the pod must call and a channel closed once it
+// did. cancel must be called when the probe is done.
+func (l *CallbackListener) expect() (callbackURL string, done <-chan struct{}, cancel func(), err error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, nil, err
+	}
+	token := hex.EncodeToString(raw)
+	ch := make(chan struct{})
+
+	l.mu.Lock()
+	l.pending[token] = ch
+	l.mu.Unlock()
+	cancel = func() {
+		l.mu.Lock()
+		delete(l.pending, token)
+		l.mu.Unlock()
+	}
+	return l.baseURL + callbackPathPrefix + token, ch, cancel, nil
+}
+
+// callbackProbe asks the pod to call the checker back and waits for it within timeout. The
+// request must succeed, so a plain connect that works only one way isn't enough.
+func callbackProbe(ctx context.Context, addr string, target HTTPTarget, listener *CallbackListener, timeout time.Duration) error {
+	callbackURL, done, cancel, err := listener.expect()
+	if err != nil {
+		return fmt.Errorf("failed to create callback token: %w", err)
+	}
+	defer cancel()
+
+	ctx, cancelCtx := context.WithTimeout(ctx, timeout)
+	defer cancelCtx()
+	probeURL := fmt.Sprintf("http://%s%s?callback=%s", addr, target.Path, url.QueryEscape(callbackURL))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set(callbackURLHeader, callbackURL)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%w: callback trigger returned status %d", errUnexpectedHTTPResponse, resp.StatusCode)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w to %s within %v", errNoCallback, listener.baseURL, timeout)
+	}
+}
+
+// callbackProbeWithRetry callback probe with retry mechanism
+func callbackProbeWithRetry(ctx context.Context, ip string, target HTTPTarget, listener *CallbackListener, config *HealthCheckConfig) error {
+	addr := net.JoinHostPort(ip, fmt.Sprintf("%d", target.Port))
+	var lastErr error
+	for i := 0; i <= config.RetryCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("callback probe of %s canceled: %w", addr, err)
+		}
+		if lastErr = callbackProbe(ctx, addr, target, listener, config.attemptTimeout()); lastErr == nil {
+			return nil
+		}
+		if i < config.RetryCount {
+			klog.V(4).Infof("Callback probe attempt %d/%d failed for %s: %v, retrying...",
+				i+1, config.RetryCount+1, addr, lastErr)
+		}
+	}
+	return fmt.Errorf("callback probe failed after %d attempts: %w", config.RetryCount+1, lastErr)
+}
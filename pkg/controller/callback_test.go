@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startCallbackListener serves a callback listener the way main does
+func startCallbackListener(t *testing.T) *CallbackListener {
+	var listener *CallbackListener
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listener.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	listener = NewCallbackListener(srv.URL + "/")
+	return listener
+}
+
+// startCallbackPod serves /reach-back, calling back the advertised URL when cooperating
+func startCallbackPod(t *testing.T, cooperating bool) int32 {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/reach-back" {
+			http.NotFound(w, r)
+			return
+		}
+		if cooperating {
+			resp, err := http.Get(r.Header.Get(callbackURLHeader))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			resp.Body.Close()
+			assert.Equal(t, r.URL.Query().Get("callback"), r.Header.Get(callbackURLHeader))
+		}
+		w.WriteHeader(http.StatusOK)
+	})}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return int32(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestCallbackProbe(t *testing.T) {
+	listener := startCallbackListener(t)
+	target := HTTPTarget{Path: "/reach-back"}
+
+	port := startCallbackPod(t, true)
+	assert.NoError(t, callbackProbe(context.Background(), fmt.Sprintf("127.0.0.1:%d", port), target, listener, time.Second))
+
+	// The pod answers but can't or won't reach the checker back
+	port = startCallbackPod(t, false)
+	err := callbackProbe(context.Background(), fmt.Sprintf("127.0.0.1:%d", port), target, listener, 100*time.Millisecond)
+	assert.ErrorIs(t, err, errNoCallback)
+	assert.Equal(t, FailureClassUnreachable, classifyProbeError(err))
+
+	// A failing trigger endpoint fails the probe without waiting
+	err = callbackProbe(context.Background(), fmt.Sprintf("127.0.0.1:%d", port), HTTPTarget{Path: "/missing"}, listener, time.Second)
+	assert.ErrorIs(t, err, errUnexpectedHTTPResponse)
+
+	// Unknown or reused tokens are refused
+	rec := httptest.NewRecorder()
+	listener.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/callback/forged", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, listener.pending, "finished probes release their token")
+}
+
+func TestCallbackAnnotation(t *testing.T) {
+	for _, cooperating := range []bool{true, false} {
+		port := startCallbackPod(t, cooperating)
+		k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{
+			"endpoint-health-checker.io/enabled": "true",
+			callbackAnnotation:                   fmt.Sprintf(":%d/reach-back", port),
+		})
+		podSet := NewPodSet()
+		podSet.AddOrUpdate(k8sPod)
+		pod := podSet.GetAvailablePods()[0]
+		pod.Ports = []int32{port}
+		if !assert.NotNil(t, pod.GetCallback()) {
+			return
+		}
+
+		hc := newLocalHealthChecker()
+		hc.SetHealthCheckTimeout(300 * time.Millisecond)
+		hc.SetRetryCount(0)
+		assert.True(t, hc.performHealthCheck(context.Background(), pod).Healthy, "without a callback listener the reverse check is skipped")
+
+		hc.SetCallbackListener(startCallbackListener(t))
+		assert.Equal(t, cooperating, hc.performHealthCheck(context.Background(), pod).Healthy, "cooperating=%v", cooperating)
+	}
+
+	// Malformed values are ignored
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "web-1", "10.0.0.6", map[string]string{
+		"endpoint-health-checker.io/enabled": "true",
+		callbackAnnotation:                   ":8080/a,:8081/b",
+	}))
+	assert.Nil(t, podSet.GetPod("default", "web-1").GetCallback())
+}
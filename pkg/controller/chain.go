@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// parseProbeChain parses a comma-separated list of probe protocols
+func parseProbeChain(s string) ([]string, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		layer := strings.ToLower(strings.TrimSpace(item))
+		switch layer {
+		case ProtocolHTTP, ProtocolTCP, ProtocolICMP:
+		default:
+			return nil, fmt.Errorf("unsupported protocol %q, expected http, tcp or icmp", item)
+		}
+		if seen[layer] {
+			return nil, fmt.Errorf("protocol %q listed twice", layer)
+		}
+		seen[layer] = true
+		chain = append(chain, layer)
+	}
+	return chain, nil
+}
+
+// chainLayerPod narrows a pod to what one layer of its probe chain probes
+type chainLayerPod struct {
+	HealthCheckPodInfo
+	ports   []int32
+	targets []HTTPTarget
+}
+
+func (p chainLayerPod) GetPorts() []int32            { return p.ports }
+func (p chainLayerPod) GetHTTPTargets() []HTTPTarget { return p.targets }
+func (p chainLayerPod) GetGRPCTargets() []GRPCTarget { return nil }
+func (p chainLayerPod) GetTLSOptions() *TLSProbeOptions {
+	return nil
+}
+func (p chainLayerPod) GetUDPOptions() *UDPProbeOptions {
+	return nil
+}
+
+// checkChain probes the layers of a pod's probe chain in order and reports the first that passes.
+// A layer the pod has nothing to probe with is skipped. An HTTP layer whose server answered with
+// an unexpected response is final, only transport errors fall back to the next layer.
+func (hc *HealthChecker) checkChain(ctx context.Context, pod HealthCheckPodInfo, chain []string, config *HealthCheckConfig) ProbeResult {
+	result := ProbeResult{Protocol: chain[0], Err: fmt.Errorf("no layer of probe chain %s applies", strings.Join(chain, ","))}
+	for _, layer := range chain {
+		switch layer {
+		case ProtocolHTTP:
+			targets := pod.GetHTTPTargets()
+			if len(targets) == 0 {
+				continue
+			}
+			ports := mergeTargetPorts(nil, targets)
+			result.PortsUp, result.Err = hc.checkPorts(ctx, chainLayerPod{HealthCheckPodInfo: pod, ports: ports, targets: targets}, config)
+		case ProtocolTCP:
+			if len(pod.GetPorts()) == 0 {
+				continue
+			}
+			result.PortsUp, result.Err = hc.checkPorts(ctx, chainLayerPod{HealthCheckPodInfo: pod, ports: pod.GetPorts()}, config)
+		case ProtocolICMP:
+			result.PortsUp, result.Err = nil, hc.checkICMP(ctx, pod, config)
+		}
+		result.Protocol = layer
+		if result.Err == nil {
+			return result
+		}
+		if layer == ProtocolHTTP && stderrors.Is(result.Err, errUnexpectedHTTPResponse) {
+			return result
+		}
+		klog.V(3).Infof("Pod %s/%s: %s layer of probe chain failed: %v", pod.GetNamespace(), pod.GetName(), layer, result.Err)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProbeChain(t *testing.T) {
+	chain, err := parseProbeChain(" HTTP, tcp ,icmp")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ProtocolHTTP, ProtocolTCP, ProtocolICMP}, chain)
+
+	_, err = parseProbeChain("http,udp")
+	assert.Error(t, err)
+	_, err = parseProbeChain("tcp,tcp")
+	assert.Error(t, err)
+	_, err = parseProbeChain("")
+	assert.Error(t, err)
+}
+
+// serverPort returns the port of a test server listening on 127.0.0.1
+func serverPort(addr net.Addr) int32 {
+	return int32(addr.(*net.TCPAddr).Port)
+}
+
+func TestProbeChainFallback(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ok.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// A plain TCP service accepts connections but does not speak HTTP
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	hc := newLocalHealthChecker()
+	chain := []string{ProtocolHTTP, ProtocolTCP, ProtocolICMP}
+
+	tests := []struct {
+		name     string
+		port     int32
+		protocol string
+		healthy  bool
+	}{
+		{"http passes", serverPort(ok.Listener.Addr()), ProtocolHTTP, true},
+		{"http transport error falls back to tcp", serverPort(ln.Addr()), ProtocolTCP, true},
+		{"http status is final", serverPort(failing.Listener.Addr()), ProtocolHTTP, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &PodInfo{
+				Namespace:   "default",
+				Name:        "web",
+				IP:          "127.0.0.1",
+				Ports:       []int32{tt.port},
+				HTTPTargets: []HTTPTarget{{Port: tt.port, Path: "/healthz"}},
+				ProbeChain:  chain,
+			}
+			result := hc.performHealthCheck(context.Background(), pod)
+			assert.Equal(t, tt.protocol, result.Protocol)
+			assert.Equal(t, tt.healthy, result.Healthy)
+			if tt.healthy {
+				assert.Equal(t, []int32{tt.port}, result.PortsUp)
+			}
+		})
+	}
+}
+
+func TestProbeChainFallsBackToICMP(t *testing.T) {
+	hc := newLocalHealthChecker()
+	// Hold the only ICMP slot so the ping deterministically times out without a raw socket
+	hc.SetMaxInFlightICMP(1)
+	assert.NoError(t, hc.icmpLimiter.acquire(context.Background()))
+	defer hc.icmpLimiter.release()
+
+	pod := &PodInfo{
+		Namespace:  "default",
+		Name:       "web",
+		IP:         "127.0.0.1",
+		Ports:      []int32{closedPort(t)},
+		ProbeChain: []string{ProtocolHTTP, ProtocolTCP, ProtocolICMP},
+	}
+	// Without HTTP targets the http layer is skipped, the refused TCP connect falls back to ICMP
+	result := hc.performHealthCheck(context.Background(), pod)
+	assert.Equal(t, ProtocolICMP, result.Protocol)
+	assert.False(t, result.Healthy)
+	assert.ErrorIs(t, result.Err, context.DeadlineExceeded)
+	assert.Empty(t, result.PortsUp)
+}
+
+func TestProbeChainWithoutApplicableLayer(t *testing.T) {
+	hc := newLocalHealthChecker()
+	pod := &PodInfo{Namespace: "default", Name: "web", IP: "127.0.0.1", ProbeChain: []string{ProtocolHTTP, ProtocolTCP}}
+	result := hc.performHealthCheck(context.Background(), pod)
+	assert.False(t, result.Healthy)
+	assert.Error(t, result.Err)
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// coalesceByService picks at most budget pods to check this tick. Every Service first gets
+// one endpoint checked, preferring the least recently checked healthy one, so each Service
+// keeps a freshly verified healthy endpoint. The rest of the budget goes to pods that are
+// unhealthy or not yet checked, then to the stalest healthy siblings, which are deferred
+// to later ticks when the budget runs out.
+func coalesceByService(pods []*PodInfo, services []*corev1.Service, budget int) []*PodInfo {
+	if budget <= 0 || len(pods) <= budget {
+		return pods
+	}
+
+	stalest := func(list []*PodInfo) {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].LastDispatchedAt.Before(list[j].LastDispatchedAt)
+		})
+	}
+	stalest(pods)
+
+	selected := make([]*PodInfo, 0, budget)
+	picked := make(map[*PodInfo]bool, budget)
+	pick := func(pod *PodInfo) {
+		selected = append(selected, pod)
+		picked[pod] = true
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Namespace != services[j].Namespace {
+			return services[i].Namespace < services[j].Namespace
+		}
+		return services[i].Name < services[j].Name
+	})
+	for _, svc := range services {
+		if len(selected) >= budget {
+			break
+		}
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+
+		var candidate *PodInfo
+		covered := false
+		for _, pod := range pods {
+			if pod.GetNamespace() != svc.Namespace || !selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			if picked[pod] {
+				covered = true
+				break
+			}
+			if candidate == nil || (isHealthy(pod) && !isHealthy(candidate)) {
+				candidate = pod
+			}
+		}
+		if covered {
+			continue
+		}
+		if candidate != nil {
+			pick(candidate)
+		}
+	}
+
+	// Pods whose health is in question come before healthy siblings
+	var healthySiblings []*PodInfo
+	for _, pod := range pods {
+		if picked[pod] {
+			continue
+		}
+		if isHealthy(pod) {
+			healthySiblings = append(healthySiblings, pod)
+			continue
+		}
+		if len(selected) < budget {
+			pick(pod)
+		}
+	}
+	for _, pod := range healthySiblings {
+		if len(selected) >= budget {
+			break
+		}
+		pick(pod)
+	}
+
+	klog.V(4).Infof("Scheduler: service coalescing checks %d of %d pods, deferring %d",
+		len(selected), len(pods), len(pods)-len(selected))
+	return selected
+}
+
+func isHealthy(pod *PodInfo) bool {
+	last := pod.GetLastHealthStatus()
+	return last != nil && *last
+}
+
+// listServices returns all Services known to the lister, nil when grouping is disabled
+func listServices(lister v1.ServiceLister) []*corev1.Service {
+	if lister == nil {
+		return nil
+	}
+	services, err := lister.List(labels.Everything())
+	if err != nil {
+		klog.Warningf("Scheduler: failed to list services: %v", err)
+		return nil
+	}
+	return services
+}
@@ -1,11 +1,19 @@
 package controller
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
+	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
 	v1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
@@ -18,11 +26,45 @@ type Controller struct {
 	podLister       v1.PodLister
 	podSynced       cache.InformerSynced
 	podSet          *PodSet
+
+	// Pods that are enabled but not yet ready, re-evaluated from the lister
+	// so the readiness transition isn't missed when update events are sparse
+	readinessRecheckInterval time.Duration
+	pendingMu                sync.Mutex
+	pendingReady             map[string]struct{} // key: namespace/name
+
+	// Periodic sweep dropping tracked pods whose delete or update event was missed, 0 disables
+	reconcileInterval time.Duration
+
+	// Optional per-owner health rollup, resolving ReplicaSets to their Deployment
+	ownerRollupInterval time.Duration
+	rsLister            appsv1listers.ReplicaSetLister
+	rsSynced            cache.InformerSynced
+
+	// Optional Service informer used to group pods by Service membership
+	serviceLister v1.ServiceLister
+	serviceSynced cache.InformerSynced
+	// Optional Node informer used to skip pods on NotReady nodes
+	nodeLister v1.NodeLister
+	nodeSynced cache.InformerSynced
+
+	// Each cache sync attempt is bounded so a transient API failure at startup is retried
+	// with backoff instead of blocking forever or killing the process
+	cacheSyncTimeout  time.Duration
+	cacheSyncAttempts int
+	cacheSyncBackoff  wait.Backoff
 }
 
-func NewController(clientset kubernetes.Interface, resync time.Duration, podSet *PodSet) *Controller {
+// NewController creates a controller tracking pods into podSet. A non-empty podLabelSelector
+// narrows the pod informer to matching pods, the other informers still see every object.
+func NewController(clientset kubernetes.Interface, resync time.Duration, podSet *PodSet, podLabelSelector string) *Controller {
 	factory := kubeinformers.NewSharedInformerFactory(clientset, resync)
-	podInformer := factory.Core().V1().Pods().Informer()
+	// Registered first, so the factory's pod lister is backed by the narrowed informer
+	podInformer := factory.InformerFor(&corev1.Pod{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return coreinformers.NewFilteredPodInformer(client, metav1.NamespaceAll, resync,
+			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+			func(options *metav1.ListOptions) { options.LabelSelector = podLabelSelector })
+	})
 
 	c := &Controller{
 		clientset:       clientset,
@@ -31,6 +73,11 @@ func NewController(clientset kubernetes.Interface, resync time.Duration, podSet
 		podLister:       factory.Core().V1().Pods().Lister(),
 		podSynced:       podInformer.HasSynced,
 		podSet:          podSet,
+		pendingReady:    make(map[string]struct{}),
+
+		cacheSyncTimeout:  time.Minute,
+		cacheSyncAttempts: 5,
+		cacheSyncBackoff:  wait.Backoff{Duration: time.Second, Factor: 2, Steps: 5, Cap: 30 * time.Second},
 	}
 
 	handler, err := podInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -46,29 +93,223 @@ func NewController(clientset kubernetes.Interface, resync time.Duration, podSet
 	return c
 }
 
-func (c *Controller) Run(stopCh <-chan struct{}) {
+// GetPodLister returns the lister backed by the pod informer cache
+func (c *Controller) GetPodLister() v1.PodLister {
+	return c.podLister
+}
+
+// SetReadinessRecheckInterval sets how often pods waiting for readiness are re-evaluated, 0 disables
+func (c *Controller) SetReadinessRecheckInterval(interval time.Duration) {
+	c.readinessRecheckInterval = interval
+}
+
+// SetReconcileInterval sets how often tracked pods are reconciled against the informer cache, 0 disables
+func (c *Controller) SetReconcileInterval(interval time.Duration) {
+	c.reconcileInterval = interval
+}
+
+// EnableOwnerRollup publishes the ehc_owner_healthy_ratio gauge at the given interval.
+// It adds a ReplicaSet informer, so it must be called before Run.
+func (c *Controller) EnableOwnerRollup(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	rsInformer := c.informerFactory.Apps().V1().ReplicaSets()
+	c.ownerRollupInterval = interval
+	c.rsLister = rsInformer.Lister()
+	c.rsSynced = rsInformer.Informer().HasSynced
+}
+
+// EnableServiceGrouping adds a Service informer so the scheduler can prioritize checks
+// per Service. It must be called before Run.
+func (c *Controller) EnableServiceGrouping() {
+	serviceInformer := c.informerFactory.Core().V1().Services()
+	c.serviceLister = serviceInformer.Lister()
+	c.serviceSynced = serviceInformer.Informer().HasSynced
+}
+
+// GetServiceLister returns the Service lister, nil unless service grouping is enabled
+func (c *Controller) GetServiceLister() v1.ServiceLister {
+	return c.serviceLister
+}
+
+// EnableNodeReadiness adds a Node informer, used to skip pods on NotReady nodes and to
+// resolve node zones. It must be called before Run.
+func (c *Controller) EnableNodeReadiness() {
+	nodeInformer := c.informerFactory.Core().V1().Nodes()
+	c.nodeLister = nodeInformer.Lister()
+	c.nodeSynced = nodeInformer.Informer().HasSynced
+}
+
+// GetNodeLister returns the Node lister, nil unless the Node informer is enabled
+func (c *Controller) GetNodeLister() v1.NodeLister {
+	return c.nodeLister
+}
+
+// HasSynced reports whether the caches of every informer Run started have synced, false before Run
+func (c *Controller) HasSynced() bool {
+	for _, synced := range []cache.InformerSynced{c.podSynced, c.rsSynced, c.serviceSynced, c.nodeSynced} {
+		if synced != nil && !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Run starts the informers and blocks until stopCh is closed. It returns an error when
+// the informer caches cannot be synced, so the caller decides whether to retry or exit.
+func (c *Controller) Run(stopCh <-chan struct{}) error {
 	klog.Info("Starting controller informers...")
 
 	// Start the informer factory
 	c.informerFactory.Start(stopCh)
 
 	// Wait for all informers to sync
-	if !cache.WaitForCacheSync(stopCh, c.podSynced) {
-		klog.Fatalf("Failed to sync pod informer")
+	synced := []cache.InformerSynced{c.podSynced}
+	if c.rsSynced != nil {
+		synced = append(synced, c.rsSynced)
+	}
+	if c.serviceSynced != nil {
+		synced = append(synced, c.serviceSynced)
+	}
+	if c.nodeSynced != nil {
+		synced = append(synced, c.nodeSynced)
+	}
+	if err := c.waitForCacheSync(stopCh, synced...); err != nil {
+		return err
 	}
 
 	klog.Info("All informers synced. Controller is running.")
+
+	if c.readinessRecheckInterval > 0 {
+		go wait.Until(c.recheckPendingPods, c.readinessRecheckInterval, stopCh)
+	}
+	if c.reconcileInterval > 0 {
+		go wait.Until(c.reconcilePods, c.reconcileInterval, stopCh)
+	}
+	if c.ownerRollupInterval > 0 {
+		go wait.Until(func() { updateOwnerMetrics(c.podSet, c.rsLister) }, c.ownerRollupInterval, stopCh)
+	}
+
 	<-stopCh
+	return nil
+}
+
+// waitForCacheSync waits for the informer caches, retrying with backoff when an attempt times out
+func (c *Controller) waitForCacheSync(stopCh <-chan struct{}, synced ...cache.InformerSynced) error {
+	backoff := c.cacheSyncBackoff
+	for attempt := 1; ; attempt++ {
+		if c.syncWithTimeout(stopCh, synced) {
+			return nil
+		}
+		select {
+		case <-stopCh:
+			return fmt.Errorf("stopped before informer caches synced")
+		default:
+		}
+		if attempt >= c.cacheSyncAttempts {
+			return fmt.Errorf("informer caches not synced after %d attempts", attempt)
+		}
+
+		delay := backoff.Step()
+		klog.Warningf("Informer caches not synced within %v (attempt %d/%d), retrying in %v",
+			c.cacheSyncTimeout, attempt, c.cacheSyncAttempts, delay)
+		select {
+		case <-stopCh:
+			return fmt.Errorf("stopped before informer caches synced")
+		case <-time.After(delay):
+		}
+	}
+}
+
+// syncWithTimeout runs one cache sync attempt bounded by the sync timeout
+func (c *Controller) syncWithTimeout(stopCh <-chan struct{}, synced []cache.InformerSynced) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.cacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return cache.WaitForCacheSync(ctx.Done(), synced...)
 }
 
 func (c *Controller) onPodAdd(obj interface{}) {
 	pod := obj.(*corev1.Pod)
-	c.podSet.AddOrUpdate(pod)
+	c.addOrUpdatePod(pod)
 }
 
 func (c *Controller) onPodUpdate(oldObj, newObj interface{}) {
 	pod := newObj.(*corev1.Pod)
+	c.addOrUpdatePod(pod)
+}
+
+// addOrUpdatePod hands the pod to the PodSet and remembers it if it is only waiting for readiness
+func (c *Controller) addOrUpdatePod(pod *corev1.Pod) {
 	c.podSet.AddOrUpdate(pod)
+
+	if c.readinessRecheckInterval <= 0 {
+		return
+	}
+	key := pod.Namespace + "/" + pod.Name
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if c.podSet.IsAwaitingReadiness(pod) {
+		c.pendingReady[key] = struct{}{}
+	} else {
+		delete(c.pendingReady, key)
+	}
+}
+
+// recheckPendingPods re-evaluates pods waiting for readiness from the lister and adopts those that became ready
+func (c *Controller) recheckPendingPods() {
+	c.pendingMu.Lock()
+	keys := make([]string, 0, len(c.pendingReady))
+	for key := range c.pendingReady {
+		keys = append(keys, key)
+	}
+	c.pendingMu.Unlock()
+
+	for _, key := range keys {
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+		pod, err := c.podLister.Pods(namespace).Get(name)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				c.pendingMu.Lock()
+				delete(c.pendingReady, key)
+				c.pendingMu.Unlock()
+			}
+			continue
+		}
+		if isPodReady(pod) {
+			klog.V(4).Infof("Pod %s became ready without an update event, adopting it", key)
+		}
+		c.addOrUpdatePod(pod)
+	}
+}
+
+// reconcilePods stops tracking pods the informer cache no longer has or that no longer qualify,
+// whose delete or update event was missed, e.g. across a watch gap
+func (c *Controller) reconcilePods() {
+	removed := c.podSet.RemoveOrphans(func(tracked *PodInfo) string {
+		pod, err := c.podLister.Pods(tracked.Namespace).Get(tracked.Name)
+		if errors.IsNotFound(err) {
+			return "no longer exists"
+		}
+		if err != nil {
+			klog.Warningf("Reconcile: failed to get pod %s/%s: %v", tracked.Namespace, tracked.Name, err)
+			return ""
+		}
+		return c.podSet.disqualified(tracked, pod)
+	})
+	if removed > 0 {
+		klog.Infof("Reconcile: deleted %d orphaned pods from PodSet", removed)
+	}
 }
 
 func (c *Controller) onPodDelete(obj interface{}) {
@@ -81,6 +322,10 @@ func (c *Controller) onPodDelete(obj interface{}) {
 		klog.Infof("Received delete event for pod %s/%s", pod.Namespace, pod.Name)
 	}
 
+	c.pendingMu.Lock()
+	delete(c.pendingReady, pod.Namespace+"/"+pod.Name)
+	c.pendingMu.Unlock()
+
 	// If PodIP is empty, use namespace and name to delete
 	if pod.Status.PodIP == "" {
 		klog.Infof("PodIP is empty for deleted pod %s/%s, using namespace/name to delete", pod.Namespace, pod.Name)
@@ -7,7 +7,9 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/cache"
 
@@ -19,7 +21,7 @@ func TestNewController(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
 	podSet := NewPodSet()
 
-	controller := NewController(clientset, time.Minute, podSet)
+	controller := NewController(clientset, time.Minute, podSet, "")
 
 	assert.NotNil(t, controller)
 	assert.NotNil(t, controller.clientset)
@@ -52,27 +54,30 @@ func TestPodSetOperations(t *testing.T) {
 	count, _ := podSet.GetStats()
 	assert.Equal(t, 1, count)
 
-	// Test updating a pod
+	// Test updating a pod, a new IP replaces the old entry
 	testPod.Status.PodIP = "192.168.1.101"
 	podSet.AddOrUpdate(testPod)
 	count, _ = podSet.GetStats()
-	assert.Equal(t, 2, count)
+	assert.Equal(t, 1, count)
+	assert.Equal(t, "192.168.1.101", podSet.GetPod("default", "test-pod").IP)
 
 	// Test deleting a pod
 	podSet.Delete(testPod)
 	count, _ = podSet.GetStats()
-	assert.Equal(t, 1, count)
+	assert.Equal(t, 0, count)
 
 	// Test deleting by namespace and name
+	podSet.AddOrUpdate(testPod)
 	podSet.DeleteByNamespaceAndName("default", "test-pod")
 	count, _ = podSet.GetStats()
 	assert.Equal(t, 0, count)
+	assert.Nil(t, podSet.GetPod("default", "test-pod"))
 }
 
 func TestControllerPodEvents(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
 	podSet := NewPodSet()
-	controller := NewController(clientset, time.Minute, podSet)
+	controller := NewController(clientset, time.Minute, podSet, "")
 
 	// Test pod add event
 	testPod := &corev1.Pod{
@@ -113,7 +118,7 @@ func TestControllerPodEvents(t *testing.T) {
 func TestControllerWithDeletedFinalStateUnknown(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
 	podSet := NewPodSet()
-	controller := NewController(clientset, time.Minute, podSet)
+	controller := NewController(clientset, time.Minute, podSet, "")
 
 	// Add a pod first
 	testPod := &corev1.Pod{
@@ -152,7 +157,7 @@ func TestControllerWithDeletedFinalStateUnknown(t *testing.T) {
 func TestControllerPodWithEmptyIP(t *testing.T) {
 	clientset := fake.NewSimpleClientset()
 	podSet := NewPodSet()
-	controller := NewController(clientset, time.Minute, podSet)
+	controller := NewController(clientset, time.Minute, podSet, "")
 
 	// Test pod with empty IP
 	testPod := &corev1.Pod{
@@ -335,3 +340,161 @@ func TestPodReadinessCheck(t *testing.T) {
 	count, _ = podSet.GetStats()
 	assert.Equal(t, 1, count, "Pod without conditions should not be added, count should remain 1")
 }
+
+func TestReadinessRecheckAdoptsPodWithoutEvent(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	podSet := NewPodSet()
+	controller := NewController(clientset, time.Minute, podSet, "")
+	controller.SetReadinessRecheckInterval(time.Second)
+
+	testPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "slow-pod",
+			Namespace:   "default",
+			Annotations: map[string]string{"endpoint-health-checker.io/enabled": "true"},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: "192.168.1.100",
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionFalse},
+			},
+		},
+	}
+
+	assert.NoError(t, controller.podInformer.GetIndexer().Add(testPod))
+	controller.onPodAdd(testPod)
+	count, _ := podSet.GetStats()
+	assert.Equal(t, 0, count, "not-ready pod should not be tracked yet")
+	assert.Contains(t, controller.pendingReady, "default/slow-pod")
+
+	// Still not ready, stays pending
+	controller.recheckPendingPods()
+	count, _ = podSet.GetStats()
+	assert.Equal(t, 0, count)
+
+	// The pod becomes ready in the cache, but no update event is delivered
+	readyPod := testPod.DeepCopy()
+	readyPod.Status.Conditions[0].Status = corev1.ConditionTrue
+	assert.NoError(t, controller.podInformer.GetIndexer().Update(readyPod))
+
+	controller.recheckPendingPods()
+	count, _ = podSet.GetStats()
+	assert.Equal(t, 1, count, "pod should be adopted once ready")
+	assert.NotContains(t, controller.pendingReady, "default/slow-pod")
+}
+
+func TestReadinessRecheckDropsDeletedPods(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	podSet := NewPodSet()
+	controller := NewController(clientset, time.Minute, podSet, "")
+	controller.SetReadinessRecheckInterval(time.Second)
+
+	testPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "gone-pod",
+			Namespace:   "default",
+			Annotations: map[string]string{"endpoint-health-checker.io/enabled": "true"},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "192.168.1.101"},
+	}
+
+	controller.onPodAdd(testPod)
+	assert.Contains(t, controller.pendingReady, "default/gone-pod")
+
+	controller.recheckPendingPods()
+	assert.NotContains(t, controller.pendingReady, "default/gone-pod")
+}
+
+func TestReconcileRemovesOrphanedPods(t *testing.T) {
+	podSet := NewPodSet()
+	controller := NewController(fake.NewSimpleClientset(), time.Minute, podSet, "")
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+
+	kept := newReadyPod("default", "kept", "10.0.0.1", enabled)
+	unready := newReadyPod("default", "unready", "10.0.0.2", enabled)
+	deleted := newReadyPod("default", "deleted", "10.0.0.3", enabled)
+	finished := newReadyPod("default", "finished", "10.0.0.4", enabled)
+	disabled := newReadyPod("default", "disabled", "10.0.0.5", enabled)
+	for _, pod := range []*corev1.Pod{kept, unready, deleted, finished, disabled} {
+		controller.onPodAdd(pod)
+	}
+	count, _ := podSet.GetStats()
+	assert.Equal(t, 5, count)
+
+	// The cache moved on without the events reaching us: one pod is gone, others changed
+	unready = unready.DeepCopy()
+	unready.Status.Conditions[0].Status = corev1.ConditionFalse
+	finished = finished.DeepCopy()
+	finished.Status.Phase = corev1.PodSucceeded
+	disabled = disabled.DeepCopy()
+	disabled.Annotations = map[string]string{excludeAnnotation: "true"}
+	for _, pod := range []*corev1.Pod{kept, unready, finished, disabled} {
+		assert.NoError(t, controller.podInformer.GetIndexer().Add(pod))
+	}
+
+	controller.reconcilePods()
+	count, _ = podSet.GetStats()
+	assert.Equal(t, 2, count)
+	assert.NotNil(t, podSet.GetPod("default", "kept"))
+	assert.NotNil(t, podSet.GetPod("default", "unready"), "pods that only lost readiness stay tracked")
+	assert.Nil(t, podSet.GetPod("default", "deleted"))
+	assert.Nil(t, podSet.GetPod("default", "finished"))
+	assert.Nil(t, podSet.GetPod("default", "disabled"))
+}
+
+func TestWaitForCacheSyncRetriesAfterFailure(t *testing.T) {
+	controller := NewController(fake.NewSimpleClientset(), time.Minute, NewPodSet(), "")
+	controller.cacheSyncTimeout = 50 * time.Millisecond
+	controller.cacheSyncBackoff = wait.Backoff{Duration: 10 * time.Millisecond, Factor: 1, Steps: 5}
+
+	// The first attempt times out, a later one sees the cache synced
+	readyAt := time.Now().Add(150 * time.Millisecond)
+	synced := func() bool { return time.Now().After(readyAt) }
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.NoError(t, controller.waitForCacheSync(stopCh, synced))
+}
+
+func TestWaitForCacheSyncGivesUp(t *testing.T) {
+	controller := NewController(fake.NewSimpleClientset(), time.Minute, NewPodSet(), "")
+	controller.cacheSyncTimeout = 20 * time.Millisecond
+	controller.cacheSyncAttempts = 2
+	controller.cacheSyncBackoff = wait.Backoff{Duration: 10 * time.Millisecond, Factor: 1, Steps: 2}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	err := controller.waitForCacheSync(stopCh, func() bool { return false })
+	assert.ErrorContains(t, err, "after 2 attempts")
+}
+
+func TestPodLabelSelectorNarrowsInformer(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	selected := newReadyPod("default", "selected", "10.0.0.1", enabled)
+	selected.Labels = map[string]string{"health-check": "enabled"}
+	other := newReadyPod("default", "other", "10.0.0.2", enabled)
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	podSet := NewPodSet()
+	controller := NewController(fake.NewSimpleClientset(selected, other, service), time.Minute, podSet, "health-check=enabled")
+	controller.EnableServiceGrouping()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	controller.informerFactory.Start(ctx.Done())
+	assert.True(t, cache.WaitForCacheSync(ctx.Done(), controller.podSynced, controller.serviceSynced))
+
+	pods, err := controller.GetPodLister().List(labels.Everything())
+	assert.NoError(t, err)
+	if assert.Len(t, pods, 1) {
+		assert.Equal(t, "selected", pods[0].Name)
+	}
+	// Event handlers may lag behind the synced cache
+	assert.Eventually(t, func() bool { return podSet.GetPod("default", "selected") != nil }, time.Second, 10*time.Millisecond)
+	assert.Nil(t, podSet.GetPod("default", "other"), "enabled but outside the selector")
+
+	services, err := controller.GetServiceLister().List(labels.Everything())
+	assert.NoError(t, err)
+	assert.Len(t, services, 1, "other informers are not narrowed")
+}
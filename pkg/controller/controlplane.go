@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+// ControlPlaneGuard tracks the rolling error rate of our API calls across all pods. When most
+// of them fail for reasons unrelated to the pod the control plane is degraded: status writes
+// are paused rather than flooding it with patches that fail or act on stale reads.
+type ControlPlaneGuard struct {
+	mu         sync.Mutex
+	threshold  float64
+	minSamples int
+	rate       rollingRate
+	degraded   bool
+	lastProbe  time.Time
+	now        func() time.Time
+}
+
+// NewControlPlaneGuard creates a guard that enters degraded mode when the API error rate within
+// window exceeds threshold, once at least minSamples calls have been recorded
+func NewControlPlaneGuard(threshold float64, window time.Duration, minSamples int) *ControlPlaneGuard {
+	return &ControlPlaneGuard{
+		threshold:  threshold,
+		minSamples: minSamples,
+		rate:       rollingRate{window: window},
+		now:        time.Now,
+	}
+}
+
+// Record adds the outcome of an API call. Rejections such as NotFound or Conflict prove the
+// API server is serving and count as successes, calls cut short by our own context don't count.
+func (g *ControlPlaneGuard) Record(err error) {
+	if isContextError(err) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	failed := isAPIUnreachable(err)
+	if g.degraded {
+		if !failed {
+			g.degraded = false
+			g.rate.reset()
+			metrics.SetControlPlaneDegraded(false)
+			klog.Warningf("Control plane recovered, resuming pod status updates")
+		}
+		return
+	}
+
+	total, failures := g.rate.add(g.now(), failed)
+	if total < g.minSamples {
+		return
+	}
+	if rate := float64(failures) / float64(total); rate > g.threshold {
+		g.degraded = true
+		g.lastProbe = g.now()
+		metrics.SetControlPlaneDegraded(true)
+		klog.Errorf("Control plane degraded: %d/%d API calls failed (%.1f%%) in the last %v, exceeding threshold %.1f%%; pausing pod status updates",
+			failures, total, rate*100, g.rate.window, g.threshold*100)
+	}
+}
+
+// Degraded reports whether status writes are currently paused
+func (g *ControlPlaneGuard) Degraded() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.degraded
+}
+
+// probeDue reports whether a degraded guard should check for recovery, at most once per bucket
+// of the window so an outage isn't met with a request per pod
+func (g *ControlPlaneGuard) probeDue() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.degraded || g.now().Sub(g.lastProbe) < g.rate.window/breakerBucketCount {
+		return false
+	}
+	g.lastProbe = g.now()
+	return true
+}
+
+// checkRecovery asks the API server for its version, the cheapest call that proves it serves
+// requests again, and resumes status writes once it does
+func (g *ControlPlaneGuard) checkRecovery(clientset kubernetes.Interface) {
+	if !g.probeDue() {
+		return
+	}
+	_, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		klog.V(4).Infof("Control plane still degraded: %v", err)
+	}
+	g.Record(err)
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+func TestControlPlaneGuard(t *testing.T) {
+	now := time.Now()
+	guard := NewControlPlaneGuard(0.5, 10*time.Second, 4)
+	guard.now = func() time.Time { return now }
+
+	// Rejections prove the API server serves requests
+	for i := 0; i < 4; i++ {
+		guard.Record(apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web-0"))
+	}
+	assert.False(t, guard.Degraded())
+
+	for i := 0; i < 5; i++ {
+		guard.Record(apierrors.NewServiceUnavailable("down"))
+	}
+	assert.True(t, guard.Degraded())
+	assert.Equal(t, float64(1), testutil.ToFloat64(metrics.ControlPlaneDegradedGauge()))
+
+	// Recovery is probed at most once per bucket of the window
+	assert.False(t, guard.probeDue())
+	now = now.Add(time.Second)
+	assert.True(t, guard.probeDue())
+	assert.False(t, guard.probeDue())
+
+	// A call cut short by our own deadline proves nothing either way
+	guard.Record(fmt.Errorf("failed to get pod: %w", context.DeadlineExceeded))
+	assert.True(t, guard.Degraded())
+
+	guard.Record(nil)
+	assert.False(t, guard.Degraded())
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.ControlPlaneDegradedGauge()))
+
+	// Errors from before the outage don't count towards the next one
+	guard.Record(apierrors.NewServiceUnavailable("down"))
+	assert.False(t, guard.Degraded())
+}
+
+func TestControlPlaneDegradedPausesStatusWrites(t *testing.T) {
+	pods := make([]runtime.Object, 0, 3)
+	for _, name := range []string{"web-0", "web-1", "web-2"} {
+		pods = append(pods, newReadyPod("default", name, "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"}))
+	}
+	clientset := fake.NewSimpleClientset(pods...)
+
+	var down atomic.Bool
+	down.Store(true)
+	unavailable := func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if down.Load() {
+			return true, nil, apierrors.NewServiceUnavailable("apiserver overloaded")
+		}
+		return false, nil, nil
+	}
+	clientset.PrependReactor("get", "pods", unavailable)
+	clientset.PrependReactor("get", "version", unavailable)
+
+	now := time.Now()
+	guard := NewControlPlaneGuard(0.5, 10*time.Second, 2)
+	guard.now = func() time.Time { return now }
+	hc := newLocalHealthChecker()
+	hc.SetControlPlaneGuard(guard)
+
+	port := closedPort(t)
+	newPod := func(name string) *PodInfo {
+		return &PodInfo{Namespace: "default", Name: name, IP: "127.0.0.1", Ports: []int32{port}}
+	}
+	web0, web1, web2 := newPod("web-0"), newPod("web-1"), newPod("web-2")
+
+	// Failing reads on different pods trip the degraded mode
+	assert.Error(t, hc.CheckPod(context.Background(), clientset, web0))
+	assert.Error(t, hc.CheckPod(context.Background(), clientset, web1))
+	assert.True(t, guard.Degraded())
+
+	// Further checks neither read nor write pods
+	paused := testutil.ToFloat64(metrics.StatusWritesPausedCounter())
+	clientset.ClearActions()
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, web2))
+	assert.Empty(t, clientset.Actions())
+	assert.Equal(t, paused+1, testutil.ToFloat64(metrics.StatusWritesPausedCounter()))
+
+	// The API server recovers, the next recovery probe resumes writes
+	down.Store(false)
+	now = now.Add(time.Second)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, web2))
+	assert.False(t, guard.Degraded())
+	assert.Equal(t, 1, countPatches(clientset))
+
+	got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-2", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.ConditionFalse, conditionStatus(got, corev1.PodReady))
+}
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// DrainingAnnotation is set on the checker's own pod when it shuts down, telling dashboards
+// that the pause in status updates that follows is a failover, not pod failures
+const DrainingAnnotation = "endpoint-health-checker.io/draining"
+
+// MarkDraining annotates the checker pod with the time checks were paused
+func MarkDraining(ctx context.Context, clientset kubernetes.Interface, namespace, name string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				DrainingAnnotation: time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := clientset.CoreV1().Pods(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("failed to mark pod %s/%s draining: %w", namespace, name, err)
+	}
+	klog.Infof("Pod %s/%s: marked draining, health checks are paused", namespace, name)
+	return nil
+}
+
+// ShutdownContext returns a context canceled on the first signal received from sigCh. The
+// beforeCancel hook runs first, while the lease is still held, so it can publish state
+// that must be visible before a standby takes over.
+func ShutdownContext(parent context.Context, sigCh <-chan os.Signal, beforeCancel func()) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case sig := <-sigCh:
+			klog.Infof("Received %v, shutting down", sig)
+			if beforeCancel != nil {
+				beforeCancel()
+			}
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
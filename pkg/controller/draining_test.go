@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestShutdownMarksDrainingBeforeCancel(t *testing.T) {
+	checker := newReadyPod("kube-system", "endpoint-health-checker-0", "10.0.0.9", nil)
+	clientset := fake.NewSimpleClientset(checker)
+
+	sigCh := make(chan os.Signal, 1)
+	var canceledDuringHook bool
+	var ctx context.Context
+	ctx, cancel := ShutdownContext(context.Background(), sigCh, func() {
+		canceledDuringHook = ctx.Err() != nil
+		assert.NoError(t, MarkDraining(context.Background(), clientset, "kube-system", "endpoint-health-checker-0"))
+	})
+	defer cancel()
+
+	assert.NoError(t, ctx.Err())
+	sigCh <- syscall.SIGTERM
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not canceled after SIGTERM")
+	}
+	assert.False(t, canceledDuringHook, "the draining marker must be set before the context is canceled")
+
+	got, err := clientset.CoreV1().Pods("kube-system").Get(context.Background(), "endpoint-health-checker-0", metav1.GetOptions{})
+	assert.NoError(t, err)
+	markedAt, err := time.Parse(time.RFC3339, got.Annotations[DrainingAnnotation])
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), markedAt, time.Minute)
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// IP families of dual-stack pods, also the suffix of their condition type
+const (
+	FamilyIPv4 = "IPv4"
+	FamilyIPv6 = "IPv6"
+)
+
+// familyConditionType returns the condition reporting the reachability of one IP family, named
+// after the readiness gate
+func (hc *HealthChecker) familyConditionType(family string) corev1.PodConditionType {
+	return corev1.PodConditionType(hc.readinessGateType + family)
+}
+
+// ipFamily returns the family of an IP address, empty if it doesn't parse
+func ipFamily(ip string) string {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return ""
+	case parsed.To4() != nil:
+		return FamilyIPv4
+	default:
+		return FamilyIPv6
+	}
+}
+
+// getFamilyIPs returns the first pod IP of each family, nil unless the pod is dual-stack
+func getFamilyIPs(pod *corev1.Pod) map[string]string {
+	if len(pod.Status.PodIPs) < 2 {
+		return nil
+	}
+	ips := make(map[string]string, 2)
+	for _, podIP := range pod.Status.PodIPs {
+		if family := ipFamily(podIP.IP); family != "" && ips[family] == "" {
+			ips[family] = podIP.IP
+		}
+	}
+	if len(ips) < 2 {
+		return nil
+	}
+	return ips
+}
+
+// familyPod probes a pod on the IP of one family
+type familyPod struct {
+	HealthCheckPodInfo
+	ip string
+}
+
+func (p familyPod) GetIP() string { return p.ip }
+
+// familyOrder is the order families are combined in, keeping failure messages stable
+var familyOrder = []string{FamilyIPv4, FamilyIPv6}
+
+// probeFamilies probes each IP family of a dual-stack pod. The primary IP reuses the result of
+// the regular check, IPs outside the allowed probe CIDRs count as failed without being probed.
+func (hc *HealthChecker) probeFamilies(ctx context.Context, pod HealthCheckPodInfo, primary ProbeResult) map[string]ProbeResult {
+	familyIPs := pod.GetFamilyIPs()
+	results := make(map[string]ProbeResult, len(familyIPs))
+	for family, ip := range familyIPs {
+		switch {
+		case ip == pod.GetIP():
+			results[family] = primary
+		case !hc.probeTargetAllowed(ip):
+			klog.Warningf("Pod %s/%s: %s IP %s is outside the allowed probe CIDRs, not probed",
+				pod.GetNamespace(), pod.GetName(), family, ip)
+			results[family] = ProbeResult{Protocol: primary.Protocol, Err: fmt.Errorf("IP %s is outside the allowed probe CIDRs", ip)}
+		default:
+			results[family] = hc.performHealthCheck(ctx, familyPod{HealthCheckPodInfo: pod, ip: ip})
+		}
+	}
+	return results
+}
+
+// combineFamilyResults decides the health of a dual-stack pod from the result of each family.
+// With the any policy one passing family is enough, with all a single failing family fails the
+// pod, and primary keeps the result of the primary IP.
+func combineFamilyResults(primary ProbeResult, results map[string]ProbeResult, policy string) ProbeResult {
+	switch policy {
+	case DualStackPolicyAny:
+		if primary.Healthy {
+			return primary
+		}
+		for _, family := range familyOrder {
+			if result, ok := results[family]; ok && result.Healthy {
+				return result
+			}
+		}
+	case DualStackPolicyAll:
+		for _, family := range familyOrder {
+			if result, ok := results[family]; ok && !result.Healthy {
+				if result.Err != nil {
+					result.Err = fmt.Errorf("%s: %w", family, result.Err)
+				}
+				return result
+			}
+		}
+	}
+	return primary
+}
+
+// updateFamilyConditions sets one condition per IP family of a dual-stack pod, showing which
+// family is broken
+func (hc *HealthChecker) updateFamilyConditions(ctx context.Context, clientset kubernetes.Interface, pod HealthCheckPodInfo, familyResults map[string]ProbeResult) error {
+	results := make(map[string]bool, len(familyResults))
+	changed := false
+	for family, result := range familyResults {
+		results[family] = result.Healthy
+		if last := pod.GetFamilyHealth(family); last == nil || *last != result.Healthy {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	k8sPod, err := clientset.CoreV1().Pods(pod.GetNamespace()).Get(ctx, pod.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get pod %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
+	}
+	if !hc.patchTerminating && k8sPod.DeletionTimestamp != nil {
+		return nil
+	}
+
+	var touched []corev1.PodConditionType
+	for family, healthy := range results {
+		status := corev1.ConditionTrue
+		if !healthy {
+			status = corev1.ConditionFalse
+		}
+		condType := hc.familyConditionType(family)
+		if conditionStatus(k8sPod, condType) != status {
+			setPodCondition(&k8sPod.Status.Conditions, condType, status)
+			touched = append(touched, condType)
+		}
+	}
+	if len(touched) > 0 {
+		patchType, patchBytes, err := buildConditionsPatch(k8sPod.Status.Conditions, touched, hc.statusPatchType)
+		if err != nil {
+			return err
+		}
+		if _, err := clientset.CoreV1().Pods(k8sPod.Namespace).Patch(ctx, k8sPod.Name, patchType, patchBytes, metav1.PatchOptions{}, "status"); err != nil {
+			return fmt.Errorf("failed to patch pod %s/%s: %w", k8sPod.Namespace, k8sPod.Name, err)
+		}
+		klog.Infof("Pod %s/%s: updated IP family conditions %v", k8sPod.Namespace, k8sPod.Name, results)
+	}
+
+	for family, healthy := range results {
+		pod.SetFamilyHealth(family, healthy)
+	}
+	return nil
+}
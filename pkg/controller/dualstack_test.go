@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetFamilyIPs(t *testing.T) {
+	pod := &corev1.Pod{Status: corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.0.0.1"}}}}
+	assert.Nil(t, getFamilyIPs(pod), "single-stack pods have no family IPs")
+
+	pod.Status.PodIPs = []corev1.PodIP{{IP: "10.0.0.1"}, {IP: "fd00::1"}, {IP: "fd00::2"}}
+	assert.Equal(t, map[string]string{FamilyIPv4: "10.0.0.1", FamilyIPv6: "fd00::1"}, getFamilyIPs(pod))
+}
+
+func TestDualStackConditionsReflectEachFamily(t *testing.T) {
+	// Only the IPv4 address serves the port
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	port := int32(ln.Addr().(*net.TCPAddr).Port)
+
+	k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	k8sPod.Status.PodIPs = []corev1.PodIP{{IP: "127.0.0.1"}, {IP: "::1"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(k8sPod)
+	pod := podSet.GetAvailablePods()[0]
+	pod.Ports = []int32{port}
+
+	hc := newLocalHealthChecker()
+	hc.SetDualStackConditions(true)
+
+	familyStatus := func(family string) corev1.ConditionStatus {
+		got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return conditionStatus(got, hc.familyConditionType(family))
+	}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionTrue, familyStatus(FamilyIPv4))
+	assert.Equal(t, corev1.ConditionFalse, familyStatus(FamilyIPv6))
+	assert.True(t, *pod.GetLastHealthStatus(), "the primary IPv4 result drives the pod status")
+	patches := countPatches(clientset)
+
+	// Unchanged family results are not patched again
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, patches, countPatches(clientset))
+
+	// Once IPv6 serves the port as well, its condition flips
+	ln6, err := net.Listen("tcp6", fmt.Sprintf("[::1]:%d", port))
+	if err != nil {
+		t.Skipf("port not free on ::1: %v", err)
+	}
+	defer ln6.Close()
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionTrue, familyStatus(FamilyIPv6))
+}
+
+func TestCombineFamilyResults(t *testing.T) {
+	pod := &corev1.Pod{Status: corev1.PodStatus{PodIPs: []corev1.PodIP{{IP: "10.0.0.5"}, {IP: "fd00::5"}}}}
+	assert.Equal(t, map[string]string{FamilyIPv4: "10.0.0.5", FamilyIPv6: "fd00::5"}, getFamilyIPs(pod))
+
+	up := ProbeResult{Protocol: "tcp", Healthy: true}
+	refused := errors.New("connection refused")
+	down := ProbeResult{Protocol: "tcp", Err: refused}
+
+	tests := []struct {
+		name        string
+		ipv4, ipv6  ProbeResult
+		policy      string
+		wantHealthy bool
+	}{
+		{"primary ignores the other family", up, down, DualStackPolicyPrimary, true},
+		{"primary fails with its own family", down, up, DualStackPolicyPrimary, false},
+		{"any passes on the primary family", up, down, DualStackPolicyAny, true},
+		{"any passes on the other family", down, up, DualStackPolicyAny, true},
+		{"any fails when both fail", down, down, DualStackPolicyAny, false},
+		{"all passes when both pass", up, up, DualStackPolicyAll, true},
+		{"all fails on the other family", up, down, DualStackPolicyAll, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := map[string]ProbeResult{FamilyIPv4: tt.ipv4, FamilyIPv6: tt.ipv6}
+			got := combineFamilyResults(tt.ipv4, results, tt.policy)
+			assert.Equal(t, tt.wantHealthy, got.Healthy)
+			if !got.Healthy {
+				assert.ErrorIs(t, got.Err, refused, "the failing family's error is kept for classification")
+			}
+		})
+	}
+
+	// The failing family is named in the error
+	got := combineFamilyResults(up, map[string]ProbeResult{FamilyIPv4: up, FamilyIPv6: down}, DualStackPolicyAll)
+	assert.Contains(t, got.Err.Error(), FamilyIPv6)
+}
+
+func TestDualStackPolicyAll(t *testing.T) {
+	// Only the IPv4 address serves the port
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	port := int32(ln.Addr().(*net.TCPAddr).Port)
+
+	k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	k8sPod.Status.PodIPs = []corev1.PodIP{{IP: "127.0.0.1"}, {IP: "::1"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(k8sPod)
+	pod := podSet.GetAvailablePods()[0]
+	pod.Ports = []int32{port}
+
+	hc := newLocalHealthChecker()
+	hc.SetDualStackPolicy(DualStackPolicyAll)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.False(t, *pod.GetLastHealthStatus(), "a broken IPv6 family fails the pod under the all policy")
+
+	hc.SetDualStackPolicy(DualStackPolicyAny)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.True(t, *pod.GetLastHealthStatus(), "one reachable family is enough under the any policy")
+}
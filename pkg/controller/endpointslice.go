@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// EndpointSlice condition modes accepted by SetEndpointSliceConditions
+const (
+	EndpointSliceConditionsOff  = "off"
+	EndpointSliceConditionsAlso = "also"
+)
+
+// EndpointSliceManager is the endpointslice.kubernetes.io/managed-by value of the slices health
+// is written into. Slices of the EndpointSlice controller are left alone, it recomputes their
+// endpoints from pod readiness on its next sync.
+const EndpointSliceManager = "endpoint-health-checker.io"
+
+// endpointConditions returns the conditions a pod's endpoints get for a health status. Like the
+// EndpointSlice controller, a terminating pod keeps serving while it is healthy but is not ready.
+func endpointConditions(pod *corev1.Pod, healthy bool) discoveryv1.EndpointConditions {
+	terminating := pod.DeletionTimestamp != nil
+	ready := healthy && !terminating
+	return discoveryv1.EndpointConditions{Ready: &ready, Serving: &healthy, Terminating: &terminating}
+}
+
+// isPodEndpoint reports whether an endpoint refers to the pod
+func isPodEndpoint(endpoint discoveryv1.Endpoint, pod *corev1.Pod) bool {
+	ref := endpoint.TargetRef
+	if ref == nil || ref.Kind != "Pod" || ref.Name != pod.Name {
+		return false
+	}
+	return ref.UID == "" || pod.UID == "" || ref.UID == pod.UID
+}
+
+// conditionsEqual reports whether two endpoint conditions are set the same, unset counting as false
+func conditionsEqual(a, b discoveryv1.EndpointConditions) bool {
+	isSet := func(v *bool) bool { return v != nil && *v }
+	return isSet(a.Ready) == isSet(b.Ready) && isSet(a.Serving) == isSet(b.Serving) &&
+		isSet(a.Terminating) == isSet(b.Terminating)
+}
+
+// updateEndpointSliceConditions writes the health status into the conditions of the pod's endpoints
+// in the EndpointSlices of its namespace managed by EndpointSliceManager, which is what kube-proxy
+// routes by. Slices already reflecting it are left alone. Endpoints are an atomic list, so each patch carries them all and
+// the slice's resourceVersion, failing on a concurrent update instead of overwriting it.
+func (hc *HealthChecker) updateEndpointSliceConditions(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, healthy bool) error {
+	slices, err := clientset.DiscoveryV1().EndpointSlices(pod.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: discoveryv1.LabelManagedBy + "=" + EndpointSliceManager,
+	})
+	hc.observeAPI(err)
+	if err != nil {
+		return fmt.Errorf("failed to list EndpointSlices of pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	want := endpointConditions(pod, healthy)
+	for _, slice := range slices.Items {
+		changed := false
+		for i := range slice.Endpoints {
+			if isPodEndpoint(slice.Endpoints[i], pod) && !conditionsEqual(slice.Endpoints[i].Conditions, want) {
+				slice.Endpoints[i].Conditions = want
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		patch := map[string]interface{}{
+			"metadata":  map[string]interface{}{"resourceVersion": slice.ResourceVersion},
+			"endpoints": slice.Endpoints,
+		}
+		patchBytes, err := json.Marshal(patch)
+		if err != nil {
+			return fmt.Errorf("failed to marshal patch: %w", err)
+		}
+		_, err = clientset.DiscoveryV1().EndpointSlices(pod.Namespace).Patch(ctx, slice.Name, types.MergePatchType, patchBytes, metav1.PatchOptions{})
+		hc.observeAPI(err)
+		if err != nil {
+			return fmt.Errorf("failed to patch EndpointSlice %s/%s: %w", pod.Namespace, slice.Name, err)
+		}
+		klog.Infof("Pod %s/%s: Set endpoint conditions in EndpointSlice %s to ready=%v serving=%v",
+			pod.Namespace, pod.Name, slice.Name, *want.Ready, *want.Serving)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestEndpointSlice(namespace, name string, podNames ...string) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, ResourceVersion: "1",
+			Labels: map[string]string{discoveryv1.LabelManagedBy: EndpointSliceManager}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	ready := true
+	for _, podName := range podNames {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryv1.EndpointConditions{Ready: &ready, Serving: &ready},
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Namespace: namespace, Name: podName},
+		})
+	}
+	return slice
+}
+
+func TestEndpointSliceConditions(t *testing.T) {
+	controllerManaged := newTestEndpointSlice("default", "web-xyz", "test-pod")
+	controllerManaged.Labels = map[string]string{discoveryv1.LabelManagedBy: "endpointslice-controller.k8s.io"}
+	clientset := fake.NewSimpleClientset(
+		newTestK8sPod("default", "test-pod", "127.0.0.1"),
+		newTestEndpointSlice("default", "web-abc", "test-pod", "other-pod"),
+		newTestEndpointSlice("default", "unrelated-abc", "other-pod"),
+		controllerManaged,
+	)
+	hc := newLocalHealthChecker()
+	hc.SetEndpointSliceConditions(EndpointSliceConditionsAlso)
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+
+	slice, err := clientset.DiscoveryV1().EndpointSlices("default").Get(context.Background(), "web-abc", metav1.GetOptions{})
+	assert.NoError(t, err)
+	conditions := slice.Endpoints[0].Conditions
+	assert.False(t, *conditions.Ready)
+	assert.False(t, *conditions.Serving)
+	assert.False(t, *conditions.Terminating)
+	assert.True(t, *slice.Endpoints[1].Conditions.Ready, "other pods' endpoints are untouched")
+
+	slice, err = clientset.DiscoveryV1().EndpointSlices("default").Get(context.Background(), "web-xyz", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, *slice.Endpoints[0].Conditions.Ready, "slices of the EndpointSlice controller are left alone")
+
+	slicePatches, podPatches := 0, 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() != "patch" {
+			continue
+		}
+		switch action.GetResource().Resource {
+		case "endpointslices":
+			slicePatches++
+		case "pods":
+			podPatches++
+		}
+	}
+	assert.Equal(t, 1, slicePatches, "slices without the pod are not patched")
+	assert.Equal(t, 1, podPatches)
+}
+
+func TestEndpointConditionsOfTerminatingPod(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	now := metav1.Now()
+	k8sPod.DeletionTimestamp = &now
+
+	conditions := endpointConditions(k8sPod, true)
+	assert.False(t, *conditions.Ready)
+	assert.True(t, *conditions.Serving)
+	assert.True(t, *conditions.Terminating)
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// EventTargetPod records transition events on the pod itself
+	EventTargetPod = "pod"
+	// EventTargetOwner records transition events on the pod's controller owner,
+	// resolving ReplicaSets to their Deployment
+	EventTargetOwner = "owner"
+
+	reasonEndpointUnhealthy = "EndpointUnhealthy"
+	reasonEndpointRecovered = "EndpointRecovered"
+)
+
+// emitTransitionEvent records a health transition of the pod, when an event recorder is set
+func (hc *HealthChecker) emitTransitionEvent(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, healthy bool) {
+	if hc.recorder == nil {
+		return
+	}
+
+	eventType, reason := corev1.EventTypeWarning, reasonEndpointUnhealthy
+	message := fmt.Sprintf("Pod %s failed endpoint health check", pod.Name)
+	if healthy {
+		eventType, reason = corev1.EventTypeNormal, reasonEndpointRecovered
+		message = fmt.Sprintf("Pod %s passed endpoint health check", pod.Name)
+	}
+
+	var target *corev1.ObjectReference
+	if hc.eventTarget == EventTargetOwner {
+		target = ownerObjectReference(ctx, clientset, pod)
+	}
+	if target == nil {
+		target = &corev1.ObjectReference{
+			Kind:       "Pod",
+			APIVersion: "v1",
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			UID:        pod.UID,
+		}
+	}
+	hc.recorder.Event(target, eventType, reason, message)
+}
+
+// ownerObjectReference builds a reference to the pod's controller owner, following a
+// ReplicaSet to the Deployment that controls it. It returns nil when the pod has no owner.
+func ownerObjectReference(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod) *corev1.ObjectReference {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return nil
+	}
+	owner := &corev1.ObjectReference{
+		Kind:       ref.Kind,
+		APIVersion: ref.APIVersion,
+		Namespace:  pod.Namespace,
+		Name:       ref.Name,
+		UID:        ref.UID,
+	}
+	if ref.Kind != "ReplicaSet" {
+		return owner
+	}
+
+	rs, err := clientset.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		klog.V(4).Infof("Failed to resolve owner of ReplicaSet %s/%s: %v", pod.Namespace, ref.Name, err)
+		return owner
+	}
+	if rsRef := metav1.GetControllerOf(rs); rsRef != nil && rsRef.Kind == "Deployment" {
+		return &corev1.ObjectReference{
+			Kind:       rsRef.Kind,
+			APIVersion: rsRef.APIVersion,
+			Namespace:  pod.Namespace,
+			Name:       rsRef.Name,
+			UID:        rsRef.UID,
+		}
+	}
+	return owner
+}
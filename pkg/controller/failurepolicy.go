@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+// Failure classes derived from probe errors
+const (
+	FailureClassTimeout     = "timeout"
+	FailureClassRefused     = "refused"
+	FailureClassUnreachable = "unreachable"
+	FailureClassTLS         = "tls_error"
+	FailureClassHTTPStatus  = "http_status"
+	FailureClassOther       = "other"
+)
+
+// classifyProbeError maps a probe error to its failure class
+func classifyProbeError(err error) string {
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return ""
+	case stderrors.Is(err, context.DeadlineExceeded), stderrors.As(err, &netErr) && netErr.Timeout():
+		return FailureClassTimeout
+	case stderrors.Is(err, syscall.ECONNREFUSED), stderrors.Is(err, syscall.ECONNRESET):
+		return FailureClassRefused
+	case stderrors.Is(err, syscall.EHOSTUNREACH), stderrors.Is(err, syscall.ENETUNREACH), stderrors.Is(err, errICMPNoResponse),
+		stderrors.Is(err, errICMPPacketLoss), stderrors.Is(err, errNoCallback):
+		return FailureClassUnreachable
+	case stderrors.Is(err, errTLSHandshake), stderrors.Is(err, errTLSHostnameMismatch),
+		stderrors.Is(err, errTLSCertUntrusted), stderrors.Is(err, errTLSCertExpiring):
+		return FailureClassTLS
+	case stderrors.Is(err, errUnexpectedHTTPResponse):
+		return FailureClassHTTPStatus
+	}
+	return FailureClassOther
+}
+
+// resultClass returns the class a check result is counted under in the metrics
+func resultClass(result ProbeResult) string {
+	if result.Healthy {
+		return metrics.ResultSuccess
+	}
+	if class := classifyProbeError(result.Err); class != "" {
+		return class
+	}
+	return FailureClassOther
+}
+
+// FailureClassPolicy controls when failures of one class flip a pod to unhealthy
+type FailureClassPolicy struct {
+	Count    int           // Consecutive failures of the class required to flip
+	Window   time.Duration // The last Count failures must fall within this window, 0 for no limit
+	WarnOnly bool          // Log the failure but never flip
+}
+
+// FailurePolicy maps failure classes to their hysteresis. Classes without an entry flip immediately.
+type FailurePolicy map[string]FailureClassPolicy
+
+// ParseFailurePolicy parses a policy such as "timeout=3/30s,refused=1,tls_error=warn"
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	policy := make(FailurePolicy)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		class, rule, ok := strings.Cut(entry, "=")
+		class, rule = strings.TrimSpace(class), strings.TrimSpace(rule)
+		if !ok || class == "" || rule == "" {
+			return nil, fmt.Errorf("invalid failure policy entry %q, expected class=count[/window] or class=warn", entry)
+		}
+		if rule == "warn" {
+			policy[class] = FailureClassPolicy{WarnOnly: true}
+			continue
+		}
+
+		countStr, windowStr, hasWindow := strings.Cut(rule, "/")
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid count in failure policy entry %q", entry)
+		}
+		classPolicy := FailureClassPolicy{Count: count}
+		if hasWindow {
+			window, err := time.ParseDuration(windowStr)
+			if err != nil || window <= 0 {
+				return nil, fmt.Errorf("invalid window in failure policy entry %q", entry)
+			}
+			classPolicy.Window = window
+		}
+		policy[class] = classPolicy
+	}
+	return policy, nil
+}
+
+// evaluate returns the health status to act on for a probe result. A failure whose class
+// has not met its policy keeps the pod at its current status.
+func (p FailurePolicy) evaluate(pod HealthCheckPodInfo, result ProbeResult, now time.Time) bool {
+	if result.Healthy {
+		pod.RecordFailureClass("", now, 0)
+		return true
+	}
+
+	class := classifyProbeError(result.Err)
+	classPolicy, exists := p[class]
+	if !exists {
+		pod.RecordFailureClass(class, now, 1)
+		return false
+	}
+
+	current := true
+	if last := pod.GetLastHealthStatus(); last != nil {
+		current = *last
+	}
+	if classPolicy.WarnOnly {
+		klog.Warningf("Pod %s/%s: %s failure ignored by policy: %v", pod.GetNamespace(), pod.GetName(), class, result.Err)
+		return current
+	}
+
+	failures := pod.RecordFailureClass(class, now, classPolicy.Count)
+	if len(failures) < classPolicy.Count {
+		klog.V(4).Infof("Pod %s/%s: %s failure %d/%d, keeping status %v",
+			pod.GetNamespace(), pod.GetName(), class, len(failures), classPolicy.Count, current)
+		return current
+	}
+	if classPolicy.Window > 0 && failures[len(failures)-1].Sub(failures[0]) > classPolicy.Window {
+		klog.V(4).Infof("Pod %s/%s: last %d %s failures span more than %v, keeping status %v",
+			pod.GetNamespace(), pod.GetName(), classPolicy.Count, class, classPolicy.Window, current)
+		return current
+	}
+	return false
+}
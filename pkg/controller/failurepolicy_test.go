@@ -0,0 +1,162 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyProbeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"net timeout", &net.OpError{Op: "dial", Err: timeoutError{}}, FailureClassTimeout},
+		{"deadline", fmt.Errorf("probe: %w", context.DeadlineExceeded), FailureClassTimeout},
+		{"refused", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, FailureClassRefused},
+		{"host unreachable", &net.OpError{Op: "dial", Err: syscall.EHOSTUNREACH}, FailureClassUnreachable},
+		{"icmp", fmt.Errorf("%w from 10.0.0.1", errICMPNoResponse), FailureClassUnreachable},
+		{"icmp loss", fmt.Errorf("%w from 10.0.0.1", errICMPPacketLoss), FailureClassUnreachable},
+		{"tls handshake", fmt.Errorf("%w: %w", errTLSHandshake, fmt.Errorf("bad certificate")), FailureClassTLS},
+		{"http status", fmt.Errorf("%w: status 503", errUnexpectedHTTPResponse), FailureClassHTTPStatus},
+		{"other", fmt.Errorf("boom"), FailureClassOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifyProbeError(tt.err))
+		})
+	}
+}
+
+func TestCheckResultClassCounters(t *testing.T) {
+	results := map[string]ProbeResult{
+		metrics.ResultSuccess:   {Healthy: true},
+		FailureClassTimeout:     {Err: &net.OpError{Op: "dial", Err: timeoutError{}}},
+		FailureClassRefused:     {Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}},
+		FailureClassUnreachable: {Err: fmt.Errorf("%w from 10.0.0.1", errICMPNoResponse)},
+		FailureClassTLS:         {Err: fmt.Errorf("%w: bad certificate", errTLSHandshake)},
+		FailureClassHTTPStatus:  {Err: fmt.Errorf("%w: status 503", errUnexpectedHTTPResponse)},
+		FailureClassOther:       {Err: fmt.Errorf("boom")},
+	}
+	const protocol, workers = "class-test", 20
+
+	for class, result := range results {
+		t.Run(class, func(t *testing.T) {
+			before := make(map[string]float64)
+			for other := range results {
+				before[other] = testutil.ToFloat64(metrics.ChecksCounter(protocol, other))
+			}
+
+			// Workers of the pool record concurrently
+			var wg sync.WaitGroup
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					metrics.ObserveCheck(context.Background(), protocol, resultClass(result), time.Millisecond)
+				}()
+			}
+			wg.Wait()
+
+			for other := range results {
+				want := before[other]
+				if other == class {
+					want += workers
+				}
+				assert.Equal(t, want, testutil.ToFloat64(metrics.ChecksCounter(protocol, other)), other)
+			}
+		})
+	}
+}
+
+func TestParseFailurePolicy(t *testing.T) {
+	policy, err := ParseFailurePolicy("timeout=3/30s, refused=1,tls_error=warn")
+	require.NoError(t, err)
+	assert.Equal(t, FailurePolicy{
+		FailureClassTimeout: {Count: 3, Window: 30 * time.Second},
+		FailureClassRefused: {Count: 1},
+		FailureClassTLS:     {WarnOnly: true},
+	}, policy)
+
+	empty, err := ParseFailurePolicy("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	for _, bad := range []string{"timeout", "timeout=0", "timeout=x", "timeout=3/abc", "=3"} {
+		_, err := ParseFailurePolicy(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestFailurePolicyEvaluate(t *testing.T) {
+	policy := FailurePolicy{
+		FailureClassTimeout: {Count: 3, Window: 30 * time.Second},
+		FailureClassRefused: {Count: 1},
+		FailureClassTLS:     {WarnOnly: true},
+	}
+	timeout := &net.OpError{Op: "dial", Err: timeoutError{}}
+	refused := &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}
+	tlsErr := fmt.Errorf("%w: expired", errTLSHandshake)
+	other := fmt.Errorf("boom")
+
+	type step struct {
+		err    error // nil means a passing probe
+		offset time.Duration
+		want   bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"timeouts flip on third within window", []step{
+			{timeout, 0, true}, {timeout, 10 * time.Second, true}, {timeout, 20 * time.Second, false},
+		}},
+		{"timeouts outside window do not flip", []step{
+			{timeout, 0, true}, {timeout, 20 * time.Second, true}, {timeout, 40 * time.Second, true},
+			{timeout, 45 * time.Second, false},
+		}},
+		{"pass resets timeout run", []step{
+			{timeout, 0, true}, {timeout, time.Second, true}, {nil, 2 * time.Second, true},
+			{timeout, 3 * time.Second, true},
+		}},
+		{"class change resets run", []step{
+			{timeout, 0, true}, {timeout, time.Second, true}, {other, 2 * time.Second, false},
+			{timeout, 3 * time.Second, false},
+		}},
+		{"refused flips immediately", []step{{refused, 0, false}}},
+		{"unlisted class flips immediately", []step{{other, 0, false}}},
+		{"tls warns without flipping", []step{
+			{tlsErr, 0, true}, {tlsErr, time.Second, true}, {tlsErr, 2 * time.Second, true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &PodInfo{Namespace: "default", Name: "pod", IP: "10.0.0.1"}
+			start := time.Now()
+			for i, s := range tt.steps {
+				result := ProbeResult{Healthy: s.err == nil, Err: s.err}
+				got := policy.evaluate(pod, result, start.Add(s.offset))
+				assert.Equal(t, s.want, got, "step %d", i)
+				pod.SetLastHealthStatus(got)
+			}
+		})
+	}
+}
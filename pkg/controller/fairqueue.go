@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"sync"
+)
+
+// fairQueue feeds tasks to the worker pool round-robin across namespaces while bounding
+// how many tasks of one namespace are in flight. A namespace full of black-holed IPs
+// then holds at most maxInFlight workers for the probe timeout, and the remaining
+// workers keep serving other namespaces.
+type fairQueue struct {
+	mu          sync.Mutex
+	maxInFlight int
+	submit      func(func())
+	queues      map[string][]func()
+	inFlight    map[string]int
+	order       []string // namespaces with queued tasks, in round-robin order
+	next        int
+	stopped     bool
+}
+
+// newFairQueue creates a queue that hands tasks to submit, at most maxInFlight per namespace
+func newFairQueue(maxInFlight int, submit func(func())) *fairQueue {
+	return &fairQueue{
+		maxInFlight: maxInFlight,
+		submit:      submit,
+		queues:      make(map[string][]func()),
+		inFlight:    make(map[string]int),
+	}
+}
+
+// Add queues a task for the namespace and submits whatever the per-namespace bound allows
+func (q *fairQueue) Add(namespace string, task func()) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.queues[namespace]) == 0 {
+		q.order = append(q.order, namespace)
+	}
+	q.queues[namespace] = append(q.queues[namespace], task)
+	q.pumpLocked()
+}
+
+// Stop discards queued tasks and stops submitting, so the worker pool can be stopped
+func (q *fairQueue) Stop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = true
+	q.queues = make(map[string][]func())
+	q.order = nil
+}
+
+// Pending returns the number of queued tasks not yet handed to the worker pool
+func (q *fairQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	pending := 0
+	for _, tasks := range q.queues {
+		pending += len(tasks)
+	}
+	return pending
+}
+
+func (q *fairQueue) done(namespace string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.inFlight[namespace]--
+	if q.inFlight[namespace] <= 0 {
+		delete(q.inFlight, namespace)
+	}
+	q.pumpLocked()
+}
+
+// pumpLocked takes one task per namespace per round until every namespace with queued
+// tasks is at its in-flight bound
+func (q *fairQueue) pumpLocked() {
+	if q.stopped {
+		return
+	}
+	for progress := true; progress && len(q.order) > 0; {
+		progress = false
+		for i := 0; i < len(q.order); {
+			if q.next >= len(q.order) {
+				q.next = 0
+			}
+			namespace := q.order[q.next]
+			if q.inFlight[namespace] >= q.maxInFlight {
+				q.next++
+				i++
+				continue
+			}
+
+			task := q.queues[namespace][0]
+			q.queues[namespace] = q.queues[namespace][1:]
+			q.inFlight[namespace]++
+			progress = true
+			q.submit(func() {
+				defer q.done(namespace)
+				task()
+			})
+
+			if len(q.queues[namespace]) == 0 {
+				delete(q.queues, namespace)
+				q.order = append(q.order[:q.next], q.order[q.next+1:]...)
+			} else {
+				q.next++
+				i++
+			}
+		}
+	}
+}
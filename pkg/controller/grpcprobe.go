@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// GRPCTarget is a gRPC Health Checking Protocol endpoint declared by a container probe
+type GRPCTarget struct {
+	Port    int32
+	Service string // Service name sent in the health check request, empty checks the whole server
+	// Method is a fully-qualified unary method called instead of the health service, e.g.
+	// "/shop.Cart/Ping". Request is its serialized request message and Expect, when set, the
+	// serialized response it must return; both are strings so targets stay comparable.
+	Method  string
+	Request string
+	Expect  string
+}
+
+var (
+	// errGRPCHealthUnimplemented reports a server that does not implement grpc.health.v1.Health
+	errGRPCHealthUnimplemented = stderrors.New("gRPC health service not implemented")
+	// errGRPCNotServing reports a health check answered with a status other than SERVING
+	errGRPCNotServing = stderrors.New("gRPC service not serving")
+	// errGRPCMethodNotFound reports a server that does not implement the probed custom method
+	errGRPCMethodNotFound = stderrors.New("gRPC method not found")
+	// errUnexpectedGRPCResponse reports a custom method response other than the expected one
+	errUnexpectedGRPCResponse = stderrors.New("unexpected gRPC response")
+)
+
+// rawCodec passes already serialized protobuf messages through, so custom methods can be
+// called without their generated types. It keeps the "proto" name so servers decode as usual.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec cannot marshal %T", v)
+	}
+	return *b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec cannot unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string { return "proto" }
+
+// getGRPCTargets collects the gRPC endpoints declared by container probes
+func getGRPCTargets(pod *corev1.Pod) []GRPCTarget {
+	var targets []GRPCTarget
+	seen := make(map[GRPCTarget]struct{})
+	for _, c := range pod.Spec.Containers {
+		for _, probe := range []*corev1.Probe{c.LivenessProbe, c.ReadinessProbe} {
+			if probe == nil || probe.GRPC == nil {
+				continue
+			}
+			target := GRPCTarget{Port: probe.GRPC.Port}
+			if probe.GRPC.Service != nil {
+				target.Service = *probe.GRPC.Service
+			}
+			if _, exists := seen[target]; !exists {
+				seen[target] = struct{}{}
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// parseGRPCMethod validates a fully-qualified method name and returns it as "/package.Service/Method"
+func parseGRPCMethod(value string) (string, error) {
+	method := "/" + strings.TrimPrefix(strings.TrimSpace(value), "/")
+	service, name, ok := strings.Cut(method[1:], "/")
+	if !ok || name == "" || strings.Contains(name, "/") || !strings.Contains(service, ".") {
+		return "", fmt.Errorf("invalid gRPC method %q, expected /package.Service/Method", value)
+	}
+	return method, nil
+}
+
+// getGRPCMethodTargets turns the pod's gRPC targets into custom method calls. A pod without
+// gRPC probes has the method called on each of its probe ports not checked over HTTP.
+func getGRPCMethodTargets(pod *corev1.Pod, targets []GRPCTarget, ports []int32, httpTargets []HTTPTarget) ([]GRPCTarget, error) {
+	method, err := parseGRPCMethod(pod.Annotations[grpcMethodAnnotation])
+	if err != nil {
+		return nil, err
+	}
+	request, err := decodeAnnotationBytes(pod.Annotations[grpcMethodRequestAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", grpcMethodRequestAnnotation, err)
+	}
+	expect, err := decodeAnnotationBytes(pod.Annotations[grpcMethodExpectAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", grpcMethodExpectAnnotation, err)
+	}
+
+	var portsToCall []int32
+	if len(targets) > 0 {
+		for _, target := range targets {
+			if !slices.Contains(portsToCall, target.Port) {
+				portsToCall = append(portsToCall, target.Port)
+			}
+		}
+	} else {
+		for _, port := range ports {
+			if !slices.ContainsFunc(httpTargets, func(t HTTPTarget) bool { return t.Port == port }) {
+				portsToCall = append(portsToCall, port)
+			}
+		}
+	}
+	methodTargets := make([]GRPCTarget, 0, len(portsToCall))
+	for _, port := range portsToCall {
+		methodTargets = append(methodTargets, GRPCTarget{Port: port, Method: method, Request: string(request), Expect: string(expect)})
+	}
+	return methodTargets, nil
+}
+
+// dialGRPC opens a plaintext connection to addr, failing when it cannot connect before ctx expires
+func dialGRPC(ctx context.Context, addr string) (*grpc.ClientConn, error) {
+	return grpc.DialContext(ctx, addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+	)
+}
+
+// grpcProbe calls grpc.health.v1.Health/Check on addr like the kubelet does, healthy only when
+// the service reports SERVING
+func grpcProbe(ctx context.Context, addr, service string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialGRPC(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return fmt.Errorf("%w on %s: %v", errGRPCHealthUnimplemented, addr, err)
+		}
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("%w: service %q on %s reported %s", errGRPCNotServing, service, addr, resp.GetStatus())
+	}
+	return nil
+}
+
+// grpcMethodProbe calls the target's custom unary method on addr, healthy when the call succeeds
+// and, if an expected response is set, returns exactly that message
+func grpcMethodProbe(ctx context.Context, addr string, target GRPCTarget, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dialGRPC(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	request := []byte(target.Request)
+	var response []byte
+	if err := conn.Invoke(ctx, target.Method, &request, &response, grpc.ForceCodec(rawCodec{})); err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return fmt.Errorf("%w: %s on %s: %v", errGRPCMethodNotFound, target.Method, addr, err)
+		}
+		return err
+	}
+	if target.Expect != "" && string(response) != target.Expect {
+		return fmt.Errorf("%w: %s on %s returned %x, expected %x", errUnexpectedGRPCResponse, target.Method, addr, response, target.Expect)
+	}
+	return nil
+}
+
+// grpcProbeWithRetry gRPC probe with retry mechanism, calling the target's custom method when set
+func grpcProbeWithRetry(ctx context.Context, addr string, target GRPCTarget, config *HealthCheckConfig) error {
+	return probeWithRetry(ctx, "gRPC", addr, config, func(ctx context.Context, addr string, timeout time.Duration) error {
+		if target.Method != "" {
+			return grpcMethodProbe(ctx, addr, target, timeout)
+		}
+		return grpcProbe(ctx, addr, target.Service, timeout)
+	})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// startGRPCServer serves gRPC on 127.0.0.1, with the health service when healthServer is set
+func startGRPCServer(t *testing.T, healthServer *health.Server) int32 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	if healthServer != nil {
+		healthpb.RegisterHealthServer(server, healthServer)
+	}
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+	return int32(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestGRPCProbe(t *testing.T) {
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("db", healthpb.HealthCheckResponse_NOT_SERVING)
+	addr := fmt.Sprintf("127.0.0.1:%d", startGRPCServer(t, healthServer))
+
+	assert.NoError(t, grpcProbe(context.Background(), addr, "", time.Second))
+	assert.ErrorIs(t, grpcProbe(context.Background(), addr, "db", time.Second), errGRPCNotServing)
+	assert.Error(t, grpcProbe(context.Background(), addr, "unknown", time.Second))
+
+	// A gRPC server without the health service is told apart from a failing one
+	bare := fmt.Sprintf("127.0.0.1:%d", startGRPCServer(t, nil))
+	assert.ErrorIs(t, grpcProbe(context.Background(), bare, "", time.Second), errGRPCHealthUnimplemented)
+
+	assert.Error(t, grpcProbe(context.Background(), fmt.Sprintf("127.0.0.1:%d", closedPort(t)), "", 100*time.Millisecond))
+}
+
+func TestGetGRPCTargets(t *testing.T) {
+	service := "db"
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name:           "app",
+		LivenessProbe:  &corev1.Probe{ProbeHandler: corev1.ProbeHandler{GRPC: &corev1.GRPCAction{Port: 9090}}},
+		ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{GRPC: &corev1.GRPCAction{Port: 9090, Service: &service}}},
+	}, {
+		Name:           "sidecar",
+		ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{GRPC: &corev1.GRPCAction{Port: 9090}}},
+	}}}}
+	assert.Equal(t, []GRPCTarget{{Port: 9090}, {Port: 9090, Service: "db"}}, getGRPCTargets(pod))
+}
+
+func TestCheckPortsUsesGRPCHealthCheck(t *testing.T) {
+	healthServer := health.NewServer()
+	port := startGRPCServer(t, healthServer)
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	pod := &PodInfo{
+		Namespace:   "default",
+		Name:        "grpc",
+		IP:          "127.0.0.1",
+		Ports:       []int32{port},
+		GRPCTargets: []GRPCTarget{{Port: port}},
+	}
+
+	result := hc.performHealthCheck(context.Background(), pod)
+	assert.Equal(t, ProtocolGRPC, result.Protocol)
+	assert.True(t, result.Healthy)
+
+	// The port still accepts connections, only the health check tells the server is not serving
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	result = hc.performHealthCheck(context.Background(), pod)
+	assert.False(t, result.Healthy)
+	assert.ErrorIs(t, result.Err, errGRPCNotServing)
+}
+
+// pingServiceDesc is a stub "/test.Pinger/Ping" method echoing its StringValue request
+var pingServiceDesc = grpc.ServiceDesc{
+	ServiceName: "test.Pinger",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Ping",
+		Handler: func(_ interface{}, _ context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := &wrapperspb.StringValue{}
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return wrapperspb.String("pong " + req.GetValue()), nil
+		},
+	}},
+}
+
+func TestGRPCMethodProbe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	server.RegisterService(&pingServiceDesc, struct{}{})
+	go func() { _ = server.Serve(ln) }()
+	t.Cleanup(server.Stop)
+	addr := ln.Addr().String()
+
+	request, err := proto.Marshal(wrapperspb.String("probe"))
+	require.NoError(t, err)
+	expect, err := proto.Marshal(wrapperspb.String("pong probe"))
+	require.NoError(t, err)
+
+	assert.NoError(t, grpcMethodProbe(context.Background(), addr, GRPCTarget{Method: "/test.Pinger/Ping"}, time.Second),
+		"an empty request is a valid message and any successful call passes")
+	assert.NoError(t, grpcMethodProbe(context.Background(), addr, GRPCTarget{Method: "/test.Pinger/Ping", Request: string(request), Expect: string(expect)}, time.Second))
+	assert.ErrorIs(t, grpcMethodProbe(context.Background(), addr, GRPCTarget{Method: "/test.Pinger/Ping", Expect: string(expect)}, time.Second),
+		errUnexpectedGRPCResponse)
+	assert.ErrorIs(t, grpcMethodProbe(context.Background(), addr, GRPCTarget{Method: "/test.Pinger/Missing"}, time.Second), errGRPCMethodNotFound)
+	assert.ErrorIs(t, grpcMethodProbe(context.Background(), addr, GRPCTarget{Method: "/test.Other/Ping"}, time.Second), errGRPCMethodNotFound)
+}
+
+func TestGetGRPCMethodTargets(t *testing.T) {
+	pod := newReadyPod("default", "cart", "10.0.0.1", map[string]string{
+		grpcMethodAnnotation:        "test.Pinger/Ping",
+		grpcMethodExpectAnnotation:  "0x0a04706f6e67",
+		grpcMethodRequestAnnotation: "",
+	})
+	targets, err := getGRPCMethodTargets(pod, nil, []int32{8080, 9090}, []HTTPTarget{{Port: 8080}})
+	require.NoError(t, err)
+	assert.Equal(t, []GRPCTarget{{Port: 9090, Method: "/test.Pinger/Ping", Expect: "\x0a\x04pong"}}, targets)
+
+	targets, err = getGRPCMethodTargets(pod, []GRPCTarget{{Port: 9000}, {Port: 9000, Service: "db"}}, []int32{9000, 9090}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []GRPCTarget{{Port: 9000, Method: "/test.Pinger/Ping", Expect: "\x0a\x04pong"}}, targets)
+
+	for _, invalid := range []string{"Ping", "/Pinger/Ping", "/test.Pinger/", "/test.Pinger/Ping/Extra"} {
+		_, err := parseGRPCMethod(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -1,10 +1,17 @@
 package controller
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"math/rand/v2"
 	"net"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	goping "github.com/prometheus-community/pro-bing"
@@ -13,7 +20,12 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+
+	"endpoint_health_checker/pkg/metrics"
 )
 
 type HealthCheckPodInfo interface {
@@ -21,43 +33,243 @@ type HealthCheckPodInfo interface {
 	GetName() string
 	GetIP() string
 	GetPorts() []int32
-	SetIsBeingChecked(checked bool)
 	GetLastHealthStatus() *bool
 	SetLastHealthStatus(status bool)
+	ClearLastHealthStatus()
+	IsTerminating() bool
+	GetTLSOptions() *TLSProbeOptions
+	GetLastAssertTime() time.Time
+	SetLastAssertTime(t time.Time)
+	GetHTTPTargets() []HTTPTarget
+	GetHTTPOptions() HTTPRequestOptions
+	GetProbeThresholds() (failure, success int32)
+	RecordProbeResult(healthy bool) (failures, successes int32)
+	GetPassingSince() time.Time
+	GetCheckInterval() time.Duration
+	SetCheckInterval(d time.Duration)
+	GetBaseInterval() time.Duration
+	GetProbeTimeout() time.Duration
+	GetRetryCount() *int
+	GetTimeoutStreak() int32
+	SetTimeoutStreak(n int32)
+	RecordFailureClass(class string, at time.Time, keep int) []time.Time
+	GetLabels() map[string]string
+	CanTransition(at time.Time, window time.Duration, max int) bool
+	RecordTransition(at time.Time)
+	RecordCheck(record CheckRecord, size int)
+	GetFamilyIPs() map[string]string
+	GetFamilyHealth(family string) *bool
+	SetFamilyHealth(family string, healthy bool)
+	RecordPortsUp(ports []int32) (pending []int32)
+	GetNodeName() string
+	GetContainersReady() bool
+	GetProbeChain() []string
+	GetProxyProtocol() string
+	GetAdoptedAt() time.Time
+	GetGRPCTargets() []GRPCTarget
+	GetUDPOptions() *UDPProbeOptions
+	GetCallback() *HTTPTarget
+}
+
+// Probe protocols reported in check results
+const (
+	ProtocolTCP  = "tcp"
+	ProtocolTLS  = "tls"
+	ProtocolHTTP = "http"
+	ProtocolICMP = "icmp"
+	ProtocolGRPC = "grpc"
+	ProtocolUDP  = "udp"
+)
+
+// ProbeResult is the outcome of one health check of a pod
+type ProbeResult struct {
+	Protocol string
+	Healthy  bool
+	Latency  time.Duration
+	Err      error
+	PortsUp  []int32 // Declared ports that passed
 }
 
+// Status patch types accepted by SetStatusPatchType
+const (
+	StatusPatchTypeMerge     = "merge"
+	StatusPatchTypeStrategic = "strategic"
+)
+
+// Dual-stack policies accepted by SetDualStackPolicy
+const (
+	DualStackPolicyPrimary = "primary"
+	DualStackPolicyAny     = "any"
+	DualStackPolicyAll     = "all"
+)
+
 // HealthCheckConfig health check configuration
 type HealthCheckConfig struct {
 	RetryCount   int           // Retry count
 	ProbeTimeout time.Duration // Single probe timeout
+	HedgedProbes int           // Concurrent probes per attempt, the first success wins
+	ICMPLimiter  *probeLimiter // Bounds concurrent ICMP operations across workers, nil for no bound
+	// ICMPPacketCount is the number of echo requests per ICMP attempt, 0 sends one
+	ICMPPacketCount int
+	// ICMPLossThreshold is the loss percentage at which an ICMP attempt fails, 0 fails only without any reply
+	ICMPLossThreshold float64
+	// ICMPSocket picks privileged or unprivileged ICMP sockets, nil always uses privileged ones
+	ICMPSocket  *icmpSocket
+	TCPHalfOpen bool // TCP probes write a byte and require a response or close, not just a connect
+	// ProxyProtocol is the PROXY protocol version whose header TCP probes send first, empty sends none
+	ProxyProtocol string
+	// TimeoutJitter randomizes each attempt's timeout within ±this fraction of ProbeTimeout, so
+	// retries don't stay in step with periodic packet loss, 0 disables
+	TimeoutJitter float64
+	// TLSCertMinTTL fails TLS and HTTPS probes whose certificate expires within this window, 0 disables
+	TLSCertMinTTL time.Duration
+}
+
+// attemptTimeout returns the timeout of one probe attempt, jittered when configured
+func (c *HealthCheckConfig) attemptTimeout() time.Duration {
+	if c.TimeoutJitter <= 0 {
+		return c.ProbeTimeout
+	}
+	factor := 1 + c.TimeoutJitter*(2*rand.Float64()-1)
+	return time.Duration(float64(c.ProbeTimeout) * factor)
 }
 
 // HealthChecker handles health check configuration and execution
 type HealthChecker struct {
-	healthCheckInterval time.Duration
-	healthCheckTimeout  time.Duration
+	// Reloadable at runtime, so read by workers while a configuration reload sets them
+	healthCheckInterval atomic.Int64 // time.Duration
+	healthCheckTimeout  atomic.Int64 // time.Duration
+	retryCount          atomic.Int64
 	workerCount         int
-	retryCount          int
+	rejectUnsafeTargets bool
+	breaker             *FailureRateBreaker
+	resultWriter        *ResultWriter
+	startupDelay        time.Duration
+	statusPatchType     string
+	hedgedProbes        int
+	patchTerminating    bool
+	verifyIPOwnership   bool
+	// statusReassertInterval re-verifies conditions even when the status is unchanged
+	statusReassertInterval time.Duration
+	// namespaceMaxInFlight bounds concurrent checks per namespace, 0 disables fair dispatch
+	namespaceMaxInFlight int
+	// sampleRate is the fraction of pods checked per tick, 1 checks every pod
+	sampleRate float64
+	// recoveryGuard is how long probes must pass continuously before a failed pod is healthy again
+	recoveryGuard time.Duration
+	// serviceCheckBudget caps checks per tick, keeping one endpoint per Service fresh, 0 disables
+	serviceCheckBudget int
+	// recorder emits transition events on eventTarget, nil disables events
+	recorder    record.EventRecorder
+	eventTarget string
+	// Each consecutive success multiplies a pod's check interval, capped at healthyIntervalMax
+	healthyIntervalMultiplier float64
+	healthyIntervalMax        time.Duration
+	// The scheduler ticks at idleInterval once no pod was tracked for idleAfter, 0 disables
+	idleAfter    time.Duration
+	idleInterval time.Duration
+	// proxyClient routes HTTP probes through the API server pod proxy, nil dials pods directly
+	proxyClient rest.Interface
+	// failurePolicy adds per failure class hysteresis before a failure flips a pod
+	failurePolicy FailurePolicy
+	// shutdownGrace is how long in-flight checks may keep running to finish their patch at shutdown
+	shutdownGrace time.Duration
+	// quorum aggregates reachability reports of DaemonSet instances, nil acts on local results
+	quorum *reachabilityQuorum
+	// autoStretchInterval lets the scheduler stretch the interval when scans can't complete within it
+	autoStretchInterval bool
+	// tcpHalfOpenCheck makes TCP probes verify the app accepted the connection
+	tcpHalfOpenCheck bool
+	// tlsCertMinTTL fails TLS and HTTPS probes whose certificate expires sooner, 0 disables
+	tlsCertMinTTL time.Duration
+	// allowedProbeCIDRs restricts probe targets to these networks, empty allows any address
+	allowedProbeCIDRs []*net.IPNet
+	// adoptionWarmup only records metrics for newly adopted pods for this long, 0 disables
+	adoptionWarmup time.Duration
+	// controlPlane pauses status writes while API calls fail cluster-wide, nil never pauses them
+	controlPlane *ControlPlaneGuard
+	// requireKubeletReady only considers a pod healthy when kubelet also reports its containers Ready
+	requireKubeletReady bool
+	// zoneTimeouts sets per-pod timeouts by zone relative to the checker, nil uses healthCheckTimeout
+	zoneTimeouts *zoneTimeouts
+	// probeEgressRate is the steady rate checks are started at, 0 starts them in bursts per tick
+	probeEgressRate float64
+	// requireAllPortsOnAdoption keeps a pod pending until each declared port passed once
+	requireAllPortsOnAdoption bool
+	// portRemap maps declared ports to the port actually probed
+	portRemap map[int32]int32
+	// dualStackConditions probes each IP family of dual-stack pods and reports it in its own condition
+	dualStackConditions bool
+	// dualStackPolicy combines the IP families of dual-stack pods into their health
+	dualStackPolicy string
+	// endpointSliceConditions writes health into the pod's EndpointSlice endpoints too (also), off
+	// or empty leaves EndpointSlices alone
+	endpointSliceConditions string
+	// uncertainty reports failures as Unknown while results can't be trusted, nil reports them as False
+	uncertainty *uncertaintyTracker
+	// probeTimeoutJitter randomizes TCP and ICMP attempt timeouts within ±this fraction
+	probeTimeoutJitter float64
+	// icmpLimiter bounds concurrent ICMP operations, which hold ping sockets, nil for no bound
+	icmpLimiter *probeLimiter
+	// icmpPacketCount is the number of echo requests each ICMP attempt sends
+	icmpPacketCount int
+	// icmpLossThreshold is the packet loss percentage at which an ICMP attempt fails
+	icmpLossThreshold float64
+	// icmpSocket picks privileged or unprivileged ICMP sockets, nil always uses privileged ones
+	icmpSocket *icmpSocket
+	// callbacks receives the calls back of pods with a callback endpoint, nil skips the reverse check
+	callbacks *CallbackListener
+	// serviceGuard defers unhealthy transitions that would leave a Service with too few healthy endpoints
+	serviceGuard *serviceGuard
+	// serviceDebounce holds unhealthy transitions while many endpoints of a Service fail together
+	serviceDebounce *serviceDebouncer
+	// maxTransitionsPerHour pins a flapping pod to its current status once exceeded, 0 disables
+	maxTransitionsPerHour int
+	// checkHistorySize is how many of its latest checks each pod keeps, 0 keeps none
+	checkHistorySize int
+	// After timeoutEscalationAfter consecutive timeouts a pod's probe timeout doubles per further
+	// timeout up to timeoutEscalationMax, telling slow pods from dead ones; 0 disables
+	timeoutEscalationAfter int
+	timeoutEscalationMax   time.Duration
+	// readinessGateType is the readiness gate condition check results are written to
+	readinessGateType string
 }
 
 // NewHealthChecker creates a new health checker
 func NewHealthChecker() *HealthChecker {
-	return &HealthChecker{
-		healthCheckInterval: 1 * time.Second,
-		healthCheckTimeout:  1 * time.Second,
+	hc := &HealthChecker{
 		workerCount:         10,
-		retryCount:          3,
+		rejectUnsafeTargets: true,
+		statusPatchType:     StatusPatchTypeMerge,
+		dualStackPolicy:     DualStackPolicyPrimary,
+		hedgedProbes:        1,
+		sampleRate:          1,
+		icmpPacketCount:     1,
+		icmpLossThreshold:   100,
+		readinessGateType:   DefaultReadinessGateType,
+	}
+	hc.healthCheckInterval.Store(int64(time.Second))
+	hc.healthCheckTimeout.Store(int64(time.Second))
+	hc.retryCount.Store(3)
+	return hc
+}
+
+// SetReadinessGateType sets the readiness gate condition driven by check results, empty keeps
+// the default
+func (hc *HealthChecker) SetReadinessGateType(gateType string) {
+	if gateType != "" {
+		hc.readinessGateType = gateType
 	}
 }
 
 // SetHealthCheckInterval sets health check interval
 func (hc *HealthChecker) SetHealthCheckInterval(interval time.Duration) {
-	hc.healthCheckInterval = interval
+	hc.healthCheckInterval.Store(int64(interval))
 }
 
 // SetHealthCheckTimeout sets health check timeout
 func (hc *HealthChecker) SetHealthCheckTimeout(timeout time.Duration) {
-	hc.healthCheckTimeout = timeout
+	hc.healthCheckTimeout.Store(int64(timeout))
 }
 
 // SetWorkerCount sets health check worker count
@@ -67,21 +279,297 @@ func (hc *HealthChecker) SetWorkerCount(count int) {
 	}
 }
 
-// SetRetryCount sets health check retry count
+// SetRetryCount sets health check retry count, 0 disables retries
 func (hc *HealthChecker) SetRetryCount(count int) {
+	if count >= 0 {
+		hc.retryCount.Store(int64(count))
+	}
+}
+
+// SetRejectUnsafeTargets sets whether loopback, link-local and unspecified pod IPs are refused
+func (hc *HealthChecker) SetRejectUnsafeTargets(reject bool) {
+	hc.rejectUnsafeTargets = reject
+}
+
+// SetFailureRateBreaker sets the breaker that pauses status updates during widespread failures
+func (hc *HealthChecker) SetFailureRateBreaker(breaker *FailureRateBreaker) {
+	hc.breaker = breaker
+}
+
+// SetResultWriter sets the writer that receives every check result
+func (hc *HealthChecker) SetResultWriter(writer *ResultWriter) {
+	hc.resultWriter = writer
+}
+
+// SetStartupDelay sets how long after pod creation the first probe is allowed
+func (hc *HealthChecker) SetStartupDelay(delay time.Duration) {
+	hc.startupDelay = delay
+}
+
+// SetStatusPatchType sets how condition updates are sent, either merge or strategic
+func (hc *HealthChecker) SetStatusPatchType(patchType string) {
+	hc.statusPatchType = patchType
+}
+
+// SetHedgedProbes sets how many probes are fired concurrently per attempt
+func (hc *HealthChecker) SetHedgedProbes(count int) {
 	if count > 0 {
-		hc.retryCount = count
+		hc.hedgedProbes = count
+	}
+}
+
+// SetPatchTerminating sets whether pods with a deletion timestamp still get status updates
+func (hc *HealthChecker) SetPatchTerminating(patch bool) {
+	hc.patchTerminating = patch
+}
+
+// SetVerifyIPOwnership sets whether IP ownership is confirmed via the lister before probing
+func (hc *HealthChecker) SetVerifyIPOwnership(verify bool) {
+	hc.verifyIPOwnership = verify
+}
+
+// SetStatusReassertInterval sets how often unchanged conditions are re-verified, 0 disables
+func (hc *HealthChecker) SetStatusReassertInterval(interval time.Duration) {
+	hc.statusReassertInterval = interval
+}
+
+// SetNamespaceMaxInFlight sets the per-namespace bound on concurrent checks, 0 disables
+func (hc *HealthChecker) SetNamespaceMaxInFlight(max int) {
+	hc.namespaceMaxInFlight = max
+}
+
+// SetSampleRate sets the fraction of pods checked per tick
+func (hc *HealthChecker) SetSampleRate(rate float64) {
+	hc.sampleRate = rate
+}
+
+// SetRecoveryGuard sets how long probes must pass continuously before a failed pod recovers, 0 disables
+func (hc *HealthChecker) SetRecoveryGuard(guard time.Duration) {
+	hc.recoveryGuard = guard
+}
+
+// SetServiceCheckBudget sets the per-tick check budget used for Service coalescing, 0 disables
+func (hc *HealthChecker) SetServiceCheckBudget(budget int) {
+	hc.serviceCheckBudget = budget
+}
+
+// SetEventRecorder enables transition events, recorded on the pod or its controller owner
+func (hc *HealthChecker) SetEventRecorder(recorder record.EventRecorder, target string) {
+	hc.recorder = recorder
+	hc.eventTarget = target
+}
+
+// SetHealthyIntervalBackoff sets how much each consecutive success stretches a pod's
+// check interval and the cap on that interval; a multiplier of 1 or less disables it
+func (hc *HealthChecker) SetHealthyIntervalBackoff(multiplier float64, max time.Duration) {
+	hc.healthyIntervalMultiplier = multiplier
+	hc.healthyIntervalMax = max
+}
+
+// SetIdleBackoff makes the scheduler tick at interval instead of the check interval once no
+// pod has been tracked for after, until a pod is added; an interval of 0 disables it
+func (hc *HealthChecker) SetIdleBackoff(after, interval time.Duration) {
+	hc.idleAfter = after
+	hc.idleInterval = interval
+}
+
+// SetProxyClient routes HTTP probes through the API server pod proxy using the given
+// core/v1 REST client, nil dials pod IPs directly
+func (hc *HealthChecker) SetProxyClient(client rest.Interface) {
+	hc.proxyClient = client
+}
+
+// SetFailurePolicy sets the per failure class hysteresis, nil flips on every failure
+func (hc *HealthChecker) SetFailurePolicy(policy FailurePolicy) {
+	hc.failurePolicy = policy
+}
+
+// SetShutdownGrace sets how long in-flight checks may run after the scheduler stops, 0 cancels them at once
+func (hc *HealthChecker) SetShutdownGrace(grace time.Duration) {
+	hc.shutdownGrace = grace
+}
+
+// SetReachabilityQuorum makes this instance report its results for the given node on each
+// pod and mark pods unhealthy only when quorum nodes reported them unreachable within maxAge.
+// The reports are read from the lister's cache, nil reads them from the API server.
+func (hc *HealthChecker) SetReachabilityQuorum(lister v1.PodLister, node string, quorum int, maxAge time.Duration) {
+	hc.quorum = &reachabilityQuorum{node: node, quorum: quorum, maxAge: maxAge, lister: lister}
+}
+
+// SetMaxTransitionsPerHour caps the status transitions of a pod within a rolling hour, pinning
+// a flapping pod to its current status until older transitions age out
+func (hc *HealthChecker) SetMaxTransitionsPerHour(max int) {
+	hc.maxTransitionsPerHour = max
+}
+
+// SetCheckHistorySize sets how many of its latest checks each pod keeps for the history API
+func (hc *HealthChecker) SetCheckHistorySize(size int) {
+	hc.checkHistorySize = size
+}
+
+// SetTimeoutEscalation doubles a pod's probe timeout for each timeout after the first after
+// consecutive ones, up to max, so a slow but alive pod eventually answers instead of timing
+// out forever; after of 0 disables it
+func (hc *HealthChecker) SetTimeoutEscalation(after int, max time.Duration) {
+	hc.timeoutEscalationAfter = after
+	hc.timeoutEscalationMax = max
+}
+
+// SetMinHealthyPerService defers marking a pod unhealthy when a Service selecting it would be
+// left with fewer than minHealthy healthy tracked endpoints
+func (hc *HealthChecker) SetMinHealthyPerService(lister v1.ServiceLister, podSet *PodSet, minHealthy int) {
+	hc.serviceGuard = &serviceGuard{lister: lister, podSet: podSet, minHealthy: minHealthy}
+}
+
+// SetServiceDebounce holds unhealthy transitions for delay once at least threshold endpoints
+// of a Service failed within window, so a shared dependency blip does not take the whole
+// Service down at once
+func (hc *HealthChecker) SetServiceDebounce(lister v1.ServiceLister, threshold int, window, delay time.Duration) {
+	hc.serviceDebounce = newServiceDebouncer(lister, threshold, window, delay)
+}
+
+// SetAutoStretchInterval sets whether the scheduler stretches the interval to the achievable scan time
+func (hc *HealthChecker) SetAutoStretchInterval(enabled bool) {
+	hc.autoStretchInterval = enabled
+}
+
+// SetAllowedProbeCIDRs restricts probing to pod IPs within the given networks, empty allows any address
+func (hc *HealthChecker) SetAllowedProbeCIDRs(cidrs []*net.IPNet) {
+	hc.allowedProbeCIDRs = cidrs
+}
+
+// SetAdoptionWarmup sets how long after adoption a pod's results only feed the metrics, with
+// status patches and events suppressed while the app warms up
+func (hc *HealthChecker) SetAdoptionWarmup(warmup time.Duration) {
+	hc.adoptionWarmup = warmup
+}
+
+// SetControlPlaneGuard sets the guard that pauses status writes while the control plane is degraded
+func (hc *HealthChecker) SetControlPlaneGuard(guard *ControlPlaneGuard) {
+	hc.controlPlane = guard
+}
+
+// SetRequireKubeletReady sets whether a pod is only healthy when both kubelet reports all its
+// containers Ready and the external probe passes, catching pods that pass their in-pod probes
+// but are unreachable from the network
+func (hc *HealthChecker) SetRequireKubeletReady(enabled bool) {
+	hc.requireKubeletReady = enabled
+}
+
+// SetZoneTimeouts probes pods in the checker's zone with sameZone and pods in other zones with
+// crossZone, resolving zones from the nodes' topology labels. A zero timeout, or a zone that
+// can't be resolved, falls back to the health check timeout.
+func (hc *HealthChecker) SetZoneTimeouts(lister v1.NodeLister, localNode string, sameZone, crossZone time.Duration) {
+	hc.zoneTimeouts = &zoneTimeouts{lister: lister, localNode: localNode, sameZone: sameZone, crossZone: crossZone}
+}
+
+// SetProbeEgressRate shapes check starts to a steady stream of perSecond checks, smoothing the
+// burst of probes each tick would otherwise send, 0 disables shaping
+func (hc *HealthChecker) SetProbeEgressRate(perSecond float64) {
+	hc.probeEgressRate = perSecond
+}
+
+// SetRequireAllPortsOnAdoption sets whether a newly tracked pod stays pending, with its status
+// left alone, until every declared port has been reachable at least once
+func (hc *HealthChecker) SetRequireAllPortsOnAdoption(enabled bool) {
+	hc.requireAllPortsOnAdoption = enabled
+}
+
+// SetPortRemap sets the table of declared ports probed on another port, such as a uniform
+// sidecar admin port
+func (hc *HealthChecker) SetPortRemap(remap map[int32]int32) {
+	hc.portRemap = remap
+}
+
+// SetDualStackConditions sets whether dual-stack pods are probed on each IP family, reported in
+// the readiness gate type suffixed with IPv4 and IPv6, endpointHealthCheckSuccessIPv4 and
+// endpointHealthCheckSuccessIPv6 by default
+func (hc *HealthChecker) SetDualStackConditions(enabled bool) {
+	hc.dualStackConditions = enabled
+}
+
+// SetDualStackPolicy sets how dual-stack pods are judged: on their primary IP only, healthy when
+// any family passes, or healthy only when all families pass
+func (hc *HealthChecker) SetDualStackPolicy(policy string) {
+	hc.dualStackPolicy = policy
+}
+
+// SetEndpointSliceConditions sets whether health is also (also) written into the Ready, Serving
+// and Terminating conditions of the pod's endpoints in EndpointSlices managed by EndpointSliceManager
+func (hc *HealthChecker) SetEndpointSliceConditions(mode string) {
+	hc.endpointSliceConditions = mode
+}
+
+// SetUnknownOnUncertainty sets whether failed pods get Unknown instead of False conditions while
+// the failure-rate breaker is open or shortly after the API server was unreachable
+func (hc *HealthChecker) SetUnknownOnUncertainty(enabled bool) {
+	if !enabled {
+		hc.uncertainty = nil
+		return
+	}
+	hc.uncertainty = newUncertaintyTracker()
+}
+
+// SetProbeTimeoutJitter randomizes each TCP and ICMP probe attempt's timeout within ±jitter of the
+// configured timeout, decorrelating retries from periodic packet loss
+func (hc *HealthChecker) SetProbeTimeoutJitter(jitter float64) {
+	hc.probeTimeoutJitter = jitter
+}
+
+// SetTCPHalfOpenCheck sets whether TCP probes send a byte after connecting and expect the app to
+// answer or close the connection, detecting apps whose connections sit unaccepted in the backlog
+func (hc *HealthChecker) SetTCPHalfOpenCheck(enabled bool) {
+	hc.tcpHalfOpenCheck = enabled
+}
+
+// SetTLSCertMinTTL fails TLS and HTTPS probes whose served certificate expires within ttl, so
+// renewals that did not roll out are caught before clients see expired certificates
+func (hc *HealthChecker) SetTLSCertMinTTL(ttl time.Duration) {
+	hc.tlsCertMinTTL = ttl
+}
+
+// SetMaxInFlightICMP bounds concurrent ICMP operations across all workers, 0 leaves them unbounded
+func (hc *HealthChecker) SetMaxInFlightICMP(max int) {
+	if max > 0 {
+		hc.icmpLimiter = newProbeLimiter(max)
+	} else {
+		hc.icmpLimiter = nil
+	}
+}
+
+// SetICMPPrivileged sets whether ICMP probes use privileged raw sockets ("true"), unprivileged
+// datagram sockets ("false"), or raw sockets falling back to datagram ones when CAP_NET_RAW is
+// missing ("auto")
+func (hc *HealthChecker) SetICMPPrivileged(mode string) {
+	hc.icmpSocket = newICMPSocket(mode)
+}
+
+// SetCallbackListener enables mutual reachability probes: pods with a callback endpoint are also
+// asked to call the checker back through listener, catching networks that only work one way
+func (hc *HealthChecker) SetCallbackListener(listener *CallbackListener) {
+	hc.callbacks = listener
+}
+
+// SetICMPPackets sets how many echo requests each ICMP attempt sends and the loss percentage at
+// which it fails, so a single dropped packet doesn't fail a pod. A threshold of 0 fails only
+// without any reply.
+func (hc *HealthChecker) SetICMPPackets(count int, lossThreshold float64) {
+	if count > 0 {
+		hc.icmpPacketCount = count
+	}
+	if lossThreshold >= 0 {
+		hc.icmpLossThreshold = lossThreshold
 	}
 }
 
 // GetHealthCheckInterval gets health check interval
 func (hc *HealthChecker) GetHealthCheckInterval() time.Duration {
-	return hc.healthCheckInterval
+	return time.Duration(hc.healthCheckInterval.Load())
 }
 
 // GetHealthCheckTimeout gets health check timeout
 func (hc *HealthChecker) GetHealthCheckTimeout() time.Duration {
-	return hc.healthCheckTimeout
+	return time.Duration(hc.healthCheckTimeout.Load())
 }
 
 // GetWorkerCount gets health check worker count
@@ -91,76 +579,593 @@ func (hc *HealthChecker) GetWorkerCount() int {
 
 // GetRetryCount gets health check retry count
 func (hc *HealthChecker) GetRetryCount() int {
-	return hc.retryCount
+	return int(hc.retryCount.Load())
+}
+
+// GetStartupDelay gets the delay after pod creation before the first probe
+func (hc *HealthChecker) GetStartupDelay() time.Duration {
+	return hc.startupDelay
+}
+
+// GetNamespaceMaxInFlight gets the per-namespace bound on concurrent checks
+func (hc *HealthChecker) GetNamespaceMaxInFlight() int {
+	return hc.namespaceMaxInFlight
+}
+
+// GetSampleRate gets the fraction of pods checked per tick
+func (hc *HealthChecker) GetSampleRate() float64 {
+	return hc.sampleRate
+}
+
+// GetServiceCheckBudget gets the per-tick check budget used for Service coalescing
+func (hc *HealthChecker) GetServiceCheckBudget() int {
+	return hc.serviceCheckBudget
+}
+
+// GetAutoStretchInterval gets whether the scheduler stretches the interval under load
+func (hc *HealthChecker) GetAutoStretchInterval() bool {
+	return hc.autoStretchInterval
+}
+
+// GetIdleBackoff gets how long the pod set must stay empty and the interval the scheduler then ticks at
+func (hc *HealthChecker) GetIdleBackoff() (time.Duration, time.Duration) {
+	return hc.idleAfter, hc.idleInterval
+}
+
+// GetAllowedProbeCIDRs gets the networks probe targets are restricted to
+func (hc *HealthChecker) GetAllowedProbeCIDRs() []*net.IPNet {
+	return hc.allowedProbeCIDRs
+}
+
+// GetAdoptionWarmup gets how long after adoption status updates are suppressed
+func (hc *HealthChecker) GetAdoptionWarmup() time.Duration {
+	return hc.adoptionWarmup
+}
+
+// GetRequireKubeletReady gets whether kubelet container readiness is combined with the probe
+func (hc *HealthChecker) GetRequireKubeletReady() bool {
+	return hc.requireKubeletReady
+}
+
+// GetProbeEgressRate gets the rate checks are started at, 0 if unshaped
+func (hc *HealthChecker) GetProbeEgressRate() float64 {
+	return hc.probeEgressRate
+}
+
+// GetRequireAllPortsOnAdoption gets whether adoption waits for every declared port
+func (hc *HealthChecker) GetRequireAllPortsOnAdoption() bool {
+	return hc.requireAllPortsOnAdoption
+}
+
+// GetPortRemap gets the declared to probed port remapping table
+func (hc *HealthChecker) GetPortRemap() map[int32]int32 {
+	return hc.portRemap
+}
+
+// GetDualStackConditions gets whether per-family conditions are set on dual-stack pods
+func (hc *HealthChecker) GetDualStackConditions() bool {
+	return hc.dualStackConditions
+}
+
+// GetICMPSocketMode describes the sockets ICMP probes currently use
+func (hc *HealthChecker) GetICMPSocketMode() string {
+	return hc.icmpSocket.String()
+}
+
+// GetDualStackPolicy gets how the IP families of dual-stack pods combine into their health
+func (hc *HealthChecker) GetDualStackPolicy() string {
+	return hc.dualStackPolicy
+}
+
+// GetUnknownOnUncertainty gets whether untrusted failures are reported as Unknown
+func (hc *HealthChecker) GetUnknownOnUncertainty() bool {
+	return hc.uncertainty != nil
+}
+
+// GetMaxTransitionsPerHour gets the per-pod status transition budget
+func (hc *HealthChecker) GetMaxTransitionsPerHour() int {
+	return hc.maxTransitionsPerHour
+}
+
+// GetShutdownGrace gets how long in-flight checks may run after the scheduler stops
+func (hc *HealthChecker) GetShutdownGrace() time.Duration {
+	return hc.shutdownGrace
+}
+
+// GetVerifyIPOwnership gets whether IP ownership is confirmed before probing
+func (hc *HealthChecker) GetVerifyIPOwnership() bool {
+	return hc.verifyIPOwnership
+}
+
+// GetRejectUnsafeTargets gets whether unsafe pod IPs are refused
+func (hc *HealthChecker) GetRejectUnsafeTargets() bool {
+	return hc.rejectUnsafeTargets
 }
 
 // CheckPod performs health check on a pod
 func (hc *HealthChecker) CheckPod(ctx context.Context, clientset kubernetes.Interface, pod HealthCheckPodInfo) error {
 	// Check if context is already canceled
 	if err := ctx.Err(); err != nil {
-		pod.SetIsBeingChecked(false)
+		return err
+	}
+
+	// Refuse to probe addresses that can never belong to a remote pod, marking the status unknown
+	if hc.rejectUnsafeTargets {
+		if err := validateProbeTarget(pod.GetIP()); err != nil {
+			return hc.markPodStatusUnknown(ctx, clientset, pod, fmt.Sprintf("%v, not probed", err))
+		}
+	}
+
+	// Refuse to probe addresses outside the pod CIDRs, they may be spoofed or misreported
+	if !hc.probeTargetAllowed(pod.GetIP()) {
+		err := hc.markPodStatusUnknown(ctx, clientset, pod, fmt.Sprintf("IP %s is outside the allowed probe CIDRs, not probed", pod.GetIP()))
 		return err
 	}
 
 	// Perform health check
-	healthy := hc.performHealthCheck(pod)
+	result := hc.performHealthCheck(ctx, pod)
+
+	// Dual-stack pods are probed on each IP family, combined according to the dual-stack policy
+	var familyResults map[string]ProbeResult
+	if len(pod.GetFamilyIPs()) > 0 && (hc.dualStackConditions || hc.dualStackPolicy != DualStackPolicyPrimary) {
+		familyResults = hc.probeFamilies(ctx, pod, result)
+		result = combineFamilyResults(result, familyResults, hc.dualStackPolicy)
+	}
+
+	// A check cut short by shutdown says nothing about the pod
+	if err := ctx.Err(); err == context.Canceled {
+		return err
+	}
+	healthy := result.Healthy
+	metrics.ObserveCheck(ctx, result.Protocol, resultClass(result), result.Latency)
+	metrics.RecordAvailability(pod.GetNamespace(), pod.GetName(), result.Healthy)
+	if hc.checkHistorySize > 0 {
+		pod.RecordCheck(CheckRecord{At: time.Now(), Healthy: result.Healthy, Latency: result.Latency, Result: resultClass(result)},
+			hc.checkHistorySize)
+	}
+
+	if hc.resultWriter != nil {
+		hc.resultWriter.Write(pod, result)
+	}
+	if hc.timeoutEscalationAfter > 0 {
+		hc.recordTimeouts(pod, result)
+	}
+
+	// Adoption completes once every declared port has answered, until then the pod stays pending
+	if hc.requireAllPortsOnAdoption {
+		if pending := pod.RecordPortsUp(result.PortsUp); len(pending) > 0 {
+			klog.V(4).Infof("Pod %s/%s: adoption pending, ports %v have never been reachable, leaving status unchanged",
+				pod.GetNamespace(), pod.GetName(), pending)
+			return nil
+		}
+	}
+
+	// During the warmup after adoption results are only recorded, never enforced
+	if hc.adoptionWarmup > 0 {
+		if since := time.Since(pod.GetAdoptedAt()); since < hc.adoptionWarmup {
+			klog.V(4).Infof("Pod %s/%s: adopted %v ago, within %v warmup, recording result only (healthy=%v)",
+				pod.GetNamespace(), pod.GetName(), since.Round(time.Second), hc.adoptionWarmup, healthy)
+			return nil
+		}
+	}
+
+	// While the control plane is degraded neither write nor act on reads, only watch for recovery
+	if hc.controlPlane != nil && hc.controlPlane.Degraded() {
+		hc.controlPlane.checkRecovery(clientset)
+		if hc.controlPlane.Degraded() {
+			klog.V(4).Infof("Pod %s/%s: control plane degraded, skipping status update (healthy=%v)",
+				pod.GetNamespace(), pod.GetName(), healthy)
+			metrics.RecordStatusWritePaused()
+			return nil
+		}
+	}
+
+	// Keep probing while the breaker is open, but don't act on the results
+	if hc.breaker != nil {
+		hc.breaker.Record(healthy)
+		if hc.breaker.IsOpen() {
+			klog.V(4).Infof("Pod %s/%s: failure-rate breaker open, skipping status update (healthy=%v)",
+				pod.GetNamespace(), pod.GetName(), healthy)
+			if !healthy && hc.uncertainty != nil {
+				err := hc.markPodStatusUnknown(ctx, clientset, pod, "failed health check while results are untrusted")
+				hc.uncertainty.observe(err)
+				return err
+			}
+			return nil
+		}
+	}
+
+	// In DaemonSet mode the decision is shared: publish our view and act on the quorum
+	if hc.quorum != nil {
+		quorumHealthy, err := hc.quorum.report(ctx, clientset, pod, result.Healthy)
+		if err != nil {
+			return err
+		}
+		if !quorumHealthy {
+			result.Healthy, healthy = false, false
+		} else if !healthy {
+			klog.V(4).Infof("Pod %s/%s: unreachable from %s but not from a quorum of nodes, keeping it healthy",
+				pod.GetNamespace(), pod.GetName(), hc.quorum.node)
+			result, healthy = ProbeResult{Protocol: result.Protocol, Healthy: true}, true
+		}
+	}
+
+	// Failure classes with a policy only flip once their hysteresis is met
+	if len(hc.failurePolicy) > 0 {
+		healthy = hc.failurePolicy.evaluate(pod, result, time.Now())
+	}
+
+	// Only flip once the pod's declared failure/success threshold is reached
+	healthy = hc.applyProbeThresholds(pod, healthy)
+	hc.updateCheckInterval(pod, result.Healthy)
+
+	// Both kubelet and the network must agree the pod is healthy
+	if hc.requireKubeletReady && healthy && !pod.GetContainersReady() {
+		klog.V(4).Infof("Pod %s/%s: reachable, but kubelet reports containers not ready",
+			pod.GetNamespace(), pod.GetName())
+		healthy = false
+	}
+
+	// Never take a Service below its minimum healthy endpoints ourselves. The transition is
+	// reserved rather than the guard held, so failures elsewhere aren't serialized behind our API
+	// calls; it is released once our status is written or the transition abandoned.
+	if last := pod.GetLastHealthStatus(); !healthy && hc.serviceGuard != nil && (last == nil || *last) {
+		service, reserved := hc.serviceGuard.reserve(pod)
+		if !reserved {
+			klog.Warningf("Pod %s/%s: failed health check, but marking it unhealthy would leave service %s with fewer than %d healthy endpoints, deferring status update",
+				pod.GetNamespace(), pod.GetName(), service, hc.serviceGuard.minHealthy)
+			metrics.RecordUnhealthyDeferred(pod.GetNamespace(), service)
+			return nil
+		}
+		defer hc.serviceGuard.release(pod)
+	}
+
+	// Give a failure shared by many endpoints of a Service time to resolve before flipping them
+	if hc.serviceDebounce != nil {
+		service, held := hc.serviceDebounce.observe(pod, result.Healthy, time.Now())
+		if last := pod.GetLastHealthStatus(); held && !healthy && (last == nil || *last) {
+			klog.Warningf("Pod %s/%s: failed health check during a synchronized failure of service %s, deferring status update",
+				pod.GetNamespace(), pod.GetName(), service)
+			metrics.RecordUnhealthyDeferred(pod.GetNamespace(), service)
+			return nil
+		}
+	}
+
+	// A pod that used up its transition budget keeps its status until the window moves on. The
+	// transition only counts against the budget once its status is written.
+	transition := false
+	if hc.maxTransitionsPerHour > 0 {
+		current := true
+		if last := pod.GetLastHealthStatus(); last != nil {
+			current = *last
+		}
+		transition = healthy != current
+		if transition && !pod.CanTransition(time.Now(), time.Hour, hc.maxTransitionsPerHour) {
+			klog.Warningf("Pod %s/%s: exceeded %d status transitions per hour, keeping current status (healthy=%v)",
+				pod.GetNamespace(), pod.GetName(), hc.maxTransitionsPerHour, current)
+			metrics.RecordTransitionSuppressed(pod.GetNamespace())
+			return nil
+		}
+	}
+
+	// Right after losing the API server our own view may be the partitioned one
+	if !healthy && hc.uncertainty != nil && hc.uncertainty.uncertain() {
+		err := hc.markPodStatusUnknown(ctx, clientset, pod, "failed health check while results are untrusted")
+		hc.uncertainty.observe(err)
+		return err
+	}
 
 	// Update pod status if changed
 	if err := hc.updatePodStatusIfChanged(ctx, clientset, pod, healthy); err != nil {
+		if hc.uncertainty != nil {
+			hc.uncertainty.observe(err)
+		}
 		return err
 	}
+	if transition {
+		pod.RecordTransition(time.Now())
+	}
+
+	// Per-family conditions are diagnostics, failing to set them doesn't fail the check
+	if hc.dualStackConditions && familyResults != nil {
+		if err := hc.updateFamilyConditions(ctx, clientset, pod, familyResults); err != nil {
+			klog.Warningf("Pod %s/%s: failed to update IP family conditions: %v", pod.GetNamespace(), pod.GetName(), err)
+		}
+	}
 
 	// Update cached health status
 	pod.SetLastHealthStatus(healthy)
 
-	// Health check completed, reset IsBeingChecked flag
-	pod.SetIsBeingChecked(false)
-
 	return nil
 }
 
+// observeAPI feeds the outcome of a status API call to the control-plane guard
+func (hc *HealthChecker) observeAPI(err error) {
+	if hc.controlPlane != nil {
+		hc.controlPlane.Record(err)
+	}
+}
+
+// applyProbeThresholds returns the health status to act on. A result that contradicts the
+// current status only takes effect after the pod's threshold of consecutive results; pods
+// are tracked once ready, so an unknown status counts as healthy. Recovery additionally
+// waits until probes have passed continuously for the recovery guard duration.
+func (hc *HealthChecker) applyProbeThresholds(pod HealthCheckPodInfo, healthy bool) bool {
+	failures, successes := pod.RecordProbeResult(healthy)
+	failureThreshold, successThreshold := pod.GetProbeThresholds()
+
+	current := true
+	if last := pod.GetLastHealthStatus(); last != nil {
+		current = *last
+	}
+	if current && !healthy && failures < failureThreshold {
+		klog.V(4).Infof("Pod %s/%s: failure %d/%d, keeping healthy status",
+			pod.GetNamespace(), pod.GetName(), failures, failureThreshold)
+		return true
+	}
+	if !current && healthy && successes < successThreshold {
+		klog.V(4).Infof("Pod %s/%s: success %d/%d, keeping unhealthy status",
+			pod.GetNamespace(), pod.GetName(), successes, successThreshold)
+		return false
+	}
+	if !current && healthy && hc.recoveryGuard > 0 {
+		if passing := time.Since(pod.GetPassingSince()); passing < hc.recoveryGuard {
+			klog.V(4).Infof("Pod %s/%s: passing for %v of %v recovery guard, keeping unhealthy status",
+				pod.GetNamespace(), pod.GetName(), passing.Round(time.Millisecond), hc.recoveryGuard)
+			return false
+		}
+	}
+	return healthy
+}
+
+// baseInterval returns the interval between checks of a pod before any stretching, the pod's
+// own when annotated
+func (hc *HealthChecker) baseInterval(pod HealthCheckPodInfo) time.Duration {
+	if interval := pod.GetBaseInterval(); interval > 0 {
+		return interval
+	}
+	return hc.GetHealthCheckInterval()
+}
+
+// updateCheckInterval stretches the pod's check interval on success and resets it to the
+// base interval on failure
+func (hc *HealthChecker) updateCheckInterval(pod HealthCheckPodInfo, healthy bool) {
+	if hc.healthyIntervalMultiplier <= 1 {
+		return
+	}
+	base := hc.baseInterval(pod)
+	if !healthy {
+		pod.SetCheckInterval(base)
+		return
+	}
+
+	interval := pod.GetCheckInterval()
+	if interval <= 0 {
+		interval = base
+	} else {
+		interval = time.Duration(float64(interval) * hc.healthyIntervalMultiplier)
+	}
+	if hc.healthyIntervalMax > 0 && interval > hc.healthyIntervalMax {
+		// A pod annotated slower than the cap is never checked more often than it asked
+		interval = hc.healthyIntervalMax
+		if base > interval {
+			interval = base
+		}
+	}
+	pod.SetCheckInterval(interval)
+}
+
+// probeTimeout returns the timeout of single probes of a pod, its own when annotated
+func (hc *HealthChecker) probeTimeout(pod HealthCheckPodInfo) time.Duration {
+	if timeout := pod.GetProbeTimeout(); timeout > 0 {
+		return timeout
+	}
+	if hc.zoneTimeouts == nil {
+		return hc.GetHealthCheckTimeout()
+	}
+	return hc.zoneTimeouts.timeoutFor(pod.GetNodeName(), hc.GetHealthCheckTimeout())
+}
+
+// probeRetries returns the retry count of a pod's probes, its own when annotated
+func (hc *HealthChecker) probeRetries(pod HealthCheckPodInfo) int {
+	if count := pod.GetRetryCount(); count != nil {
+		return *count
+	}
+	return hc.GetRetryCount()
+}
+
+// escalatedTimeout returns the probe timeout of a pod after its run of consecutive timeouts,
+// doubled for each timeout from timeoutEscalationAfter on and capped at timeoutEscalationMax
+func (hc *HealthChecker) escalatedTimeout(pod HealthCheckPodInfo) time.Duration {
+	timeout := hc.probeTimeout(pod)
+	if hc.timeoutEscalationAfter <= 0 || timeout >= hc.timeoutEscalationMax {
+		return timeout
+	}
+	for i := int32(hc.timeoutEscalationAfter); i <= pod.GetTimeoutStreak() && timeout < hc.timeoutEscalationMax; i++ {
+		timeout *= 2
+	}
+	return min(timeout, hc.timeoutEscalationMax)
+}
+
+// checkAPIBudget is the time a check keeps for its API calls, status reads and patches, once
+// its probes are done
+const checkAPIBudget = 10 * time.Second
+
+// checkDeadline returns how long a check of a pod may run: every probe it may send failing at its
+// escalated and jittered timeout on every attempt, plus the API budget. It is an upper bound, so
+// a slow pod is never cut off before its probes had the timeouts and retries it was given.
+func (hc *HealthChecker) checkDeadline(pod HealthCheckPodInfo) time.Duration {
+	attempt := hc.escalatedTimeout(pod)
+	if hc.probeTimeoutJitter > 0 {
+		attempt = time.Duration(float64(attempt) * (1 + hc.probeTimeoutJitter))
+	}
+	probes := max(len(pod.GetPorts())+len(pod.GetHTTPTargets())+len(pod.GetGRPCTargets()), 1)
+	if chain := len(pod.GetProbeChain()); chain > 1 {
+		probes *= chain
+	}
+	if pod.GetCallback() != nil && hc.callbacks != nil {
+		probes++
+	}
+	if families := len(pod.GetFamilyIPs()); families > 1 && (hc.dualStackConditions || hc.dualStackPolicy != DualStackPolicyPrimary) {
+		probes *= families
+	}
+	return attempt*time.Duration(hc.probeRetries(pod)+1)*time.Duration(probes) + checkAPIBudget
+}
+
+// recordTimeouts tracks a pod's run of timed out checks. A check run with an escalated timeout
+// tells a slow but alive pod, which answered, from a dead one still timing out at the cap.
+func (hc *HealthChecker) recordTimeouts(pod HealthCheckPodInfo, result ProbeResult) {
+	base := hc.probeTimeout(pod)
+	timeout := hc.escalatedTimeout(pod)
+	escalated := timeout > base
+	if !result.Healthy && classifyProbeError(result.Err) == FailureClassTimeout {
+		if escalated && timeout >= hc.timeoutEscalationMax {
+			klog.Warningf("Pod %s/%s: still timing out with the escalated timeout of %v, it is not just slow",
+				pod.GetNamespace(), pod.GetName(), timeout)
+			metrics.RecordTimeoutEscalation(pod.GetNamespace(), metrics.EscalationDead)
+		}
+		pod.SetTimeoutStreak(pod.GetTimeoutStreak() + 1)
+		return
+	}
+	if escalated && result.Healthy {
+		klog.Infof("Pod %s/%s: answered within the escalated timeout of %v after %d timeouts, slow but alive",
+			pod.GetNamespace(), pod.GetName(), timeout, pod.GetTimeoutStreak())
+		metrics.RecordTimeoutEscalation(pod.GetNamespace(), metrics.EscalationSlow)
+		// Stay escalated while the pod needs it, probing at the base timeout would only time out again
+		if result.Latency >= base {
+			return
+		}
+	}
+	pod.SetTimeoutStreak(0)
+}
+
 // performHealthCheck performs the actual health check on a pod
-func (hc *HealthChecker) performHealthCheck(pod HealthCheckPodInfo) bool {
+func (hc *HealthChecker) performHealthCheck(ctx context.Context, pod HealthCheckPodInfo) ProbeResult {
 	config := &HealthCheckConfig{
-		RetryCount:   hc.retryCount,
-		ProbeTimeout: hc.healthCheckTimeout,
+		RetryCount:        hc.probeRetries(pod),
+		ProbeTimeout:      hc.escalatedTimeout(pod),
+		HedgedProbes:      hc.hedgedProbes,
+		ICMPLimiter:       hc.icmpLimiter,
+		ICMPPacketCount:   hc.icmpPacketCount,
+		ICMPLossThreshold: hc.icmpLossThreshold,
+		ICMPSocket:        hc.icmpSocket,
+		TCPHalfOpen:       hc.tcpHalfOpenCheck,
+		TimeoutJitter:     hc.probeTimeoutJitter,
+		ProxyProtocol:     pod.GetProxyProtocol(),
+		TLSCertMinTTL:     hc.tlsCertMinTTL,
 	}
 
-	if len(pod.GetPorts()) > 0 {
-		return hc.checkPorts(pod, config)
+	start := time.Now()
+	result := ProbeResult{Protocol: probeProtocol(pod)}
+	if chain := pod.GetProbeChain(); len(chain) > 0 {
+		result = hc.checkChain(ctx, pod, chain, config)
+	} else if len(pod.GetPorts()) > 0 {
+		result.PortsUp, result.Err = hc.checkPorts(ctx, pod, config)
+	} else if pod.GetUDPOptions() != nil {
+		result.Err = fmt.Errorf("no UDP container ports declared")
 	} else {
-		return hc.checkICMP(pod, config)
+		result.Protocol = ProtocolICMP
+		result.Err = hc.checkICMP(ctx, pod, config)
+	}
+	// Only a pod reachable from the checker is asked to reach it back
+	if callback := pod.GetCallback(); result.Err == nil && callback != nil && hc.callbacks != nil {
+		result.Err = callbackProbeWithRetry(ctx, pod.GetIP(), *callback, hc.callbacks, config)
 	}
+	result.Healthy = result.Err == nil
+	result.Latency = time.Since(start)
+	return result
 }
 
-// checkPorts performs TCP health check on all ports
-func (hc *HealthChecker) checkPorts(pod HealthCheckPodInfo, config *HealthCheckConfig) bool {
-	healthy := true
+// probeProtocol returns the protocol the ports of a pod are probed with
+func probeProtocol(pod HealthCheckPodInfo) string {
+	switch {
+	case pod.GetUDPOptions() != nil:
+		return ProtocolUDP
+	case pod.GetTLSOptions() != nil:
+		return ProtocolTLS
+	case len(pod.GetHTTPTargets()) > 0:
+		return ProtocolHTTP
+	case len(pod.GetGRPCTargets()) > 0:
+		return ProtocolGRPC
+	}
+	return ProtocolTCP
+}
+
+// checkPorts performs TCP (or TLS) health check on all ports, returning the last probe error if any port failed.
+// Ports declared by HTTP probes are checked with an HTTP GET instead, ports of gRPC probes with a gRPC health check.
+func (hc *HealthChecker) checkPorts(ctx context.Context, pod HealthCheckPodInfo, config *HealthCheckConfig) ([]int32, error) {
+	httpTargets := make(map[int32][]HTTPTarget)
+	grpcTargets := make(map[int32][]GRPCTarget)
+	if pod.GetTLSOptions() == nil {
+		for _, target := range pod.GetHTTPTargets() {
+			httpTargets[target.Port] = append(httpTargets[target.Port], target)
+		}
+		for _, target := range pod.GetGRPCTargets() {
+			grpcTargets[target.Port] = append(grpcTargets[target.Port], target)
+		}
+	}
+
+	var lastErr error
+	var up []int32
 	for _, port := range pod.GetPorts() {
-		addr := net.JoinHostPort(pod.GetIP(), fmt.Sprintf("%d", port))
-		if err := tcpProbeWithRetry(addr, config); err != nil {
-			healthy = false
+		probePort := port
+		if remapped, ok := hc.portRemap[port]; ok {
+			klog.V(4).Infof("Pod %s/%s: probing port %d on remapped port %d", pod.GetNamespace(), pod.GetName(), port, remapped)
+			probePort = remapped
+		}
+		addr := net.JoinHostPort(pod.GetIP(), fmt.Sprintf("%d", probePort))
+		var err error
+		if udpOptions := pod.GetUDPOptions(); udpOptions != nil {
+			err = udpProbeWithRetry(ctx, addr, udpOptions, config)
+		} else if tlsOptions := pod.GetTLSOptions(); tlsOptions != nil {
+			err = tlsProbeWithRetry(ctx, addr, *tlsOptions, config)
+		} else if targets, ok := httpTargets[port]; ok {
+			for _, target := range targets {
+				opts := pod.GetHTTPOptions().forTarget(target)
+				opts.CertMinTTL = config.TLSCertMinTTL
+				target.Port = probePort
+				var probeErr error
+				if hc.proxyClient != nil {
+					probeErr = proxyHTTPProbeWithRetry(ctx, hc.proxyClient, pod.GetNamespace(), pod.GetName(), target, opts, config)
+				} else {
+					probeErr = httpProbeWithRetry(ctx, httpTargetURL(pod.GetIP(), target), opts, config)
+				}
+				if probeErr != nil {
+					err = probeErr
+				}
+			}
+		} else if targets, ok := grpcTargets[port]; ok {
+			for _, target := range targets {
+				if probeErr := grpcProbeWithRetry(ctx, addr, target, config); probeErr != nil {
+					err = probeErr
+				}
+			}
+		} else {
+			err = tcpProbeWithRetry(ctx, addr, config)
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("port %d: %w", port, err)
 			// Extract actual retry count from error message
 			klog.Errorf("Pod %s/%s probe port %d failed: %v",
 				pod.GetNamespace(), pod.GetName(), port, err)
 		} else {
 			klog.V(4).Infof("Pod %s/%s probe port %d success", pod.GetNamespace(), pod.GetName(), port)
+			up = append(up, port)
 		}
 	}
-	return healthy
+	return up, lastErr
 }
 
 // checkICMP performs ICMP health check
-func (hc *HealthChecker) checkICMP(pod HealthCheckPodInfo, config *HealthCheckConfig) bool {
-	if err := icmpProbeWithRetry(pod.GetIP(), config); err != nil {
+func (hc *HealthChecker) checkICMP(ctx context.Context, pod HealthCheckPodInfo, config *HealthCheckConfig) error {
+	if err := icmpProbeWithRetry(ctx, pod.GetIP(), config); err != nil {
 		// Extract actual retry count from error message
 		klog.Errorf("Pod %s/%s ICMP probe failed: %v",
 			pod.GetNamespace(), pod.GetName(), err)
-		return false
-	} else {
-		klog.V(4).Infof("Pod %s/%s ICMP probe success", pod.GetNamespace(), pod.GetName())
-		return true
+		return err
 	}
+	klog.V(4).Infof("Pod %s/%s ICMP probe success", pod.GetNamespace(), pod.GetName())
+	return nil
 }
 
 // updatePodStatusIfChanged updates pod ready status only if health status changed
@@ -169,14 +1174,24 @@ func (hc *HealthChecker) updatePodStatusIfChanged(ctx context.Context, clientset
 	lastStatus := pod.GetLastHealthStatus()
 	statusChanged := lastStatus == nil || *lastStatus != healthy
 
-	if !statusChanged {
+	reassertDue := hc.statusReassertInterval > 0 && time.Since(pod.GetLastAssertTime()) >= hc.statusReassertInterval
+
+	if !statusChanged && !reassertDue {
 		klog.V(4).Infof("Pod %s/%s: Health status unchanged (%v), skipping API call",
 			pod.GetNamespace(), pod.GetName(), healthy)
 		return nil
 	}
 
+	// Leave draining pods to the termination flow
+	if !hc.patchTerminating && pod.IsTerminating() {
+		klog.V(4).Infof("Pod %s/%s: terminating, skipping status update (healthy=%v)",
+			pod.GetNamespace(), pod.GetName(), healthy)
+		return nil
+	}
+
 	// Get pod from Kubernetes API
 	k8sPod, err := clientset.CoreV1().Pods(pod.GetNamespace()).Get(ctx, pod.GetName(), metav1.GetOptions{})
+	hc.observeAPI(err)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			klog.Infof("Pod %s/%s not found in Kubernetes, should be removed from PodSet",
@@ -186,54 +1201,211 @@ func (hc *HealthChecker) updatePodStatusIfChanged(ctx context.Context, clientset
 		return fmt.Errorf("failed to get pod %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
 	}
 
-	if err := updatePodReadyWithPod(ctx, clientset, k8sPod, healthy); err != nil {
+	if !hc.patchTerminating && k8sPod.DeletionTimestamp != nil {
+		klog.V(4).Infof("Pod %s/%s: terminating, skipping status update (healthy=%v)",
+			pod.GetNamespace(), pod.GetName(), healthy)
+		return nil
+	}
+
+	// Only endpoints that drifted from the health status are patched, re-asserts included
+	if hc.endpointSliceConditions == EndpointSliceConditionsAlso {
+		if err := hc.updateEndpointSliceConditions(ctx, clientset, k8sPod, healthy); err != nil {
+			klog.Errorf("update pod %s/%s endpoint conditions failed: %v", pod.GetNamespace(), pod.GetName(), err)
+			return err
+		}
+	}
+
+	// On a periodic re-assert only patch if someone else changed our conditions
+	if !statusChanged && hc.conditionsReflectHealth(k8sPod, healthy) {
+		klog.V(4).Infof("Pod %s/%s: conditions still reflect health status (%v), nothing to re-assert",
+			pod.GetNamespace(), pod.GetName(), healthy)
+		pod.SetLastAssertTime(time.Now())
+		return nil
+	}
+	if !statusChanged {
+		klog.Infof("Pod %s/%s: conditions drifted from health status (%v), re-asserting",
+			pod.GetNamespace(), pod.GetName(), healthy)
+	}
+
+	wasUnhealthy := lastStatus != nil && !*lastStatus
+	err = hc.updatePodReadyWithPod(ctx, clientset, k8sPod, healthy, wasUnhealthy)
+	hc.observeAPI(err)
+	if err != nil {
 		klog.Errorf("update pod %s/%s ready failed: %v", pod.GetNamespace(), pod.GetName(), err)
 		return err
 	}
+	pod.SetLastAssertTime(time.Now())
+
+	// The first healthy result is just adoption, not a transition worth an event
+	if statusChanged && (lastStatus != nil || !healthy) {
+		hc.emitTransitionEvent(ctx, clientset, k8sPod, healthy)
+	}
+
+	return nil
+}
+
+// conditionsReflectHealth reports whether the pod's conditions already match what
+// updatePodReadyWithPod would set for the given health status
+func (hc *HealthChecker) conditionsReflectHealth(pod *corev1.Pod, healthy bool) bool {
+	if hasReadinessGate(pod, hc.readinessGateType) {
+		want := corev1.ConditionTrue
+		if !healthy {
+			want = corev1.ConditionFalse
+		}
+		if conditionStatus(pod, corev1.PodConditionType(hc.readinessGateType)) != want {
+			return false
+		}
+	}
+	if !healthy && managesReady(pod) && conditionStatus(pod, corev1.PodReady) != corev1.ConditionFalse {
+		return false
+	}
+	return true
+}
+
+// managesReady reports whether failed checks should also force the Ready condition to
+// False. Pods opt out with the manage-ready annotation to have only the gate driven.
+func managesReady(pod *corev1.Pod) bool {
+	value, ok := pod.Annotations[manageReadyAnnotation]
+	if !ok {
+		return true
+	}
+	manage, err := strconv.ParseBool(value)
+	if err != nil {
+		klog.Warningf("Pod %s/%s: invalid %s annotation %q, managing Ready", pod.Namespace, pod.Name, manageReadyAnnotation, value)
+		return true
+	}
+	return manage
+}
+
+// conditionStatus returns the status of the given condition type, empty if absent
+func conditionStatus(pod *corev1.Pod, condType corev1.PodConditionType) corev1.ConditionStatus {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == condType {
+			return cond.Status
+		}
+	}
+	return ""
+}
 
+// validateProbeTarget rejects loopback, link-local and unspecified addresses,
+// which can only come from a misconfigured PodIP and would make the checker probe itself
+func validateProbeTarget(ip string) error {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return fmt.Errorf("invalid probe target %q", ip)
+	}
+	switch {
+	case parsed.IsUnspecified():
+		return fmt.Errorf("refusing to probe unspecified address %s", ip)
+	case parsed.IsLoopback():
+		return fmt.Errorf("refusing to probe loopback address %s", ip)
+	case parsed.IsLinkLocalUnicast(), parsed.IsLinkLocalMulticast():
+		return fmt.Errorf("refusing to probe link-local address %s", ip)
+	}
 	return nil
 }
 
+// probeTargetAllowed reports whether ip is within the allowed probe CIDRs, always true when none are set
+func (hc *HealthChecker) probeTargetAllowed(ip string) bool {
+	if len(hc.allowedProbeCIDRs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	for _, cidr := range hc.allowedProbeCIDRs {
+		if parsed != nil && cidr.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
 // tcpProbeWithRetry TCP probe with retry mechanism
-func tcpProbeWithRetry(addr string, config *HealthCheckConfig) error {
+func tcpProbeWithRetry(ctx context.Context, addr string, config *HealthCheckConfig) error {
+	if config.ProxyProtocol != "" {
+		return probeWithRetry(ctx, "TCP", addr, config, func(ctx context.Context, addr string, timeout time.Duration) error {
+			return tcpProxyProtocolProbe(ctx, addr, config.ProxyProtocol, config.TCPHalfOpen, timeout)
+		})
+	}
+	if config.TCPHalfOpen {
+		return probeWithRetry(ctx, "TCP", addr, config, tcpHalfOpenProbe)
+	}
+	return probeWithRetry(ctx, "TCP", addr, config, tcpProbe)
+}
+
+// udpProbeWithRetry UDP probe with retry mechanism
+func udpProbeWithRetry(ctx context.Context, addr string, opts *UDPProbeOptions, config *HealthCheckConfig) error {
+	return probeWithRetry(ctx, "UDP", addr, config, func(ctx context.Context, addr string, timeout time.Duration) error {
+		return udpProbe(ctx, addr, opts.Payload, opts.ExpectPrefix, timeout)
+	})
+}
+
+// tlsProbeWithRetry TLS probe with retry mechanism
+func tlsProbeWithRetry(ctx context.Context, addr string, opts TLSProbeOptions, config *HealthCheckConfig) error {
+	opts.MinTTL = config.TLSCertMinTTL
+	return probeWithRetry(ctx, "TLS", addr, config, func(ctx context.Context, addr string, timeout time.Duration) error {
+		return tlsProbe(ctx, addr, opts, timeout)
+	})
+}
+
+// probeWithRetry runs a connection-oriented probe with retry mechanism, giving up once ctx is done
+func probeWithRetry(ctx context.Context, kind, addr string, config *HealthCheckConfig, probe func(ctx context.Context, addr string, timeout time.Duration) error) error {
 	var lastErr error
 
 	for i := 0; i <= config.RetryCount; i++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("%s probe of %s canceled: %w", kind, addr, err)
+		}
 		start := time.Now()
-		if err := tcpProbe(addr, config.ProbeTimeout); err != nil {
+		timeout := config.attemptTimeout()
+		if err := hedgedProbe(ctx, config.HedgedProbes, func(ctx context.Context) error {
+			return probe(ctx, addr, timeout)
+		}); err != nil {
 			lastErr = err
 			if i < config.RetryCount {
 				elapsed := time.Since(start)
-				remaining := config.ProbeTimeout - elapsed
+				remaining := timeout - elapsed
 				if remaining > 0 {
-					klog.V(4).Infof("TCP probe attempt %d/%d failed for %s: %v, waiting %v before retry...",
-						i+1, config.RetryCount+1, addr, err, remaining)
-					time.Sleep(remaining)
+					klog.V(4).Infof("%s probe attempt %d/%d failed for %s: %v, waiting %v before retry...",
+						kind, i+1, config.RetryCount+1, addr, err, remaining)
+					select {
+					case <-time.After(remaining):
+					case <-ctx.Done():
+					}
 				} else {
-					klog.V(4).Infof("TCP probe attempt %d/%d failed for %s: %v, retrying immediately...",
-						i+1, config.RetryCount+1, addr, err)
+					klog.V(4).Infof("%s probe attempt %d/%d failed for %s: %v, retrying immediately...",
+						kind, i+1, config.RetryCount+1, addr, err)
 				}
 				continue
 			}
 		} else {
 			// Return immediately on success, no more retries
 			if i > 0 {
-				klog.V(4).Infof("TCP probe succeeded on attempt %d/%d for %s",
-					i+1, config.RetryCount+1, addr)
+				klog.V(4).Infof("%s probe succeeded on attempt %d/%d for %s",
+					kind, i+1, config.RetryCount+1, addr)
 			}
 			return nil
 		}
 	}
 
-	return fmt.Errorf("TCP probe failed after %d attempts: %w", config.RetryCount+1, lastErr)
+	return fmt.Errorf("%s probe failed after %d attempts: %w", kind, config.RetryCount+1, lastErr)
 }
 
 // icmpProbeWithRetry ICMP probe with retry mechanism
-func icmpProbeWithRetry(ip string, config *HealthCheckConfig) error {
+func icmpProbeWithRetry(ctx context.Context, ip string, config *HealthCheckConfig) error {
 	var lastErr error
 
 	for i := 0; i <= config.RetryCount; i++ {
-		if err := icmpProbe(ip, 1, config.ProbeTimeout); err != nil {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("ICMP probe of %s canceled: %w", ip, err)
+		}
+		timeout := config.attemptTimeout()
+		if err := hedgedProbe(ctx, config.HedgedProbes, func(ctx context.Context) error {
+			stats, err := icmpProbe(ctx, ip, max(config.ICMPPacketCount, 1), timeout, config.ICMPLimiter, config.ICMPSocket)
+			if err != nil {
+				return err
+			}
+			return checkICMPLoss(ip, stats, config.ICMPLossThreshold)
+		}); err != nil {
 			lastErr = err
 			if i < config.RetryCount {
 				klog.V(4).Infof("ICMP probe attempt %d/%d failed for %s: %v, retrying...",
@@ -253,8 +1425,36 @@ func icmpProbeWithRetry(ip string, config *HealthCheckConfig) error {
 	return fmt.Errorf("ICMP probe failed after %d attempts: %w", config.RetryCount+1, lastErr)
 }
 
-func tcpProbe(addr string, timeout time.Duration) error {
-	conn, err := net.DialTimeout("tcp", addr, timeout)
+// hedgedProbe runs count copies of probe concurrently and returns as soon as one succeeds,
+// which cuts tail latency on lossy networks. Stragglers are canceled once it returns.
+func hedgedProbe(ctx context.Context, count int, probe func(ctx context.Context) error) error {
+	if count <= 1 {
+		return probe(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := make(chan error, count)
+	for i := 0; i < count; i++ {
+		go func() {
+			errCh <- probe(ctx)
+		}()
+	}
+
+	var lastErr error
+	for i := 0; i < count; i++ {
+		if err := <-errCh; err != nil {
+			lastErr = err
+		} else {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func tcpProbe(ctx context.Context, addr string, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return err
 	}
@@ -262,32 +1462,224 @@ func tcpProbe(addr string, timeout time.Duration) error {
 	return nil
 }
 
-func icmpProbe(ip string, count int, timeout time.Duration) error {
-	pinger, err := goping.NewPinger(ip)
+// udpProbe sends payload and requires a response starting with expectPrefix within the timeout.
+// UDP is connectionless, so any response is the only sign of a serving app; a closed port
+// usually surfaces as connection refused from the ICMP port unreachable reply.
+func udpProbe(ctx context.Context, addr string, payload, expectPrefix []byte, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "udp", addr)
 	if err != nil {
 		return err
 	}
-	pinger.Count = count
-	pinger.Timeout = timeout
-	pinger.SetPrivileged(true)
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(payload); err != nil {
+		return err
+	}
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(buf[:n], expectPrefix) {
+		return fmt.Errorf("%w: response from %s does not start with %q", errUnexpectedUDPResponse, addr, expectPrefix)
+	}
+	return nil
+}
 
-	err = pinger.Run()
+// tcpHalfOpenProbe connects, writes a byte and waits for the app to answer or close the
+// connection. The kernel completes the handshake for connections queued in the accept
+// backlog, so a bare connect succeeds even when the app never calls accept().
+func tcpHalfOpenProbe(ctx context.Context, addr string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+	return awaitServed(ctx, conn)
+}
 
-	// Check ping results, ensure there are successful responses
-	stats := pinger.Statistics()
-	if stats.PacketsRecv == 0 {
-		return fmt.Errorf("ICMP probe failed: no response from %s", ip)
+// closeOnDone closes conn as soon as ctx is done, aborting blocked reads and writes that only
+// watch the connection deadline. The returned stop must be called once conn is no longer used.
+func closeOnDone(ctx context.Context, conn net.Conn) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+}
+
+// awaitServed writes a byte on a connection with a deadline set and waits for the app to
+// answer or close it. The connection is closed when ctx is done, which says nothing about the app.
+func awaitServed(ctx context.Context, conn net.Conn) error {
+	if _, err := conn.Write([]byte{'\n'}); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("%w: %w", errTCPNotServed, err)
+	}
+	// Data, a close or a reset all mean the app accepted the connection, only silence
+	// until the deadline means it is still waiting in the backlog
+	_, err := conn.Read(make([]byte, 1))
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
 	}
+	var netErr net.Error
+	if stderrors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", errTCPNotServed, err)
+	}
+	return nil
+}
+
+var (
+	// errTLSHostnameMismatch reports a certificate that does not cover the expected server name
+	errTLSHostnameMismatch = stderrors.New("TLS certificate does not match server name")
+	// errTLSHandshake reports a TLS handshake that failed after the TCP connection was established
+	errTLSHandshake = stderrors.New("TLS handshake failed")
+	// errTLSCertUntrusted reports a certificate chain that does not verify against the system roots
+	errTLSCertUntrusted = stderrors.New("TLS certificate not trusted")
+	// errTLSCertExpiring reports a certificate that expires within the configured minimum TTL
+	errTLSCertExpiring = stderrors.New("TLS certificate expiring")
+	// errICMPNoResponse reports an ICMP probe that got no echo reply
+	errICMPNoResponse = stderrors.New("ICMP probe failed: no response")
+	// errICMPPacketLoss reports an ICMP probe that lost too many of its echo requests
+	errICMPPacketLoss = stderrors.New("ICMP probe failed: packet loss")
+	// errTCPNotServed reports a connection that was established but never answered or closed by the app
+	errTCPNotServed = stderrors.New("TCP connection not served")
+	// errUnexpectedUDPResponse reports a UDP response without the expected prefix
+	errUnexpectedUDPResponse = stderrors.New("unexpected UDP response")
+	// errUnexpectedHTTPResponse reports an HTTP status or header that does not indicate health
+	errUnexpectedHTTPResponse = stderrors.New("unexpected HTTP response")
+)
+
+// TLSProbeOptions configures the TLS handshake probes complete instead of a TCP connect
+type TLSProbeOptions struct {
+	ServerName string // Name the certificate must cover, empty only checks the handshake
+	// Verify checks the certificate chain against the system roots, off for self-signed certificates
+	Verify bool
+	MinTTL time.Duration // Fail when the certificate expires within this window, 0 disables
+}
+
+// tlsProbe completes a TLS handshake and, when a server name is set, verifies that the
+// presented certificate covers it, catching misissued or swapped certificates
+func tlsProbe(ctx context.Context, addr string, opts TLSProbeOptions, timeout time.Duration) error {
+	dialer := net.Dialer{Timeout: timeout}
+	rawConn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	conn := tls.Client(rawConn, &tls.Config{
+		ServerName: opts.ServerName,
+		// The chain, hostname and expiry are checked explicitly below, each with its own error
+		InsecureSkipVerify: true,
+	})
+	defer conn.Close()
+	handshakeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := conn.HandshakeContext(handshakeCtx); err != nil {
+		return fmt.Errorf("%w: %w", errTLSHandshake, err)
+	}
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fmt.Errorf("%w: no certificate presented by %s", errTLSHandshake, addr)
+	}
+	if opts.Verify {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{Intermediates: intermediates}); err != nil {
+			return fmt.Errorf("%w: %v", errTLSCertUntrusted, err)
+		}
+	}
+	if opts.ServerName != "" {
+		if err := certs[0].VerifyHostname(opts.ServerName); err != nil {
+			return fmt.Errorf("%w %q: %v", errTLSHostnameMismatch, opts.ServerName, err)
+		}
+	}
+	return checkCertTTL(certs[0], opts.MinTTL)
+}
 
+// checkCertTTL fails a certificate that expires within minTTL, 0 disables the check
+func checkCertTTL(cert *x509.Certificate, minTTL time.Duration) error {
+	if minTTL <= 0 {
+		return nil
+	}
+	if remaining := time.Until(cert.NotAfter); remaining < minTTL {
+		return fmt.Errorf("%w: certificate for %q expires at %s, within %v",
+			errTLSCertExpiring, cert.Subject.CommonName, cert.NotAfter.Format(time.RFC3339), minTTL)
+	}
+	return nil
+}
+
+// checkICMPLoss fails an ICMP probe that got no reply, or lost lossThreshold percent or more of
+// its packets. A threshold of 0 or 100 accepts any reply.
+func checkICMPLoss(ip string, stats *goping.Statistics, lossThreshold float64) error {
+	if stats.PacketsRecv == 0 {
+		return fmt.Errorf("%w from %s", errICMPNoResponse, ip)
+	}
+	if lossThreshold > 0 && stats.PacketLoss >= lossThreshold {
+		return fmt.Errorf("%w from %s: %.0f%% of %d packets lost", errICMPPacketLoss, ip, stats.PacketLoss, stats.PacketsSent)
+	}
 	return nil
 }
-func updatePodReadyWithPod(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, success bool) error {
+
+func icmpProbe(ctx context.Context, ip string, count int, timeout time.Duration, limiter *probeLimiter, socket *icmpSocket) (*goping.Statistics, error) {
+	// Waiting for a slot counts against the probe timeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := limiter.acquire(ctx); err != nil {
+		return nil, fmt.Errorf("ICMP probe to %s: %w", ip, err)
+	}
+	defer limiter.release()
+
+	privileged := socket.privileged()
+	stats, err := ping(ctx, ip, count, timeout, privileged)
+	if err != nil && privileged && socket.fallBack(err) {
+		stats, err = ping(ctx, ip, count, timeout, false)
+	}
+	return stats, err
+}
+
+// ping sends count echo requests to ip spread over timeout and returns their statistics
+func ping(ctx context.Context, ip string, count int, timeout time.Duration, privileged bool) (*goping.Statistics, error) {
+	// The pinger picks ICMP or ICMPv6 from the family of ip
+	pinger, err := goping.NewPinger(ip)
+	if err != nil {
+		return nil, err
+	}
+	pinger.Count = count
+	pinger.Timeout = timeout
+	// Spread the packets over the timeout, leaving the last one as long to be answered
+	if count > 1 {
+		pinger.Interval = timeout / time.Duration(count)
+	}
+	pinger.SetPrivileged(privileged)
+
+	if err := runPinger(ctx, pinger); err != nil {
+		return nil, err
+	}
+	return pinger.Statistics(), nil
+}
+
+// updatePodReadyWithPod patches the conditions we drive for a check result. wasUnhealthy tells
+// that we reported the pod unhealthy before, so a Ready=False we set is restored on recovery.
+func (hc *HealthChecker) updatePodReadyWithPod(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, success, wasUnhealthy bool) error {
 	klog.V(4).Infof("Updating pod status: namespace=%s, name=%s, success=%v", pod.Namespace, pod.Name, success)
 
-	hasReadinessGate := hasReadinessGate(pod)
+	hasReadinessGate := hasReadinessGate(pod, hc.readinessGateType)
+	var touched []corev1.PodConditionType
 
 	if hasReadinessGate {
 		status := corev1.ConditionTrue
@@ -295,33 +1687,42 @@ func updatePodReadyWithPod(ctx context.Context, clientset kubernetes.Interface,
 			status = corev1.ConditionFalse
 		}
 		klog.Infof("Pod %s/%s: Setting readinessGate condition to %v", pod.Namespace, pod.Name, status)
-		updateReadinessGateCondition(&pod.Status.Conditions, status)
+		hc.updateReadinessGateCondition(&pod.Status.Conditions, status)
+		touched = append(touched, corev1.PodConditionType(hc.readinessGateType))
 	}
 
-	if !success {
+	if !success && managesReady(pod) {
 		klog.Infof("Pod %s/%s: Setting Ready condition to False due to health check failure", pod.Namespace, pod.Name)
 		updateReadyCondition(&pod.Status.Conditions, corev1.ConditionFalse)
-	} else if !hasReadinessGate {
-		// If health check passed and no readinessGate, no need to update anything
-		return nil
+		touched = append(touched, corev1.PodReady)
 	}
 
-	// Apply the patch
-	patch := map[string]interface{}{
-		"status": map[string]interface{}{
-			"conditions": pod.Status.Conditions,
-		},
+	// With a readinessGate the kubelet recomputes Ready once the gate passes, without one nothing
+	// would undo our Ready=False, nor the Unknown only we set. Containers the kubelet reports not
+	// ready keep it False.
+	ready := conditionStatus(pod, corev1.PodReady)
+	if success && !hasReadinessGate && managesReady(pod) &&
+		(wasUnhealthy && ready == corev1.ConditionFalse || ready == corev1.ConditionUnknown) &&
+		conditionStatus(pod, corev1.ContainersReady) != corev1.ConditionFalse {
+		klog.Infof("Pod %s/%s: Restoring Ready condition to True after recovery", pod.Namespace, pod.Name)
+		updateReadyCondition(&pod.Status.Conditions, corev1.ConditionTrue)
+		touched = append(touched, corev1.PodReady)
+	}
+	if len(touched) == 0 {
+		// Nothing of ours to update: passed without readinessGate, or Ready is left to the kubelet
+		return nil
 	}
 
-	patchBytes, err := json.Marshal(patch)
+	// Apply the patch
+	patchType, patchBytes, err := buildConditionsPatch(pod.Status.Conditions, touched, hc.statusPatchType)
 	if err != nil {
-		return fmt.Errorf("failed to marshal patch: %w", err)
+		return err
 	}
 
 	_, err = clientset.CoreV1().Pods(pod.Namespace).Patch(
 		ctx,
 		pod.Name,
-		types.MergePatchType,
+		patchType,
 		patchBytes,
 		metav1.PatchOptions{},
 		"status",
@@ -335,13 +1736,51 @@ func updatePodReadyWithPod(ctx context.Context, clientset kubernetes.Interface,
 	return nil
 }
 
+// buildConditionsPatch builds the status patch for the updated conditions. A merge patch
+// replaces the whole conditions list, while a strategic merge patch only carries the
+// touched conditions and lets the API server merge them into the list by type.
+func buildConditionsPatch(conditions []corev1.PodCondition, touched []corev1.PodConditionType, patchMode string) (types.PatchType, []byte, error) {
+	patchType := types.MergePatchType
+	patchConditions := conditions
+
+	if patchMode == StatusPatchTypeStrategic {
+		patchType = types.StrategicMergePatchType
+		patchConditions = make([]corev1.PodCondition, 0, len(touched))
+		for _, cond := range conditions {
+			for _, condType := range touched {
+				if cond.Type == condType {
+					patchConditions = append(patchConditions, cond)
+					break
+				}
+			}
+		}
+	}
+
+	patch := map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": patchConditions,
+		},
+	}
+
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal patch: %w", err)
+	}
+	return patchType, patchBytes, nil
+}
+
 // updateReadyCondition updates the Ready condition status
 func updateReadyCondition(conditions *[]corev1.PodCondition, status corev1.ConditionStatus) {
+	setPodCondition(conditions, corev1.PodReady, status)
+}
+
+// setPodCondition updates the condition of the given type, appending it if not found
+func setPodCondition(conditions *[]corev1.PodCondition, condType corev1.PodConditionType, status corev1.ConditionStatus) {
 	now := metav1.Now()
 
-	// Update existing Ready condition
+	// Update existing condition
 	for i, cond := range *conditions {
-		if cond.Type == corev1.PodReady {
+		if cond.Type == condType {
 			(*conditions)[i].Status = status
 			(*conditions)[i].LastProbeTime = now
 			(*conditions)[i].LastTransitionTime = now
@@ -349,21 +1788,19 @@ func updateReadyCondition(conditions *[]corev1.PodCondition, status corev1.Condi
 		}
 	}
 
-	// Append new Ready condition if not found
+	// Append new condition if not found
 	*conditions = append(*conditions, corev1.PodCondition{
-		Type:               corev1.PodReady,
+		Type:               condType,
 		Status:             status,
 		LastProbeTime:      now,
 		LastTransitionTime: now,
 	})
 }
 
-// hasReadinessGate checks if pod has readinessGate configured
-func hasReadinessGate(pod *corev1.Pod) bool {
-	const readinessGateType = "endpointHealthCheckSuccess"
-
+// hasReadinessGate checks if pod has a readinessGate of gateType configured
+func hasReadinessGate(pod *corev1.Pod, gateType string) bool {
 	for _, gate := range pod.Spec.ReadinessGates {
-		if string(gate.ConditionType) == readinessGateType {
+		if string(gate.ConditionType) == gateType {
 			return true
 		}
 	}
@@ -371,25 +1808,6 @@ func hasReadinessGate(pod *corev1.Pod) bool {
 }
 
 // updateReadinessGateCondition updates the readinessGate condition status
-func updateReadinessGateCondition(conditions *[]corev1.PodCondition, status corev1.ConditionStatus) {
-	const readinessGateType = "endpointHealthCheckSuccess"
-	now := metav1.Now()
-
-	// Update existing readinessGate condition
-	for i, cond := range *conditions {
-		if cond.Type == corev1.PodConditionType(readinessGateType) {
-			(*conditions)[i].Status = status
-			(*conditions)[i].LastProbeTime = now
-			(*conditions)[i].LastTransitionTime = now
-			return
-		}
-	}
-
-	// Append new readinessGate condition if not found
-	*conditions = append(*conditions, corev1.PodCondition{
-		Type:               corev1.PodConditionType(readinessGateType),
-		Status:             status,
-		LastProbeTime:      now,
-		LastTransitionTime: now,
-	})
+func (hc *HealthChecker) updateReadinessGateCondition(conditions *[]corev1.PodCondition, status corev1.ConditionStatus) {
+	setPodCondition(conditions, corev1.PodConditionType(hc.readinessGateType), status)
 }
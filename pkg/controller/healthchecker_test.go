@@ -0,0 +1,1500 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+
+	goping "github.com/prometheus-community/pro-bing"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+func TestValidateProbeTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		ip      string
+		wantErr bool
+	}{
+		{name: "pod IPv4", ip: "10.16.0.5", wantErr: false},
+		{name: "pod IPv6", ip: "fd00::5", wantErr: false},
+		{name: "loopback IPv4", ip: "127.0.0.1", wantErr: true},
+		{name: "loopback IPv6", ip: "::1", wantErr: true},
+		{name: "link-local IPv4", ip: "169.254.10.20", wantErr: true},
+		{name: "link-local IPv6", ip: "fe80::1", wantErr: true},
+		{name: "unspecified IPv4", ip: "0.0.0.0", wantErr: true},
+		{name: "unspecified IPv6", ip: "::", wantErr: true},
+		{name: "garbage", ip: "not-an-ip", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProbeTarget(tt.ip)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckPodRejectsUnsafeTarget(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "169.254.1.1", "::"} {
+		t.Run(ip, func(t *testing.T) {
+			k8sPod := newTestK8sPod("default", "test-pod", ip)
+			k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+			clientset := fake.NewSimpleClientset(k8sPod)
+			healthy := true
+			pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: ip, LastHealthStatus: &healthy}
+
+			err := NewHealthChecker().CheckPod(context.Background(), clientset, pod)
+
+			assert.NoError(t, err)
+			assert.Nil(t, pod.GetLastHealthStatus(), "status should be unknown")
+			got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, corev1.ConditionUnknown, conditionStatus(got, corev1.PodReady))
+			assert.Equal(t, corev1.ConditionUnknown, conditionStatus(got, "endpointHealthCheckSuccess"))
+		})
+	}
+}
+
+// closedPort returns a local TCP port with nothing listening on it
+func closedPort(t *testing.T) int32 {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := int32(ln.Addr().(*net.TCPAddr).Port)
+	_ = ln.Close()
+	return port
+}
+
+// newLocalHealthChecker returns a checker with fast probes that is allowed to dial localhost
+func newLocalHealthChecker() *HealthChecker {
+	hc := NewHealthChecker()
+	hc.SetHealthCheckTimeout(10 * time.Millisecond)
+	hc.SetRetryCount(1)
+	hc.SetRejectUnsafeTargets(false)
+	return hc
+}
+
+func newTestK8sPod(namespace, name, ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: ip,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+}
+
+func countPatches(clientset *fake.Clientset) int {
+	count := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "patch" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestFailureRateBreaker(t *testing.T) {
+	now := time.Now()
+	breaker := NewFailureRateBreaker(0.5, 10*time.Second, 4)
+	breaker.now = func() time.Time { return now }
+
+	// Below the minimum sample count the breaker never opens
+	for i := 0; i < 3; i++ {
+		breaker.Record(false)
+	}
+	assert.False(t, breaker.IsOpen())
+
+	breaker.Record(false)
+	assert.True(t, breaker.IsOpen(), "4/4 failures should open the breaker")
+
+	// Recovering below the threshold closes it again
+	for i := 0; i < 4; i++ {
+		breaker.Record(true)
+	}
+	assert.False(t, breaker.IsOpen(), "4/8 failures should close the breaker")
+
+	// Old results fall out of the window
+	for i := 0; i < 10; i++ {
+		breaker.Record(false)
+	}
+	assert.True(t, breaker.IsOpen())
+	now = now.Add(11 * time.Second)
+	for i := 0; i < 4; i++ {
+		breaker.Record(true)
+	}
+	assert.False(t, breaker.IsOpen())
+}
+
+func TestCheckPodBreakerSuspendsPatching(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestK8sPod("default", "test-pod", "127.0.0.1"))
+	breaker := NewFailureRateBreaker(0.5, time.Minute, 2)
+	hc := newLocalHealthChecker()
+	hc.SetFailureRateBreaker(breaker)
+
+	healthy := true
+	pod := &PodInfo{
+		Namespace:        "default",
+		Name:             "test-pod",
+		IP:               "127.0.0.1",
+		Ports:            []int32{closedPort(t)},
+		LastHealthStatus: &healthy,
+	}
+
+	// Simulate a network-wide blip
+	breaker.Record(false)
+	breaker.Record(false)
+	assert.True(t, breaker.IsOpen())
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 0, countPatches(clientset), "status must not be patched while the breaker is open")
+	assert.True(t, *pod.GetLastHealthStatus(), "cached status must not change while the breaker is open")
+
+	// Once the rate recovers, the failing pod gets patched
+	for i := 0; i < 4; i++ {
+		breaker.Record(true)
+	}
+	assert.False(t, breaker.IsOpen())
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.False(t, *pod.GetLastHealthStatus())
+}
+
+func TestCanceledCheckLeavesStatusAlone(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestK8sPod("default", "test-pod", "127.0.0.1"))
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckTimeout(5 * time.Second)
+	hc.SetRetryCount(3)
+
+	healthy := true
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}, LastHealthStatus: &healthy}
+
+	// Shutdown cancels the scheduler context, in-flight probes give up instead of retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	assert.ErrorIs(t, hc.CheckPod(ctx, clientset, pod), context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 0, countPatches(clientset))
+	assert.True(t, *pod.GetLastHealthStatus())
+
+	config := &HealthCheckConfig{RetryCount: 3, ProbeTimeout: 5 * time.Second}
+	err := tcpProbeWithRetry(ctx, fmt.Sprintf("127.0.0.1:%d", closedPort(t)), config)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestClearedHealthStatusResyncsConditions(t *testing.T) {
+	// The pod is Ready in the cluster, but this instance last saw it unhealthy
+	clientset := fake.NewSimpleClientset(newTestK8sPod("default", "test-pod", "127.0.0.1"))
+	hc := newLocalHealthChecker()
+
+	unhealthy := false
+	podSet := NewPodSet()
+	podSet.set(&PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}, LastHealthStatus: &unhealthy})
+	pod := podSet.pods["127.0.0.1"]
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 0, countPatches(clientset), "a stale status skips the diverged condition")
+
+	// Losing leadership forgets the statuses, the next check writes the condition again
+	podSet.ClearHealthStatus()
+	assert.Nil(t, pod.GetLastHealthStatus())
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.False(t, *pod.GetLastHealthStatus())
+}
+
+func TestResultWriterEmitsJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewResultWriter(&buf)
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer.now = func() time.Time { return ts }
+
+	hc := newLocalHealthChecker()
+	hc.SetResultWriter(writer)
+	clientset := fake.NewSimpleClientset(newTestK8sPod("default", "test-pod", "127.0.0.1"))
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	writer.Write(&PodInfo{Namespace: "prod", Name: "ok-pod", IP: "10.0.0.1"},
+		ProbeResult{Protocol: ProtocolICMP, Healthy: true, Latency: 1500 * time.Microsecond})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var failed map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &failed))
+	assert.Equal(t, "2024-01-02T03:04:05Z", failed["ts"])
+	assert.Equal(t, "default", failed["ns"])
+	assert.Equal(t, "test-pod", failed["name"])
+	assert.Equal(t, "127.0.0.1", failed["ip"])
+	assert.Equal(t, ProtocolTCP, failed["protocol"])
+	assert.Equal(t, false, failed["healthy"])
+	assert.Contains(t, failed, "latencyMs")
+	assert.NotEmpty(t, failed["err"])
+
+	var ok map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &ok))
+	assert.Equal(t, "prod", ok["ns"])
+	assert.Equal(t, true, ok["healthy"])
+	assert.Equal(t, 1.5, ok["latencyMs"])
+	assert.NotContains(t, ok, "err")
+}
+
+func TestBuildConditionsPatch(t *testing.T) {
+	conditions := []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		{Type: corev1.PodReady, Status: corev1.ConditionFalse},
+		{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+	}
+
+	patchType, patchBytes, err := buildConditionsPatch(conditions, []corev1.PodConditionType{corev1.PodReady}, StatusPatchTypeMerge)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MergePatchType, patchType)
+	assert.Contains(t, string(patchBytes), `"type":"PodScheduled"`)
+	assert.Contains(t, string(patchBytes), `"type":"ContainersReady"`)
+
+	patchType, patchBytes, err = buildConditionsPatch(conditions, []corev1.PodConditionType{corev1.PodReady}, StatusPatchTypeStrategic)
+	assert.NoError(t, err)
+	assert.Equal(t, types.StrategicMergePatchType, patchType)
+	assert.JSONEq(t,
+		`{"status":{"conditions":[{"type":"Ready","status":"False","lastProbeTime":null,"lastTransitionTime":null}]}}`,
+		string(patchBytes))
+}
+
+func TestStrategicPatchPreservesOtherConditions(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Status.Conditions = append(k8sPod.Status.Conditions,
+		corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue},
+		corev1.PodCondition{Type: "example.com/other", Status: corev1.ConditionTrue},
+	)
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	hc := newLocalHealthChecker()
+	hc.SetStatusPatchType(StatusPatchTypeStrategic)
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+
+	updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	statuses := make(map[corev1.PodConditionType]corev1.ConditionStatus)
+	for _, cond := range updated.Status.Conditions {
+		statuses[cond.Type] = cond.Status
+	}
+	assert.Equal(t, map[corev1.PodConditionType]corev1.ConditionStatus{
+		corev1.PodReady:     corev1.ConditionFalse,
+		corev1.PodScheduled: corev1.ConditionTrue,
+		"example.com/other": corev1.ConditionTrue,
+	}, statuses)
+}
+
+func TestHedgedProbeFirstSuccessWins(t *testing.T) {
+	var calls, canceled int32
+	probe := func(ctx context.Context) error {
+		// Only the second probe succeeds, the others hang until their timeout
+		if atomic.AddInt32(&calls, 1) == 2 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&canceled, 1)
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return errors.New("timeout")
+		}
+	}
+
+	start := time.Now()
+	err := hedgedProbe(context.Background(), 3, probe)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 3 }, time.Second, 10*time.Millisecond,
+		"all hedged probes should have been launched")
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&canceled) == 2 }, 500*time.Millisecond, 10*time.Millisecond,
+		"stragglers should be canceled once a probe succeeds")
+}
+
+func TestHedgedProbeAllFail(t *testing.T) {
+	var calls int32
+	err := hedgedProbe(context.Background(), 3, func(context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("refused")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestTCPProbeWithRetryHedged(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	config := &HealthCheckConfig{RetryCount: 0, ProbeTimeout: 100 * time.Millisecond, HedgedProbes: 4}
+	assert.NoError(t, tcpProbeWithRetry(context.Background(), ln.Addr().String(), config))
+}
+
+func TestPortRemapProbesMappedPort(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	adminPort := int32(ln.Addr().(*net.TCPAddr).Port)
+
+	var requested atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			requested.Add(1)
+		}
+	}))
+	defer server.Close()
+	httpAdminPort := int32(server.Listener.Addr().(*net.TCPAddr).Port)
+
+	declared, httpDeclared := closedPort(t), closedPort(t)
+	hc := newLocalHealthChecker()
+	tcpPod := &PodInfo{Namespace: "default", Name: "tcp", IP: "127.0.0.1", Ports: []int32{declared}}
+	httpPod := &PodInfo{Namespace: "default", Name: "http", IP: "127.0.0.1", Ports: []int32{httpDeclared},
+		HTTPTargets: []HTTPTarget{{Port: httpDeclared, Path: "/ready"}}}
+
+	// The declared ports are closed
+	assert.False(t, hc.performHealthCheck(context.Background(), tcpPod).Healthy)
+	assert.False(t, hc.performHealthCheck(context.Background(), httpPod).Healthy)
+
+	hc.SetPortRemap(map[int32]int32{declared: adminPort, httpDeclared: httpAdminPort})
+	assert.True(t, hc.performHealthCheck(context.Background(), tcpPod).Healthy)
+	assert.True(t, hc.performHealthCheck(context.Background(), httpPod).Healthy)
+	assert.Equal(t, int32(1), requested.Load())
+	assert.Equal(t, []HTTPTarget{{Port: httpDeclared, Path: "/ready"}}, httpPod.HTTPTargets, "pod targets are not modified")
+}
+
+func TestCheckICMPLoss(t *testing.T) {
+	tests := []struct {
+		name          string
+		sent, recv    int
+		lossThreshold float64
+		wantErr       error
+	}{
+		{"single packet answered", 1, 1, 100, nil},
+		{"single packet lost", 1, 0, 100, errICMPNoResponse},
+		{"any reply under the default threshold", 5, 1, 100, nil},
+		{"loss under the threshold", 5, 4, 50, nil},
+		{"loss at the threshold", 5, 3, 40, errICMPPacketLoss},
+		{"loss over the threshold", 5, 1, 50, errICMPPacketLoss},
+		{"zero threshold accepts any reply", 5, 1, 0, nil},
+		{"no reply at all", 5, 0, 50, errICMPNoResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := &goping.Statistics{
+				PacketsSent: tt.sent,
+				PacketsRecv: tt.recv,
+				PacketLoss:  float64(tt.sent-tt.recv) / float64(tt.sent) * 100,
+			}
+			err := checkICMPLoss("10.0.0.1", stats, tt.lossThreshold)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProbeWithRetryJittersAttemptTimeouts(t *testing.T) {
+	config := &HealthCheckConfig{RetryCount: 19, ProbeTimeout: time.Millisecond, TimeoutJitter: 0.2}
+	var timeouts []time.Duration
+	err := probeWithRetry(context.Background(), "TCP", "127.0.0.1:1", config, func(_ context.Context, addr string, timeout time.Duration) error {
+		timeouts = append(timeouts, timeout)
+		return fmt.Errorf("refused")
+	})
+	assert.Error(t, err)
+	assert.Len(t, timeouts, 20)
+
+	distinct := make(map[time.Duration]struct{})
+	for _, timeout := range timeouts {
+		assert.GreaterOrEqual(t, timeout, 800*time.Microsecond)
+		assert.LessOrEqual(t, timeout, 1200*time.Microsecond)
+		distinct[timeout] = struct{}{}
+	}
+	assert.Greater(t, len(distinct), 1, "attempt timeouts should vary")
+
+	// Without jitter every attempt uses the configured timeout
+	config.TimeoutJitter = 0
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, time.Millisecond, config.attemptTimeout())
+	}
+}
+
+func TestTCPHalfOpenProbe(t *testing.T) {
+	// The kernel completes handshakes into the backlog, but nothing ever accepts them
+	stuck, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer stuck.Close()
+
+	serving, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer serving.Close()
+	go func() {
+		for {
+			conn, err := serving.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	config := &HealthCheckConfig{RetryCount: 0, ProbeTimeout: 200 * time.Millisecond, HedgedProbes: 1}
+	assert.NoError(t, tcpProbeWithRetry(context.Background(), stuck.Addr().String(), config), "a bare connect can't tell")
+	assert.NoError(t, tcpProbeWithRetry(context.Background(), serving.Addr().String(), config))
+
+	config.TCPHalfOpen = true
+	err = tcpProbeWithRetry(context.Background(), stuck.Addr().String(), config)
+	assert.ErrorIs(t, err, errTCPNotServed)
+	assert.NoError(t, tcpProbeWithRetry(context.Background(), serving.Addr().String(), config))
+
+	// Canceling the check aborts the wait for the app
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, tcpHalfOpenProbe(ctx, stuck.Addr().String(), 5*time.Second))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestTerminatingPodNotPatched(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	now := metav1.Now()
+	k8sPod.DeletionTimestamp = &now
+	k8sPod.Finalizers = []string{"example.com/drain"}
+	clientset := fake.NewSimpleClientset(k8sPod)
+	hc := newLocalHealthChecker()
+
+	// Tracked before the deletion timestamp was observed, so only the API view shows it
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 0, countPatches(clientset))
+
+	// Tracked as terminating, no API call at all
+	clientset.ClearActions()
+	pod = &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}, Terminating: true}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Empty(t, clientset.Actions())
+
+	// Opting in restores patching
+	hc.SetPatchTerminating(true)
+	pod = &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}, Terminating: true}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+}
+
+func TestTLSProbeServerName(t *testing.T) {
+	// httptest certificates cover example.com and 127.0.0.1
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	assert.NoError(t, tlsProbe(context.Background(), addr, TLSProbeOptions{ServerName: "example.com"}, time.Second))
+	assert.NoError(t, tlsProbe(context.Background(), addr, TLSProbeOptions{}, time.Second), "no server name only checks the handshake")
+
+	err := tlsProbe(context.Background(), addr, TLSProbeOptions{ServerName: "payments.internal"}, time.Second)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, errTLSHostnameMismatch))
+
+	// A plain TCP listener fails the handshake, but not with a hostname mismatch
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			_ = conn.Close()
+		}
+	}()
+	err = tlsProbe(context.Background(), ln.Addr().String(), TLSProbeOptions{ServerName: "example.com"}, time.Second)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, errTLSHostnameMismatch))
+}
+
+func TestCheckPodTLSServerName(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	port := int32(server.Listener.Addr().(*net.TCPAddr).Port)
+
+	hc := newLocalHealthChecker()
+	matching := &PodInfo{Namespace: "default", Name: "tls-pod", IP: "127.0.0.1", Ports: []int32{port}, TLS: &TLSProbeOptions{ServerName: "example.com"}}
+	result := hc.performHealthCheck(context.Background(), matching)
+	assert.True(t, result.Healthy)
+	assert.Equal(t, ProtocolTLS, result.Protocol)
+
+	mismatching := &PodInfo{Namespace: "default", Name: "tls-pod", IP: "127.0.0.1", Ports: []int32{port}, TLS: &TLSProbeOptions{ServerName: "wrong.example.org"}}
+	result = hc.performHealthCheck(context.Background(), mismatching)
+	assert.False(t, result.Healthy)
+	assert.True(t, errors.Is(result.Err, errTLSHostnameMismatch))
+}
+
+func TestTLSProbeVerifyAndCertTTL(t *testing.T) {
+	// httptest serves a self-signed certificate valid until 2084
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := server.Listener.Addr().String()
+
+	err := tlsProbe(context.Background(), addr, TLSProbeOptions{ServerName: "example.com", Verify: true}, time.Second)
+	assert.ErrorIs(t, err, errTLSCertUntrusted)
+	assert.Equal(t, FailureClassTLS, classifyProbeError(err))
+
+	assert.NoError(t, tlsProbe(context.Background(), addr, TLSProbeOptions{MinTTL: 7 * 24 * time.Hour}, time.Second))
+	err = tlsProbe(context.Background(), addr, TLSProbeOptions{MinTTL: 100 * 365 * 24 * time.Hour}, time.Second)
+	assert.ErrorIs(t, err, errTLSCertExpiring)
+	assert.Equal(t, FailureClassTLS, classifyProbeError(err))
+
+	// HTTPS probes check the served certificate too
+	assert.NoError(t, httpProbe(context.Background(), server.URL, HTTPRequestOptions{CertMinTTL: 7 * 24 * time.Hour}, time.Second))
+	assert.ErrorIs(t, httpProbe(context.Background(), server.URL, HTTPRequestOptions{CertMinTTL: 100 * 365 * 24 * time.Hour}, time.Second),
+		errTLSCertExpiring)
+}
+
+func TestTLSProtocolAnnotation(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "verified", "10.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled": "true",
+		protocolAnnotation:                   "tls",
+	}))
+	podSet.AddOrUpdate(newReadyPod("default", "self-signed", "10.0.0.2", map[string]string{
+		"endpoint-health-checker.io/enabled": "true",
+		protocolAnnotation:                   "TLS",
+		tlsServerNameAnnotation:              "api.internal",
+		tlsSkipVerifyAnnotation:              "true",
+	}))
+	podSet.AddOrUpdate(newReadyPod("default", "unknown", "10.0.0.3", map[string]string{
+		"endpoint-health-checker.io/enabled": "true",
+		protocolAnnotation:                   "sctp",
+	}))
+
+	options := make(map[string]*TLSProbeOptions)
+	podSet.ForEach(func(pod *PodInfo) { options[pod.Name] = pod.GetTLSOptions() })
+	assert.Equal(t, &TLSProbeOptions{Verify: true}, options["verified"])
+	assert.Equal(t, &TLSProbeOptions{ServerName: "api.internal"}, options["self-signed"])
+	assert.Nil(t, options["unknown"], "an unsupported protocol keeps the default probe")
+}
+
+func TestStatusReassertAfterExternalReset(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	hc := newLocalHealthChecker()
+	hc.SetStatusReassertInterval(time.Minute)
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	// First failure is patched
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+
+	// An external actor resets our conditions
+	reset := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	reset.Spec.ReadinessGates = k8sPod.Spec.ReadinessGates
+	reset.Status.Conditions = append(reset.Status.Conditions,
+		corev1.PodCondition{Type: "endpointHealthCheckSuccess", Status: corev1.ConditionTrue})
+	_, err := clientset.CoreV1().Pods("default").UpdateStatus(context.Background(), reset, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	clientset.ClearActions()
+
+	// Within the interval the unchanged status is not re-checked
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Empty(t, clientset.Actions())
+
+	// After the interval the drift is detected and corrected
+	pod.SetLastAssertTime(time.Now().Add(-2 * time.Minute))
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+	updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.ConditionFalse, conditionStatus(updated, "endpointHealthCheckSuccess"))
+	assert.Equal(t, corev1.ConditionFalse, conditionStatus(updated, corev1.PodReady))
+
+	// A re-assert that finds conditions intact only verifies
+	clientset.ClearActions()
+	pod.SetLastAssertTime(time.Now().Add(-2 * time.Minute))
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 0, countPatches(clientset))
+	assert.Len(t, clientset.Actions(), 1, "only a get is expected")
+}
+
+func TestManageReadyAnnotation(t *testing.T) {
+	for _, tc := range []struct {
+		manageReady string
+		wantReady   corev1.ConditionStatus
+	}{
+		{"true", corev1.ConditionFalse},
+		{"false", corev1.ConditionTrue},
+	} {
+		t.Run("manage-ready="+tc.manageReady, func(t *testing.T) {
+			k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+			k8sPod.Annotations = map[string]string{manageReadyAnnotation: tc.manageReady}
+			k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+			clientset := fake.NewSimpleClientset(k8sPod)
+
+			hc := newLocalHealthChecker()
+			pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+			assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+			updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, corev1.ConditionFalse, conditionStatus(updated, "endpointHealthCheckSuccess"))
+			assert.Equal(t, tc.wantReady, conditionStatus(updated, corev1.PodReady))
+		})
+	}
+}
+
+func TestManageReadyFalseWithoutGateSkipsPatch(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Annotations = map[string]string{manageReadyAnnotation: "false"}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	hc := newLocalHealthChecker()
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 0, countPatches(clientset))
+}
+
+func TestReadyRestoredOnRecoveryWithoutGate(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestK8sPod("default", "test-pod", "127.0.0.1"))
+	hc := newLocalHealthChecker()
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+	ready := func() corev1.ConditionStatus {
+		k8sPod, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return conditionStatus(k8sPod, corev1.PodReady)
+	}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionFalse, ready())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	pod.Ports = []int32{int32(ln.Addr().(*net.TCPAddr).Port)}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionTrue, ready(), "recovery restores the Ready we set to False")
+	assert.Equal(t, 2, countPatches(clientset))
+
+	// Staying healthy patches nothing more
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 2, countPatches(clientset))
+}
+
+func TestProbeThresholdsDelayFlip(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	openPort := int32(ln.Addr().(*net.TCPAddr).Port)
+	deadPort := closedPort(t)
+
+	hc := newLocalHealthChecker()
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{openPort},
+		FailureThreshold: 3, SuccessThreshold: 2}
+	gate := func() corev1.ConditionStatus {
+		updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return conditionStatus(updated, "endpointHealthCheckSuccess")
+	}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionTrue, gate())
+
+	// Two failures stay below the failure threshold of 3
+	pod.Ports = []int32{deadPort}
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+		assert.Equal(t, corev1.ConditionTrue, gate())
+	}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionFalse, gate())
+
+	// Recovery needs two consecutive successes
+	pod.Ports = []int32{openPort}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionFalse, gate())
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionTrue, gate())
+}
+
+func TestRecoveryGuardRequiresContinuousPasses(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	openPort := int32(ln.Addr().(*net.TCPAddr).Port)
+	deadPort := closedPort(t)
+
+	hc := newLocalHealthChecker()
+	hc.SetRecoveryGuard(time.Minute)
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{deadPort}}
+	gate := func() corev1.ConditionStatus {
+		updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return conditionStatus(updated, "endpointHealthCheckSuccess")
+	}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionFalse, gate())
+
+	// A brief pass between restarts does not restore the pod
+	pod.Ports = []int32{openPort}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionFalse, gate())
+
+	// Failing again restarts the guard
+	pod.Ports = []int32{deadPort}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	pod.Ports = []int32{openPort}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionFalse, gate())
+	assert.WithinDuration(t, time.Now(), pod.GetPassingSince(), time.Second)
+
+	// Once passing for the full guard duration the pod recovers
+	pod.PassingSince = time.Now().Add(-time.Minute)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, corev1.ConditionTrue, gate())
+}
+
+// recordingEventRecorder captures the object each event was recorded on
+type recordingEventRecorder struct {
+	*record.FakeRecorder
+	objects []*corev1.ObjectReference
+}
+
+func (r *recordingEventRecorder) Event(object runtime.Object, eventType, reason, message string) {
+	r.objects = append(r.objects, object.(*corev1.ObjectReference))
+	r.FakeRecorder.Event(object, eventType, reason, message)
+}
+
+func TestTransitionEventOnOwningDeployment(t *testing.T) {
+	controllerRef := true
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default", Name: "web-7d9f8",
+		OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "web", UID: "deploy-uid", Controller: &controllerRef}},
+	}}
+	k8sPod := newTestK8sPod("default", "web-7d9f8-abcde", "127.0.0.1")
+	k8sPod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-7d9f8", UID: "rs-uid", Controller: &controllerRef}}
+	clientset := fake.NewSimpleClientset(k8sPod, rs)
+
+	recorder := &recordingEventRecorder{FakeRecorder: record.NewFakeRecorder(10)}
+	hc := newLocalHealthChecker()
+	hc.SetEventRecorder(recorder, EventTargetOwner)
+	pod := &PodInfo{Namespace: "default", Name: "web-7d9f8-abcde", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	if assert.Len(t, recorder.objects, 1) {
+		assert.Equal(t, "Deployment", recorder.objects[0].Kind)
+		assert.Equal(t, "web", recorder.objects[0].Name)
+		assert.Equal(t, types.UID("deploy-uid"), recorder.objects[0].UID)
+	}
+	assert.Equal(t, "Warning EndpointUnhealthy Pod web-7d9f8-abcde failed endpoint health check", <-recorder.Events)
+
+	// Without the ReplicaSet the event lands on the ReplicaSet reference
+	assert.NoError(t, clientset.AppsV1().ReplicaSets("default").Delete(context.Background(), "web-7d9f8", metav1.DeleteOptions{}))
+	k8sPod.Name = "other"
+	assert.Equal(t, "ReplicaSet", ownerObjectReference(context.Background(), clientset, k8sPod).Kind)
+}
+
+func TestTransitionEventFallsBackToPod(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestK8sPod("default", "test-pod", "127.0.0.1"))
+	recorder := &recordingEventRecorder{FakeRecorder: record.NewFakeRecorder(10)}
+	hc := newLocalHealthChecker()
+	hc.SetEventRecorder(recorder, EventTargetOwner)
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	if assert.Len(t, recorder.objects, 1) {
+		assert.Equal(t, "Pod", recorder.objects[0].Kind)
+		assert.Equal(t, "test-pod", recorder.objects[0].Name)
+	}
+
+	// An unchanged status records nothing new
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Len(t, recorder.objects, 1)
+}
+
+func TestAdoptionWarmupOnlyRecordsMetrics(t *testing.T) {
+	podSet := NewPodSet()
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Annotations = map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	k8sPod.Spec.Containers = []corev1.Container{{Name: "app", ReadinessProbe: &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(int(closedPort(t)))}},
+	}}}
+	podSet.AddOrUpdate(k8sPod)
+	pod := podSet.pods["127.0.0.1"]
+	adoptedAt := pod.AdoptedAt
+	assert.False(t, adoptedAt.IsZero())
+
+	// Updates of a tracked pod keep its adoption time
+	podSet.AddOrUpdate(k8sPod)
+	pod = podSet.pods["127.0.0.1"]
+	assert.Equal(t, adoptedAt, pod.AdoptedAt)
+
+	clientset := fake.NewSimpleClientset(k8sPod)
+	recorder := &recordingEventRecorder{FakeRecorder: record.NewFakeRecorder(10)}
+	hc := newLocalHealthChecker()
+	hc.SetEventRecorder(recorder, EventTargetPod)
+	hc.SetAdoptionWarmup(time.Hour)
+
+	refused := metrics.ChecksCounter(ProtocolTCP, FailureClassRefused)
+	before := testutil.ToFloat64(refused)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, before+1, testutil.ToFloat64(refused))
+	assert.Equal(t, 0, countPatches(clientset))
+	assert.Empty(t, recorder.objects)
+	assert.Nil(t, pod.GetLastHealthStatus())
+
+	// Once the warmup is over the failure is enforced
+	pod.AdoptedAt = time.Now().Add(-2 * time.Hour)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, before+2, testutil.ToFloat64(refused))
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.Len(t, recorder.objects, 1)
+}
+
+func TestAllowedProbeCIDRs(t *testing.T) {
+	mustParseCIDR := func(s string) *net.IPNet {
+		_, cidr, err := net.ParseCIDR(s)
+		assert.NoError(t, err)
+		return cidr
+	}
+
+	tests := []struct {
+		name  string
+		cidrs []*net.IPNet
+		want  corev1.ConditionStatus
+	}{
+		{"in CIDR is probed", []*net.IPNet{mustParseCIDR("10.16.0.0/16"), mustParseCIDR("127.0.0.0/8")}, corev1.ConditionFalse},
+		{"out of CIDR is Unknown", []*net.IPNet{mustParseCIDR("10.16.0.0/16")}, corev1.ConditionUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+			k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+			clientset := fake.NewSimpleClientset(k8sPod)
+
+			hc := newLocalHealthChecker()
+			hc.SetAllowedProbeCIDRs(tt.cidrs)
+			pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+			assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+			got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, conditionStatus(got, "endpointHealthCheckSuccess"))
+			assert.Equal(t, tt.want, conditionStatus(got, corev1.PodReady))
+			assert.Equal(t, tt.want == corev1.ConditionUnknown, pod.GetLastHealthStatus() == nil)
+		})
+	}
+
+	hc := NewHealthChecker()
+	assert.True(t, hc.probeTargetAllowed("203.0.113.7"), "no CIDRs allow any address")
+	hc.SetAllowedProbeCIDRs([]*net.IPNet{mustParseCIDR("fd00:10:16::/64")})
+	assert.True(t, hc.probeTargetAllowed("fd00:10:16::5"))
+	assert.False(t, hc.probeTargetAllowed("fd00:10:17::5"))
+	assert.False(t, hc.probeTargetAllowed("not-an-ip"))
+}
+
+// startUDPServer answers each datagram with reply followed by the request, or stays silent when reply is nil
+func startUDPServer(t *testing.T, reply []byte) int32 {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if reply != nil {
+				_, _ = conn.WriteTo(append(append([]byte{}, reply...), buf[:n]...), addr)
+			}
+		}
+	}()
+	return int32(conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func TestUDPProbe(t *testing.T) {
+	echo := fmt.Sprintf("127.0.0.1:%d", startUDPServer(t, []byte("pong ")))
+	assert.NoError(t, udpProbe(context.Background(), echo, []byte("ping"), nil, time.Second))
+	assert.NoError(t, udpProbe(context.Background(), echo, []byte("ping"), []byte("pong ping"), time.Second))
+	assert.ErrorIs(t, udpProbe(context.Background(), echo, []byte("ping"), []byte("PONG"), time.Second), errUnexpectedUDPResponse)
+
+	// Without a response within the timeout the app is not considered serving
+	silent := fmt.Sprintf("127.0.0.1:%d", startUDPServer(t, nil))
+	err := udpProbe(context.Background(), silent, []byte("ping"), nil, 50*time.Millisecond)
+	assert.Equal(t, FailureClassTimeout, classifyProbeError(err))
+
+	// Canceling the check aborts the wait for a response
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.Error(t, udpProbe(ctx, silent, []byte("ping"), nil, 5*time.Second))
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A closed port answers with ICMP port unreachable
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	closed := conn.LocalAddr().String()
+	_ = conn.Close()
+	err = udpProbe(context.Background(), closed, []byte("ping"), nil, time.Second)
+	assert.Equal(t, FailureClassRefused, classifyProbeError(err))
+}
+
+func TestUDPProtocolAnnotation(t *testing.T) {
+	port := startUDPServer(t, []byte("pong "))
+	k8sPod := newReadyPod("default", "dns", "127.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled":           "true",
+		"endpoint-health-checker.io/protocol":          "udp",
+		"endpoint-health-checker.io/udp-payload":       "0x70696e67",
+		"endpoint-health-checker.io/udp-expect-prefix": "pong",
+	})
+	k8sPod.Spec.Containers = []corev1.Container{{
+		Name:           "dns",
+		Ports:          []corev1.ContainerPort{{ContainerPort: port, Protocol: corev1.ProtocolUDP}, {ContainerPort: 9153}},
+		ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8181)}}},
+	}}
+
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(k8sPod)
+	pod := podSet.pods["127.0.0.1"]
+	assert.Equal(t, []int32{port}, pod.GetPorts())
+	assert.Equal(t, &UDPProbeOptions{Payload: []byte("ping"), ExpectPrefix: []byte("pong")}, pod.GetUDPOptions())
+
+	result := newLocalHealthChecker().performHealthCheck(context.Background(), pod)
+	assert.Equal(t, ProtocolUDP, result.Protocol)
+	assert.True(t, result.Healthy)
+
+	// Other protocols are rejected and the pod keeps its TCP probe ports
+	k8sPod.Annotations["endpoint-health-checker.io/protocol"] = "sctp"
+	podSet.AddOrUpdate(k8sPod)
+	pod = podSet.pods["127.0.0.1"]
+	assert.Nil(t, pod.GetUDPOptions())
+	assert.Equal(t, []int32{8181}, pod.GetPorts())
+}
+
+func TestHealthyIntervalBackoff(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	openPort := int32(ln.Addr().(*net.TCPAddr).Port)
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(time.Second)
+	hc.SetHealthyIntervalBackoff(2, 5*time.Second)
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{openPort}}
+
+	// Grows on sustained success up to the cap
+	var intervals []time.Duration
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+		intervals = append(intervals, pod.GetCheckInterval())
+	}
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, intervals)
+
+	// Any failure resets to the base interval
+	pod.Ports = []int32{closedPort(t)}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, time.Second, pod.GetCheckInterval())
+}
+
+func TestMinHealthyPerServiceDefersLastHealthyPod(t *testing.T) {
+	deadPort := closedPort(t)
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+	var k8sPods []runtime.Object
+	for i, name := range []string{"web-0", "web-1"} {
+		pod := newReadyPod("default", name, fmt.Sprintf("127.0.0.%d", i+1), enabled)
+		pod.Labels = map[string]string{"app": "web"}
+		podSet.AddOrUpdate(pod)
+		k8sPods = append(k8sPods, pod)
+	}
+	clientset := fake.NewSimpleClientset(k8sPods...)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}))
+
+	var web0, web1 *PodInfo
+	podSet.ForEach(func(pod *PodInfo) {
+		pod.Ports = []int32{deadPort}
+		pod.SetLastHealthStatus(true)
+		if pod.GetName() == "web-0" {
+			web0 = pod
+		} else {
+			web1 = pod
+		}
+	})
+	web1.SetLastHealthStatus(false)
+
+	hc := newLocalHealthChecker()
+	hc.SetMinHealthyPerService(listersv1.NewServiceLister(indexer), podSet, 1)
+	deferredBefore := testutil.ToFloat64(metrics.UnhealthyDeferredCounter("default", "web"))
+
+	// web-0 is the last healthy endpoint of the Service, its failure is not patched
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, web0))
+	assert.Equal(t, 0, countPatches(clientset))
+	assert.True(t, *web0.GetLastHealthStatus())
+	assert.Equal(t, deferredBefore+1, testutil.ToFloat64(metrics.UnhealthyDeferredCounter("default", "web")))
+
+	// Once a sibling is healthy again the failure goes through
+	web1.SetLastHealthStatus(true)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, web0))
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.False(t, *web0.GetLastHealthStatus())
+}
+
+func TestMinHealthyPerServiceReservesTransitions(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+	for i, name := range []string{"web-0", "web-1", "web-2"} {
+		pod := newReadyPod("default", name, fmt.Sprintf("10.0.0.%d", i+1), enabled)
+		pod.Labels = map[string]string{"app": "web"}
+		podSet.AddOrUpdate(pod)
+	}
+	pods := make(map[string]*PodInfo)
+	podSet.ForEach(func(pod *PodInfo) { pods[pod.GetName()] = pod })
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}))
+	guard := &serviceGuard{lister: listersv1.NewServiceLister(indexer), podSet: podSet, minHealthy: 1}
+
+	// Transitions still being written count against the Service like written ones
+	_, reserved := guard.reserve(pods["web-0"])
+	assert.True(t, reserved)
+	_, reserved = guard.reserve(pods["web-1"])
+	assert.True(t, reserved)
+	service, reserved := guard.reserve(pods["web-2"])
+	assert.False(t, reserved, "web-2 is the last endpoint not on its way down")
+	assert.Equal(t, "web", service)
+
+	// An abandoned transition, such as a failed patch, gives its endpoint back
+	guard.release(pods["web-0"])
+	_, reserved = guard.reserve(pods["web-2"])
+	assert.True(t, reserved)
+}
+
+func TestTimeoutEscalationTellsSlowFromDead(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+	}))
+	defer slow.Close()
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+
+	podFor := func(name string, server *httptest.Server) (*PodInfo, *corev1.Pod) {
+		port := int32(server.Listener.Addr().(*net.TCPAddr).Port)
+		k8sPod := newReadyPod("escalation", name, "127.0.0.1", nil)
+		k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+		return &PodInfo{Namespace: "escalation", Name: name, IP: "127.0.0.1", Ports: []int32{port},
+			HTTPTargets: []HTTPTarget{{Port: port, Path: "/"}}}, k8sPod
+	}
+	slowPod, slowK8sPod := podFor("slow", slow)
+	hungPod, hungK8sPod := podFor("hung", hung)
+	clientset := fake.NewSimpleClientset(slowK8sPod, hungK8sPod)
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckTimeout(50 * time.Millisecond)
+	hc.SetRetryCount(0)
+	hc.SetTimeoutEscalation(2, 400*time.Millisecond)
+	slowBefore := testutil.ToFloat64(metrics.TimeoutEscalationsCounter("escalation", metrics.EscalationSlow))
+	deadBefore := testutil.ToFloat64(metrics.TimeoutEscalationsCounter("escalation", metrics.EscalationDead))
+
+	// 50ms, 50ms and 100ms time out, the 200ms escalation lets the slow pod answer
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, slowPod))
+		assert.False(t, *slowPod.GetLastHealthStatus())
+	}
+	assert.Equal(t, 200*time.Millisecond, hc.escalatedTimeout(slowPod))
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, slowPod))
+	assert.True(t, *slowPod.GetLastHealthStatus(), "escalation reveals the pod is slow but alive")
+	assert.Equal(t, slowBefore+1, testutil.ToFloat64(metrics.TimeoutEscalationsCounter("escalation", metrics.EscalationSlow)))
+	assert.Equal(t, 200*time.Millisecond, hc.escalatedTimeout(slowPod), "a slow pod keeps the timeout it needs")
+
+	// A hung pod times out all the way to the cap
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, hungPod))
+	}
+	assert.False(t, *hungPod.GetLastHealthStatus())
+	assert.Equal(t, 400*time.Millisecond, hc.escalatedTimeout(hungPod))
+	assert.Equal(t, deadBefore+1, testutil.ToFloat64(metrics.TimeoutEscalationsCounter("escalation", metrics.EscalationDead)))
+}
+
+func TestCheckDeadlineCoversEscalatedTimeout(t *testing.T) {
+	hc := NewHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	hc.SetRetryCount(3)
+	hc.SetTimeoutEscalation(1, 30*time.Second)
+	pod := &PodInfo{Namespace: "default", Name: "web-0", IP: "10.0.0.1", Ports: []int32{8080, 9090}}
+
+	// Two ports, four attempts each at the base timeout
+	assert.Equal(t, 8*time.Second+checkAPIBudget, hc.checkDeadline(pod))
+
+	// At the cap every attempt may take the escalated timeout, beyond any fixed deadline
+	pod.TimeoutStreak = 10
+	assert.Equal(t, 30*time.Second, hc.escalatedTimeout(pod))
+	assert.Equal(t, 240*time.Second+checkAPIBudget, hc.checkDeadline(pod))
+}
+
+func TestMaxTransitionsPerHourSuppressesFlapping(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	openPort := int32(ln.Addr().(*net.TCPAddr).Port)
+	deadPort := closedPort(t)
+
+	k8sPod := newReadyPod("default", "flappy", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+	pod := &PodInfo{Namespace: "default", Name: "flappy", IP: "127.0.0.1"}
+
+	hc := newLocalHealthChecker()
+	hc.SetMaxTransitionsPerHour(2)
+	suppressedBefore := testutil.ToFloat64(metrics.TransitionsSuppressedCounter("default"))
+
+	// The first two flips go through
+	pod.Ports = []int32{deadPort}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	pod.Ports = []int32{openPort}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 2, countPatches(clientset))
+	assert.True(t, *pod.GetLastHealthStatus())
+
+	// Further flips are suppressed and the pod stays pinned healthy
+	pod.Ports = []int32{deadPort}
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	}
+	assert.Equal(t, 2, countPatches(clientset))
+	assert.True(t, *pod.GetLastHealthStatus())
+	assert.Equal(t, suppressedBefore+3, testutil.ToFloat64(metrics.TransitionsSuppressedCounter("default")))
+
+	// Once the window moves past the earlier transitions the flip is allowed again
+	for i := range pod.TransitionTimes {
+		pod.TransitionTimes[i] = pod.TransitionTimes[i].Add(-time.Hour)
+	}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 3, countPatches(clientset))
+	assert.False(t, *pod.GetLastHealthStatus())
+}
+
+func TestFailedStatusWriteKeepsTransitionBudget(t *testing.T) {
+	deadPort := closedPort(t)
+
+	k8sPod := newReadyPod("default", "flappy", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+	failWrites := true
+	clientset.PrependReactor("patch", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if failWrites {
+			return true, nil, errors.New("apiserver unavailable")
+		}
+		return false, nil, nil
+	})
+	pod := &PodInfo{Namespace: "default", Name: "flappy", IP: "127.0.0.1", Ports: []int32{deadPort}}
+
+	hc := newLocalHealthChecker()
+	hc.SetMaxTransitionsPerHour(1)
+
+	// Failed writes leave the status unchanged and don't use up the budget
+	for i := 0; i < 3; i++ {
+		assert.Error(t, hc.CheckPod(context.Background(), clientset, pod))
+	}
+	assert.Empty(t, pod.TransitionTimes)
+	assert.Nil(t, pod.GetLastHealthStatus())
+
+	// The transition goes through once the API server accepts it
+	failWrites = false
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Len(t, pod.TransitionTimes, 1)
+	assert.False(t, *pod.GetLastHealthStatus())
+}
+
+func TestRequireAllPortsOnAdoptionKeepsPodPending(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	upPort := int32(ln.Addr().(*net.TCPAddr).Port)
+	neverUp := closedPort(t)
+
+	k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	clientset := fake.NewSimpleClientset(k8sPod)
+	pod := &PodInfo{Namespace: "default", Name: "web-0", IP: "127.0.0.1", Ports: []int32{upPort, neverUp}}
+
+	hc := newLocalHealthChecker()
+	hc.SetRequireAllPortsOnAdoption(true)
+
+	// One port never comes up: the pod stays pending, neither patched nor counted
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	}
+	assert.Equal(t, 0, countPatches(clientset))
+	assert.Nil(t, pod.GetLastHealthStatus())
+	assert.Equal(t, int32(0), pod.Failures)
+
+	// Once it answered, adoption completes and later failures are enforced
+	ln2, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", neverUp))
+	if err != nil {
+		t.Skipf("port %d taken: %v", neverUp, err)
+	}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.True(t, *pod.GetLastHealthStatus())
+
+	ln2.Close()
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.False(t, *pod.GetLastHealthStatus())
+}
+
+func TestAdoptionSurvivesPodUpdates(t *testing.T) {
+	k8sPod := newReadyPod("default", "web-0", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(k8sPod)
+	pod := podSet.GetAvailablePods()[0]
+	pod.Ports = []int32{8080}
+	assert.Empty(t, pod.RecordPortsUp([]int32{8080}))
+
+	podSet.AddOrUpdate(k8sPod)
+	updated := podSet.GetAvailablePods()[0]
+	updated.Ports = []int32{8080, 9090}
+	assert.Equal(t, []int32{9090}, updated.RecordPortsUp(nil))
+}
+
+func TestProbeThresholdsSurvivePodUpdates(t *testing.T) {
+	k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	k8sPod.UID = "uid-1"
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	k8sPod.Spec.Containers = []corev1.Container{{
+		Name: "web",
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler:     corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(int(closedPort(t)))}},
+			FailureThreshold: 3,
+		},
+	}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+	gate := func() corev1.ConditionStatus {
+		updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return conditionStatus(updated, "endpointHealthCheckSuccess")
+	}
+
+	hc := newLocalHealthChecker()
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(k8sPod)
+
+	// Updates of the pod between failing checks, as our own patches cause, keep the count going
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, podSet.GetAvailablePods()[0]))
+		podSet.AddOrUpdate(k8sPod)
+	}
+	assert.Equal(t, corev1.ConditionTrue, gate(), "two failures stay below the threshold of 3")
+	assert.Equal(t, int32(2), podSet.GetAvailablePods()[0].Failures)
+
+	// An update while the check runs replaces the entry, the check's result is carried over
+	pod := podSet.GetAvailablePods()[0]
+	podSet.SetBeingChecked(pod.GetIP(), true)
+	podSet.AddOrUpdate(k8sPod)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	podSet.FinishCheck(pod)
+	assert.Equal(t, corev1.ConditionFalse, gate())
+	if pods := podSet.GetAvailablePods(); assert.Len(t, pods, 1) {
+		assert.Equal(t, int32(3), pods[0].Failures)
+		assert.False(t, *pods[0].GetLastHealthStatus())
+	}
+}
+
+func TestRequireKubeletReadyCombinations(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+	openPort := int32(ln.Addr().(*net.TCPAddr).Port)
+	deadPort := closedPort(t)
+
+	tests := []struct {
+		kubeletReady bool
+		reachable    bool
+		expected     corev1.ConditionStatus
+	}{
+		{kubeletReady: true, reachable: true, expected: corev1.ConditionTrue},
+		{kubeletReady: true, reachable: false, expected: corev1.ConditionFalse},
+		{kubeletReady: false, reachable: true, expected: corev1.ConditionFalse},
+		{kubeletReady: false, reachable: false, expected: corev1.ConditionFalse},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("kubelet=%v,reachable=%v", tt.kubeletReady, tt.reachable), func(t *testing.T) {
+			k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+			k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+			k8sPod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", Ready: tt.kubeletReady}}
+			clientset := fake.NewSimpleClientset(k8sPod)
+
+			podSet := NewPodSet()
+			podSet.AddOrUpdate(k8sPod)
+			pod := podSet.GetAvailablePods()[0]
+			pod.Ports = []int32{deadPort}
+			if tt.reachable {
+				pod.Ports = []int32{openPort}
+			}
+
+			hc := newLocalHealthChecker()
+			hc.SetRequireKubeletReady(true)
+			assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+
+			got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, conditionStatus(got, "endpointHealthCheckSuccess"))
+			assert.Equal(t, tt.expected == corev1.ConditionTrue, *pod.GetLastHealthStatus())
+		})
+	}
+}
+
+func TestContainersReadyFollowsKubeletAfterReadyForcedFalse(t *testing.T) {
+	k8sPod := newReadyPod("default", "web-0", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	k8sPod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "app", Ready: true}}
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(k8sPod)
+	pod := podSet.GetAvailablePods()[0]
+	assert.True(t, pod.GetContainersReady())
+
+	// Ready is False, whether forced by us or by kubelet, but the entry is still tracked
+	notReady := k8sPod.DeepCopy()
+	notReady.Status.Conditions[0].Status = corev1.ConditionFalse
+	notReady.Status.ContainerStatuses[0].Ready = false
+	podSet.AddOrUpdate(notReady)
+	assert.False(t, pod.GetContainersReady())
+}
+
+func TestPerPodProbeOverrides(t *testing.T) {
+	podSet := NewPodSet()
+	for _, p := range []struct{ name, ip, timeout, retries string }{
+		{"tuned", "10.0.0.1", "3s", "0"},
+		{"invalid", "10.0.0.2", "-1s", "-2"},
+		{"global", "10.0.0.3", "", ""},
+	} {
+		annotations := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+		if p.timeout != "" {
+			annotations["endpoint-health-checker.io/timeout"] = p.timeout
+			annotations["endpoint-health-checker.io/retry-count"] = p.retries
+		}
+		podSet.AddOrUpdate(newReadyPod("default", p.name, p.ip, annotations))
+	}
+	tuned, invalid, global := podSet.GetPod("default", "tuned"), podSet.GetPod("default", "invalid"), podSet.GetPod("default", "global")
+
+	hc := NewHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	hc.SetRetryCount(5)
+
+	// The annotation takes precedence, 0 retries included
+	assert.Equal(t, 3*time.Second, hc.probeTimeout(tuned))
+	assert.Equal(t, 0, hc.probeRetries(tuned))
+	cfg := hc.EffectiveConfig(tuned)
+	assert.Equal(t, "3s", cfg.Timeout)
+	assert.Equal(t, 0, cfg.RetryCount)
+
+	// The check deadline follows the overrides, 3 retries of 3s take 12s before the API calls
+	slow := newReadyPod("default", "slow", "10.0.0.4", map[string]string{"endpoint-health-checker.io/enabled": "true",
+		"endpoint-health-checker.io/timeout": "3s", "endpoint-health-checker.io/retry-count": "3"})
+	podSet.AddOrUpdate(slow)
+	assert.Equal(t, 12*time.Second+checkAPIBudget, hc.checkDeadline(podSet.GetPod("default", "slow")))
+	assert.Equal(t, (12*time.Second + checkAPIBudget).String(), hc.EffectiveConfig(podSet.GetPod("default", "slow")).Deadline)
+
+	// Invalid values and absent annotations fall back to the global settings
+	for _, pod := range []*PodInfo{invalid, global} {
+		assert.Equal(t, time.Second, hc.probeTimeout(pod), pod.Name)
+		assert.Equal(t, 5, hc.probeRetries(pod), pod.Name)
+	}
+
+	// A global count of 0 disables retries, as a reload may set it
+	hc.SetRetryCount(0)
+	assert.Equal(t, 0, hc.probeRetries(global))
+	hc.SetRetryCount(-1)
+	assert.Equal(t, 0, hc.probeRetries(global), "negative counts are ignored")
+	hc.SetRetryCount(5)
+
+	// The annotation also wins over zone timeouts, and escalation still applies on top
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name, zone := range map[string]string{"checker-node": "zone-a", "remote-node": "zone-b"} {
+		assert.NoError(t, indexer.Add(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelTopologyZone: zone}}}))
+	}
+	hc.SetZoneTimeouts(listersv1.NewNodeLister(indexer), "checker-node", 0, 2*time.Second)
+	tuned.NodeName, global.NodeName = "remote-node", "remote-node"
+	assert.Equal(t, 2*time.Second, hc.probeTimeout(global))
+	hc.SetTimeoutEscalation(1, 10*time.Second)
+	tuned.TimeoutStreak = 1
+	assert.Equal(t, 3*time.Second, hc.probeTimeout(tuned))
+	assert.Equal(t, 6*time.Second, hc.escalatedTimeout(tuned))
+}
+
+func TestCustomReadinessGateType(t *testing.T) {
+	k8sPod := newTestK8sPod("default", "test-pod", "127.0.0.1")
+	k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "tenantAHealthy"}}
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	hc := newLocalHealthChecker()
+	hc.SetReadinessGateType("tenantAHealthy")
+	pod := &PodInfo{Namespace: "default", Name: "test-pod", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+
+	updated, err := clientset.CoreV1().Pods("default").Get(context.Background(), "test-pod", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.ConditionFalse, conditionStatus(updated, "tenantAHealthy"))
+	assert.Empty(t, conditionStatus(updated, "endpointHealthCheckSuccess"), "the default gate is left alone")
+}
@@ -0,0 +1,371 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// HTTPTarget is an HTTP health endpoint declared by a container probe
+type HTTPTarget struct {
+	Port   int32
+	Path   string
+	Scheme string // "http" or "https", empty means http
+	// Host and Headers come from the probe spec so requests match what the kubelet sends
+	Host    string
+	Headers http.Header
+}
+
+// HTTPRequestOptions are the per-pod settings applied to every HTTP probe request
+type HTTPRequestOptions struct {
+	Method    string // GET when empty
+	Body      string
+	Headers   http.Header
+	TokenFile string // Bearer token read from this file on every probe, so rotation is picked up
+	// ExpectHeaders are response headers that must be present with the given value
+	ExpectHeaders []HeaderAssertion
+	// ExpectVersion compares a version field of the JSON response body, nil skips the check
+	ExpectVersion *VersionAssertion
+	// ExpectCodes are the status codes that indicate health, empty means 200-399
+	ExpectCodes []int
+	// CertMinTTL fails HTTPS probes whose certificate expires within this window, 0 disables
+	CertMinTTL time.Duration
+}
+
+// statusExpected reports whether a response status code indicates health
+func (o HTTPRequestOptions) statusExpected(code int) bool {
+	if len(o.ExpectCodes) == 0 {
+		return code >= http.StatusOK && code < http.StatusBadRequest
+	}
+	for _, expected := range o.ExpectCodes {
+		if code == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// Version comparison modes of a VersionAssertion
+const (
+	// VersionMatchEqual requires the served version to equal the expected one
+	VersionMatchEqual = "equal"
+	// VersionMatchSemverAtLeast requires the served version to be semver >= the expected one
+	VersionMatchSemverAtLeast = "semver-gte"
+)
+
+// VersionAssertion requires the JSON response body to report an expected version, so a
+// pod still serving an old version of a rollout is not ready for traffic
+type VersionAssertion struct {
+	Field    string // Dot-separated path of the version in the JSON body, e.g. "build.version"
+	Expected string
+	Match    string // VersionMatchEqual or VersionMatchSemverAtLeast
+}
+
+// maxVersionBodyBytes bounds how much of a response is read looking for the version
+const maxVersionBodyBytes = 1 << 20
+
+// HeaderAssertion requires a response header to carry a value
+type HeaderAssertion struct {
+	Name  string
+	Value string
+}
+
+// parseHTTPMethod validates a probe method and returns it in canonical upper case
+func parseHTTPMethod(method string) (string, error) {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodOptions:
+		return method, nil
+	}
+	return "", fmt.Errorf("unsupported HTTP probe method %q", method)
+}
+
+// parseExpectedHeaders parses assertions such as "X-Health=ok,X-Role=primary"
+func parseExpectedHeaders(s string) ([]HeaderAssertion, error) {
+	var assertions []HeaderAssertion
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header assertion %q, expected Name=value", entry)
+		}
+		assertions = append(assertions, HeaderAssertion{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return assertions, nil
+}
+
+// parseHTTPHeaders parses a header list such as "Host=svc.internal,Authorization=Bearer xyz".
+// Only the first "=" separates name and value, so values may contain "=" but not ",".
+func parseHTTPHeaders(s string) (http.Header, error) {
+	headers := make(http.Header)
+	for i, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			// The entry may carry a secret, so only its position is reported
+			return nil, fmt.Errorf("invalid header in entry %d, expected Name=value", i+1)
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// parseExpectedCodes parses a status code list such as "200,401,503"
+func parseExpectedCodes(s string) ([]int, error) {
+	var codes []int
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		code, err := strconv.Atoi(entry)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid HTTP status code %q", entry)
+		}
+		codes = append(codes, code)
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no HTTP status codes in %q", s)
+	}
+	return codes, nil
+}
+
+// parseVersionMatch validates a version comparison mode, defaulting to equality
+func parseVersionMatch(match string) (string, error) {
+	switch match = strings.ToLower(strings.TrimSpace(match)); match {
+	case "":
+		return VersionMatchEqual, nil
+	case VersionMatchEqual, VersionMatchSemverAtLeast:
+		return match, nil
+	}
+	return "", fmt.Errorf("unsupported version match %q, expected %s or %s", match, VersionMatchEqual, VersionMatchSemverAtLeast)
+}
+
+// checkVersion reads the asserted field from a JSON body and compares it to the expected version
+func (a *VersionAssertion) checkVersion(body io.Reader) error {
+	var doc interface{}
+	if err := json.NewDecoder(io.LimitReader(body, maxVersionBodyBytes)).Decode(&doc); err != nil {
+		return fmt.Errorf("response is not JSON: %w", err)
+	}
+	value := doc
+	for _, key := range strings.Split(a.Field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("version field %s not found", a.Field)
+		}
+		if value, ok = object[key]; !ok {
+			return fmt.Errorf("version field %s not found", a.Field)
+		}
+	}
+	served, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("version field %s is not a string", a.Field)
+	}
+
+	if a.Match == VersionMatchSemverAtLeast {
+		servedVersion, err := version.ParseSemantic(served)
+		if err != nil {
+			return fmt.Errorf("served version %q: %w", served, err)
+		}
+		expectedVersion, err := version.ParseSemantic(a.Expected)
+		if err != nil {
+			return fmt.Errorf("expected version %q: %w", a.Expected, err)
+		}
+		if !servedVersion.AtLeast(expectedVersion) {
+			return fmt.Errorf("serves version %s, expected at least %s", served, a.Expected)
+		}
+		return nil
+	}
+	if served != a.Expected {
+		return fmt.Errorf("serves version %s, expected %s", served, a.Expected)
+	}
+	return nil
+}
+
+// parseHTTPURLs parses a list of endpoints on the pod IP such as ":8080/live,:8080/ready"
+func parseHTTPURLs(s string) ([]HTTPTarget, error) {
+	var targets []HTTPTarget
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		hostPort, path, _ := strings.Cut(strings.TrimPrefix(entry, ":"), "/")
+		port, err := strconv.ParseUint(hostPort, 10, 16)
+		if err != nil || port == 0 {
+			return nil, fmt.Errorf("invalid HTTP URL %q, expected :port/path", entry)
+		}
+		targets = append(targets, HTTPTarget{Port: int32(port), Path: "/" + path})
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no HTTP URLs in %q", s)
+	}
+	return targets, nil
+}
+
+// mergeTargetPorts adds the ports of the HTTP targets to the probed ports
+func mergeTargetPorts(ports []int32, targets []HTTPTarget) []int32 {
+	seen := make(map[int32]struct{}, len(ports))
+	for _, port := range ports {
+		seen[port] = struct{}{}
+	}
+	for _, target := range targets {
+		if _, exists := seen[target.Port]; !exists {
+			seen[target.Port] = struct{}{}
+			ports = append(ports, target.Port)
+		}
+	}
+	return ports
+}
+
+// httpTargetURL builds the probe URL for a target on the pod IP
+// scheme returns the target's URL scheme, defaulting to http
+func (t HTTPTarget) scheme() string {
+	if t.Scheme == "" {
+		return "http"
+	}
+	return t.Scheme
+}
+
+func httpTargetURL(ip string, target HTTPTarget) string {
+	path := target.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("%s://%s%s", target.scheme(), net.JoinHostPort(ip, fmt.Sprintf("%d", target.Port)), path)
+}
+
+// forTarget merges the target's probe headers into the options. Annotation headers take
+// precedence, and the probe's host is sent as the Host header unless one is already set.
+func (o HTTPRequestOptions) forTarget(target HTTPTarget) HTTPRequestOptions {
+	if len(target.Headers) == 0 && target.Host == "" {
+		return o
+	}
+	headers := target.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	for name, values := range o.Headers {
+		headers[name] = values
+	}
+	if target.Host != "" && headers.Get("Host") == "" {
+		headers.Set("Host", target.Host)
+	}
+	o.Headers = headers
+	return o
+}
+
+// httpProbeWithRetry HTTP probe with retry mechanism
+func httpProbeWithRetry(ctx context.Context, url string, opts HTTPRequestOptions, config *HealthCheckConfig) error {
+	return probeWithRetry(ctx, "HTTP", url, config, func(ctx context.Context, url string, timeout time.Duration) error {
+		return httpProbe(ctx, url, opts, timeout)
+	})
+}
+
+// httpProbe issues the request (GET by default) and treats 2xx/3xx as healthy. Redirects are not followed.
+func httpProbe(ctx context.Context, url string, opts HTTPRequestOptions, timeout time.Duration) error {
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if opts.Body != "" {
+		body = strings.NewReader(opts.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	for name, values := range opts.Headers {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if host := opts.Headers.Get("Host"); host != "" {
+		req.Host = host
+	}
+	if opts.TokenFile != "" {
+		token, err := os.ReadFile(opts.TokenFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token file: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		// Like the kubelet, HTTPS probes do not verify the serving certificate
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		if err := checkCertTTL(resp.TLS.PeerCertificates[0], opts.CertMinTTL); err != nil {
+			return fmt.Errorf("HTTP probe to %s: %w", url, err)
+		}
+	}
+	if !opts.statusExpected(resp.StatusCode) {
+		return fmt.Errorf("%w: HTTP probe to %s returned status %d", errUnexpectedHTTPResponse, url, resp.StatusCode)
+	}
+	for _, expect := range opts.ExpectHeaders {
+		values := resp.Header.Values(expect.Name)
+		if len(values) == 0 {
+			return fmt.Errorf("%w: HTTP probe to %s: response header %s missing", errUnexpectedHTTPResponse, url, expect.Name)
+		}
+		matched := false
+		for _, value := range values {
+			if value == expect.Value {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%w: HTTP probe to %s: response header %s is %q, expected %q",
+				errUnexpectedHTTPResponse, url, expect.Name, values[0], expect.Value)
+		}
+	}
+	if opts.ExpectVersion != nil {
+		if err := opts.ExpectVersion.checkVersion(resp.Body); err != nil {
+			return fmt.Errorf("%w: HTTP probe to %s: %v", errUnexpectedHTTPResponse, url, err)
+		}
+	}
+	return nil
+}
+
+// redactHeaders renders header names for logging with every value hidden
+func redactHeaders(headers http.Header) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name+"=<redacted>")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
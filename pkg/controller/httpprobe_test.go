@@ -0,0 +1,424 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog/v2"
+)
+
+func TestHTTPProbeAppliesHeadersAndToken(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s3cret-token\n"), 0o600))
+
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		httpHeaderAnnotationPrefix + "X-Api-Key": "abc123",
+		httpTokenFileAnnotation:                  tokenFile,
+	})
+	opts := getHTTPRequestOptions(pod)
+
+	require.NoError(t, httpProbe(context.Background(), server.URL+"/healthz", opts, time.Second))
+	assert.Equal(t, "abc123", got.Get("X-Api-Key"))
+	assert.Equal(t, "Bearer s3cret-token", got.Get("Authorization"))
+}
+
+func TestHTTPProbeStatusCodes(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status == http.StatusFound {
+			w.Header().Set("Location", "/elsewhere")
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		status  int
+		healthy bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusFound, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusServiceUnavailable, false},
+	} {
+		status = tc.status
+		err := httpProbe(context.Background(), server.URL, HTTPRequestOptions{}, time.Second)
+		assert.Equal(t, tc.healthy, err == nil, "status %d", tc.status)
+	}
+}
+
+func TestHTTPHeadersAnnotation(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		httpHeadersAnnotation:                   "Host=svc.internal, Authorization=Bearer xyz==,X-Tenant=a,",
+		httpHeaderAnnotationPrefix + "X-Tenant": "b",
+	})
+	opts := getHTTPRequestOptions(pod)
+	require.NoError(t, httpProbe(context.Background(), server.URL, opts, time.Second))
+	assert.Equal(t, "svc.internal", got.Host)
+	assert.Equal(t, "Bearer xyz==", got.Header.Get("Authorization"), "only the first = separates the value")
+	assert.Equal(t, []string{"b"}, got.Header.Values("X-Tenant"), "a single-header annotation wins")
+
+	pod.Annotations = map[string]string{httpHeadersAnnotation: " , "}
+	assert.Nil(t, getHTTPRequestOptions(pod).Headers, "an empty list adds no headers")
+
+	pod.Annotations = map[string]string{httpHeadersAnnotation: "Host=svc.internal,secret-token"}
+	assert.Nil(t, getHTTPRequestOptions(pod).Headers, "a malformed list is ignored")
+	_, err := parseHTTPHeaders("secret-token")
+	if assert.Error(t, err) {
+		assert.NotContains(t, err.Error(), "token", "entries without = may be secrets and are not echoed")
+	}
+}
+
+func TestHTTPProbeExpectedCodes(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		httpExpectedCodesAnnotation: "200, 401,503",
+	})
+	opts := getHTTPRequestOptions(pod)
+	assert.Equal(t, []int{200, 401, 503}, opts.ExpectCodes)
+
+	for _, tc := range []struct {
+		status  int
+		healthy bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusUnauthorized, true},
+		{http.StatusServiceUnavailable, true},
+		{http.StatusNoContent, false},
+		{http.StatusForbidden, false},
+	} {
+		status = tc.status
+		err := httpProbe(context.Background(), server.URL, opts, time.Second)
+		assert.Equal(t, tc.healthy, err == nil, "status %d", tc.status)
+	}
+
+	for _, malformed := range []string{"200,abc", "99", "600", ","} {
+		pod.Annotations[httpExpectedCodesAnnotation] = malformed
+		assert.Nil(t, getHTTPRequestOptions(pod).ExpectCodes, "%q falls back to 200-399", malformed)
+	}
+}
+
+func TestHTTPProbeMissingTokenFile(t *testing.T) {
+	opts := HTTPRequestOptions{TokenFile: filepath.Join(t.TempDir(), "missing")}
+	assert.Error(t, httpProbe(context.Background(), "http://127.0.0.1:1/", opts, time.Second))
+}
+
+func TestHTTPHeadersRedactedInLogs(t *testing.T) {
+	fs := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(fs)
+	require.NoError(t, fs.Set("v", "4"))
+	require.NoError(t, fs.Set("logtostderr", "false"))
+	var buf bytes.Buffer
+	klog.SetOutput(&buf)
+	defer func() {
+		_ = fs.Set("v", "0")
+		_ = fs.Set("logtostderr", "true")
+		klog.SetOutput(os.Stderr)
+	}()
+
+	ps := NewPodSet()
+	ps.AddOrUpdate(newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled":         "true",
+		httpHeaderAnnotationPrefix + "Authorization": "Basic dXNlcjpwYXNz",
+		httpHeaderAnnotationPrefix + "X-Api-Key":     "abc123",
+	}))
+	klog.Flush()
+
+	logs := buf.String()
+	assert.Contains(t, logs, "X-Api-Key=<redacted>")
+	assert.NotContains(t, logs, "dXNlcjpwYXNz")
+	assert.NotContains(t, logs, "abc123")
+}
+
+func TestGetHTTPTargets(t *testing.T) {
+	pod := newReadyPod("default", "web", "10.0.0.1", nil)
+	pod.Spec.Containers = []corev1.Container{{
+		Name: "app",
+		ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt(8080)},
+		}},
+		LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromInt(8080)},
+		}},
+	}}
+
+	assert.Equal(t, []HTTPTarget{{Port: 8080, Path: "/ready"}}, getHTTPTargets(pod))
+}
+
+func TestHTTPSSchemeProbeUsesTLS(t *testing.T) {
+	var gotPath string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	pod := newReadyPod("default", "web", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{{
+		Name:  "app",
+		Ports: []corev1.ContainerPort{{ContainerPort: int32(port)}},
+		ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: "/secure/ready", Port: intstr.FromInt(port), Scheme: corev1.URISchemeHTTPS},
+		}},
+	}}
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+	pods := podSet.GetAvailablePods()
+	require.Len(t, pods, 1)
+	assert.Equal(t, []HTTPTarget{{Port: int32(port), Path: "/secure/ready", Scheme: "https"}}, pods[0].GetHTTPTargets())
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	result := hc.performHealthCheck(context.Background(), pods[0])
+	assert.Equal(t, ProtocolHTTP, result.Protocol)
+	assert.True(t, result.Healthy, "HTTPS probe should succeed over TLS: %v", result.Err)
+	assert.Equal(t, "/secure/ready", gotPath)
+
+	// The same target probed as plain HTTP fails against the TLS listener
+	pods[0].HTTPTargets[0].Scheme = ""
+	assert.False(t, hc.performHealthCheck(context.Background(), pods[0]).Healthy)
+}
+
+func TestHTTPProbeUsesProbeHostAndHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "app.example.com" || r.Header.Get("X-Probe") != "kubelet" || r.Header.Get("X-Tenant") != "override" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	pod := newReadyPod("default", "web", "127.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled":    "true",
+		httpHeaderAnnotationPrefix + "X-Tenant": "override",
+	})
+	pod.Spec.Containers = []corev1.Container{{
+		Name:  "app",
+		Ports: []corev1.ContainerPort{{ContainerPort: int32(port)}},
+		ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/ready",
+				Port: intstr.FromInt(port),
+				Host: "app.example.com",
+				HTTPHeaders: []corev1.HTTPHeader{
+					{Name: "X-Probe", Value: "kubelet"},
+					{Name: "X-Tenant", Value: "from-probe"},
+				},
+			},
+		}},
+	}}
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+	pods := podSet.GetAvailablePods()
+	require.Len(t, pods, 1)
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	result := hc.performHealthCheck(context.Background(), pods[0])
+	assert.True(t, result.Healthy, "probe Host and headers should be sent: %v", result.Err)
+
+	pods[0].HTTPTargets[0].Host = ""
+	assert.False(t, hc.performHealthCheck(context.Background(), pods[0]).Healthy, "virtual host should not match without the probe Host")
+}
+
+func TestHTTPProbeExpectHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Health", "ok")
+		w.Header().Set("X-Role", "replica")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		annotation string
+		healthy    bool
+	}{
+		{"X-Health=ok", true},
+		{"x-health=ok, X-Role=replica", true},
+		{"X-Health=ok,X-Role=primary", false},
+		{"X-Missing=yes", false},
+	} {
+		expect, err := parseExpectedHeaders(tc.annotation)
+		require.NoError(t, err)
+		err = httpProbe(context.Background(), server.URL, HTTPRequestOptions{ExpectHeaders: expect}, time.Second)
+		assert.Equal(t, tc.healthy, err == nil, "%s: %v", tc.annotation, err)
+	}
+}
+
+func TestParseExpectedHeaders(t *testing.T) {
+	expect, err := parseExpectedHeaders("X-Health=ok,,X-Empty=")
+	require.NoError(t, err)
+	assert.Equal(t, []HeaderAssertion{{Name: "X-Health", Value: "ok"}, {Name: "X-Empty", Value: ""}}, expect)
+
+	_, err = parseExpectedHeaders("X-Health")
+	assert.Error(t, err)
+	_, err = parseExpectedHeaders("=ok")
+	assert.Error(t, err)
+}
+
+func TestHTTPProbeMethodAndBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"check":"deep"}` {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		httpMethodAnnotation: "post",
+		httpBodyAnnotation:   `{"check":"deep"}`,
+	})
+	opts := getHTTPRequestOptions(pod)
+	assert.Equal(t, http.MethodPost, opts.Method)
+	assert.NoError(t, httpProbe(context.Background(), server.URL, opts, time.Second))
+
+	// The default GET without a body is rejected by this endpoint
+	assert.Error(t, httpProbe(context.Background(), server.URL, HTTPRequestOptions{}, time.Second))
+}
+
+func TestParseHTTPMethod(t *testing.T) {
+	method, err := parseHTTPMethod(" put ")
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+
+	for _, invalid := range []string{"CONNECT", "TRACE", "DELETE", "G ET"} {
+		_, err := parseHTTPMethod(invalid)
+		assert.Error(t, err, invalid)
+	}
+
+	// An invalid annotation falls back to GET
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{httpMethodAnnotation: "DELETE"})
+	assert.Empty(t, getHTTPRequestOptions(pod).Method)
+}
+
+func TestHTTPURLsRequireAllHealthy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "web", "127.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled": "true",
+		httpURLsAnnotation:                   fmt.Sprintf(":%d/live, :%d/ready", port, port),
+	}))
+	pods := podSet.GetAvailablePods()
+	require.Len(t, pods, 1)
+	assert.Equal(t, []int32{int32(port)}, pods[0].GetPorts())
+	assert.Len(t, pods[0].GetHTTPTargets(), 2)
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	result := hc.performHealthCheck(context.Background(), pods[0])
+	assert.Equal(t, ProtocolHTTP, result.Protocol)
+	assert.False(t, result.Healthy, "/ready failing should fail the pod")
+
+	pods[0].HTTPTargets = pods[0].HTTPTargets[:1]
+	assert.True(t, hc.performHealthCheck(context.Background(), pods[0]).Healthy)
+}
+
+func TestParseHTTPURLs(t *testing.T) {
+	targets, err := parseHTTPURLs(":8080/live,8081,:8080/ready/deep")
+	require.NoError(t, err)
+	assert.Equal(t, []HTTPTarget{{Port: 8080, Path: "/live"}, {Port: 8081, Path: "/"}, {Port: 8080, Path: "/ready/deep"}}, targets)
+
+	for _, invalid := range []string{"", ":http/live", ":0/live", ":70000/live"} {
+		_, err := parseHTTPURLs(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHTTPProbeExpectVersion(t *testing.T) {
+	served := "1.4.0"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"build":{"version":%q}}`, served)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		served  string
+		match   string
+		healthy bool
+	}{
+		{"equal matching", "1.4.0", VersionMatchEqual, true},
+		{"equal older", "1.3.9", VersionMatchEqual, false},
+		{"equal newer", "1.5.0", VersionMatchEqual, false},
+		{"semver matching", "v1.4.0", VersionMatchSemverAtLeast, true},
+		{"semver older", "1.3.9", VersionMatchSemverAtLeast, false},
+		{"semver prerelease older", "1.4.0-rc.1", VersionMatchSemverAtLeast, false},
+		{"semver newer", "1.10.0", VersionMatchSemverAtLeast, true},
+		{"semver unparseable", "latest", VersionMatchSemverAtLeast, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			served = tt.served
+			opts := HTTPRequestOptions{ExpectVersion: &VersionAssertion{Field: "build.version", Expected: "1.4.0", Match: tt.match}}
+			err := httpProbe(context.Background(), server.URL, opts, time.Second)
+			if tt.healthy {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, errUnexpectedHTTPResponse)
+			}
+		})
+	}
+
+	opts := HTTPRequestOptions{ExpectVersion: &VersionAssertion{Field: "version", Expected: "1.4.0", Match: VersionMatchEqual}}
+	assert.ErrorContains(t, httpProbe(context.Background(), server.URL, opts, time.Second), "version field version not found")
+}
+
+func TestExpectVersionAnnotations(t *testing.T) {
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		httpExpectVersionAnnotation: "2.0.0",
+		httpVersionMatchAnnotation:  "semver-gte",
+	})
+	assert.Equal(t, &VersionAssertion{Field: "version", Expected: "2.0.0", Match: VersionMatchSemverAtLeast},
+		getHTTPRequestOptions(pod).ExpectVersion)
+
+	pod.Annotations[httpVersionMatchAnnotation] = "newest"
+	assert.Nil(t, getHTTPRequestOptions(pod).ExpectVersion, "an unknown match mode disables the assertion")
+}
@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"sync/atomic"
+
+	goping "github.com/prometheus-community/pro-bing"
+	"k8s.io/klog/v2"
+)
+
+// ICMP socket modes accepted by SetICMPPrivileged
+const (
+	ICMPPrivilegedAuto  = "auto"
+	ICMPPrivilegedTrue  = "true"
+	ICMPPrivilegedFalse = "false"
+)
+
+// runPinger runs a pinger, replaced in tests to simulate missing privileges
+var runPinger = func(ctx context.Context, pinger *goping.Pinger) error { return pinger.RunWithContext(ctx) }
+
+// icmpSocket picks raw (privileged) or datagram (unprivileged) sockets for ICMP probes. In auto
+// mode it starts privileged and switches to unprivileged for good the first time raw sockets are
+// refused, as they are without CAP_NET_RAW. A nil icmpSocket always uses raw sockets.
+type icmpSocket struct {
+	auto         bool
+	unprivileged atomic.Bool
+}
+
+// newICMPSocket returns the socket choice of an ICMP_PRIVILEGED mode
+func newICMPSocket(mode string) *icmpSocket {
+	s := &icmpSocket{auto: mode == ICMPPrivilegedAuto}
+	s.unprivileged.Store(mode == ICMPPrivilegedFalse)
+	return s
+}
+
+// privileged reports whether probes currently use raw sockets
+func (s *icmpSocket) privileged() bool {
+	return s == nil || !s.unprivileged.Load()
+}
+
+// fallBack reports whether a probe refused a raw socket with err should be retried unprivileged,
+// switching later probes to unprivileged sockets as well
+func (s *icmpSocket) fallBack(err error) bool {
+	if s == nil || !s.auto || !stderrors.Is(err, os.ErrPermission) {
+		return false
+	}
+	if s.unprivileged.CompareAndSwap(false, true) {
+		klog.Warningf("ICMP probes: raw sockets not permitted (%v), falling back to unprivileged ICMP, "+
+			"which needs the checker's group in net.ipv4.ping_group_range", err)
+	}
+	return true
+}
+
+// String describes the socket mode for the startup log
+func (s *icmpSocket) String() string {
+	switch {
+	case !s.privileged():
+		return "unprivileged datagram sockets"
+	case s != nil && s.auto:
+		return "privileged raw sockets, falling back to unprivileged ones without CAP_NET_RAW"
+	default:
+		return "privileged raw sockets"
+	}
+}
@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	goping "github.com/prometheus-community/pro-bing"
+	"github.com/stretchr/testify/assert"
+)
+
+// withoutRawSockets makes pingers fail like they do without CAP_NET_RAW and records the
+// privileged mode of each run
+func withoutRawSockets(t *testing.T) *[]bool {
+	var runs []bool
+	orig := runPinger
+	runPinger = func(_ context.Context, pinger *goping.Pinger) error {
+		runs = append(runs, pinger.Privileged())
+		if pinger.Privileged() {
+			return &net.OpError{Op: "listen", Net: "ip4:icmp", Err: os.NewSyscallError("socket", syscall.EPERM)}
+		}
+		return nil
+	}
+	t.Cleanup(func() { runPinger = orig })
+	return &runs
+}
+
+func TestICMPFallsBackToUnprivileged(t *testing.T) {
+	runs := withoutRawSockets(t)
+	socket := newICMPSocket(ICMPPrivilegedAuto)
+	assert.True(t, socket.privileged())
+
+	_, err := icmpProbe(context.Background(), "127.0.0.1", 1, 50*time.Millisecond, nil, socket)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, *runs, "retried once unprivileged")
+	assert.False(t, socket.privileged())
+	assert.Equal(t, "unprivileged datagram sockets", socket.String())
+
+	// Later probes go straight to unprivileged sockets
+	_, err = icmpProbe(context.Background(), "127.0.0.1", 1, 50*time.Millisecond, nil, socket)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false, false}, *runs)
+}
+
+func TestICMPPrivilegedModes(t *testing.T) {
+	runs := withoutRawSockets(t)
+
+	// Forced privileged mode reports the permission error instead of falling back
+	_, err := icmpProbe(context.Background(), "127.0.0.1", 1, 50*time.Millisecond, nil, newICMPSocket(ICMPPrivilegedTrue))
+	assert.ErrorIs(t, err, os.ErrPermission)
+	_, err = icmpProbe(context.Background(), "127.0.0.1", 1, 50*time.Millisecond, nil, nil)
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.Equal(t, []bool{true, true}, *runs)
+
+	*runs = nil
+	_, err = icmpProbe(context.Background(), "127.0.0.1", 1, 50*time.Millisecond, nil, newICMPSocket(ICMPPrivilegedFalse))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false}, *runs)
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog/v2"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+// scanOverrunsBeforeStretch is how many consecutive scans must overrun the interval
+// before the scheduler warns and, when enabled, stretches the interval
+const scanOverrunsBeforeStretch = 3
+
+// intervalAdjuster tracks how long dispatched scans take to complete and derives the
+// effective check interval from it, so a scheduler that can't keep up degrades to a
+// longer interval instead of building a silent backlog
+type intervalAdjuster struct {
+	mu          sync.Mutex
+	base        time.Duration
+	autoStretch bool
+	effective   time.Duration
+	overruns    int
+}
+
+func newIntervalAdjuster(base time.Duration, autoStretch bool) *intervalAdjuster {
+	metrics.SetEffectiveInterval(base)
+	return &intervalAdjuster{base: base, autoStretch: autoStretch, effective: base}
+}
+
+// interval returns the effective check interval
+func (a *intervalAdjuster) interval() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.effective
+}
+
+// setBase changes the configured interval, starting over from it unstretched. It reports whether
+// the interval changed.
+func (a *intervalAdjuster) setBase(base time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if base <= 0 || base == a.base {
+		return false
+	}
+	a.base, a.effective, a.overruns = base, base, 0
+	metrics.SetEffectiveInterval(base)
+	return true
+}
+
+// observeScan records how long a dispatched scan took to complete
+func (a *intervalAdjuster) observeScan(duration time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Leave some headroom so a stretched interval is actually achievable
+	achievable := duration + duration/10
+	if duration <= a.effective {
+		a.overruns = 0
+		if a.autoStretch && a.effective > a.base && achievable < a.effective {
+			a.effective = max(a.base, achievable)
+			klog.Infof("Scheduler: scans complete in %v, shrinking effective interval to %v", duration, a.effective)
+			metrics.SetEffectiveInterval(a.effective)
+		}
+		return
+	}
+
+	a.overruns++
+	if a.overruns < scanOverrunsBeforeStretch {
+		return
+	}
+	a.overruns = 0
+	if !a.autoStretch {
+		klog.Warningf("Scheduler: scans take %v, longer than the %v interval, checks are backing up; consider more workers or AUTO_STRETCH_INTERVAL",
+			duration, a.effective)
+		return
+	}
+	klog.Warningf("Scheduler: scans take %v, longer than the %v interval, stretching effective interval to %v",
+		duration, a.effective, achievable)
+	a.effective = achievable
+	metrics.SetEffectiveInterval(a.effective)
+}
+
+// scanRound tracks the completion of the checks dispatched in one tick
+type scanRound struct {
+	start     time.Time
+	remaining int32
+	adjuster  *intervalAdjuster
+}
+
+func (a *intervalAdjuster) startRound(checks int) *scanRound {
+	return &scanRound{start: time.Now(), remaining: int32(checks), adjuster: a}
+}
+
+// done marks one check of the round finished, the last one reports the scan duration
+func (r *scanRound) done() {
+	if atomic.AddInt32(&r.remaining, -1) == 0 {
+		r.adjuster.observeScan(time.Since(r.start))
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// probeLimiter bounds how many probe operations of one kind run at once across all
+// workers. A nil limiter does not limit.
+type probeLimiter struct {
+	slots chan struct{}
+}
+
+func newProbeLimiter(max int) *probeLimiter {
+	return &probeLimiter{slots: make(chan struct{}, max)}
+}
+
+// acquire waits for a free slot until ctx is done
+func (l *probeLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for a free probe slot: %w", ctx.Err())
+	}
+}
+
+// release frees a slot taken by acquire
+func (l *probeLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
+
+// egressShaper is a leaky bucket spacing probe starts evenly at a fixed rate, so a tick
+// releases its checks as a steady stream instead of a burst. A nil shaper does not shape.
+type egressShaper struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newEgressShaper(perSecond float64) *egressShaper {
+	return &egressShaper{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the caller's slot in the stream comes up or ctx is done
+func (s *egressShaper) wait(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	now := time.Now()
+	if s.next.Before(now) {
+		s.next = now
+	}
+	slot := s.next
+	s.next = s.next.Add(s.interval)
+	s.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for probe egress slot: %w", ctx.Err())
+	}
+}
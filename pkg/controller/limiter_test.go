@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbeLimiterBoundsConcurrency(t *testing.T) {
+	limiter := newProbeLimiter(3)
+	var inFlight, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !assert.NoError(t, limiter.acquire(context.Background())) {
+				return
+			}
+			defer limiter.release()
+			current := atomic.AddInt32(&inFlight, 1)
+			for {
+				old := atomic.LoadInt32(&peak)
+				if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&peak))
+}
+
+func TestICMPProbeRespectsLimiter(t *testing.T) {
+	limiter := newProbeLimiter(1)
+	assert.NoError(t, limiter.acquire(context.Background()))
+
+	// With every slot taken the probe gives up at its timeout without pinging
+	start := time.Now()
+	_, err := icmpProbe(context.Background(), "127.0.0.1", 1, 50*time.Millisecond, limiter, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, FailureClassTimeout, classifyProbeError(err))
+
+	var unbounded *probeLimiter
+	assert.NoError(t, unbounded.acquire(context.Background()))
+	unbounded.release()
+}
+
+func TestEgressShaperSpacesProbeStarts(t *testing.T) {
+	shaper := newEgressShaper(100) // one start every 10ms
+	var mu sync.Mutex
+	var starts []time.Time
+	var wg sync.WaitGroup
+	begin := time.Now()
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, shaper.wait(context.Background()))
+			mu.Lock()
+			starts = append(starts, time.Now())
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// A burst of 10 is released as a stream, the i-th start no earlier than its 10ms slot
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for i, start := range starts {
+		assert.GreaterOrEqual(t, start.Sub(begin), time.Duration(i)*10*time.Millisecond, "start %d", i)
+	}
+
+	// A nil shaper does not shape
+	var unshaped *egressShaper
+	assert.NoError(t, unshaped.wait(context.Background()))
+}
+
+func TestEgressShaperRespectsContext(t *testing.T) {
+	shaper := newEgressShaper(1)
+	assert.NoError(t, shaper.wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, shaper.wait(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/klog/v2"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+// OwnerRef identifies the top-level workload that owns a pod
+type OwnerRef struct {
+	Namespace string
+	Kind      string
+	Name      string
+}
+
+// podOwner returns the controller owner recorded on the pod, if any
+func podOwner(pod *corev1.Pod) (kind, name string) {
+	if ref := metav1.GetControllerOf(pod); ref != nil {
+		return ref.Kind, ref.Name
+	}
+	return "", ""
+}
+
+// resolveOwner walks ReplicaSet -> Deployment so pods of every revision roll up to their Deployment
+func resolveOwner(rsLister appsv1listers.ReplicaSetLister, namespace, kind, name string) OwnerRef {
+	owner := OwnerRef{Namespace: namespace, Kind: kind, Name: name}
+	if kind != "ReplicaSet" || rsLister == nil {
+		return owner
+	}
+
+	rs, err := rsLister.ReplicaSets(namespace).Get(name)
+	if err != nil {
+		klog.V(4).Infof("Failed to resolve owner of ReplicaSet %s/%s: %v", namespace, name, err)
+		return owner
+	}
+	if ref := metav1.GetControllerOf(rs); ref != nil && ref.Kind == "Deployment" {
+		return OwnerRef{Namespace: namespace, Kind: ref.Kind, Name: ref.Name}
+	}
+	return owner
+}
+
+// ownerHealthyRatios groups tracked pods by owner and returns the fraction of pods with a known
+// status that are healthy. Pods without an owner or without a result yet are left out.
+func ownerHealthyRatios(podSet *PodSet, rsLister appsv1listers.ReplicaSetLister) map[OwnerRef]float64 {
+	type counts struct{ healthy, known int }
+	byOwner := make(map[OwnerRef]*counts)
+
+	podSet.ForEach(func(pod *PodInfo) {
+		status := pod.GetLastHealthStatus()
+		if pod.OwnerKind == "" || status == nil {
+			return
+		}
+		owner := resolveOwner(rsLister, pod.Namespace, pod.OwnerKind, pod.OwnerName)
+		c, exists := byOwner[owner]
+		if !exists {
+			c = &counts{}
+			byOwner[owner] = c
+		}
+		c.known++
+		if *status {
+			c.healthy++
+		}
+	})
+
+	ratios := make(map[OwnerRef]float64, len(byOwner))
+	for owner, c := range byOwner {
+		ratios[owner] = float64(c.healthy) / float64(c.known)
+	}
+	return ratios
+}
+
+// updateOwnerMetrics publishes the per-owner healthy ratio gauge
+func updateOwnerMetrics(podSet *PodSet, rsLister appsv1listers.ReplicaSetLister) {
+	metrics.ResetOwnerHealthyRatio()
+	for owner, ratio := range ownerHealthyRatios(podSet, rsLister) {
+		metrics.SetOwnerHealthyRatio(owner.Namespace, owner.Kind, owner.Name, ratio)
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1listers "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/stretchr/testify/assert"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+func ownedPod(namespace, name, ip, ownerKind, ownerName string) *PodInfo {
+	return &PodInfo{Namespace: namespace, Name: name, IP: ip, OwnerKind: ownerKind, OwnerName: ownerName}
+}
+
+func TestOwnerHealthyRatios(t *testing.T) {
+	isController := true
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web-7d4b9",
+		Namespace: "default",
+		OwnerReferences: []metav1.OwnerReference{
+			{Kind: "Deployment", Name: "web", Controller: &isController},
+		},
+	}}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(rs))
+	rsLister := appsv1listers.NewReplicaSetLister(indexer)
+
+	podSet := NewPodSet()
+	pods := []*PodInfo{
+		ownedPod("default", "web-7d4b9-a", "10.0.0.1", "ReplicaSet", "web-7d4b9"),
+		ownedPod("default", "web-7d4b9-b", "10.0.0.2", "ReplicaSet", "web-7d4b9"),
+		ownedPod("default", "web-7d4b9-c", "10.0.0.3", "ReplicaSet", "web-7d4b9"),
+		ownedPod("default", "web-7d4b9-d", "10.0.0.4", "ReplicaSet", "web-7d4b9"), // not checked yet
+		ownedPod("default", "db-0", "10.0.0.5", "StatefulSet", "db"),
+		ownedPod("default", "standalone", "10.0.0.6", "", ""),
+	}
+	pods[0].SetLastHealthStatus(true)
+	pods[1].SetLastHealthStatus(true)
+	pods[2].SetLastHealthStatus(false)
+	pods[4].SetLastHealthStatus(true)
+	pods[5].SetLastHealthStatus(false)
+	for _, pod := range pods {
+		podSet.pods[pod.IP] = pod
+	}
+
+	ratios := ownerHealthyRatios(podSet, rsLister)
+	assert.Equal(t, map[OwnerRef]float64{
+		{Namespace: "default", Kind: "Deployment", Name: "web"}: 2.0 / 3.0,
+		{Namespace: "default", Kind: "StatefulSet", Name: "db"}: 1,
+	}, ratios)
+
+	updateOwnerMetrics(podSet, rsLister)
+	assert.InDelta(t, 2.0/3.0, testutil.ToFloat64(metrics.OwnerHealthyRatioGauge("default", "Deployment", "web")), 1e-9)
+}
@@ -1,28 +1,247 @@
 package controller
 
 import (
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/klog/v2"
 )
 
+// Defaults of the opt-in annotation key and the readiness gate type, see SetOptInKeys
+const (
+	DefaultAnnotationKey     = "endpoint-health-checker.io/enabled"
+	DefaultReadinessGateType = "endpointHealthCheckSuccess"
+)
+
+const (
+	// tlsServerNameAnnotation makes probes complete a TLS handshake and verify the certificate covers this name
+	tlsServerNameAnnotation = "endpoint-health-checker.io/tls-servername"
+	// tlsSkipVerifyAnnotation set to "true" skips certificate chain verification of "tls" protocol probes
+	tlsSkipVerifyAnnotation = "endpoint-health-checker.io/tls-insecure-skip-verify"
+	// httpHeaderAnnotationPrefix adds the suffix as a header to HTTP probes, e.g. endpoint-health-checker.io/http-header-X-Api-Key
+	httpHeaderAnnotationPrefix = "endpoint-health-checker.io/http-header-"
+	// httpHeadersAnnotation adds a list of headers to HTTP probes, e.g. "Host=svc.internal,Authorization=Bearer xyz"
+	httpHeadersAnnotation = "endpoint-health-checker.io/http-headers"
+	// httpTokenFileAnnotation sends the content of a file mounted in the checker as a bearer token on HTTP probes
+	httpTokenFileAnnotation = "endpoint-health-checker.io/http-token-file"
+	// httpExpectHeaderAnnotation lists response headers HTTP probes must carry, e.g. "X-Health=ok,X-Role=primary"
+	httpExpectHeaderAnnotation = "endpoint-health-checker.io/http-expect-header"
+	// httpMethodAnnotation sets the HTTP probe method, GET by default
+	httpMethodAnnotation = "endpoint-health-checker.io/http-method"
+	// httpBodyAnnotation is sent as the HTTP probe request body
+	httpBodyAnnotation = "endpoint-health-checker.io/http-body"
+	// httpURLsAnnotation lists HTTP endpoints that must all pass, e.g. ":8080/live,:8080/ready"
+	httpURLsAnnotation = "endpoint-health-checker.io/http-urls"
+	// manageReadyAnnotation set to "false" leaves the Ready condition to the kubelet and only drives the readiness gate
+	manageReadyAnnotation = "endpoint-health-checker.io/manage-ready"
+	// httpExpectVersionAnnotation is the version HTTP probes must find in the JSON response body
+	httpExpectVersionAnnotation = "endpoint-health-checker.io/http-expect-version"
+	// httpVersionFieldAnnotation is the dot-separated path of the version in the body, "version" by default
+	httpVersionFieldAnnotation = "endpoint-health-checker.io/http-version-field"
+	// httpVersionMatchAnnotation selects "equal" (default) or "semver-gte" version comparison
+	httpVersionMatchAnnotation = "endpoint-health-checker.io/http-version-match"
+	// httpExpectedCodesAnnotation lists the status codes HTTP probes accept, e.g. "200,401,503"
+	httpExpectedCodesAnnotation = "endpoint-health-checker.io/http-expected-codes"
+	// excludeAnnotation set to "true" keeps a pod untracked whatever namespace policy or opt-in applies
+	excludeAnnotation = "endpoint-health-checker.io/exclude"
+	// probeChainAnnotation lists protocols tried in order until one passes, e.g. "http,tcp,icmp"
+	probeChainAnnotation = "endpoint-health-checker.io/probe-chain"
+	// proxyProtocolAnnotation makes TCP probes send a PROXY protocol header of this version, "v1" or "v2"
+	proxyProtocolAnnotation = "endpoint-health-checker.io/proxy-protocol"
+	// protocolAnnotation set to "udp" probes the pod's UDP container ports instead of its probe ports,
+	// "tls" completes a verified TLS handshake on the probe ports
+	protocolAnnotation = "endpoint-health-checker.io/protocol"
+	// udpPayloadAnnotation is the datagram UDP probes send, hex-decoded when prefixed with 0x
+	udpPayloadAnnotation = "endpoint-health-checker.io/udp-payload"
+	// udpExpectPrefixAnnotation is the prefix UDP responses must start with, hex-decoded when prefixed with 0x
+	udpExpectPrefixAnnotation = "endpoint-health-checker.io/udp-expect-prefix"
+	// grpcMethodAnnotation is a unary method gRPC probes call instead of the health service, e.g. "/shop.Cart/Ping"
+	grpcMethodAnnotation = "endpoint-health-checker.io/grpc-method"
+	// grpcMethodRequestAnnotation is the serialized request message sent to the method, hex-decoded when prefixed with 0x
+	grpcMethodRequestAnnotation = "endpoint-health-checker.io/grpc-method-request"
+	// grpcMethodExpectAnnotation is the serialized response the method must return, hex-decoded when prefixed with 0x
+	grpcMethodExpectAnnotation = "endpoint-health-checker.io/grpc-method-expect"
+	// callbackAnnotation is an endpoint, e.g. ":8080/reach-back", asked to call the checker back to
+	// prove the pod can reach it too; only probed when the checker runs a callback listener
+	callbackAnnotation = "endpoint-health-checker.io/callback"
+	// intervalAnnotation is the pod's own interval between checks, e.g. "500ms", instead of the global one
+	intervalAnnotation = "endpoint-health-checker.io/interval"
+	// timeoutAnnotation is the pod's own single probe timeout, e.g. "3s", instead of the global one
+	timeoutAnnotation = "endpoint-health-checker.io/timeout"
+	// retryCountAnnotation is the pod's own number of probe retries instead of the global one, 0 disables retries
+	retryCountAnnotation = "endpoint-health-checker.io/retry-count"
+)
+
 type PodInfo struct {
 	Namespace        string
 	Name             string
 	IP               string
 	Ports            []int32
-	IsBeingChecked   bool  // Mark whether it's being health checked
-	LastHealthStatus *bool // Record last health check status, nil means unknown
+	IsBeingChecked   bool               // Mark whether it's being health checked
+	LastHealthStatus *bool              // Record last health check status, nil means unknown
+	CreatedAt        time.Time          // Pod creation timestamp, used to delay the first probe
+	Terminating      bool               // Pod has a deletion timestamp and is draining
+	TLS              *TLSProbeOptions   // Probes complete a TLS handshake when set
+	OwnerKind        string             // Kind of the pod's controller owner, empty if none
+	OwnerName        string             // Name of the pod's controller owner
+	LastAssertTime   time.Time          // Last time our conditions were patched or verified
+	HTTPTargets      []HTTPTarget       // HTTP endpoints probed with a GET instead of a TCP connect
+	HTTPOptions      HTTPRequestOptions // Headers applied to HTTP probes
+	FailureThreshold int32              // Consecutive failures before marking unhealthy, from the pod's probes
+	SuccessThreshold int32              // Consecutive successes before marking healthy again, from the pod's probes
+	Failures         int32              // Current run of consecutive failed checks
+	Successes        int32              // Current run of consecutive successful checks
+	PassingSince     time.Time          // First passing check of the current success run
+	Labels           map[string]string  // Pod labels, matched against Service selectors
+	LastDispatchedAt time.Time          // Last time the scheduler dispatched a check for this pod
+	CheckInterval    time.Duration      // Effective interval between checks, grown while the pod stays healthy
+	BaseInterval     time.Duration      // Interval between checks from the pod's annotation, 0 follows the global one
+	ProbeTimeout     time.Duration      // Single probe timeout from the pod's annotation, 0 follows the global one
+	RetryCount       *int               // Probe retries from the pod's annotation, nil follows the global count
+	TimeoutStreak    int32              // Consecutive checks that timed out, escalating the probe timeout
+	ContainerPorts   map[string][]int32 // Probed ports by container name
+	FailureClass     string             // Class of the current run of failures
+	FailureTimes     []time.Time        // Times of the latest failures of that class
+	NodeName         string             // Node the pod is scheduled on
+	TransitionTimes  []time.Time        // Times of recent status transitions, bounding flapping
+	ProbeStartAt     time.Time          // Not probed before, container start plus its readinessProbe initial delay
+	FamilyIPs        map[string]string  // First pod IP of each family, set for dual-stack pods only
+	FamilyHealth     map[string]bool    // Last reported reachability of each IP family
+	PortsSeenUp      map[int32]bool     // Declared ports that passed at least once, adoption completes once all have
+	ContainersReady  bool               // Kubelet reports every container Ready
+	ProbeChain       []string           // Protocols tried in order until one passes, empty for the default probe
+	ProxyProtocol    string             // PROXY protocol version sent by TCP probes, empty for none
+	AdoptedAt        time.Time          // When the pod started being tracked
+	GRPCTargets      []GRPCTarget       // gRPC endpoints probed with a health check instead of a TCP connect
+	UDPOptions       *UDPProbeOptions   // Set when Ports are UDP ports probed with a datagram
+	UID              types.UID          // Pod UID, tells a recreated pod from updates of the same pod
+	Callback         *HTTPTarget        // Endpoint asked to call the checker back, nil skips the reverse check
+	History          *CheckHistory      // Latest checks, nil until the first one or after eviction under the detail cap
+	DetailEvicted    bool               // Detail was dropped under the cap, healthy checks don't rebuild it
+
+	// state guards the check state a worker updates while the pod is read from other goroutines,
+	// taken by its getters and setters
+	state sync.Mutex
 }
 
 type PodSet struct {
 	mu   sync.RWMutex
 	pods map[string]*PodInfo // key: podIP
+	// ipByName indexes the IP each tracked pod is keyed by, key: namespace/name
+	ipByName map[string]string
+
+	// namespacePolicy forces health checking on (true) or off (false) for whole namespaces
+	namespacePolicy map[string]bool
+	// namespaceAllow limits tracking to these namespaces when not empty, namespaceDeny never tracks
+	// pods in its namespaces, whatever the allowlist, policy or annotation say
+	namespaceAllow map[string]bool
+	namespaceDeny  map[string]bool
+	// probeAllContainers also probes the declared ports of containers without probes
+	probeAllContainers bool
+	// seeds are statuses loaded from a snapshot, applied once when their pod is added
+	seeds map[string]statusSnapshotEntry
+	// respectInitialDelay defers probing until the readinessProbe initialDelaySeconds have passed
+	respectInitialDelay bool
+	// snapshotKey signs saved status snapshots and verifies loaded ones, empty leaves them unsigned
+	snapshotKey []byte
+	// maxPortsPerPod caps the ports probed per pod to the lowest ones, 0 probes all
+	maxPortsPerPod int
+	// added is signaled when a pod starts being tracked, waking an idle scheduler
+	added chan struct{}
+	// detail caps the pods holding detailed state, nil keeps it for all
+	detail *detailLRU
+	// annotationKey set to "true" opts a pod in, a readiness gate of readinessGateType also does
+	annotationKey     string
+	readinessGateType string
 }
 
 func NewPodSet() *PodSet {
-	return &PodSet{pods: make(map[string]*PodInfo)}
+	return &PodSet{
+		pods:              make(map[string]*PodInfo),
+		ipByName:          make(map[string]string),
+		added:             make(chan struct{}, 1),
+		annotationKey:     DefaultAnnotationKey,
+		readinessGateType: DefaultReadinessGateType,
+	}
+}
+
+// SetOptInKeys sets the annotation and the readiness gate type that opt pods in to health
+// checking, empty values keep the defaults
+func (ps *PodSet) SetOptInKeys(annotationKey, readinessGateType string) {
+	if annotationKey != "" {
+		ps.annotationKey = annotationKey
+	}
+	if readinessGateType != "" {
+		ps.readinessGateType = readinessGateType
+	}
+}
+
+// Added returns a channel signaled when a pod starts being tracked. Signals coalesce, so
+// one receive may stand for several added pods.
+func (ps *PodSet) Added() <-chan struct{} {
+	return ps.added
+}
+
+// SetNamespacePolicy sets per-namespace overrides that take precedence over the pod annotation
+func (ps *PodSet) SetNamespacePolicy(policy map[string]bool) {
+	ps.namespacePolicy = policy
+}
+
+// SetNamespaceScope limits tracking to the allowed namespaces, all when allow is empty, and never
+// tracks pods in denied ones, the denylist taking precedence
+func (ps *PodSet) SetNamespaceScope(allow, deny []string) {
+	ps.namespaceAllow, ps.namespaceDeny = toSet(allow), toSet(deny)
+}
+
+// toSet returns the set of a list's items, nil for an empty list
+func toSet(items []string) map[string]bool {
+	if len(items) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
+
+// namespaceInScope reports whether pods of the namespace may be tracked at all
+func (ps *PodSet) namespaceInScope(namespace string) bool {
+	if ps.namespaceDeny[namespace] {
+		return false
+	}
+	return len(ps.namespaceAllow) == 0 || ps.namespaceAllow[namespace]
+}
+
+// SetSnapshotKey sets the HMAC key status snapshots are signed and verified with
+func (ps *PodSet) SetSnapshotKey(key []byte) {
+	ps.snapshotKey = key
+}
+
+// SetMaxPortsPerPod caps the ports probed per pod, so a misconfigured pod can't monopolize workers
+func (ps *PodSet) SetMaxPortsPerPod(max int) {
+	ps.maxPortsPerPod = max
+}
+
+// SetProbeAllContainers sets whether containers without probes have their declared ports probed too
+func (ps *PodSet) SetProbeAllContainers(all bool) {
+	ps.probeAllContainers = all
+}
+
+// SetRespectInitialDelay sets whether pods are first probed only once their containers' readiness
+// probe initialDelaySeconds have passed since the container started, like kubelet does
+func (ps *PodSet) SetRespectInitialDelay(respect bool) {
+	ps.respectInitialDelay = respect
 }
 
 func (ps *PodSet) AddOrUpdate(pod *corev1.Pod) {
@@ -32,32 +251,259 @@ func (ps *PodSet) AddOrUpdate(pod *corev1.Pod) {
 		return
 	}
 
-	if !shouldCheckPod(pod) {
-		klog.V(4).Infof("Skipping pod %s/%s: health check not enabled via annotation",
+	if isPodExcluded(pod) {
+		// Stop tracking a pod that was excluded after it was added
+		ps.DeleteIfOwnedBy(pod.Status.PodIP, pod.Namespace, pod.Name)
+		klog.V(4).Infof("Skipping pod %s/%s: excluded via %s annotation", pod.Namespace, pod.Name, excludeAnnotation)
+		return
+	}
+
+	if !ps.shouldCheckPod(pod) {
+		klog.V(4).Infof("Skipping pod %s/%s: health check not enabled via annotation or namespace policy, or namespace out of scope",
 			pod.Namespace, pod.Name)
 		return
 	}
 
 	if !isPodReady(pod) {
+		// A tracked pod whose Ready we may have forced False still follows kubelet's view
+		ps.updateContainersReady(pod)
 		klog.V(4).Infof("Skipping pod %s/%s: waiting for initial readiness probe to pass",
 			pod.Namespace, pod.Name)
 		return
 	}
 
+	ownerKind, ownerName := podOwner(pod)
+	failureThreshold, successThreshold := getProbeThresholds(pod)
+	httpOptions := getHTTPRequestOptions(pod)
+	var probeStartAt time.Time
+	if ps.respectInitialDelay {
+		probeStartAt = getProbeStartAt(pod)
+	}
+	containerPorts := getContainerPorts(pod, ps.probeAllContainers)
+	ports, httpTargets := flattenPorts(containerPorts), getHTTPTargets(pod)
+	if urls := pod.Annotations[httpURLsAnnotation]; urls != "" {
+		if targets, err := parseHTTPURLs(urls); err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, httpURLsAnnotation, err)
+		} else {
+			ports, httpTargets = mergeTargetPorts(ports, targets), targets
+		}
+	}
+	grpcTargets := getGRPCTargets(pod)
+	if pod.Annotations[grpcMethodAnnotation] != "" {
+		if targets, err := getGRPCMethodTargets(pod, grpcTargets, ports, httpTargets); err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, grpcMethodAnnotation, err)
+		} else {
+			grpcTargets = targets
+		}
+	}
+	var udpOptions *UDPProbeOptions
+	var tlsOptions *TLSProbeOptions
+	if serverName := pod.Annotations[tlsServerNameAnnotation]; serverName != "" {
+		tlsOptions = &TLSProbeOptions{ServerName: serverName}
+	}
+	switch protocol := pod.Annotations[protocolAnnotation]; strings.ToLower(protocol) {
+	case "":
+	case ProtocolUDP:
+		if opts, err := getUDPProbeOptions(pod); err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, protocolAnnotation, err)
+		} else {
+			udpOptions, tlsOptions = opts, nil
+			ports, httpTargets, grpcTargets = getUDPPorts(pod), nil, nil
+		}
+	case ProtocolTLS:
+		tlsOptions = &TLSProbeOptions{
+			ServerName: pod.Annotations[tlsServerNameAnnotation],
+			Verify:     pod.Annotations[tlsSkipVerifyAnnotation] != "true",
+		}
+	default:
+		klog.Warningf("Pod %s/%s: ignoring %s annotation: unsupported protocol %q, expected udp or tls",
+			pod.Namespace, pod.Name, protocolAnnotation, protocol)
+	}
+	if ps.maxPortsPerPod > 0 && len(ports) > ps.maxPortsPerPod {
+		var dropped []int32
+		ports, dropped = capPorts(ports, ps.maxPortsPerPod)
+		httpTargets, grpcTargets = filterHTTPTargets(httpTargets, ports), filterGRPCTargets(grpcTargets, ports)
+		klog.Warningf("Pod %s/%s declares %d probe ports, more than the %d allowed per pod; probing %v, skipping %v",
+			pod.Namespace, pod.Name, len(ports)+len(dropped), ps.maxPortsPerPod, ports, dropped)
+	}
+	var probeChain []string
+	if value := pod.Annotations[probeChainAnnotation]; value != "" {
+		if chain, err := parseProbeChain(value); err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, probeChainAnnotation, err)
+		} else {
+			probeChain = chain
+		}
+	}
+	var callback *HTTPTarget
+	if value := pod.Annotations[callbackAnnotation]; value != "" {
+		if targets, err := parseHTTPURLs(value); err != nil || len(targets) != 1 {
+			if err == nil {
+				err = fmt.Errorf("expected a single :port/path")
+			}
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, callbackAnnotation, err)
+		} else {
+			callback = &targets[0]
+		}
+	}
+	baseInterval := getDurationAnnotation(pod, intervalAnnotation)
+	probeTimeout, retryCount := getDurationAnnotation(pod, timeoutAnnotation), getRetryCount(pod)
+	proxyProtocol := pod.Annotations[proxyProtocolAnnotation]
+	if proxyProtocol != "" && proxyProtocol != ProxyProtocolV1 && proxyProtocol != ProxyProtocolV2 {
+		klog.Warningf("Pod %s/%s: ignoring %s annotation: unsupported version %q, expected v1 or v2",
+			pod.Namespace, pod.Name, proxyProtocolAnnotation, proxyProtocol)
+		proxyProtocol = ""
+	}
+	if len(httpOptions.Headers) > 0 || httpOptions.TokenFile != "" {
+		klog.V(4).Infof("Pod %s/%s: HTTP probe headers %s, token file %q",
+			pod.Namespace, pod.Name, redactHeaders(httpOptions.Headers), httpOptions.TokenFile)
+	}
+
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	ps.pods[pod.Status.PodIP] = &PodInfo{
-		Namespace: pod.Namespace,
-		Name:      pod.Name,
-		IP:        pod.Status.PodIP,
-		Ports:     getProbePorts(pod),
+	podInfo := &PodInfo{
+		Namespace:    pod.Namespace,
+		Name:         pod.Name,
+		UID:          pod.UID,
+		IP:           pod.Status.PodIP,
+		Ports:        ports,
+		CreatedAt:    pod.CreationTimestamp.Time,
+		ProbeStartAt: probeStartAt,
+		FamilyIPs:    getFamilyIPs(pod),
+		Terminating:  pod.DeletionTimestamp != nil,
+		OwnerKind:    ownerKind,
+		OwnerName:    ownerName,
+		HTTPTargets:  httpTargets,
+		HTTPOptions:  httpOptions,
+
+		FailureThreshold: failureThreshold,
+		SuccessThreshold: successThreshold,
+		Labels:           pod.Labels,
+		ContainerPorts:   containerPorts,
+		NodeName:         pod.Spec.NodeName,
+		BaseInterval:     baseInterval,
+		ProbeTimeout:     probeTimeout,
+		RetryCount:       retryCount,
+		ContainersReady:  containersReady(pod),
+		ProbeChain:       probeChain,
+		ProxyProtocol:    proxyProtocol,
+		GRPCTargets:      grpcTargets,
+		UDPOptions:       udpOptions,
+		TLS:              tlsOptions,
+		Callback:         callback,
+	}
+	existing, tracked := ps.pods[podInfo.IP]
+	samePod := tracked && existing.Namespace == podInfo.Namespace && existing.Name == podInfo.Name
+	if previousIP, indexed := ps.ipByName[podKey(podInfo.Namespace, podInfo.Name)]; indexed && previousIP != podInfo.IP {
+		// The pod changed IP, or was recreated under another one before its delete event arrived,
+		// and its entry under the old IP would keep being probed
+		previous := ps.pods[previousIP]
+		ps.remove(previousIP)
+		if previous.UID == podInfo.UID {
+			klog.Infof("Pod %s/%s changed IP from %s to %s, deleted the entry of the old IP",
+				pod.Namespace, pod.Name, previousIP, podInfo.IP)
+		}
+		if !samePod {
+			existing, samePod = previous, true
+		}
+	}
+	if samePod && existing.UID == podInfo.UID {
+		// Our own status patches trigger updates, the state of its checks and adoption must survive them
+		podInfo.inheritCheckState(existing)
+		podInfo.AdoptedAt = existing.AdoptedAt
+		// The pod stays on its cadence instead of being due again on every update
+		podInfo.LastDispatchedAt = existing.LastDispatchedAt
+		// A check in flight finishes for the replaced entry, it must not be dispatched again meanwhile
+		podInfo.IsBeingChecked = existing.IsBeingChecked
+	} else {
+		// A pod recreated under the same name starts fresh, whether or not it kept its IP
+		if samePod {
+			klog.Infof("Pod %s/%s was recreated (UID %s, was %s), discarding the state of the previous pod",
+				pod.Namespace, pod.Name, podInfo.UID, existing.UID)
+		}
+		podInfo.AdoptedAt = time.Now()
+		if !tracked {
+			ps.seedStatus(podInfo)
+		}
+	}
+	ps.set(podInfo)
+	if !tracked {
+		select {
+		case ps.added <- struct{}{}:
+		default:
+		}
 	}
 
 	klog.Infof("Added pod %s/%s (IP: %s) to PodSet, total: %d",
 		pod.Namespace, pod.Name, pod.Status.PodIP, len(ps.pods))
 }
 
+// podKey returns the key of a pod in the name index
+func podKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// set tracks a pod under its IP, replacing whatever pod was tracked there. Callers hold the lock.
+func (ps *PodSet) set(pod *PodInfo) {
+	if replaced, exists := ps.pods[pod.IP]; exists {
+		ps.remove(replaced.IP)
+	}
+	ps.pods[pod.IP] = pod
+	ps.ipByName[podKey(pod.Namespace, pod.Name)] = pod.IP
+}
+
+// remove stops tracking the pod at ip. Callers hold the lock.
+func (ps *PodSet) remove(ip string) {
+	pod, exists := ps.pods[ip]
+	if !exists {
+		return
+	}
+	delete(ps.pods, ip)
+	key := podKey(pod.Namespace, pod.Name)
+	if ps.ipByName[key] == ip {
+		delete(ps.ipByName, key)
+	}
+}
+
+// RemoveOrphans stops tracking every pod orphaned reports a reason for, holding the lock so event
+// handlers can't interleave, and returns how many were removed
+func (ps *PodSet) RemoveOrphans(orphaned func(tracked *PodInfo) string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	removed := 0
+	for ip, tracked := range ps.pods {
+		if reason := orphaned(tracked); reason != "" {
+			ps.remove(ip)
+			removed++
+			klog.Infof("Deleted orphaned pod %s/%s with IP %s from PodSet: %s", tracked.Namespace, tracked.Name, ip, reason)
+		}
+	}
+	return removed
+}
+
+// disqualified returns why a tracked pod no longer qualifies for tracking given its current
+// object, empty if it still does. Pods that only lost readiness stay, we may have caused it.
+func (ps *PodSet) disqualified(tracked *PodInfo, pod *corev1.Pod) string {
+	switch {
+	case tracked.UID != "" && pod.UID != tracked.UID:
+		return fmt.Sprintf("recreated with UID %s", pod.UID)
+	case pod.Status.Phase != corev1.PodRunning:
+		return fmt.Sprintf("phase is %s", pod.Status.Phase)
+	case pod.Status.PodIP != tracked.IP:
+		return fmt.Sprintf("IP changed to %q", pod.Status.PodIP)
+	case !ps.shouldCheckPod(pod):
+		return "health checking is no longer enabled"
+	}
+	return ""
+}
+
+// IsAwaitingReadiness reports whether the pod would be tracked once kubelet reports it ready
+func (ps *PodSet) IsAwaitingReadiness(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" &&
+		ps.shouldCheckPod(pod) && !isPodReady(pod)
+}
+
 func (ps *PodSet) Delete(pod *corev1.Pod) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
@@ -74,7 +520,7 @@ func (ps *PodSet) Delete(pod *corev1.Pod) {
 		return
 	}
 
-	delete(ps.pods, pod.Status.PodIP)
+	ps.remove(pod.Status.PodIP)
 	klog.Infof("Deleted pod %s/%s with IP %s from PodSet", pod.Namespace, pod.Name, pod.Status.PodIP)
 }
 
@@ -83,18 +529,39 @@ func (ps *PodSet) DeleteByNamespaceAndName(namespace, name string) {
 	ps.mu.Lock()
 	defer ps.mu.Unlock()
 
-	// Iterate through all pods to find matching pod
-	for ip, podInfo := range ps.pods {
-		if podInfo.Namespace == namespace && podInfo.Name == name {
-			klog.Infof("Deleted pod %s/%s with IP %s from PodSet", namespace, name, ip)
-			delete(ps.pods, ip)
-			return
-		}
+	if ip, exists := ps.ipByName[podKey(namespace, name)]; exists {
+		ps.remove(ip)
+		klog.Infof("Deleted pod %s/%s with IP %s from PodSet", namespace, name, ip)
+		return
 	}
 
 	klog.V(4).Infof("Pod %s/%s not found in PodSet", namespace, name)
 }
 
+// updateContainersReady refreshes kubelet's container readiness of a tracked pod
+func (ps *PodSet) updateContainersReady(pod *corev1.Pod) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if podInfo, tracked := ps.pods[pod.Status.PodIP]; tracked && podInfo.Namespace == pod.Namespace && podInfo.Name == pod.Name {
+		podInfo.ContainersReady = containersReady(pod)
+	}
+}
+
+// DeleteIfOwnedBy deletes the entry for podIP only if it still belongs to the given pod
+func (ps *PodSet) DeleteIfOwnedBy(podIP, namespace, name string) bool {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	podInfo, exists := ps.pods[podIP]
+	if !exists || podInfo.Namespace != namespace || podInfo.Name != name {
+		return false
+	}
+	ps.remove(podIP)
+	klog.Infof("Deleted stale pod %s/%s with IP %s from PodSet", namespace, name, podIP)
+	return true
+}
+
 // GetStats gets PodSet statistics
 func (ps *PodSet) GetStats() (int, map[string]int) {
 	ps.mu.RLock()
@@ -108,6 +575,61 @@ func (ps *PodSet) GetStats() (int, map[string]int) {
 	return len(ps.pods), namespaceCount
 }
 
+// ShortestInterval returns the shortest interval tracked pods ask for by annotation, 0 if none does
+func (ps *PodSet) ShortestInterval() time.Duration {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	var shortest time.Duration
+	for _, pod := range ps.pods {
+		if pod.BaseInterval > 0 && (shortest == 0 || pod.BaseInterval < shortest) {
+			shortest = pod.BaseInterval
+		}
+	}
+	return shortest
+}
+
+// ForEach calls fn for every tracked pod while holding the read lock
+func (ps *PodSet) ForEach(fn func(pod *PodInfo)) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	for _, pod := range ps.pods {
+		fn(pod)
+	}
+}
+
+// GetPod returns a copy of a tracked pod by namespace and name, nil if it isn't tracked
+func (ps *PodSet) GetPod(namespace, name string) *PodInfo {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	ip, exists := ps.ipByName[podKey(namespace, name)]
+	if !exists {
+		return nil
+	}
+	return ps.pods[ip].snapshot()
+}
+
+// FinishCheck marks a pod available again once its check completed. When the entry was replaced
+// by an update of the same pod meanwhile, the check recorded its results on the replaced entry
+// and they are carried over.
+func (ps *PodSet) FinishCheck(checked *PodInfo) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pod, exists := ps.pods[checked.IP]
+	if !exists {
+		klog.V(4).Infof("Pod %s/%s with IP %s no longer tracked when its check finished",
+			checked.Namespace, checked.Name, checked.IP)
+		return
+	}
+	if pod != checked && pod.Namespace == checked.Namespace && pod.Name == checked.Name && pod.UID == checked.UID {
+		pod.inheritCheckState(checked)
+	}
+	pod.IsBeingChecked = false
+}
+
 // SetBeingChecked sets Pod's being checked status
 func (ps *PodSet) SetBeingChecked(podIP string, isBeingChecked bool) bool {
 	ps.mu.Lock()
@@ -123,6 +645,18 @@ func (ps *PodSet) SetBeingChecked(podIP string, isBeingChecked bool) bool {
 	return false
 }
 
+// MarkDispatched records when a check was dispatched for the pods at ips
+func (ps *PodSet) MarkDispatched(ips []string, now time.Time) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for _, ip := range ips {
+		if pod, exists := ps.pods[ip]; exists {
+			pod.LastDispatchedAt = now
+		}
+	}
+}
+
 // GetAvailablePods gets all unchecked Pod list
 func (ps *PodSet) GetAvailablePods() []*PodInfo {
 	ps.mu.RLock()
@@ -138,49 +672,405 @@ func (ps *PodSet) GetAvailablePods() []*PodInfo {
 	return result
 }
 
-func getProbePorts(pod *corev1.Pod) []int32 {
-	ports := make(map[int32]struct{})
+// ResetAllBeingChecked marks every pod available again, for checks that were dispatched but will
+// never run, and returns how many pods were still marked
+func (ps *PodSet) ResetAllBeingChecked() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	reset := 0
+	for _, pod := range ps.pods {
+		if pod.IsBeingChecked {
+			pod.IsBeingChecked = false
+			reset++
+		}
+	}
+	return reset
+}
+
+// ClearHealthStatus forgets the last health status of every pod, so the next check of each one
+// writes its conditions again instead of trusting state that may have diverged meanwhile
+func (ps *PodSet) ClearHealthStatus() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	for _, pod := range ps.pods {
+		pod.ClearLastHealthStatus()
+	}
+}
+
+// resolveContainerPort returns the number of a probe port, looking named ports up in the
+// container's declared ports like the kubelet does
+func resolveContainerPort(c corev1.Container, port intstr.IntOrString) (int32, bool) {
+	if port.Type == intstr.Int {
+		return port.IntVal, true
+	}
+	for _, declared := range c.Ports {
+		if declared.Name == port.StrVal {
+			return declared.ContainerPort, true
+		}
+	}
+	return 0, false
+}
+
+// getContainerPorts returns the probe ports of each container. Containers without probes
+// are left out unless includeUnprobed is set, in which case their declared TCP ports are used.
+func getContainerPorts(pod *corev1.Pod, includeUnprobed bool) map[string][]int32 {
+	result := make(map[string][]int32)
 	for _, c := range pod.Spec.Containers {
+		ports := make(map[int32]struct{})
+		hasProbe := false
 		for _, probe := range []*corev1.Probe{c.LivenessProbe, c.ReadinessProbe} {
 			if probe == nil {
 				continue
 			}
+			hasProbe = true
+			var probePort *intstr.IntOrString
 			if probe.TCPSocket != nil {
-				ports[probe.TCPSocket.Port.IntVal] = struct{}{}
+				probePort = &probe.TCPSocket.Port
+			} else if probe.HTTPGet != nil {
+				probePort = &probe.HTTPGet.Port
 			}
-			if probe.HTTPGet != nil {
-				ports[probe.HTTPGet.Port.IntVal] = struct{}{}
+			if probePort != nil {
+				if port, ok := resolveContainerPort(c, *probePort); ok {
+					ports[port] = struct{}{}
+				} else {
+					klog.Warningf("Pod %s/%s: container %s probe references port %q, which is not a named port of the container",
+						pod.Namespace, pod.Name, c.Name, probePort.StrVal)
+				}
 			}
 			if probe.GRPC != nil {
 				ports[probe.GRPC.Port] = struct{}{}
 			}
 		}
+		if !hasProbe && includeUnprobed {
+			for _, port := range c.Ports {
+				if port.Protocol == "" || port.Protocol == corev1.ProtocolTCP {
+					ports[port.ContainerPort] = struct{}{}
+				}
+			}
+		}
+		for p := range ports {
+			result[c.Name] = append(result[c.Name], p)
+		}
 	}
+	return result
+}
+
+// flattenPorts merges per-container ports into one list without duplicates
+func flattenPorts(containerPorts map[string][]int32) []int32 {
+	seen := make(map[int32]struct{})
 	var result []int32
-	for p := range ports {
-		result = append(result, p)
+	for _, ports := range containerPorts {
+		for _, p := range ports {
+			if _, exists := seen[p]; !exists {
+				seen[p] = struct{}{}
+				result = append(result, p)
+			}
+		}
 	}
 	return result
 }
 
-func shouldCheckPod(pod *corev1.Pod) bool {
-	const annotationKey = "endpoint-health-checker.io/enabled"
-	const readinessGateType = "endpointHealthCheckSuccess"
+// UDPProbeOptions configures the datagram UDP probes send and the response they expect
+type UDPProbeOptions struct {
+	Payload      []byte
+	ExpectPrefix []byte // Empty accepts any response
+}
+
+// getUDPProbeOptions parses the UDP probe annotations of a pod whose protocol annotation is udp
+func getUDPProbeOptions(pod *corev1.Pod) (*UDPProbeOptions, error) {
+	payload, err := decodeAnnotationBytes(pod.Annotations[udpPayloadAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", udpPayloadAnnotation, err)
+	}
+	prefix, err := decodeAnnotationBytes(pod.Annotations[udpExpectPrefixAnnotation])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", udpExpectPrefixAnnotation, err)
+	}
+	return &UDPProbeOptions{Payload: payload, ExpectPrefix: prefix}, nil
+}
+
+// decodeAnnotationBytes returns the bytes of an annotation value, hex-decoded when prefixed with 0x
+func decodeAnnotationBytes(value string) ([]byte, error) {
+	if hexValue, isHex := strings.CutPrefix(value, "0x"); isHex {
+		return hex.DecodeString(hexValue)
+	}
+	return []byte(value), nil
+}
+
+// getUDPPorts returns the UDP container ports declared by the pod
+func getUDPPorts(pod *corev1.Pod) []int32 {
+	var ports []int32
+	for _, c := range pod.Spec.Containers {
+		for _, port := range c.Ports {
+			if port.Protocol == corev1.ProtocolUDP && !slices.Contains(ports, port.ContainerPort) {
+				ports = append(ports, port.ContainerPort)
+			}
+		}
+	}
+	return ports
+}
+
+// capPorts keeps the max lowest ports, so the same subset is probed on every update
+func capPorts(ports []int32, max int) (kept, dropped []int32) {
+	sorted := append([]int32(nil), ports...)
+	slices.Sort(sorted)
+	return sorted[:max], sorted[max:]
+}
+
+// filterHTTPTargets keeps the HTTP targets on one of the given ports
+func filterHTTPTargets(targets []HTTPTarget, ports []int32) []HTTPTarget {
+	var kept []HTTPTarget
+	for _, target := range targets {
+		if slices.Contains(ports, target.Port) {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
+
+// filterGRPCTargets keeps the gRPC targets on one of the given ports
+func filterGRPCTargets(targets []GRPCTarget, ports []int32) []GRPCTarget {
+	var kept []GRPCTarget
+	for _, target := range targets {
+		if slices.Contains(ports, target.Port) {
+			kept = append(kept, target)
+		}
+	}
+	return kept
+}
+
+// getProbeThresholds returns the failure and success thresholds declared by the pod's
+// readiness probes, falling back to liveness probes, and 1/1 when none declare them.
+// With several containers the most tolerant value wins.
+func getProbeThresholds(pod *corev1.Pod) (failure, success int32) {
+	for _, useReadiness := range []bool{true, false} {
+		for _, c := range pod.Spec.Containers {
+			probe := c.LivenessProbe
+			if useReadiness {
+				probe = c.ReadinessProbe
+			}
+			if probe == nil {
+				continue
+			}
+			if probe.FailureThreshold > failure {
+				failure = probe.FailureThreshold
+			}
+			if probe.SuccessThreshold > success {
+				success = probe.SuccessThreshold
+			}
+		}
+		if failure > 0 || success > 0 {
+			break
+		}
+	}
+	if failure < 1 {
+		failure = 1
+	}
+	if success < 1 {
+		success = 1
+	}
+	return failure, success
+}
+
+// getDurationAnnotation returns the positive duration of a pod annotation, 0 when it is absent or
+// invalid so the global setting applies
+func getDurationAnnotation(pod *corev1.Pod, annotation string) time.Duration {
+	value := pod.Annotations[annotation]
+	if value == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("duration must be positive")
+	}
+	if err != nil {
+		klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, annotation, err)
+		return 0
+	}
+	return d
+}
+
+// getRetryCount returns the probe retries of a pod's annotation, nil when it is absent or invalid
+// so the global count applies
+func getRetryCount(pod *corev1.Pod) *int {
+	value, ok := pod.Annotations[retryCountAnnotation]
+	if !ok {
+		return nil
+	}
+	count, err := strconv.Atoi(value)
+	if err == nil && count < 0 {
+		err = fmt.Errorf("retry count must not be negative")
+	}
+	if err != nil {
+		klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, retryCountAnnotation, err)
+		return nil
+	}
+	return &count
+}
+
+// getProbeStartAt returns when the initialDelaySeconds of the containers' readiness probes
+// have passed, measured from each container's start. Zero when none declare a delay.
+func getProbeStartAt(pod *corev1.Pod) time.Time {
+	startedAt := make(map[string]time.Time, len(pod.Status.ContainerStatuses))
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Running != nil {
+			startedAt[status.Name] = status.State.Running.StartedAt.Time
+		}
+	}
+
+	var probeStartAt time.Time
+	for _, c := range pod.Spec.Containers {
+		if c.ReadinessProbe == nil || c.ReadinessProbe.InitialDelaySeconds <= 0 {
+			continue
+		}
+		started, ok := startedAt[c.Name]
+		if !ok {
+			continue
+		}
+		if at := started.Add(time.Duration(c.ReadinessProbe.InitialDelaySeconds) * time.Second); at.After(probeStartAt) {
+			probeStartAt = at
+		}
+	}
+	return probeStartAt
+}
+
+// getHTTPTargets collects the HTTP endpoints declared by container probes
+func getHTTPTargets(pod *corev1.Pod) []HTTPTarget {
+	var targets []HTTPTarget
+	seen := make(map[string]struct{})
+	for _, c := range pod.Spec.Containers {
+		for _, probe := range []*corev1.Probe{c.LivenessProbe, c.ReadinessProbe} {
+			if probe == nil || probe.HTTPGet == nil {
+				continue
+			}
+			// Unresolvable named ports are reported by getContainerPorts
+			port, ok := resolveContainerPort(c, probe.HTTPGet.Port)
+			if !ok {
+				continue
+			}
+			target := HTTPTarget{
+				Port:   port,
+				Path:   probe.HTTPGet.Path,
+				Scheme: strings.ToLower(string(probe.HTTPGet.Scheme)),
+				Host:   probe.HTTPGet.Host,
+			}
+			for _, header := range probe.HTTPGet.HTTPHeaders {
+				if target.Headers == nil {
+					target.Headers = make(http.Header)
+				}
+				target.Headers.Add(header.Name, header.Value)
+			}
+			// http.Header is not comparable, its formatted value is stable as map keys print sorted
+			key := fmt.Sprint(target)
+			if _, exists := seen[key]; !exists {
+				seen[key] = struct{}{}
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// getHTTPRequestOptions parses the HTTP header, token file and assertion annotations
+func getHTTPRequestOptions(pod *corev1.Pod) HTTPRequestOptions {
+	opts := HTTPRequestOptions{
+		TokenFile: pod.Annotations[httpTokenFileAnnotation],
+		Body:      pod.Annotations[httpBodyAnnotation],
+	}
+	if method := pod.Annotations[httpMethodAnnotation]; method != "" {
+		if normalized, err := parseHTTPMethod(method); err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, httpMethodAnnotation, err)
+		} else {
+			opts.Method = normalized
+		}
+	}
+	if expect := pod.Annotations[httpExpectHeaderAnnotation]; expect != "" {
+		expected, err := parseExpectedHeaders(expect)
+		if err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, httpExpectHeaderAnnotation, err)
+		} else {
+			opts.ExpectHeaders = expected
+		}
+	}
+	if codes := pod.Annotations[httpExpectedCodesAnnotation]; codes != "" {
+		expected, err := parseExpectedCodes(codes)
+		if err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, httpExpectedCodesAnnotation, err)
+		} else {
+			opts.ExpectCodes = expected
+		}
+	}
+	if expected := pod.Annotations[httpExpectVersionAnnotation]; expected != "" {
+		match, err := parseVersionMatch(pod.Annotations[httpVersionMatchAnnotation])
+		if err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, httpExpectVersionAnnotation, err)
+		} else {
+			field := pod.Annotations[httpVersionFieldAnnotation]
+			if field == "" {
+				field = "version"
+			}
+			opts.ExpectVersion = &VersionAssertion{Field: field, Expected: expected, Match: match}
+		}
+	}
+	if list := pod.Annotations[httpHeadersAnnotation]; list != "" {
+		if headers, err := parseHTTPHeaders(list); err != nil {
+			klog.Warningf("Pod %s/%s: ignoring %s annotation: %v", pod.Namespace, pod.Name, httpHeadersAnnotation, err)
+		} else if len(headers) > 0 {
+			opts.Headers = headers
+		}
+	}
+	// A single-header annotation overrides the same header in the list
+	for key, value := range pod.Annotations {
+		name := strings.TrimPrefix(key, httpHeaderAnnotationPrefix)
+		if name == key || name == "" {
+			continue
+		}
+		if opts.Headers == nil {
+			opts.Headers = make(http.Header)
+		}
+		opts.Headers.Set(name, value)
+	}
+	return opts
+}
+
+func (ps *PodSet) shouldCheckPod(pod *corev1.Pod) bool {
+	if isPodExcluded(pod) || !ps.namespaceInScope(pod.Namespace) {
+		return false
+	}
+
+	if enabled, exists := ps.namespacePolicy[pod.Namespace]; exists {
+		return enabled
+	}
 
 	if pod.Annotations != nil {
-		if value, exists := pod.Annotations[annotationKey]; exists {
+		if value, exists := pod.Annotations[ps.annotationKey]; exists {
 			return value == "true"
 		}
 	}
 
 	// legacy way for backward compatibility
-	for _, gate := range pod.Spec.ReadinessGates {
-		if string(gate.ConditionType) == readinessGateType {
-			return true
+	return hasReadinessGate(pod, ps.readinessGateType)
+}
+
+// isPodExcluded reports whether the pod opted out via the exclude annotation
+func isPodExcluded(pod *corev1.Pod) bool {
+	return pod.Annotations[excludeAnnotation] == "true"
+}
+
+// containersReady reports whether kubelet considers every container of the pod Ready. Unlike
+// the pod's Ready condition, container readiness is never written by us.
+func containersReady(pod *corev1.Pod) bool {
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready {
+			return false
 		}
 	}
-
-	return false
+	return true
 }
 
 // isPodReady checks if Pod has passed kubelet's readiness probe
@@ -193,10 +1083,293 @@ func isPodReady(pod *corev1.Pod) bool {
 	return false
 }
 
-func (p *PodInfo) GetNamespace() string            { return p.Namespace }
-func (p *PodInfo) GetName() string                 { return p.Name }
-func (p *PodInfo) GetIP() string                   { return p.IP }
-func (p *PodInfo) GetPorts() []int32               { return p.Ports }
-func (p *PodInfo) SetIsBeingChecked(checked bool)  { p.IsBeingChecked = checked }
-func (p *PodInfo) GetLastHealthStatus() *bool      { return p.LastHealthStatus }
-func (p *PodInfo) SetLastHealthStatus(status bool) { p.LastHealthStatus = &status }
+func (p *PodInfo) GetNamespace() string         { return p.Namespace }
+func (p *PodInfo) GetName() string              { return p.Name }
+func (p *PodInfo) GetIP() string                { return p.IP }
+func (p *PodInfo) GetPorts() []int32            { return p.Ports }
+func (p *PodInfo) GetCreatedAt() time.Time      { return p.CreatedAt }
+func (p *PodInfo) GetProbeStartAt() time.Time   { return p.ProbeStartAt }
+func (p *PodInfo) GetNodeName() string          { return p.NodeName }
+func (p *PodInfo) GetContainersReady() bool     { return p.ContainersReady }
+func (p *PodInfo) GetProbeChain() []string      { return p.ProbeChain }
+func (p *PodInfo) GetProxyProtocol() string     { return p.ProxyProtocol }
+func (p *PodInfo) GetAdoptedAt() time.Time      { return p.AdoptedAt }
+func (p *PodInfo) GetGRPCTargets() []GRPCTarget { return p.GRPCTargets }
+func (p *PodInfo) GetLabels() map[string]string { return p.Labels }
+func (p *PodInfo) IsTerminating() bool          { return p.Terminating }
+func (p *PodInfo) GetHTTPTargets() []HTTPTarget { return p.HTTPTargets }
+func (p *PodInfo) GetHTTPOptions() HTTPRequestOptions {
+	return p.HTTPOptions
+}
+func (p *PodInfo) GetTLSOptions() *TLSProbeOptions {
+	return p.TLS
+}
+
+func (p *PodInfo) GetUDPOptions() *UDPProbeOptions {
+	return p.UDPOptions
+}
+func (p *PodInfo) GetCallback() *HTTPTarget {
+	return p.Callback
+}
+func (p *PodInfo) GetProbeThresholds() (failure, success int32) {
+	return p.FailureThreshold, p.SuccessThreshold
+}
+func (p *PodInfo) GetBaseInterval() time.Duration { return p.BaseInterval }
+func (p *PodInfo) GetProbeTimeout() time.Duration { return p.ProbeTimeout }
+func (p *PodInfo) GetRetryCount() *int            { return p.RetryCount }
+
+// The getters and setters below cover the check state, see PodInfo.state
+
+func (p *PodInfo) GetLastHealthStatus() *bool {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.LastHealthStatus
+}
+
+func (p *PodInfo) GetLastAssertTime() time.Time {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.LastAssertTime
+}
+
+func (p *PodInfo) GetPassingSince() time.Time {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.PassingSince
+}
+
+func (p *PodInfo) GetCheckInterval() time.Duration {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.CheckInterval
+}
+
+func (p *PodInfo) SetCheckInterval(d time.Duration) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.CheckInterval = d
+}
+
+func (p *PodInfo) GetTimeoutStreak() int32 {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.TimeoutStreak
+}
+
+func (p *PodInfo) SetTimeoutStreak(n int32) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.TimeoutStreak = n
+}
+
+func (p *PodInfo) SetLastAssertTime(t time.Time) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.LastAssertTime = t
+}
+
+func (p *PodInfo) SetLastHealthStatus(status bool) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.LastHealthStatus = &status
+}
+
+func (p *PodInfo) ClearLastHealthStatus() {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.LastHealthStatus = nil
+}
+
+// snapshot returns a copy of the pod sharing none of the state its checks keep updating
+func (p *PodInfo) snapshot() *PodInfo {
+	p.state.Lock()
+	defer p.state.Unlock()
+
+	pod := &PodInfo{
+		Namespace:        p.Namespace,
+		Name:             p.Name,
+		IP:               p.IP,
+		Ports:            p.Ports,
+		IsBeingChecked:   p.IsBeingChecked,
+		CreatedAt:        p.CreatedAt,
+		Terminating:      p.Terminating,
+		TLS:              p.TLS,
+		OwnerKind:        p.OwnerKind,
+		OwnerName:        p.OwnerName,
+		LastAssertTime:   p.LastAssertTime,
+		HTTPTargets:      p.HTTPTargets,
+		HTTPOptions:      p.HTTPOptions,
+		FailureThreshold: p.FailureThreshold,
+		SuccessThreshold: p.SuccessThreshold,
+		Failures:         p.Failures,
+		Successes:        p.Successes,
+		PassingSince:     p.PassingSince,
+		Labels:           p.Labels,
+		LastDispatchedAt: p.LastDispatchedAt,
+		CheckInterval:    p.CheckInterval,
+		BaseInterval:     p.BaseInterval,
+		ProbeTimeout:     p.ProbeTimeout,
+		RetryCount:       p.RetryCount,
+		TimeoutStreak:    p.TimeoutStreak,
+		ContainerPorts:   p.ContainerPorts,
+		FailureClass:     p.FailureClass,
+		FailureTimes:     slices.Clone(p.FailureTimes),
+		NodeName:         p.NodeName,
+		TransitionTimes:  slices.Clone(p.TransitionTimes),
+		ProbeStartAt:     p.ProbeStartAt,
+		FamilyIPs:        p.FamilyIPs,
+		FamilyHealth:     maps.Clone(p.FamilyHealth),
+		PortsSeenUp:      maps.Clone(p.PortsSeenUp),
+		ContainersReady:  p.ContainersReady,
+		ProbeChain:       p.ProbeChain,
+		ProxyProtocol:    p.ProxyProtocol,
+		AdoptedAt:        p.AdoptedAt,
+		GRPCTargets:      p.GRPCTargets,
+		UDPOptions:       p.UDPOptions,
+		UID:              p.UID,
+		Callback:         p.Callback,
+		History:          p.History.clone(),
+		DetailEvicted:    p.DetailEvicted,
+	}
+	if p.LastHealthStatus != nil {
+		healthy := *p.LastHealthStatus
+		pod.LastHealthStatus = &healthy
+	}
+	return pod
+}
+
+// inheritCheckState takes over the state built by the checks of a previous entry of the same pod
+func (p *PodInfo) inheritCheckState(from *PodInfo) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	from.state.Lock()
+	defer from.state.Unlock()
+
+	p.LastHealthStatus = from.LastHealthStatus
+	p.LastAssertTime = from.LastAssertTime
+	p.Failures, p.Successes, p.PassingSince = from.Failures, from.Successes, from.PassingSince
+	p.CheckInterval = from.CheckInterval
+	p.TimeoutStreak = from.TimeoutStreak
+	p.FailureClass, p.FailureTimes = from.FailureClass, from.FailureTimes
+	p.FamilyHealth = from.FamilyHealth
+	p.TransitionTimes = from.TransitionTimes
+	p.PortsSeenUp = from.PortsSeenUp
+	p.History, p.DetailEvicted = from.History, from.DetailEvicted
+}
+
+// RecordProbeResult extends the current run of results and returns the consecutive failure and success counts
+func (p *PodInfo) RecordProbeResult(healthy bool) (failures, successes int32) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	if healthy {
+		if p.Successes == 0 {
+			p.PassingSince = time.Now()
+		}
+		p.Successes++
+		p.Failures = 0
+	} else {
+		p.Failures++
+		p.Successes = 0
+	}
+	return p.Failures, p.Successes
+}
+
+// GetFamilyIPs returns the pod IP of each family, nil unless the pod is dual-stack
+func (p *PodInfo) GetFamilyIPs() map[string]string { return p.FamilyIPs }
+
+// GetFamilyHealth returns the last reported reachability of an IP family, nil if unknown
+func (p *PodInfo) GetFamilyHealth(family string) *bool {
+	p.state.Lock()
+	defer p.state.Unlock()
+	healthy, ok := p.FamilyHealth[family]
+	if !ok {
+		return nil
+	}
+	return &healthy
+}
+
+// SetFamilyHealth records the reported reachability of an IP family
+func (p *PodInfo) SetFamilyHealth(family string, healthy bool) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	if p.FamilyHealth == nil {
+		p.FamilyHealth = make(map[string]bool)
+	}
+	p.FamilyHealth[family] = healthy
+}
+
+// RecordPortsUp marks ports as having passed and returns the declared ports that never have
+func (p *PodInfo) RecordPortsUp(ports []int32) (pending []int32) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	if p.PortsSeenUp == nil {
+		p.PortsSeenUp = make(map[int32]bool)
+	}
+	for _, port := range ports {
+		p.PortsSeenUp[port] = true
+	}
+	for _, port := range p.Ports {
+		if !p.PortsSeenUp[port] {
+			pending = append(pending, port)
+		}
+	}
+	return pending
+}
+
+// RecordCheck adds a check to the pod's history, keeping the latest size checks. A pod whose
+// detail was evicted stays without history until a check fails.
+func (p *PodInfo) RecordCheck(record CheckRecord, size int) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	if p.DetailEvicted {
+		if record.Healthy {
+			return
+		}
+		p.DetailEvicted = false
+	}
+	if p.History == nil {
+		p.History = &CheckHistory{}
+	}
+	p.History.add(record, size)
+}
+
+// CanTransition reports whether a status transition at the given time stays within max
+// transitions in the window before it, forgetting transitions that left the window
+func (p *PodInfo) CanTransition(at time.Time, window time.Duration, max int) bool {
+	p.state.Lock()
+	defer p.state.Unlock()
+	recent := p.TransitionTimes[:0]
+	for _, t := range p.TransitionTimes {
+		if at.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	p.TransitionTimes = recent
+	return len(recent) < max
+}
+
+// RecordTransition records a status transition that was written at the given time
+func (p *PodInfo) RecordTransition(at time.Time) {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.TransitionTimes = append(p.TransitionTimes, at)
+}
+
+// RecordFailureClass extends the run of failures of one class, keeping the latest keep
+// timestamps, and returns a copy of them. A different class starts a new run, an empty class ends it.
+func (p *PodInfo) RecordFailureClass(class string, at time.Time, keep int) []time.Time {
+	p.state.Lock()
+	defer p.state.Unlock()
+	if class != p.FailureClass {
+		p.FailureClass = class
+		p.FailureTimes = nil
+	}
+	if class == "" {
+		return nil
+	}
+	p.FailureTimes = append(p.FailureTimes, at)
+	if keep > 0 && len(p.FailureTimes) > keep {
+		p.FailureTimes = p.FailureTimes[len(p.FailureTimes)-keep:]
+	}
+	return slices.Clone(p.FailureTimes)
+}
@@ -0,0 +1,403 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newReadyPod returns a running, ready pod with the given annotations
+func newReadyPod(namespace, name, ip string, annotations map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: annotations,
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: ip,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+}
+
+func TestNamespacePolicy(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+
+	tests := []struct {
+		name        string
+		namespace   string
+		annotations map[string]string
+		expected    int
+	}{
+		{name: "enable override without annotation", namespace: "prod", annotations: nil, expected: 1},
+		{name: "enable override with annotation disabled", namespace: "prod",
+			annotations: map[string]string{"endpoint-health-checker.io/enabled": "false"}, expected: 1},
+		{name: "disable override ignores annotation", namespace: "kube-system", annotations: enabled, expected: 0},
+		{name: "default namespace uses annotation", namespace: "default", annotations: enabled, expected: 1},
+		{name: "default namespace without annotation", namespace: "default", annotations: nil, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := NewPodSet()
+			podSet.SetNamespacePolicy(map[string]bool{"prod": true, "kube-system": false})
+
+			podSet.AddOrUpdate(newReadyPod(tt.namespace, "test-pod", "10.0.0.1", tt.annotations))
+
+			count, _ := podSet.GetStats()
+			assert.Equal(t, tt.expected, count)
+		})
+	}
+}
+
+func TestExcludeAnnotationOverridesEnablement(t *testing.T) {
+	exclude := func(annotations map[string]string) map[string]string {
+		annotations[excludeAnnotation] = "true"
+		return annotations
+	}
+	withGate := func(pod *corev1.Pod) *corev1.Pod {
+		pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+		return pod
+	}
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+	}{
+		{name: "namespace policy", pod: newReadyPod("prod", "test-pod", "10.0.0.1", exclude(map[string]string{}))},
+		{name: "enabled annotation", pod: newReadyPod("default", "test-pod", "10.0.0.1",
+			exclude(map[string]string{"endpoint-health-checker.io/enabled": "true"}))},
+		{name: "readiness gate", pod: withGate(newReadyPod("default", "test-pod", "10.0.0.1", exclude(map[string]string{})))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			podSet := NewPodSet()
+			podSet.SetNamespacePolicy(map[string]bool{"prod": true})
+
+			podSet.AddOrUpdate(tt.pod)
+			count, _ := podSet.GetStats()
+			assert.Equal(t, 0, count)
+
+			// Without the exclude annotation the same pod is tracked
+			delete(tt.pod.Annotations, excludeAnnotation)
+			podSet.AddOrUpdate(tt.pod)
+			count, _ = podSet.GetStats()
+			assert.Equal(t, 1, count)
+
+			// Excluding a tracked pod stops tracking it
+			tt.pod.Annotations[excludeAnnotation] = "true"
+			podSet.AddOrUpdate(tt.pod)
+			count, _ = podSet.GetStats()
+			assert.Equal(t, 0, count)
+		})
+	}
+}
+
+func TestTLSServerNameAnnotation(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "tls-pod", "10.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled":        "true",
+		"endpoint-health-checker.io/tls-servername": "api.example.com",
+	}))
+
+	pods := podSet.GetAvailablePods()
+	assert.Len(t, pods, 1)
+	if assert.NotNil(t, pods[0].GetTLSOptions()) {
+		assert.Equal(t, TLSProbeOptions{ServerName: "api.example.com"}, *pods[0].GetTLSOptions())
+	}
+}
+
+func TestGetProbeThresholds(t *testing.T) {
+	pod := newReadyPod("default", "web", "10.0.0.1", nil)
+	failure, success := getProbeThresholds(pod)
+	assert.Equal(t, int32(1), failure)
+	assert.Equal(t, int32(1), success)
+
+	pod.Spec.Containers = []corev1.Container{
+		{Name: "app", ReadinessProbe: &corev1.Probe{FailureThreshold: 5, SuccessThreshold: 2},
+			LivenessProbe: &corev1.Probe{FailureThreshold: 10}},
+		{Name: "sidecar", ReadinessProbe: &corev1.Probe{FailureThreshold: 3}},
+	}
+	failure, success = getProbeThresholds(pod)
+	assert.Equal(t, int32(5), failure, "readiness probes take precedence, most tolerant wins")
+	assert.Equal(t, int32(2), success)
+
+	pod.Spec.Containers = []corev1.Container{{Name: "app", LivenessProbe: &corev1.Probe{FailureThreshold: 4}}}
+	failure, success = getProbeThresholds(pod)
+	assert.Equal(t, int32(4), failure, "liveness probe is the fallback")
+	assert.Equal(t, int32(1), success)
+}
+
+func TestContainerPortsOnlyProbedContainersByDefault(t *testing.T) {
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{
+		{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: 8080}, {ContainerPort: 5353, Protocol: corev1.ProtocolUDP}}},
+		{Name: "sidecar", ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(15021)},
+		}}},
+	}
+
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+	pods := podSet.GetAvailablePods()
+	assert.Len(t, pods, 1)
+	assert.Equal(t, []int32{15021}, pods[0].GetPorts())
+	assert.Equal(t, map[string][]int32{"sidecar": {15021}}, pods[0].ContainerPorts)
+
+	podSet = NewPodSet()
+	podSet.SetProbeAllContainers(true)
+	podSet.AddOrUpdate(pod)
+	pods = podSet.GetAvailablePods()
+	assert.Len(t, pods, 1)
+	assert.ElementsMatch(t, []int32{8080, 15021}, pods[0].GetPorts())
+	assert.Equal(t, map[string][]int32{"app": {8080}, "sidecar": {15021}}, pods[0].ContainerPorts)
+}
+
+func TestNamedProbePorts(t *testing.T) {
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{
+		{
+			Name:  "app",
+			Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9090}, {Name: "http", ContainerPort: 8080}},
+			ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/ready", Port: intstr.FromString("http")},
+			}},
+		},
+		{
+			Name: "sidecar",
+			ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+				TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("admin")},
+			}},
+		},
+	}
+
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+	pods := podSet.GetAvailablePods()
+	assert.Len(t, pods, 1)
+	assert.Equal(t, []int32{8080}, pods[0].GetPorts())
+	assert.Equal(t, map[string][]int32{"app": {8080}}, pods[0].ContainerPorts, "an unresolvable name is skipped")
+	assert.Equal(t, []HTTPTarget{{Port: 8080, Path: "/ready"}}, pods[0].GetHTTPTargets())
+}
+
+func TestMaxPortsPerPod(t *testing.T) {
+	pod := newReadyPod("default", "web", "10.0.0.1", map[string]string{
+		"endpoint-health-checker.io/enabled":   "true",
+		"endpoint-health-checker.io/http-urls": ":9443/ready",
+	})
+	container := corev1.Container{Name: "app"}
+	for _, port := range []int{8084, 8081, 8083, 8080, 8082} {
+		container.Ports = append(container.Ports, corev1.ContainerPort{ContainerPort: int32(port)})
+	}
+	pod.Spec.Containers = []corev1.Container{container}
+
+	podSet := NewPodSet()
+	podSet.SetProbeAllContainers(true)
+	podSet.SetMaxPortsPerPod(3)
+	podSet.AddOrUpdate(pod)
+	pods := podSet.GetAvailablePods()
+	if assert.Len(t, pods, 1) {
+		// The same lowest ports are kept whatever the declaration order, targets on dropped ports go too
+		assert.Equal(t, []int32{8080, 8081, 8082}, pods[0].GetPorts())
+		assert.Empty(t, pods[0].GetHTTPTargets())
+	}
+
+	podSet.SetMaxPortsPerPod(0)
+	podSet.AddOrUpdate(pod)
+	assert.Len(t, podSet.GetAvailablePods()[0].GetPorts(), 6)
+}
+
+func TestRecreatedPodStartsFresh(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+
+	first := newReadyPod("default", "db-0", "10.0.0.5", enabled)
+	first.UID = "uid-1"
+	podSet.AddOrUpdate(first)
+	podSet.ForEach(func(pod *PodInfo) {
+		pod.AdoptedAt = time.Now().Add(-time.Hour)
+		pod.TransitionTimes = []time.Time{time.Now()}
+		pod.PortsSeenUp = map[int32]bool{5432: true}
+	})
+
+	// Updates of the same pod keep its state
+	podSet.AddOrUpdate(first)
+	pod := podSet.GetPod("default", "db-0")
+	assert.Len(t, pod.TransitionTimes, 1)
+	assert.True(t, pod.PortsSeenUp[5432])
+	assert.Greater(t, time.Since(pod.GetAdoptedAt()), time.Minute)
+
+	// Recreated with the same IP, as StatefulSet pods with fixed IPs are, it starts fresh
+	second := newReadyPod("default", "db-0", "10.0.0.5", enabled)
+	second.UID = "uid-2"
+	podSet.AddOrUpdate(second)
+	pod = podSet.GetPod("default", "db-0")
+	assert.Equal(t, types.UID("uid-2"), pod.UID)
+	assert.Empty(t, pod.TransitionTimes)
+	assert.Empty(t, pod.PortsSeenUp)
+	assert.Less(t, time.Since(pod.GetAdoptedAt()), time.Minute)
+
+	// Recreated with a new IP before the delete event arrived, the previous pod is dropped
+	third := newReadyPod("default", "db-0", "10.0.0.6", enabled)
+	third.UID = "uid-3"
+	podSet.AddOrUpdate(third)
+	total, _ := podSet.GetStats()
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "10.0.0.6", podSet.GetPod("default", "db-0").GetIP())
+}
+
+func TestMarkDispatchedWhileUpdated(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+	web := newReadyPod("default", "web-0", "10.0.0.5", enabled)
+	podSet.AddOrUpdate(web)
+
+	// Informer updates copy the dispatch time from the entry they replace
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			podSet.AddOrUpdate(web)
+		}
+	}()
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		podSet.MarkDispatched([]string{"10.0.0.5", "10.0.0.6"}, now)
+	}
+	<-done
+
+	assert.True(t, podSet.GetPod("default", "web-0").LastDispatchedAt.Equal(now))
+}
+
+func TestGetPodSnapshotsCheckState(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "web-0", "10.0.0.5", map[string]string{"endpoint-health-checker.io/enabled": "true"}))
+	var tracked *PodInfo
+	podSet.ForEach(func(pod *PodInfo) { tracked = pod })
+
+	// A worker keeps recording checks while the pod is read
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			tracked.RecordProbeResult(i%2 == 0)
+			tracked.RecordCheck(CheckRecord{At: time.Now(), Healthy: true}, 10)
+			tracked.RecordPortsUp([]int32{int32(i)})
+			tracked.SetFamilyHealth("IPv4", i%2 == 0)
+			tracked.CanTransition(time.Now(), time.Hour, 5)
+			tracked.RecordTransition(time.Now())
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		pod := podSet.GetPod("default", "web-0")
+		_ = pod.History.Records()
+		_ = len(pod.PortsSeenUp)
+	}
+	<-done
+
+	// The copy shares nothing the checks update
+	pod := podSet.GetPod("default", "web-0")
+	assert.Len(t, pod.History.Records(), 10)
+	pod.PortsSeenUp[1000] = true
+	pod.FamilyHealth["IPv6"] = true
+	pod.TransitionTimes[0] = time.Time{}
+	tracked.RecordCheck(CheckRecord{At: time.Now(), Healthy: false}, 10)
+	assert.False(t, tracked.PortsSeenUp[1000])
+	assert.Nil(t, tracked.GetFamilyHealth("IPv6"))
+	assert.False(t, tracked.TransitionTimes[0].IsZero())
+	assert.True(t, pod.History.Records()[9].Healthy)
+}
+
+func TestPodIPChangeReplacesEntry(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+
+	web := newReadyPod("default", "web-0", "10.0.0.5", enabled)
+	web.UID = "uid-1"
+	podSet.AddOrUpdate(web)
+	podSet.ForEach(func(pod *PodInfo) { pod.TransitionTimes = []time.Time{time.Now()} })
+
+	// The same pod on a new IP keeps its state, only the new IP is probed
+	web.Status.PodIP = "10.0.0.9"
+	podSet.AddOrUpdate(web)
+	total, _ := podSet.GetStats()
+	assert.Equal(t, 1, total)
+	pod := podSet.GetPod("default", "web-0")
+	assert.Equal(t, "10.0.0.9", pod.GetIP())
+	assert.Len(t, pod.TransitionTimes, 1)
+	assert.False(t, podSet.DeleteIfOwnedBy("10.0.0.5", "default", "web-0"), "the old IP is no longer tracked")
+
+	// Another pod taking over the old IP doesn't disturb it
+	podSet.AddOrUpdate(newReadyPod("default", "web-1", "10.0.0.5", enabled))
+	total, _ = podSet.GetStats()
+	assert.Equal(t, 2, total)
+
+	// Nor does a pod taking over its current IP leave it indexed
+	podSet.AddOrUpdate(newReadyPod("default", "web-2", "10.0.0.9", enabled))
+	assert.Nil(t, podSet.GetPod("default", "web-0"))
+	podSet.DeleteByNamespaceAndName("default", "web-0")
+	total, _ = podSet.GetStats()
+	assert.Equal(t, 2, total)
+}
+
+func TestCustomOptInKeys(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.SetOptInKeys("tenant-a.example.com/health-check", "tenantAHealthy")
+
+	optedIn := newReadyPod("default", "annotated", "10.0.0.1", map[string]string{"tenant-a.example.com/health-check": "true"})
+	gated := newReadyPod("default", "gated", "10.0.0.2", nil)
+	gated.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "tenantAHealthy"}}
+	defaultKeys := newReadyPod("default", "default-keys", "10.0.0.3", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	defaultKeys.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+
+	assert.True(t, podSet.shouldCheckPod(optedIn))
+	assert.True(t, podSet.shouldCheckPod(gated))
+	assert.False(t, podSet.shouldCheckPod(defaultKeys), "the default keys belong to another instance")
+
+	// Empty values keep the defaults
+	podSet = NewPodSet()
+	podSet.SetOptInKeys("", "")
+	assert.True(t, podSet.shouldCheckPod(defaultKeys))
+}
+
+func TestNamespaceScope(t *testing.T) {
+	annotated := func(namespace string) *corev1.Pod {
+		return newReadyPod(namespace, "web", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	}
+
+	// Empty lists track every namespace
+	podSet := NewPodSet()
+	podSet.SetNamespaceScope(nil, nil)
+	assert.True(t, podSet.shouldCheckPod(annotated("prod")))
+
+	// The allowlist limits tracking to its namespaces
+	podSet.SetNamespaceScope([]string{"prod", "staging"}, nil)
+	assert.True(t, podSet.shouldCheckPod(annotated("prod")))
+	assert.False(t, podSet.shouldCheckPod(annotated("dev")), "annotated but not allowed")
+
+	// The denylist alone excludes its namespaces only
+	podSet.SetNamespaceScope(nil, []string{"kube-system"})
+	assert.False(t, podSet.shouldCheckPod(annotated("kube-system")))
+	assert.True(t, podSet.shouldCheckPod(annotated("dev")))
+
+	// The denylist wins over the allowlist and the namespace policy
+	podSet.SetNamespaceScope([]string{"prod", "staging"}, []string{"staging"})
+	podSet.SetNamespacePolicy(map[string]bool{"staging": true})
+	assert.True(t, podSet.shouldCheckPod(annotated("prod")))
+	assert.False(t, podSet.shouldCheckPod(annotated("staging")))
+
+	podSet.AddOrUpdate(annotated("staging"))
+	total, _ := podSet.GetStats()
+	assert.Zero(t, total, "pods in denied namespaces are never tracked")
+}
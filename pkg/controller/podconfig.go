@@ -0,0 +1,214 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// podConfigPathPrefix is where PodConfigHandler is mounted, followed by {namespace}/{name}/config
+// or {namespace}/{name}/history
+const podConfigPathPrefix = "/api/v1/pods/"
+
+// EffectiveConfig is the resolved configuration a pod is probed with, the checker's global
+// settings overlaid with the pod's annotations and probe specs. Header values, tokens and
+// payloads may be secrets and are never included, only the header names.
+type EffectiveConfig struct {
+	Namespace        string            `json:"namespace"`
+	Name             string            `json:"name"`
+	IP               string            `json:"ip"`
+	FamilyIPs        map[string]string `json:"familyIPs,omitempty"`
+	Protocol         string            `json:"protocol"`
+	ProbeChain       []string          `json:"probeChain,omitempty"`
+	Ports            []int32           `json:"ports,omitempty"`
+	RemappedPorts    map[int32]int32   `json:"remappedPorts,omitempty"`
+	Interval         string            `json:"interval"`
+	Timeout          string            `json:"timeout"`
+	RetryCount       int               `json:"retryCount"`
+	Deadline         string            `json:"deadline"`
+	FailureThreshold int32             `json:"failureThreshold"`
+	SuccessThreshold int32             `json:"successThreshold"`
+	ProxyProtocol    string            `json:"proxyProtocol,omitempty"`
+	Callback         string            `json:"callback,omitempty"`
+	CertMinTTL       string            `json:"certMinTTL,omitempty"`
+	HTTP             *EffectiveHTTP    `json:"http,omitempty"`
+	GRPC             []EffectiveGRPC   `json:"grpc,omitempty"`
+	TLS              *EffectiveTLS     `json:"tls,omitempty"`
+	UDP              *EffectiveUDP     `json:"udp,omitempty"`
+}
+
+// EffectiveHTTP are the resolved settings of a pod's HTTP probes
+type EffectiveHTTP struct {
+	URLs          []string `json:"urls"`
+	Method        string   `json:"method"`
+	ExpectCodes   []int    `json:"expectCodes,omitempty"`
+	HeaderNames   []string `json:"headerNames,omitempty"`
+	TokenFile     string   `json:"tokenFile,omitempty"`
+	ExpectHeaders []string `json:"expectHeaders,omitempty"`
+	ExpectVersion string   `json:"expectVersion,omitempty"`
+}
+
+// EffectiveGRPC is one resolved gRPC probe target of a pod
+type EffectiveGRPC struct {
+	Port    int32  `json:"port"`
+	Service string `json:"service,omitempty"`
+	Method  string `json:"method,omitempty"`
+}
+
+// EffectiveTLS are the resolved settings of a pod's TLS probes
+type EffectiveTLS struct {
+	ServerName string `json:"serverName,omitempty"`
+	Verify     bool   `json:"verify"`
+}
+
+// EffectiveUDP are the resolved settings of a pod's UDP probes, sizes only since payloads may be secrets
+type EffectiveUDP struct {
+	PayloadBytes      int `json:"payloadBytes"`
+	ExpectPrefixBytes int `json:"expectPrefixBytes"`
+}
+
+// EffectiveConfig resolves the configuration the next check of pod runs with
+func (hc *HealthChecker) EffectiveConfig(pod HealthCheckPodInfo) EffectiveConfig {
+	failureThreshold, successThreshold := pod.GetProbeThresholds()
+	interval := pod.GetCheckInterval()
+	if interval <= 0 {
+		interval = hc.baseInterval(pod)
+	}
+	cfg := EffectiveConfig{
+		Namespace:        pod.GetNamespace(),
+		Name:             pod.GetName(),
+		IP:               pod.GetIP(),
+		FamilyIPs:        pod.GetFamilyIPs(),
+		Protocol:         probeProtocol(pod),
+		ProbeChain:       pod.GetProbeChain(),
+		Ports:            pod.GetPorts(),
+		Interval:         interval.String(),
+		Timeout:          hc.escalatedTimeout(pod).String(),
+		RetryCount:       hc.probeRetries(pod),
+		Deadline:         hc.checkDeadline(pod).String(),
+		FailureThreshold: failureThreshold,
+		SuccessThreshold: successThreshold,
+		ProxyProtocol:    pod.GetProxyProtocol(),
+	}
+	if len(cfg.ProbeChain) == 0 && len(cfg.Ports) == 0 && pod.GetUDPOptions() == nil {
+		cfg.Protocol = ProtocolICMP
+	}
+	for _, port := range cfg.Ports {
+		if remapped, ok := hc.portRemap[port]; ok {
+			if cfg.RemappedPorts == nil {
+				cfg.RemappedPorts = make(map[int32]int32)
+			}
+			cfg.RemappedPorts[port] = remapped
+		}
+	}
+	if callback := pod.GetCallback(); callback != nil && hc.callbacks != nil {
+		cfg.Callback = fmt.Sprintf(":%d%s", callback.Port, callback.Path)
+	}
+	if hc.tlsCertMinTTL > 0 {
+		cfg.CertMinTTL = hc.tlsCertMinTTL.String()
+	}
+
+	if targets := pod.GetHTTPTargets(); len(targets) > 0 {
+		opts := pod.GetHTTPOptions()
+		httpCfg := &EffectiveHTTP{
+			Method:      opts.Method,
+			ExpectCodes: opts.ExpectCodes,
+			TokenFile:   opts.TokenFile,
+		}
+		if httpCfg.Method == "" {
+			httpCfg.Method = http.MethodGet
+		}
+		headerNames := make(map[string]bool)
+		for name := range opts.Headers {
+			headerNames[name] = true
+		}
+		for _, target := range targets {
+			scheme := target.Scheme
+			if scheme == "" {
+				scheme = "http"
+			}
+			httpCfg.URLs = append(httpCfg.URLs, fmt.Sprintf("%s://:%d%s", scheme, target.Port, target.Path))
+			for name := range target.Headers {
+				headerNames[name] = true
+			}
+		}
+		for name := range headerNames {
+			httpCfg.HeaderNames = append(httpCfg.HeaderNames, name)
+		}
+		slices.Sort(httpCfg.HeaderNames)
+		for _, assertion := range opts.ExpectHeaders {
+			httpCfg.ExpectHeaders = append(httpCfg.ExpectHeaders, assertion.Name)
+		}
+		if v := opts.ExpectVersion; v != nil {
+			httpCfg.ExpectVersion = fmt.Sprintf("%s %s %s", v.Field, v.Match, v.Expected)
+		}
+		cfg.HTTP = httpCfg
+	}
+	for _, target := range pod.GetGRPCTargets() {
+		cfg.GRPC = append(cfg.GRPC, EffectiveGRPC{Port: target.Port, Service: target.Service, Method: target.Method})
+	}
+	if opts := pod.GetTLSOptions(); opts != nil {
+		cfg.TLS = &EffectiveTLS{ServerName: opts.ServerName, Verify: opts.Verify}
+	}
+	if opts := pod.GetUDPOptions(); opts != nil {
+		cfg.UDP = &EffectiveUDP{PayloadBytes: len(opts.Payload), ExpectPrefixBytes: len(opts.ExpectPrefix)}
+	}
+	return cfg
+}
+
+// CheckHistoryEntry is one of a pod's latest checks as served by the history API
+type CheckHistoryEntry struct {
+	At      time.Time `json:"at"`
+	Healthy bool      `json:"healthy"`
+	Latency string    `json:"latency"`
+	Result  string    `json:"result"`
+}
+
+// checkHistory returns a pod's kept checks, oldest first
+func checkHistory(pod *PodInfo) []CheckHistoryEntry {
+	entries := []CheckHistoryEntry{}
+	for _, record := range pod.History.Records() {
+		entries = append(entries, CheckHistoryEntry{
+			At:      record.At,
+			Healthy: record.Healthy,
+			Latency: record.Latency.String(),
+			Result:  record.Result,
+		})
+	}
+	return entries
+}
+
+// PodConfigHandler serves GET /api/v1/pods/{namespace}/{name}/config with the effective
+// configuration of a tracked pod and GET /api/v1/pods/{namespace}/{name}/history with its
+// latest checks. Only the instance running checks tracks pods, standby replicas answer 404.
+func PodConfigHandler(podSet *PodSet, hc *HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, podConfigPathPrefix), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || (parts[2] != "config" && parts[2] != "history") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		pod := podSet.GetPod(parts[0], parts[1])
+		if pod == nil {
+			http.Error(w, fmt.Sprintf("pod %s/%s is not tracked", parts[0], parts[1]), http.StatusNotFound)
+			return
+		}
+		var body interface{} = hc.EffectiveConfig(pod)
+		if parts[2] == "history" {
+			body = checkHistory(pod)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(body); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
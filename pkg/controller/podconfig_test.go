@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	enabled := "endpoint-health-checker.io/enabled"
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		ports       bool
+		check       func(t *testing.T, cfg EffectiveConfig)
+	}{
+		{
+			name:        "defaults without ports probe icmp",
+			annotations: map[string]string{enabled: "true"},
+			check: func(t *testing.T, cfg EffectiveConfig) {
+				assert.Equal(t, ProtocolICMP, cfg.Protocol)
+				assert.Equal(t, "10s", cfg.Interval)
+				assert.Equal(t, "3s", cfg.Timeout)
+				assert.Equal(t, int32(1), cfg.FailureThreshold)
+				assert.Nil(t, cfg.HTTP)
+			},
+		},
+		{
+			name:        "readiness probe port and thresholds",
+			annotations: map[string]string{enabled: "true"},
+			ports:       true,
+			check: func(t *testing.T, cfg EffectiveConfig) {
+				assert.Equal(t, ProtocolTCP, cfg.Protocol)
+				assert.Equal(t, []int32{8080}, cfg.Ports)
+				assert.Equal(t, int32(3), cfg.FailureThreshold)
+				assert.Equal(t, int32(2), cfg.SuccessThreshold)
+			},
+		},
+		{
+			name: "http overrides keep header values out",
+			annotations: map[string]string{
+				enabled:                     "true",
+				httpURLsAnnotation:          ":9090/ready",
+				httpMethodAnnotation:        "head",
+				httpHeadersAnnotation:       "Authorization=Bearer secret",
+				httpExpectedCodesAnnotation: "200,503",
+			},
+			check: func(t *testing.T, cfg EffectiveConfig) {
+				assert.Equal(t, ProtocolHTTP, cfg.Protocol)
+				assert.Equal(t, []int32{9090}, cfg.Ports)
+				if assert.NotNil(t, cfg.HTTP) {
+					assert.Equal(t, []string{"http://:9090/ready"}, cfg.HTTP.URLs)
+					assert.Equal(t, http.MethodHead, cfg.HTTP.Method)
+					assert.Equal(t, []int{200, 503}, cfg.HTTP.ExpectCodes)
+					assert.Equal(t, []string{"Authorization"}, cfg.HTTP.HeaderNames)
+				}
+				body, err := json.Marshal(cfg)
+				assert.NoError(t, err)
+				assert.NotContains(t, string(body), "secret")
+			},
+		},
+		{
+			name: "tls protocol and proxy protocol",
+			annotations: map[string]string{
+				enabled:                 "true",
+				protocolAnnotation:      "tls",
+				tlsServerNameAnnotation: "web.internal",
+				proxyProtocolAnnotation: "v2",
+			},
+			ports: true,
+			check: func(t *testing.T, cfg EffectiveConfig) {
+				assert.Equal(t, ProtocolTLS, cfg.Protocol)
+				assert.Equal(t, &EffectiveTLS{ServerName: "web.internal", Verify: true}, cfg.TLS)
+				assert.Equal(t, "v2", cfg.ProxyProtocol)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k8sPod := newReadyPod("default", "web-0", "10.0.0.5", tt.annotations)
+			if tt.ports {
+				k8sPod.Spec.Containers = []corev1.Container{{
+					Name: "web",
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler:     corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(8080)}},
+						FailureThreshold: 3,
+						SuccessThreshold: 2,
+					},
+				}}
+			}
+			podSet := NewPodSet()
+			podSet.AddOrUpdate(k8sPod)
+			pod := podSet.GetPod("default", "web-0")
+			if !assert.NotNil(t, pod) {
+				return
+			}
+
+			hc := NewHealthChecker()
+			hc.SetHealthCheckInterval(10 * time.Second)
+			hc.SetHealthCheckTimeout(3 * time.Second)
+			tt.check(t, hc.EffectiveConfig(pod))
+		})
+	}
+}
+
+func TestPodConfigHandler(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "web-0", "10.0.0.5", map[string]string{"endpoint-health-checker.io/enabled": "true"}))
+	hc := NewHealthChecker()
+	handler := PodConfigHandler(podSet, hc)
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := get(http.MethodGet, "/api/v1/pods/default/web-0/config")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var cfg EffectiveConfig
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cfg))
+	assert.Equal(t, "web-0", cfg.Name)
+	assert.Equal(t, "10.0.0.5", cfg.IP)
+
+	// History is served oldest first
+	podSet.ForEach(func(pod *PodInfo) {
+		pod.RecordCheck(CheckRecord{At: time.Now(), Healthy: false, Latency: 3 * time.Second, Result: FailureClassTimeout}, 2)
+		pod.RecordCheck(CheckRecord{At: time.Now(), Healthy: true, Latency: 2 * time.Millisecond, Result: "success"}, 2)
+	})
+	rec = get(http.MethodGet, "/api/v1/pods/default/web-0/history")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var history []CheckHistoryEntry
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &history))
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, FailureClassTimeout, history[0].Result)
+		assert.Equal(t, "3s", history[0].Latency)
+		assert.True(t, history[1].Healthy)
+	}
+
+	assert.Equal(t, http.StatusNotFound, get(http.MethodGet, "/api/v1/pods/default/web-1/config").Code, "untracked pod")
+	assert.Equal(t, http.StatusNotFound, get(http.MethodGet, "/api/v1/pods/default/web-0").Code, "missing config suffix")
+	assert.Equal(t, http.StatusMethodNotAllowed, get(http.MethodPost, "/api/v1/pods/default/web-0/config").Code)
+}
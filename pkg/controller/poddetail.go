@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"container/list"
+	"slices"
+	"time"
+)
+
+// CheckRecord is one check of a pod kept in its history
+type CheckRecord struct {
+	At      time.Time
+	Healthy bool
+	Latency time.Duration
+	Result  string // Success or the failure class
+}
+
+// CheckHistory is a ring buffer of a pod's latest checks
+type CheckHistory struct {
+	records []CheckRecord
+	next    int // Slot overwritten by the next record once the buffer is full
+}
+
+// add appends a record, overwriting the oldest one once size records are kept
+func (h *CheckHistory) add(record CheckRecord, size int) {
+	if len(h.records) < size {
+		h.records = append(h.records, record)
+		return
+	}
+	if len(h.records) > size {
+		// The size shrank, keep the latest records
+		h.records = append([]CheckRecord(nil), h.Records()[len(h.records)-size:]...)
+		h.next = 0
+	}
+	h.records[h.next] = record
+	h.next = (h.next + 1) % size
+}
+
+// clone returns a copy of the history, nil for none
+func (h *CheckHistory) clone() *CheckHistory {
+	if h == nil {
+		return nil
+	}
+	return &CheckHistory{records: slices.Clone(h.records), next: h.next}
+}
+
+// Records returns the kept checks, oldest first
+func (h *CheckHistory) Records() []CheckRecord {
+	if h == nil {
+		return nil
+	}
+	result := make([]CheckRecord, 0, len(h.records))
+	result = append(result, h.records[h.next:]...)
+	return append(result, h.records[:h.next]...)
+}
+
+// detailLRU orders the pods holding detailed state, their check history and transition times,
+// by their last eventful check. Pods that stay healthy keep the position they entered at, so
+// the ones healthy and idle longest are evicted first once more than max pods hold detail.
+type detailLRU struct {
+	max   int
+	order *list.List               // Pod IPs, most recently eventful first
+	items map[string]*list.Element // Pod IP to its element in order
+}
+
+func newDetailLRU(max int) *detailLRU {
+	return &detailLRU{max: max, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// touch records a check of the pod at ip, moving it to the front when the check was eventful
+func (l *detailLRU) touch(ip string, eventful bool) {
+	if elem, exists := l.items[ip]; exists {
+		if eventful {
+			l.order.MoveToFront(elem)
+		}
+		return
+	}
+	l.items[ip] = l.order.PushFront(ip)
+}
+
+// remove forgets the pod at ip
+func (l *detailLRU) remove(ip string) {
+	if elem, exists := l.items[ip]; exists {
+		l.order.Remove(elem)
+		delete(l.items, ip)
+	}
+}
+
+// victim returns the pod to evict while over max: the least recent one that is idle, or the least
+// recent evictable one when none is. ok is false within max or when nothing can be evicted.
+func (l *detailLRU) victim(evictable, idle func(ip string) bool) (ip string, ok bool) {
+	if l.order.Len() <= l.max {
+		return "", false
+	}
+	var fallback *list.Element
+	for elem := l.order.Back(); elem != nil; elem = elem.Prev() {
+		ip := elem.Value.(string)
+		if !evictable(ip) {
+			continue
+		}
+		if idle(ip) {
+			return ip, true
+		}
+		if fallback == nil {
+			fallback = elem
+		}
+	}
+	if fallback == nil {
+		return "", false
+	}
+	return fallback.Value.(string), true
+}
+
+// SetMaxDetailedPods caps the pods holding detailed state, dropping it from the pods healthy and
+// idle longest first. Their status and thresholds are kept, so checks carry on unaffected. 0
+// keeps detail for every pod.
+func (ps *PodSet) SetMaxDetailedPods(max int) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if max > 0 {
+		ps.detail = newDetailLRU(max)
+	} else {
+		ps.detail = nil
+	}
+}
+
+// NoteChecked records a finished check of the pod at podIP for the detail cap, evicting the
+// detail of other pods once over it
+func (ps *PodSet) NoteChecked(podIP string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if ps.detail == nil {
+		return
+	}
+	pod, exists := ps.pods[podIP]
+	if !exists || !pod.hasDetail() {
+		ps.detail.remove(podIP)
+		return
+	}
+	ps.detail.touch(podIP, !pod.isIdle())
+
+	evictable := func(ip string) bool {
+		// Stopped pods are dropped from the order, pods being checked may be writing their detail
+		other, exists := ps.pods[ip]
+		return !exists || !other.IsBeingChecked
+	}
+	idle := func(ip string) bool {
+		other, exists := ps.pods[ip]
+		return !exists || other.isIdle()
+	}
+	for {
+		ip, ok := ps.detail.victim(evictable, idle)
+		if !ok {
+			return
+		}
+		ps.detail.remove(ip)
+		if other, exists := ps.pods[ip]; exists {
+			other.dropDetail()
+		}
+	}
+}
+
+// hasDetail reports whether the pod holds state that can be dropped under the detail cap
+func (p *PodInfo) hasDetail() bool {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.History != nil || len(p.TransitionTimes) > 0
+}
+
+// isIdle reports whether the pod is healthy with nothing in progress
+func (p *PodInfo) isIdle() bool {
+	p.state.Lock()
+	defer p.state.Unlock()
+	return p.LastHealthStatus != nil && *p.LastHealthStatus && p.Failures == 0
+}
+
+// dropDetail releases the pod's detailed state, keeping what checks need to carry on
+func (p *PodInfo) dropDetail() {
+	p.state.Lock()
+	defer p.state.Unlock()
+	p.History = nil
+	p.TransitionTimes = nil
+	p.DetailEvicted = true
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckHistoryRing(t *testing.T) {
+	var history *CheckHistory
+	assert.Empty(t, history.Records())
+
+	history = &CheckHistory{}
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		history.add(CheckRecord{At: base.Add(time.Duration(i) * time.Second)}, 3)
+	}
+	records := history.Records()
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, base.Add(2*time.Second), records[0].At, "oldest first")
+		assert.Equal(t, base.Add(4*time.Second), records[2].At)
+	}
+
+	// A smaller size keeps the latest records
+	history.add(CheckRecord{At: base.Add(5 * time.Second)}, 2)
+	records = history.Records()
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, base.Add(4*time.Second), records[0].At)
+		assert.Equal(t, base.Add(5*time.Second), records[1].At)
+	}
+}
+
+func TestMaxDetailedPodsEvictsIdlePods(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.SetMaxDetailedPods(2)
+	for i := 0; i < 4; i++ {
+		podSet.AddOrUpdate(newReadyPod("default", fmt.Sprintf("web-%d", i), fmt.Sprintf("10.0.0.%d", i+1),
+			map[string]string{"endpoint-health-checker.io/enabled": "true"}))
+	}
+
+	// web-0 keeps failing, the others stay healthy
+	check := func(name string, healthy bool) {
+		podSet.ForEach(func(pod *PodInfo) {
+			if pod.Name != name {
+				return
+			}
+			pod.SetLastHealthStatus(healthy)
+			pod.RecordProbeResult(healthy)
+			pod.RecordCheck(CheckRecord{At: time.Now(), Healthy: healthy}, 4)
+		})
+		podSet.NoteChecked(podSet.GetPod("default", name).IP)
+	}
+	check("web-0", false)
+	check("web-1", true)
+	check("web-2", true)
+	check("web-3", true)
+	check("web-1", true)
+
+	hasHistory := func(name string) bool { return podSet.GetPod("default", name).History != nil }
+	assert.True(t, hasHistory("web-0"), "failing pods keep their detail")
+	assert.False(t, hasHistory("web-1"), "healthy the longest")
+	assert.False(t, hasHistory("web-2"))
+	assert.True(t, hasHistory("web-3"))
+
+	// Core tracking persists
+	total, _ := podSet.GetStats()
+	assert.Equal(t, 4, total)
+	pod := podSet.GetPod("default", "web-1")
+	assert.True(t, *pod.GetLastHealthStatus())
+	assert.Equal(t, int32(2), pod.Successes)
+
+	// A pod that failed again comes back to the front, evicting the oldest idle one
+	check("web-2", false)
+	assert.True(t, hasHistory("web-2"))
+	assert.True(t, hasHistory("web-0"))
+	assert.False(t, hasHistory("web-3"))
+}
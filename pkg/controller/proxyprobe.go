@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// proxyHTTPProbeWithRetry HTTP probe through the API server pod proxy with retry mechanism
+func proxyHTTPProbeWithRetry(ctx context.Context, client rest.Interface, namespace, name string, target HTTPTarget, opts HTTPRequestOptions, config *HealthCheckConfig) error {
+	path := fmt.Sprintf("%s/%s:%d/proxy%s", namespace, name, target.Port, target.Path)
+	return probeWithRetry(ctx, "HTTP proxy", path, config, func(ctx context.Context, _ string, timeout time.Duration) error {
+		return proxyHTTPProbe(ctx, client, namespace, name, target, opts, timeout)
+	})
+}
+
+// proxyHTTPProbe reaches the pod through /api/v1/namespaces/{ns}/pods/{name}:{port}/proxy/{path},
+// which works when the checker cannot route to pod IPs. The Authorization header belongs to
+// the API server, so token files and explicit Authorization headers are not forwarded, and
+// response headers are not available for assertions.
+func proxyHTTPProbe(ctx context.Context, client rest.Interface, namespace, name string, target HTTPTarget, opts HTTPRequestOptions, timeout time.Duration) error {
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req := client.Verb(method).
+		Namespace(namespace).
+		Resource("pods").
+		Name(proxyPodName(name, target)).
+		SubResource("proxy").
+		Suffix(strings.TrimPrefix(target.Path, "/")).
+		Timeout(timeout)
+	for header, values := range opts.Headers {
+		if http.CanonicalHeaderKey(header) == "Authorization" {
+			continue
+		}
+		req = req.SetHeader(header, values...)
+	}
+	if opts.Body != "" {
+		req = req.Body([]byte(opts.Body))
+	}
+	if opts.TokenFile != "" || len(opts.ExpectHeaders) > 0 || opts.ExpectVersion != nil {
+		klog.V(4).Infof("Pod %s/%s: token file, header and version assertions are not supported through the API server proxy",
+			namespace, name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var statusCode int
+	result := req.Do(ctx).StatusCode(&statusCode)
+	if statusCode == 0 {
+		if err := result.Error(); err != nil {
+			return err
+		}
+	}
+	if !opts.statusExpected(statusCode) {
+		return fmt.Errorf("%w: HTTP proxy probe to %s/%s:%d%s returned status %d",
+			errUnexpectedHTTPResponse, namespace, name, target.Port, target.Path, statusCode)
+	}
+	return nil
+}
+
+// proxyPodName builds the pod proxy name, prefixing the scheme for HTTPS targets
+func proxyPodName(name string, target HTTPTarget) string {
+	if target.Scheme == "https" {
+		return fmt.Sprintf("https:%s:%d", name, target.Port)
+	}
+	return fmt.Sprintf("%s:%d", name, target.Port)
+}
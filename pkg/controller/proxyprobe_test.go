@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeProxyClient(handler func(*http.Request) (*http.Response, error)) *restfake.RESTClient {
+	return &restfake.RESTClient{
+		GroupVersion:         schema.GroupVersion{Version: "v1"},
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client:               restfake.CreateHTTPClient(handler),
+	}
+}
+
+func proxyResponse(code int) *http.Response {
+	return &http.Response{StatusCode: code, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok"))}
+}
+
+func TestProxyHTTPProbe(t *testing.T) {
+	var gotPath, gotMethod, gotHeader, gotAuth string
+	client := newFakeProxyClient(func(req *http.Request) (*http.Response, error) {
+		gotPath, gotMethod = req.URL.Path, req.Method
+		gotHeader, gotAuth = req.Header.Get("X-Api-Key"), req.Header.Get("Authorization")
+		return proxyResponse(http.StatusOK), nil
+	})
+
+	opts := HTTPRequestOptions{Headers: http.Header{"X-Api-Key": {"abc"}, "Authorization": {"Bearer pod-token"}}}
+	err := proxyHTTPProbe(context.Background(), client, "default", "web", HTTPTarget{Port: 8080, Path: "/healthz"}, opts, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "/namespaces/default/pods/web:8080/proxy/healthz", gotPath)
+	assert.Equal(t, http.MethodGet, gotMethod)
+	assert.Equal(t, "abc", gotHeader)
+	assert.Empty(t, gotAuth, "the Authorization header belongs to the API server")
+}
+
+func TestProxyPodNameScheme(t *testing.T) {
+	assert.Equal(t, "web:8080", proxyPodName("web", HTTPTarget{Port: 8080}))
+	assert.Equal(t, "https:web:8443", proxyPodName("web", HTTPTarget{Port: 8443, Scheme: "https"}))
+}
+
+func TestProxyHTTPProbeFailureStatus(t *testing.T) {
+	client := newFakeProxyClient(func(*http.Request) (*http.Response, error) {
+		return proxyResponse(http.StatusServiceUnavailable), nil
+	})
+	err := proxyHTTPProbe(context.Background(), client, "default", "web", HTTPTarget{Port: 8080, Path: "/healthz"}, HTTPRequestOptions{}, time.Second)
+	assert.Error(t, err)
+}
+
+func TestCheckPortsUsesProxyClient(t *testing.T) {
+	client := newFakeProxyClient(func(*http.Request) (*http.Response, error) {
+		return proxyResponse(http.StatusOK), nil
+	})
+	hc := newLocalHealthChecker()
+	hc.SetProxyClient(client)
+
+	// The pod IP is unreachable, so only the proxy path can succeed
+	pod := &PodInfo{Namespace: "default", Name: "web", IP: "192.0.2.1", Ports: []int32{8080},
+		HTTPTargets: []HTTPTarget{{Port: 8080, Path: "/healthz"}}}
+	result := hc.performHealthCheck(context.Background(), pod)
+	assert.True(t, result.Healthy, "%v", result.Err)
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// PROXY protocol versions accepted by the proxy-protocol annotation
+const (
+	ProxyProtocolV1 = "v1"
+	ProxyProtocolV2 = "v2"
+)
+
+// proxyProtocolV2Signature starts every PROXY protocol v2 header
+var proxyProtocolV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// proxyProtocolHeader builds the PROXY protocol header announcing a TCP connection from src to dst
+func proxyProtocolHeader(version string, src, dst *net.TCPAddr) ([]byte, error) {
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	ipv4 := srcIP != nil && dstIP != nil
+	if !ipv4 {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+		if srcIP == nil || dstIP == nil {
+			return nil, fmt.Errorf("invalid PROXY protocol addresses %s and %s", src, dst)
+		}
+	}
+
+	switch version {
+	case ProxyProtocolV1:
+		family := "TCP6"
+		if ipv4 {
+			family = "TCP4"
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", family, srcIP, dstIP, src.Port, dst.Port)), nil
+	case ProxyProtocolV2:
+		// Version 2 with the PROXY command, then TCP over IPv4 or IPv6
+		header := append([]byte{}, proxyProtocolV2Signature...)
+		family := byte(0x21)
+		if ipv4 {
+			family = 0x11
+		}
+		header = append(header, 0x21, family)
+		header = binary.BigEndian.AppendUint16(header, uint16(2*len(srcIP)+4))
+		header = append(header, srcIP...)
+		header = append(header, dstIP...)
+		header = binary.BigEndian.AppendUint16(header, uint16(src.Port))
+		header = binary.BigEndian.AppendUint16(header, uint16(dst.Port))
+		return header, nil
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol version %q, expected v1 or v2", version)
+	}
+}
+
+// tcpProxyProtocolProbe connects and sends a PROXY protocol header announcing the probe's own
+// connection, for endpoints behind L4 load balancers that drop connections without one. With
+// halfOpen the header is followed by the half-open check.
+func tcpProxyProtocolProbe(ctx context.Context, addr, version string, halfOpen bool, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	header, err := proxyProtocolHeader(version, conn.LocalAddr().(*net.TCPAddr), conn.RemoteAddr().(*net.TCPAddr))
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(header); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return fmt.Errorf("%w: %w", errTCPNotServed, err)
+	}
+	if halfOpen {
+		return awaitServed(ctx, conn)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyProtocolHeader(t *testing.T) {
+	src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 40000}
+	dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 8080}
+
+	header, err := proxyProtocolHeader(ProxyProtocolV1, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, "PROXY TCP4 10.0.0.1 10.0.0.2 40000 8080\r\n", string(header))
+
+	header, err = proxyProtocolHeader(ProxyProtocolV2, src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, proxyProtocolV2Signature, header[:12])
+	assert.Equal(t, []byte{0x21, 0x11, 0x00, 0x0C, 10, 0, 0, 1, 10, 0, 0, 2, 0x9C, 0x40, 0x1F, 0x90}, header[12:])
+
+	src6 := &net.TCPAddr{IP: net.ParseIP("fd00::1"), Port: 40000}
+	dst6 := &net.TCPAddr{IP: net.ParseIP("fd00::2"), Port: 8080}
+	header, err = proxyProtocolHeader(ProxyProtocolV1, src6, dst6)
+	assert.NoError(t, err)
+	assert.Equal(t, "PROXY TCP6 fd00::1 fd00::2 40000 8080\r\n", string(header))
+
+	header, err = proxyProtocolHeader(ProxyProtocolV2, src6, dst6)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0x21), header[13])
+	assert.Equal(t, uint16(36), binary.BigEndian.Uint16(header[14:16]))
+	assert.Len(t, header, 16+36)
+
+	_, err = proxyProtocolHeader("v3", src, dst)
+	assert.Error(t, err)
+}
+
+// readProxyHeader reads a PROXY protocol header of either version and returns the announced
+// source and destination addresses
+func readProxyHeader(r *bufio.Reader) (string, error) {
+	prefix, err := r.Peek(12)
+	if err != nil {
+		return "", err
+	}
+	if !bytes.Equal(prefix, proxyProtocolV2Signature) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		var family, srcIP, dstIP string
+		var srcPort, dstPort int
+		if _, err := fmt.Sscanf(line, "PROXY %s %s %s %d %d\r\n", &family, &srcIP, &dstIP, &srcPort, &dstPort); err != nil {
+			return "", fmt.Errorf("no PROXY header: %q", line)
+		}
+		return fmt.Sprintf("%s:%d>%s:%d", srcIP, srcPort, dstIP, dstPort), nil
+	}
+
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return "", err
+	}
+	addrs := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(r, addrs); err != nil {
+		return "", err
+	}
+	if fixed[13] != 0x11 || len(addrs) != 12 {
+		return "", fmt.Errorf("unexpected PROXY v2 family %#x", fixed[13])
+	}
+	return fmt.Sprintf("%s:%d>%s:%d", net.IP(addrs[0:4]), binary.BigEndian.Uint16(addrs[8:10]),
+		net.IP(addrs[4:8]), binary.BigEndian.Uint16(addrs[10:12])), nil
+}
+
+func TestTCPProbeSendsProxyProtocolHeader(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// Like an endpoint behind an L4 load balancer: wait for a PROXY header before serving and
+	// drop connections that don't start with one
+	announced := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				addrs, err := readProxyHeader(reader)
+				if err != nil {
+					announced <- ""
+					return
+				}
+				announced <- addrs
+				if _, err := reader.ReadByte(); err == nil {
+					_, _ = conn.Write([]byte("ok"))
+				}
+			}()
+		}
+	}()
+
+	for _, version := range []string{ProxyProtocolV1, ProxyProtocolV2} {
+		t.Run(version, func(t *testing.T) {
+			config := &HealthCheckConfig{ProbeTimeout: time.Second, TCPHalfOpen: true, ProxyProtocol: version}
+			assert.NoError(t, tcpProbeWithRetry(context.Background(), ln.Addr().String(), config))
+			addrs := <-announced
+			assert.Regexp(t, `^127\.0\.0\.1:\d+>`+ln.Addr().String()+`$`, addrs)
+		})
+	}
+
+	// Without a header the endpoint never serves the probe, a false failure
+	err = tcpProbeWithRetry(context.Background(), ln.Addr().String(), &HealthCheckConfig{ProbeTimeout: 100 * time.Millisecond, TCPHalfOpen: true})
+	assert.ErrorIs(t, err, errTCPNotServed)
+	assert.Equal(t, "", <-announced)
+}
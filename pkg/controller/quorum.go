@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// reachabilityAnnotationPrefix is followed by the reporting node's name. The value is
+// "<reachable>,<RFC3339 time>", e.g. "false,2024-05-01T10:00:00Z".
+const reachabilityAnnotationPrefix = "reachability.endpoint-health-checker.io/"
+
+// ReachabilityReport is one node's view of whether a pod is reachable
+type ReachabilityReport struct {
+	Node      string
+	Reachable bool
+	At        time.Time
+}
+
+// reachabilityQuorum makes each DaemonSet instance report what it sees and only marks a
+// pod unhealthy when enough nodes agree it is unreachable
+type reachabilityQuorum struct {
+	node   string        // Name of the node this instance reports for
+	quorum int           // Fresh unreachable reports needed to mark a pod unhealthy
+	maxAge time.Duration // Reports older than this are ignored
+	// lister reads the reports from the informer cache, nil reads them from the API server
+	lister v1.PodLister
+}
+
+// formatReachability renders a report as an annotation value
+func formatReachability(reachable bool, at time.Time) string {
+	return strconv.FormatBool(reachable) + "," + at.UTC().Format(time.RFC3339)
+}
+
+// parseReachabilityReports reads the reports of every node from the pod's annotations,
+// ignoring malformed values
+func parseReachabilityReports(annotations map[string]string) []ReachabilityReport {
+	var reports []ReachabilityReport
+	for key, value := range annotations {
+		node := strings.TrimPrefix(key, reachabilityAnnotationPrefix)
+		if node == key || node == "" {
+			continue
+		}
+		reachableStr, atStr, ok := strings.Cut(value, ",")
+		if !ok {
+			continue
+		}
+		reachable, err := strconv.ParseBool(reachableStr)
+		if err != nil {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			continue
+		}
+		reports = append(reports, ReachabilityReport{Node: node, Reachable: reachable, At: at})
+	}
+	return reports
+}
+
+// evaluateQuorum returns false only when at least quorum nodes reported the pod unreachable
+// within maxAge. Missing or stale reports never count against the pod.
+func evaluateQuorum(reports []ReachabilityReport, quorum int, maxAge time.Duration, now time.Time) bool {
+	unreachable := 0
+	for _, report := range reports {
+		if maxAge > 0 && now.Sub(report.At) > maxAge {
+			continue
+		}
+		if !report.Reachable {
+			unreachable++
+		}
+	}
+	return unreachable < quorum
+}
+
+// report publishes this node's probe result on the pod and returns the quorum decision
+// across all nodes' reports. The result is only written when it changed or this node's report
+// is half way to expiring, and the write drops reports that already expired.
+func (q *reachabilityQuorum) report(ctx context.Context, clientset kubernetes.Interface, pod HealthCheckPodInfo, reachable bool) (bool, error) {
+	now := time.Now()
+	annotations, err := q.annotations(ctx, clientset, pod)
+	if err != nil {
+		return false, err
+	}
+
+	reports := parseReachabilityReports(annotations)
+	if !q.needsPublish(reports, reachable, now) {
+		healthy := evaluateQuorum(reports, q.quorum, q.maxAge, now)
+		klog.V(5).Infof("Pod %s/%s: reachability from %s unchanged, quorum of %d over %d reports says healthy=%v",
+			pod.GetNamespace(), pod.GetName(), q.node, q.quorum, len(reports), healthy)
+		return healthy, nil
+	}
+
+	changes := map[string]interface{}{
+		reachabilityAnnotationPrefix + q.node: formatReachability(reachable, now),
+	}
+	for _, report := range reports {
+		if report.Node != q.node && q.maxAge > 0 && now.Sub(report.At) > q.maxAge {
+			// A null value removes the annotation, e.g. one left by a removed node
+			changes[reachabilityAnnotationPrefix+report.Node] = nil
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": changes},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var updated *corev1.Pod
+	updated, err = clientset.CoreV1().Pods(pod.GetNamespace()).Patch(ctx, pod.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to report reachability of pod %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
+	}
+
+	reports = parseReachabilityReports(updated.Annotations)
+	healthy := evaluateQuorum(reports, q.quorum, q.maxAge, now)
+	klog.V(4).Infof("Pod %s/%s: reachable from %s=%v, quorum of %d over %d reports says healthy=%v",
+		pod.GetNamespace(), pod.GetName(), q.node, reachable, q.quorum, len(reports), healthy)
+	return healthy, nil
+}
+
+// annotations returns the pod's current annotations, from the informer cache when there is one
+func (q *reachabilityQuorum) annotations(ctx context.Context, clientset kubernetes.Interface, pod HealthCheckPodInfo) (map[string]string, error) {
+	if q.lister != nil {
+		cached, err := q.lister.Pods(pod.GetNamespace()).Get(pod.GetName())
+		if err == nil {
+			return cached.Annotations, nil
+		}
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get pod %s/%s from cache: %w", pod.GetNamespace(), pod.GetName(), err)
+		}
+	}
+	current, err := clientset.CoreV1().Pods(pod.GetNamespace()).Get(ctx, pod.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
+	}
+	return current.Annotations, nil
+}
+
+// needsPublish reports whether this node's report must be written: it is missing, says
+// otherwise, or is older than half the max age, so it is refreshed before it expires
+func (q *reachabilityQuorum) needsPublish(reports []ReachabilityReport, reachable bool, now time.Time) bool {
+	for _, report := range reports {
+		if report.Node != q.node {
+			continue
+		}
+		if report.Reachable != reachable {
+			return true
+		}
+		return q.maxAge > 0 && now.Sub(report.At) >= q.maxAge/2
+	}
+	return true
+}
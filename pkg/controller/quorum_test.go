@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEvaluateQuorum(t *testing.T) {
+	now := time.Now()
+	report := func(node string, reachable bool, age time.Duration) ReachabilityReport {
+		return ReachabilityReport{Node: node, Reachable: reachable, At: now.Add(-age)}
+	}
+
+	tests := []struct {
+		name    string
+		reports []ReachabilityReport
+		quorum  int
+		healthy bool
+	}{
+		{"no reports", nil, 2, true},
+		{"all reachable", []ReachabilityReport{report("a", true, 0), report("b", true, 0)}, 2, true},
+		{"one node below quorum", []ReachabilityReport{report("a", false, 0), report("b", true, 0), report("c", true, 0)}, 2, true},
+		{"quorum unreachable", []ReachabilityReport{report("a", false, 0), report("b", false, time.Second), report("c", true, 0)}, 2, false},
+		{"stale reports ignored", []ReachabilityReport{report("a", false, 0), report("b", false, 2*time.Minute)}, 2, true},
+		{"quorum of one", []ReachabilityReport{report("a", false, 0)}, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.healthy, evaluateQuorum(tt.reports, tt.quorum, time.Minute, now))
+		})
+	}
+}
+
+func TestParseReachabilityReports(t *testing.T) {
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	reports := parseReachabilityReports(map[string]string{
+		reachabilityAnnotationPrefix + "node-a": formatReachability(false, at),
+		reachabilityAnnotationPrefix + "node-b": "garbage",
+		reachabilityAnnotationPrefix + "node-c": "maybe," + at.Format(time.RFC3339),
+		"endpoint-health-checker.io/enabled":    "true",
+	})
+	assert.Equal(t, []ReachabilityReport{{Node: "node-a", Reachable: false, At: at}}, reports)
+}
+
+func TestReachabilityReportOnlyWrittenWhenChanged(t *testing.T) {
+	stale := time.Now().Add(-2 * time.Minute)
+	k8sPod := newReadyPod("default", "web-0", "10.0.0.5", map[string]string{
+		reachabilityAnnotationPrefix + "removed-node": formatReachability(false, stale),
+	})
+	clientset := fake.NewSimpleClientset(k8sPod)
+	q := &reachabilityQuorum{node: "node-a", quorum: 1, maxAge: time.Minute}
+	pod := &PodInfo{Namespace: "default", Name: "web-0", IP: "10.0.0.5"}
+	annotations := func() map[string]string {
+		got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+		assert.NoError(t, err)
+		return got.Annotations
+	}
+
+	// The first report is written, dropping the expired one of a removed node
+	healthy, err := q.report(context.Background(), clientset, pod, true)
+	assert.NoError(t, err)
+	assert.True(t, healthy)
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.NotContains(t, annotations(), reachabilityAnnotationPrefix+"removed-node")
+
+	// An unchanged report is not written again
+	for i := 0; i < 3; i++ {
+		healthy, err = q.report(context.Background(), clientset, pod, true)
+		assert.NoError(t, err)
+		assert.True(t, healthy)
+	}
+	assert.Equal(t, 1, countPatches(clientset))
+
+	// A changed one is
+	healthy, err = q.report(context.Background(), clientset, pod, false)
+	assert.NoError(t, err)
+	assert.False(t, healthy)
+	assert.Equal(t, 2, countPatches(clientset))
+
+	// And so is one half way to expiring
+	q.maxAge = time.Second
+	time.Sleep(600 * time.Millisecond)
+	_, err = q.report(context.Background(), clientset, pod, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, countPatches(clientset))
+}
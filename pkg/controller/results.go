@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// resultRecord is the JSON line emitted for each check result
+type resultRecord struct {
+	Timestamp time.Time `json:"ts"`
+	Namespace string    `json:"ns"`
+	Name      string    `json:"name"`
+	IP        string    `json:"ip"`
+	Protocol  string    `json:"protocol"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs float64   `json:"latencyMs"`
+	Err       string    `json:"err,omitempty"`
+}
+
+// ResultWriter emits one JSON line per check result, for consumers that tail the container log
+type ResultWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewResultWriter creates a result writer on top of w
+func NewResultWriter(w io.Writer) *ResultWriter {
+	return &ResultWriter{
+		enc: json.NewEncoder(w),
+		now: time.Now,
+	}
+}
+
+// Write emits the result of a single pod check
+func (rw *ResultWriter) Write(pod HealthCheckPodInfo, result ProbeResult) {
+	record := resultRecord{
+		Timestamp: rw.now().UTC(),
+		Namespace: pod.GetNamespace(),
+		Name:      pod.GetName(),
+		IP:        pod.GetIP(),
+		Protocol:  result.Protocol,
+		Healthy:   result.Healthy,
+		LatencyMs: float64(result.Latency) / float64(time.Millisecond),
+	}
+	if result.Err != nil {
+		record.Err = result.Err.Error()
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if err := rw.enc.Encode(record); err != nil {
+		klog.Warningf("Failed to write check result for pod %s/%s: %v", record.Namespace, record.Name, err)
+	}
+}
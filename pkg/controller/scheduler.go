@@ -2,10 +2,16 @@ package controller
 
 import (
 	"context"
+	"math"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/gammazero/workerpool"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
+	v1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/klog/v2"
 )
 
@@ -15,8 +21,35 @@ type Scheduler struct {
 	podSet     *PodSet
 	config     *HealthChecker
 	workerPool *workerpool.WorkerPool
+	fairQueue  *fairQueue
+	podLister  v1.PodLister
+	// serviceLister groups pods by Service for budgeted dispatch, nil disables coalescing
+	serviceLister v1.ServiceLister
+	// nodeLister skips pods on NotReady nodes, nil probes pods regardless of their node
+	nodeLister v1.NodeLister
+	// sampleCursor is where the next sampled subset starts in the IP-sorted pod list
+	sampleCursor int
+	// adjuster measures scan completion and derives the effective interval, set once the workers
+	// start and read by health endpoints meanwhile
+	adjuster atomic.Pointer[intervalAdjuster]
+	// egress spaces check starts evenly, nil starts them as soon as a worker is free
+	egress *egressShaper
+	// taskCtx outlives the scheduler context so in-flight checks can finish during the shutdown grace
+	taskCtx     context.Context
+	cancelTasks context.CancelFunc
+	// idle is set while the scheduler ticks at the idle interval because no pod is tracked
+	idle atomic.Bool
+	// running is set while the scheduler loop runs, lastTick is when it last finished dispatching
+	// (unix nanoseconds) and tickInterval its current interval, telling a wedged loop from a live one
+	running      atomic.Bool
+	lastTick     atomic.Int64
+	tickInterval atomic.Int64
 }
 
+// stallIntervals is how many tick intervals may pass without a dispatch before the scheduler
+// counts as stalled
+const stallIntervals = 3
+
 // NewScheduler creates a new health check scheduler
 func NewScheduler(clientset kubernetes.Interface, podSet *PodSet) *Scheduler {
 	return &Scheduler{
@@ -31,6 +64,21 @@ func (s *Scheduler) SetConfig(config *HealthChecker) {
 	s.config = config
 }
 
+// SetPodLister sets the lister used to verify a tracked pod still owns its IP before probing
+func (s *Scheduler) SetPodLister(lister v1.PodLister) {
+	s.podLister = lister
+}
+
+// SetServiceLister sets the lister used to group pods by Service when the check budget is exceeded
+func (s *Scheduler) SetServiceLister(lister v1.ServiceLister) {
+	s.serviceLister = lister
+}
+
+// SetNodeLister sets the lister used to skip pods whose node is NotReady
+func (s *Scheduler) SetNodeLister(lister v1.NodeLister) {
+	s.nodeLister = lister
+}
+
 // StartHealthCheckWorkers starts health check workers using WorkerPool
 func (s *Scheduler) StartHealthCheckWorkers(ctx context.Context) {
 	interval := s.config.GetHealthCheckInterval()
@@ -42,6 +90,20 @@ func (s *Scheduler) StartHealthCheckWorkers(ctx context.Context) {
 	s.workerPool = workerpool.New(workerCount)
 	klog.Infof("Scheduler: worker pool created successfully")
 
+	if maxInFlight := s.config.GetNamespaceMaxInFlight(); maxInFlight > 0 {
+		s.fairQueue = newFairQueue(maxInFlight, s.workerPool.Submit)
+		klog.Infof("Scheduler: fair dispatch enabled, at most %d checks in flight per namespace", maxInFlight)
+	}
+
+	if rate := s.config.GetProbeEgressRate(); rate > 0 {
+		s.egress = newEgressShaper(rate)
+		klog.Infof("Scheduler: probe egress shaped to %v checks per second", rate)
+	}
+
+	s.adjuster.Store(newIntervalAdjuster(interval, s.config.GetAutoStretchInterval()))
+	klog.Infof("Scheduler: interval auto-stretch enabled=%v", s.config.GetAutoStretchInterval())
+
+	s.taskCtx, s.cancelTasks = context.WithCancel(context.WithoutCancel(ctx))
 	s.runHealthCheckScheduler(ctx, interval)
 }
 
@@ -49,21 +111,154 @@ func (s *Scheduler) StartHealthCheckWorkers(ctx context.Context) {
 func (s *Scheduler) runHealthCheckScheduler(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	idleAfter, idleInterval := s.config.GetIdleBackoff()
+	var emptySince time.Time
+
+	s.markTick(interval)
+	s.running.Store(true)
 
 	for {
 		select {
 		case <-ctx.Done():
 			klog.Info("Health check scheduler stopped")
-			if s.workerPool != nil {
-				s.workerPool.StopWait()
-			}
+			// Draining in-flight checks is no stall
+			s.running.Store(false)
+			s.shutdown()
 			return
+		case <-s.podSet.Added():
+			emptySince = time.Time{}
+			if !s.idle.Load() {
+				continue
+			}
+			klog.Info("Scheduler: pod added, leaving idle mode")
+			s.idle.Store(false)
+			s.dispatchHealthCheckTasks(ctx)
+			interval = s.dispatchInterval()
+			ticker.Reset(interval)
+			s.markTick(interval)
 		case <-ticker.C:
 			s.dispatchHealthCheckTasks(ctx)
+			effective := s.dispatchInterval()
+			if idleInterval > 0 {
+				if total, _ := s.podSet.GetStats(); total > 0 {
+					emptySince = time.Time{}
+				} else if emptySince.IsZero() {
+					emptySince = time.Now()
+				}
+				if !emptySince.IsZero() && time.Since(emptySince) >= idleAfter {
+					if !s.idle.Swap(true) {
+						klog.Infof("Scheduler: no pods tracked for %v, ticking every %v until one is added", idleAfter, idleInterval)
+					}
+					effective = idleInterval
+				}
+			}
+			if effective != interval {
+				interval = effective
+				ticker.Reset(interval)
+			}
+			s.markTick(interval)
 		}
 	}
 }
 
+// dispatchInterval returns the interval to tick at, the effective interval unless a pod asks to
+// be checked more often. A check interval changed by a configuration reload takes effect here.
+func (s *Scheduler) dispatchInterval() time.Duration {
+	adjuster := s.adjuster.Load()
+	if base := s.config.GetHealthCheckInterval(); adjuster.setBase(base) {
+		klog.Infof("Scheduler: check interval changed to %v", base)
+	}
+	interval := adjuster.interval()
+	if shortest := s.podSet.ShortestInterval(); shortest > 0 && shortest < interval {
+		return shortest
+	}
+	return interval
+}
+
+// markTick records a finished dispatch and the interval until the next one
+func (s *Scheduler) markTick(interval time.Duration) {
+	s.tickInterval.Store(int64(interval))
+	s.lastTick.Store(time.Now().UnixNano())
+}
+
+// Stalled reports whether the scheduler loop runs but has not dispatched for several intervals,
+// as when it is wedged
+func (s *Scheduler) Stalled() bool {
+	if !s.running.Load() {
+		return false
+	}
+	since := time.Since(time.Unix(0, s.lastTick.Load()))
+	return since > stallIntervals*time.Duration(s.tickInterval.Load())
+}
+
+// Dispatching reports whether the scheduler loop runs and keeps dispatching checks
+func (s *Scheduler) Dispatching() bool {
+	return s.running.Load() && !s.Stalled()
+}
+
+// Idle reports whether the scheduler backed off to the idle interval because no pod is tracked
+func (s *Scheduler) Idle() bool {
+	return s.idle.Load()
+}
+
+// EffectiveInterval returns the interval scans are currently dispatched at
+func (s *Scheduler) EffectiveInterval() time.Duration {
+	adjuster := s.adjuster.Load()
+	if adjuster == nil {
+		return s.config.GetHealthCheckInterval()
+	}
+	return adjuster.interval()
+}
+
+// shutdown stops dispatching and drops queued checks, then gives in-flight checks the
+// shutdown grace to finish their status patch before canceling them. Once drained, no pod is
+// left marked as being checked, so a later run, e.g. after leadership is reacquired, probes
+// them all.
+func (s *Scheduler) shutdown() {
+	defer s.releaseBeingChecked()
+	defer s.cancelTasks()
+	if s.fairQueue != nil {
+		s.fairQueue.Stop()
+	}
+	if s.workerPool == nil {
+		return
+	}
+
+	grace := s.config.GetShutdownGrace()
+	if grace <= 0 {
+		s.cancelTasks()
+	}
+	done := make(chan struct{})
+	go func() {
+		// Queued checks see the canceled scheduler context and are skipped
+		s.workerPool.StopWait()
+		close(done)
+	}()
+	if grace <= 0 {
+		<-done
+		return
+	}
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		klog.Info("Scheduler: in-flight health checks finished")
+	case <-timer.C:
+		klog.Warningf("Scheduler: shutdown grace %v elapsed, canceling in-flight health checks", grace)
+		s.cancelTasks()
+		<-done
+	}
+}
+
+// releaseBeingChecked resets the pods still marked as being checked, whose checks were dropped
+// from the fair queue before reaching a worker
+func (s *Scheduler) releaseBeingChecked() {
+	if reset := s.podSet.ResetAllBeingChecked(); reset > 0 {
+		klog.Infof("Scheduler: released %d pods whose checks were dropped on shutdown", reset)
+	}
+}
+
 // dispatchHealthCheckTasks dispatches health check tasks to worker pool
 func (s *Scheduler) dispatchHealthCheckTasks(ctx context.Context) {
 	klog.V(4).Infof("Scheduler: starting health check task dispatch")
@@ -75,6 +270,23 @@ func (s *Scheduler) dispatchHealthCheckTasks(ctx context.Context) {
 		return
 	}
 
+	availablePods = s.pruneStalePods(availablePods)
+	availablePods = s.skipNotReadyNodes(availablePods)
+	availablePods = s.eligiblePods(availablePods, time.Now())
+	availablePods = s.samplePods(availablePods)
+	if budget := s.config.GetServiceCheckBudget(); budget > 0 && s.serviceLister != nil {
+		availablePods = coalesceByService(availablePods, listServices(s.serviceLister), budget)
+	}
+	ips := make([]string, 0, len(availablePods))
+	for _, pod := range availablePods {
+		ips = append(ips, pod.IP)
+	}
+	s.podSet.MarkDispatched(ips, time.Now())
+	if len(availablePods) == 0 {
+		klog.V(4).Infof("No pods eligible for health check yet")
+		return
+	}
+
 	klog.V(4).Infof("Scheduler: found %d available pods for health check", len(availablePods))
 
 	// Log statistics
@@ -84,10 +296,22 @@ func (s *Scheduler) dispatchHealthCheckTasks(ctx context.Context) {
 	if s.workerPool != nil {
 		waitingCount := s.workerPool.WaitingQueueSize()
 		klog.V(4).Infof("WorkerPool stats: waiting queue size=%d", waitingCount)
+		if s.fairQueue != nil {
+			klog.V(4).Infof("Fair queue stats: pending=%d", s.fairQueue.Pending())
+		}
 	} else {
 		klog.Warningf("Scheduler: workerPool is nil!")
 	}
 
+	taskParent := s.taskCtx
+	if taskParent == nil {
+		taskParent = ctx
+	}
+	var round *scanRound
+	if adjuster := s.adjuster.Load(); adjuster != nil {
+		round = adjuster.startRound(len(availablePods))
+	}
+
 	// Convert pods to tasks and submit to worker pool
 	for _, pod := range availablePods {
 		// Mark pod as being checked
@@ -96,14 +320,27 @@ func (s *Scheduler) dispatchHealthCheckTasks(ctx context.Context) {
 		// Create task function for this pod
 		podCopy := pod // Capture pod in closure
 		task := func() {
-			// Create task-specific context with timeout
-			taskCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			if round != nil {
+				defer round.done()
+			}
+			// Completed or skipped, the pod is available again. Reset through the PodSet, under
+			// its lock, since the entry for the IP may have been replaced meanwhile.
+			defer s.podSet.FinishCheck(podCopy)
+
+			// Wait for this check's slot in the shaped egress stream
+			if err := s.egress.wait(taskParent); err != nil {
+				klog.V(4).Infof("Skipping health check for pod %s: %v", podCopy.GetName(), err)
+				return
+			}
+
+			// Create task-specific context with timeout, long enough for the pod's escalated
+			// timeout and retries on each of its probes
+			taskCtx, cancel := context.WithTimeout(taskParent, s.config.checkDeadline(podCopy))
 			defer cancel()
 
 			// Check if parent context is already canceled
 			if ctx.Err() != nil {
 				klog.V(4).Infof("Skipping health check for pod %s: scheduler stopped", podCopy.GetName())
-				podCopy.SetIsBeingChecked(false)
 				return
 			}
 
@@ -111,6 +348,7 @@ func (s *Scheduler) dispatchHealthCheckTasks(ctx context.Context) {
 			start := time.Now()
 
 			err := s.config.CheckPod(taskCtx, s.clientset, podCopy)
+			s.podSet.NoteChecked(podCopy.GetIP())
 
 			duration := time.Since(start)
 			if err != nil {
@@ -127,19 +365,155 @@ func (s *Scheduler) dispatchHealthCheckTasks(ctx context.Context) {
 			}
 		}
 
-		// Submit task to worker pool
-		s.workerPool.Submit(task)
+		// Submit task to worker pool, through the fair queue when namespaces are isolated
+		if s.fairQueue != nil {
+			s.fairQueue.Add(podCopy.GetNamespace(), task)
+		} else {
+			s.workerPool.Submit(task)
+		}
 		klog.V(4).Infof("Scheduler: submitted task for pod %s (IP: %s)", pod.GetName(), pod.GetIP())
 	}
 
 	klog.V(4).Infof("Scheduler: dispatched %d health check tasks to worker pool", len(availablePods))
 }
 
+// eligiblePods filters out pods that are tracked but not yet due for probing
+func (s *Scheduler) eligiblePods(pods []*PodInfo, now time.Time) []*PodInfo {
+	startupDelay := s.config.GetStartupDelay()
+
+	result := make([]*PodInfo, 0, len(pods))
+	for _, pod := range pods {
+		if age := now.Sub(pod.GetCreatedAt()); startupDelay > 0 && age < startupDelay {
+			klog.V(4).Infof("Scheduler: pod %s/%s is %v old, waiting for startup delay %v",
+				pod.GetNamespace(), pod.GetName(), age, startupDelay)
+			continue
+		}
+		if startAt := pod.GetProbeStartAt(); now.Before(startAt) {
+			klog.V(4).Infof("Scheduler: pod %s/%s is within its readiness probe initial delay, waiting until %v",
+				pod.GetNamespace(), pod.GetName(), startAt.Format(time.RFC3339))
+			continue
+		}
+		// Each pod is due at its own interval, stretched while it stays healthy, and the others on
+		// every tick unless ticks come faster for pods with a shorter one. Half a tick of slack
+		// keeps ticker jitter from delaying a due check by a whole tick.
+		interval, tick := pod.GetCheckInterval(), time.Duration(s.tickInterval.Load())
+		if interval <= 0 {
+			interval = pod.GetBaseInterval()
+		}
+		if effective := s.EffectiveInterval(); interval <= 0 && tick > 0 && tick < effective {
+			interval = effective
+		}
+		if interval > 0 && now.Sub(pod.LastDispatchedAt) < interval-tick/2 {
+			continue
+		}
+		result = append(result, pod)
+	}
+	return result
+}
+
+// samplePods returns the next rotating subset of pods when sampling is enabled. Pods are
+// ordered by IP so consecutive ticks walk the whole set, covering every pod within
+// 1/rate ticks while bounding the per-tick load.
+func (s *Scheduler) samplePods(pods []*PodInfo) []*PodInfo {
+	rate := s.config.GetSampleRate()
+	if rate <= 0 || rate >= 1 || len(pods) == 0 {
+		return pods
+	}
+
+	sort.Slice(pods, func(i, j int) bool { return pods[i].GetIP() < pods[j].GetIP() })
+	count := int(math.Ceil(float64(len(pods)) * rate))
+	start := s.sampleCursor % len(pods)
+
+	result := make([]*PodInfo, 0, count)
+	for i := 0; i < count; i++ {
+		result = append(result, pods[(start+i)%len(pods)])
+	}
+	s.sampleCursor = (start + count) % len(pods)
+
+	klog.V(4).Infof("Scheduler: sampled %d of %d pods starting at %d", count, len(pods), start)
+	return result
+}
+
+// pruneStalePods drops tracked pods whose IP no longer belongs to them, so a recycled IP
+// is never probed on behalf of the pod that used to own it
+func (s *Scheduler) pruneStalePods(pods []*PodInfo) []*PodInfo {
+	if !s.config.GetVerifyIPOwnership() || s.podLister == nil {
+		return pods
+	}
+
+	result := make([]*PodInfo, 0, len(pods))
+	for _, pod := range pods {
+		current, err := s.podLister.Pods(pod.GetNamespace()).Get(pod.GetName())
+		switch {
+		case errors.IsNotFound(err):
+			klog.Infof("Scheduler: pod %s/%s no longer exists, pruning stale entry for IP %s",
+				pod.GetNamespace(), pod.GetName(), pod.GetIP())
+		case err != nil:
+			// Can't tell, keep probing rather than dropping a live pod
+			result = append(result, pod)
+			continue
+		case current.Status.PodIP != pod.GetIP():
+			klog.Infof("Scheduler: pod %s/%s now has IP %q, pruning stale entry for IP %s",
+				pod.GetNamespace(), pod.GetName(), current.Status.PodIP, pod.GetIP())
+		default:
+			result = append(result, pod)
+			continue
+		}
+		s.podSet.DeleteIfOwnedBy(pod.GetIP(), pod.GetNamespace(), pod.GetName())
+	}
+	return result
+}
+
+// skipNotReadyNodes filters out pods on nodes that are NotReady, where probe failures are
+// expected during drains and outages and say nothing about the pod itself
+func (s *Scheduler) skipNotReadyNodes(pods []*PodInfo) []*PodInfo {
+	if s.nodeLister == nil {
+		return pods
+	}
+
+	notReady := make(map[string]bool)
+	result := make([]*PodInfo, 0, len(pods))
+	for _, pod := range pods {
+		nodeName := pod.NodeName
+		if nodeName == "" {
+			result = append(result, pod)
+			continue
+		}
+		skip, seen := notReady[nodeName]
+		if !seen {
+			node, err := s.nodeLister.Get(nodeName)
+			// Can't tell, keep probing rather than skipping a live pod
+			skip = err == nil && !isNodeReady(node)
+			notReady[nodeName] = skip
+		}
+		if skip {
+			klog.V(4).Infof("Scheduler: skipping pod %s/%s on NotReady node %s", pod.GetNamespace(), pod.GetName(), nodeName)
+			continue
+		}
+		result = append(result, pod)
+	}
+	return result
+}
+
+// isNodeReady reports whether the node's Ready condition is True
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // Stop stops the scheduler and worker pool
 func (s *Scheduler) Stop() {
+	if s.fairQueue != nil {
+		s.fairQueue.Stop()
+	}
 	if s.workerPool != nil {
 		s.workerPool.StopWait()
 	}
+	s.releaseBeingChecked()
 }
 
 // GetStats returns scheduler statistics
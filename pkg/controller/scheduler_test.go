@@ -0,0 +1,581 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gammazero/workerpool"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+func TestEligiblePodsStartupDelay(t *testing.T) {
+	podSet := NewPodSet()
+	created := time.Now()
+	pod := newReadyPod("default", "fresh-pod", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.CreationTimestamp = metav1.NewTime(created)
+	podSet.AddOrUpdate(pod)
+
+	healthChecker := NewHealthChecker()
+	healthChecker.SetStartupDelay(30 * time.Second)
+	scheduler := NewScheduler(fake.NewSimpleClientset(), podSet)
+	scheduler.SetConfig(healthChecker)
+
+	available := podSet.GetAvailablePods()
+	assert.Len(t, available, 1, "pod should be tracked during the startup delay")
+
+	assert.Empty(t, scheduler.eligiblePods(available, created.Add(10*time.Second)))
+	assert.Len(t, scheduler.eligiblePods(available, created.Add(30*time.Second)), 1)
+}
+
+func TestEligiblePodsReadinessProbeInitialDelay(t *testing.T) {
+	started := time.Now()
+	pod := newReadyPod("default", "slow-start", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{
+		{Name: "app", ReadinessProbe: &corev1.Probe{InitialDelaySeconds: 20}},
+		{Name: "sidecar", ReadinessProbe: &corev1.Probe{InitialDelaySeconds: 5}},
+		{Name: "no-delay", ReadinessProbe: &corev1.Probe{}},
+	}
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{
+		{Name: "app", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(started)}}},
+		{Name: "sidecar", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(started.Add(30 * time.Second))}}},
+		{Name: "no-delay", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.NewTime(started)}}},
+	}
+
+	scheduler := NewScheduler(fake.NewSimpleClientset(), nil)
+	scheduler.SetConfig(NewHealthChecker())
+
+	// Ignored unless enabled
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+	assert.Len(t, scheduler.eligiblePods(podSet.GetAvailablePods(), started), 1)
+
+	// The restarted sidecar's delay ends last
+	podSet = NewPodSet()
+	podSet.SetRespectInitialDelay(true)
+	podSet.AddOrUpdate(pod)
+	available := podSet.GetAvailablePods()
+	assert.Equal(t, started.Add(35*time.Second), available[0].GetProbeStartAt())
+	assert.Empty(t, scheduler.eligiblePods(available, started.Add(25*time.Second)))
+	assert.Len(t, scheduler.eligiblePods(available, started.Add(35*time.Second)), 1)
+}
+
+func TestPruneStalePodsOnIPReuse(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "old-pod", "10.0.0.1", enabled))
+	podSet.AddOrUpdate(newReadyPod("default", "moved-pod", "10.0.0.2", enabled))
+	podSet.AddOrUpdate(newReadyPod("default", "live-pod", "10.0.0.3", enabled))
+
+	// old-pod is gone and its IP went to another pod, moved-pod got a new IP
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(newReadyPod("default", "new-pod", "10.0.0.1", nil)))
+	assert.NoError(t, indexer.Add(newReadyPod("default", "moved-pod", "10.0.0.9", nil)))
+	assert.NoError(t, indexer.Add(newReadyPod("default", "live-pod", "10.0.0.3", nil)))
+
+	healthChecker := NewHealthChecker()
+	healthChecker.SetVerifyIPOwnership(true)
+	scheduler := NewScheduler(fake.NewSimpleClientset(), podSet)
+	scheduler.SetConfig(healthChecker)
+	scheduler.SetPodLister(listersv1.NewPodLister(indexer))
+
+	remaining := scheduler.pruneStalePods(podSet.GetAvailablePods())
+	if assert.Len(t, remaining, 1) {
+		assert.Equal(t, "live-pod", remaining[0].GetName())
+	}
+	count, _ := podSet.GetStats()
+	assert.Equal(t, 1, count, "stale entries should be pruned from the PodSet")
+}
+
+func TestPruneStalePodsDisabled(t *testing.T) {
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(newReadyPod("default", "old-pod", "10.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"}))
+
+	scheduler := NewScheduler(fake.NewSimpleClientset(), podSet)
+	scheduler.SetPodLister(listersv1.NewPodLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})))
+
+	assert.Len(t, scheduler.pruneStalePods(podSet.GetAvailablePods()), 1)
+}
+
+func TestFairQueueSlowNamespaceDoesNotStarveOthers(t *testing.T) {
+	pool := workerpool.New(4)
+	queue := newFairQueue(2, pool.Submit)
+	defer pool.StopWait()
+	defer queue.Stop()
+
+	// A namespace of black-holed pods, each holding a worker for the full timeout
+	for i := 0; i < 20; i++ {
+		queue.Add("slow", func() { time.Sleep(200 * time.Millisecond) })
+	}
+
+	var fastDone sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		fastDone.Add(1)
+		queue.Add("fast", func() {
+			time.Sleep(time.Millisecond)
+			fastDone.Done()
+		})
+	}
+	fastDone.Wait()
+
+	// Without isolation the fast checks would queue behind ~1s of slow ones
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+	assert.Greater(t, queue.Pending(), 0, "slow namespace should still be waiting for its bound")
+}
+
+func TestFairQueueBoundsInFlightPerNamespace(t *testing.T) {
+	pool := workerpool.New(8)
+	queue := newFairQueue(2, pool.Submit)
+
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	for i := 0; i < 10; i++ {
+		queue.Add("ns", func() {
+			mu.Lock()
+			inFlight++
+			if inFlight > peak {
+				peak = inFlight
+			}
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		})
+	}
+
+	assert.Eventually(t, func() bool { return queue.Pending() == 0 }, time.Second, 5*time.Millisecond)
+	pool.StopWait()
+	assert.Equal(t, 2, peak)
+}
+
+func TestSamplePodsRotatesOverWindow(t *testing.T) {
+	var pods []*PodInfo
+	for i := 0; i < 10; i++ {
+		pods = append(pods, &PodInfo{Namespace: "default", Name: fmt.Sprintf("pod-%d", i), IP: fmt.Sprintf("10.0.0.%d", i)})
+	}
+
+	healthChecker := NewHealthChecker()
+	healthChecker.SetSampleRate(0.3)
+	scheduler := NewScheduler(fake.NewSimpleClientset(), NewPodSet())
+	scheduler.SetConfig(healthChecker)
+
+	// ceil(10*0.3) = 3 per tick, so 4 ticks cover all 10 pods
+	seen := make(map[string]int)
+	for tick := 0; tick < 4; tick++ {
+		sampled := scheduler.samplePods(append([]*PodInfo(nil), pods...))
+		assert.Len(t, sampled, 3)
+		for _, pod := range sampled {
+			seen[pod.GetIP()]++
+		}
+	}
+	assert.Len(t, seen, 10, "every pod should be covered within the window")
+
+	healthChecker.SetSampleRate(1)
+	assert.Len(t, scheduler.samplePods(pods), 10)
+}
+
+func TestCoalesceByServiceKeepsOneEndpointPerServiceFresh(t *testing.T) {
+	var services []*corev1.Service
+	var pods []*PodInfo
+	healthy := true
+	for _, app := range []string{"api", "web", "cache"} {
+		services = append(services, &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: app},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": app}},
+		})
+		for i := 0; i < 4; i++ {
+			pods = append(pods, &PodInfo{
+				Namespace: "default", Name: fmt.Sprintf("%s-%d", app, i), IP: fmt.Sprintf("10.0.%d.%d", len(services), i),
+				Labels: map[string]string{"app": app}, LastHealthStatus: &healthy,
+			})
+		}
+	}
+
+	// One unhealthy pod must not crowd out the per-service guarantee
+	unhealthy := false
+	pods[5].LastHealthStatus = &unhealthy
+
+	now := time.Now()
+	for tick := 0; tick < 5; tick++ {
+		selected := coalesceByService(append([]*PodInfo(nil), pods...), services, 4)
+		assert.Len(t, selected, 4)
+
+		covered := make(map[string]bool)
+		for _, pod := range selected {
+			covered[pod.Labels["app"]] = true
+		}
+		assert.Len(t, covered, 3, "tick %d: every service should have an endpoint checked", tick)
+		assert.Contains(t, selected, pods[5], "tick %d: the unhealthy pod should be rechecked", tick)
+
+		now = now.Add(time.Second)
+		for _, pod := range selected {
+			pod.LastDispatchedAt = now
+		}
+	}
+
+	// Healthy siblings rotate instead of the same endpoint being checked every tick
+	for _, pod := range pods {
+		if pod.Labels["app"] != "web" {
+			assert.False(t, pod.LastDispatchedAt.IsZero(), "%s never checked", pod.Name)
+		}
+	}
+}
+
+func TestCoalesceByServiceWithinBudget(t *testing.T) {
+	pods := []*PodInfo{{Namespace: "default", Name: "a", IP: "10.0.0.1"}, {Namespace: "default", Name: "b", IP: "10.0.0.2"}}
+	assert.Len(t, coalesceByService(pods, nil, 5), 2)
+}
+
+func TestEligiblePodsHonorsCheckInterval(t *testing.T) {
+	now := time.Now()
+	scheduler := NewScheduler(fake.NewSimpleClientset(), NewPodSet())
+	stretched := &PodInfo{Name: "stretched", CheckInterval: 8 * time.Second, LastDispatchedAt: now.Add(-5 * time.Second)}
+	due := &PodInfo{Name: "due", CheckInterval: 4 * time.Second, LastDispatchedAt: now.Add(-5 * time.Second)}
+	base := &PodInfo{Name: "base", LastDispatchedAt: now}
+
+	assert.Equal(t, []*PodInfo{due, base}, scheduler.eligiblePods([]*PodInfo{stretched, due, base}, now))
+}
+
+func TestPerPodIntervalAnnotation(t *testing.T) {
+	podSet := NewPodSet()
+	for _, p := range []struct{ name, ip, interval string }{
+		{"fast", "10.0.0.1", "200ms"},
+		{"invalid", "10.0.0.2", "soon"},
+		{"global", "10.0.0.3", ""},
+	} {
+		annotations := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+		if p.interval != "" {
+			annotations["endpoint-health-checker.io/interval"] = p.interval
+		}
+		podSet.AddOrUpdate(newReadyPod("default", p.name, p.ip, annotations))
+	}
+	fast, invalid, global := podSet.GetPod("default", "fast"), podSet.GetPod("default", "invalid"), podSet.GetPod("default", "global")
+	assert.Equal(t, 200*time.Millisecond, fast.BaseInterval)
+	assert.Zero(t, invalid.BaseInterval, "invalid intervals fall back to the global one")
+	assert.Zero(t, global.BaseInterval)
+	assert.Equal(t, 200*time.Millisecond, podSet.ShortestInterval())
+
+	// Ticking for the fast pod, the others stay at the global interval
+	scheduler := NewScheduler(fake.NewSimpleClientset(), podSet)
+	scheduler.markTick(200 * time.Millisecond)
+	now := time.Now()
+	fast.LastDispatchedAt = now.Add(-190 * time.Millisecond)
+	global.LastDispatchedAt = now.Add(-190 * time.Millisecond)
+	invalid.LastDispatchedAt = now.Add(-950 * time.Millisecond)
+	assert.Equal(t, []*PodInfo{fast, invalid}, scheduler.eligiblePods([]*PodInfo{fast, global, invalid}, now))
+}
+
+func TestShutdownGraceFinishesInFlightPatch(t *testing.T) {
+	deadPort := closedPort(t)
+	pod := newReadyPod("default", "web", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: deadPort}}}}
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+
+	// Hold the status update until the scheduler has been told to stop
+	entered, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	clientset := fake.NewSimpleClientset(pod)
+	clientset.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() { close(entered) })
+		<-release
+		return false, nil, nil
+	})
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(20 * time.Millisecond)
+	hc.SetShutdownGrace(5 * time.Second)
+	scheduler := NewScheduler(clientset, podSet)
+	scheduler.SetConfig(hc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.StartHealthCheckWorkers(ctx)
+		close(stopped)
+	}()
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check never reached the status update")
+	}
+	cancel()
+
+	select {
+	case <-stopped:
+		t.Fatal("scheduler stopped before the in-flight patch finished")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler did not stop after the in-flight check finished")
+	}
+	assert.Equal(t, 1, countPatches(clientset), "transition determined before shutdown should be patched")
+}
+
+func TestBeingCheckedSurvivesPodUpdate(t *testing.T) {
+	deadPort := closedPort(t)
+	pod := newReadyPod("default", "web", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: deadPort}}}}
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+	podSet := NewPodSet()
+	podSet.AddOrUpdate(pod)
+
+	// Hold the check in its status update
+	entered, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	clientset := fake.NewSimpleClientset(pod)
+	clientset.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() { close(entered) })
+		<-release
+		return false, nil, nil
+	})
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(20 * time.Millisecond)
+	scheduler := NewScheduler(clientset, podSet)
+	scheduler.SetConfig(hc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.StartHealthCheckWorkers(ctx)
+
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("health check never reached the status update")
+	}
+
+	// An update replaces the entry mid-check, it must not become available for a second check
+	podSet.AddOrUpdate(pod)
+	assert.Empty(t, podSet.GetAvailablePods())
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return len(podSet.GetAvailablePods()) == 1
+	}, 5*time.Second, 5*time.Millisecond, "the finished check frees the current entry")
+}
+
+func TestAddedPodWakesIdleScheduler(t *testing.T) {
+	deadPort := closedPort(t)
+	pod := newReadyPod("default", "web", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	pod.Spec.Containers = []corev1.Container{{Name: "app", Ports: []corev1.ContainerPort{{ContainerPort: deadPort}}}}
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+
+	checked := make(chan struct{})
+	var once sync.Once
+	clientset := fake.NewSimpleClientset(pod)
+	clientset.PrependReactor("get", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() { close(checked) })
+		return false, nil, nil
+	})
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(20 * time.Millisecond)
+	hc.SetIdleBackoff(0, time.Hour)
+	podSet := NewPodSet()
+	scheduler := NewScheduler(clientset, podSet)
+	scheduler.SetConfig(hc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduler.StartHealthCheckWorkers(ctx)
+
+	assert.Eventually(t, scheduler.Idle, 5*time.Second, 10*time.Millisecond, "an empty pod set backs off to the idle interval")
+
+	// Without the wake-up the next tick would only come after an hour
+	podSet.AddOrUpdate(pod)
+	select {
+	case <-checked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("adding a pod did not wake the idle scheduler")
+	}
+	assert.False(t, scheduler.Idle())
+	assert.Equal(t, 20*time.Millisecond, scheduler.EffectiveInterval())
+}
+
+func TestSkipNotReadyNodes(t *testing.T) {
+	node := func(name string, status corev1.ConditionStatus) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+		}
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(node("node-ready", corev1.ConditionTrue)))
+	assert.NoError(t, indexer.Add(node("node-down", corev1.ConditionFalse)))
+
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+	for i, nodeName := range []string{"node-ready", "node-down", "node-unknown"} {
+		pod := newReadyPod("default", "pod-"+nodeName, fmt.Sprintf("10.0.0.%d", i+1), enabled)
+		pod.Spec.NodeName = nodeName
+		podSet.AddOrUpdate(pod)
+	}
+
+	scheduler := NewScheduler(fake.NewSimpleClientset(), podSet)
+	assert.Len(t, scheduler.skipNotReadyNodes(podSet.GetAvailablePods()), 3, "no node lister should probe every pod")
+
+	scheduler.SetNodeLister(listersv1.NewNodeLister(indexer))
+	var names []string
+	for _, pod := range scheduler.skipNotReadyNodes(podSet.GetAvailablePods()) {
+		names = append(names, pod.GetName())
+	}
+	assert.ElementsMatch(t, []string{"pod-node-ready", "pod-node-unknown"}, names,
+		"pods on NotReady nodes are skipped, nodes missing from the cache are not")
+}
+
+func TestIntervalAdjusterStretchesAfterOverruns(t *testing.T) {
+	adjuster := newIntervalAdjuster(time.Second, true)
+	for i := 0; i < scanOverrunsBeforeStretch-1; i++ {
+		adjuster.observeScan(2 * time.Second)
+	}
+	assert.Equal(t, time.Second, adjuster.interval(), "a few slow scans are tolerated")
+	adjuster.observeScan(2 * time.Second)
+	assert.Equal(t, 2200*time.Millisecond, adjuster.interval())
+	assert.Equal(t, 2.2, testutil.ToFloat64(metrics.EffectiveIntervalGauge()))
+
+	// Faster scans shrink it back, never below the configured interval
+	adjuster.observeScan(1500 * time.Millisecond)
+	assert.Equal(t, 1650*time.Millisecond, adjuster.interval())
+	adjuster.observeScan(100 * time.Millisecond)
+	assert.Equal(t, time.Second, adjuster.interval())
+
+	fixed := newIntervalAdjuster(time.Second, false)
+	for i := 0; i < 2*scanOverrunsBeforeStretch; i++ {
+		fixed.observeScan(2 * time.Second)
+	}
+	assert.Equal(t, time.Second, fixed.interval(), "without auto-stretch the overrun is only reported")
+}
+
+func TestShutdownReleasesUndispatchedPods(t *testing.T) {
+	// Connections to this listener are never accepted, so half-open probes run to their timeout
+	stuck, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Skipf("cannot listen on all addresses: %v", err)
+	}
+	defer stuck.Close()
+	port := int32(stuck.Addr().(*net.TCPAddr).Port)
+
+	podSet := NewPodSet()
+	var k8sPods []runtime.Object
+	for i := 1; i <= 4; i++ {
+		pod := newReadyPod("default", fmt.Sprintf("slow-%d", i), fmt.Sprintf("127.0.0.%d", i),
+			map[string]string{"endpoint-health-checker.io/enabled": "true"})
+		podSet.AddOrUpdate(pod)
+		k8sPods = append(k8sPods, pod)
+	}
+	podSet.ForEach(func(pod *PodInfo) { pod.Ports = []int32{port} })
+
+	// One check in flight at a time, the others wait in the fair queue
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(20 * time.Millisecond)
+	hc.SetHealthCheckTimeout(time.Second)
+	hc.SetTCPHalfOpenCheck(true)
+	hc.SetNamespaceMaxInFlight(1)
+	scheduler := NewScheduler(fake.NewSimpleClientset(k8sPods...), podSet)
+	scheduler.SetConfig(hc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.StartHealthCheckWorkers(ctx)
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool { return len(podSet.GetAvailablePods()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// Canceled mid-dispatch, the queued checks are dropped
+	cancel()
+	<-stopped
+	assert.Len(t, podSet.GetAvailablePods(), 4, "every pod is available to the next run")
+}
+
+func TestSchedulerPicksUpReloadedInterval(t *testing.T) {
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(50 * time.Millisecond)
+	scheduler := NewScheduler(fake.NewSimpleClientset(), NewPodSet())
+	scheduler.SetConfig(hc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.StartHealthCheckWorkers(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	assert.Eventually(t, scheduler.Dispatching, time.Second, 10*time.Millisecond)
+	hc.SetHealthCheckInterval(20 * time.Millisecond)
+	assert.Eventually(t, func() bool {
+		return scheduler.EffectiveInterval() == 20*time.Millisecond &&
+			time.Duration(scheduler.tickInterval.Load()) == 20*time.Millisecond
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSlowPodsStretchSchedulerInterval(t *testing.T) {
+	// Connections to this listener are never accepted, so half-open probes run to their timeout
+	stuck, err := net.Listen("tcp", "0.0.0.0:0")
+	if err != nil {
+		t.Skipf("cannot listen on all addresses: %v", err)
+	}
+	defer stuck.Close()
+	port := int32(stuck.Addr().(*net.TCPAddr).Port)
+
+	podSet := NewPodSet()
+	var k8sPods []runtime.Object
+	for i := 1; i <= 4; i++ {
+		pod := newReadyPod("default", fmt.Sprintf("slow-%d", i), fmt.Sprintf("127.0.0.%d", i),
+			map[string]string{"endpoint-health-checker.io/enabled": "true"})
+		podSet.AddOrUpdate(pod)
+		k8sPods = append(k8sPods, pod)
+	}
+	podSet.ForEach(func(pod *PodInfo) { pod.Ports = []int32{port} })
+
+	hc := newLocalHealthChecker()
+	hc.SetHealthCheckInterval(20 * time.Millisecond)
+	hc.SetHealthCheckTimeout(30 * time.Millisecond)
+	hc.SetWorkerCount(1)
+	hc.SetTCPHalfOpenCheck(true)
+	hc.SetAutoStretchInterval(true)
+	scheduler := NewScheduler(fake.NewSimpleClientset(k8sPods...), podSet)
+	scheduler.SetConfig(hc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		scheduler.StartHealthCheckWorkers(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	// Four 30ms checks on one worker can't fit in 20ms
+	assert.Eventually(t, func() bool {
+		return scheduler.EffectiveInterval() >= 100*time.Millisecond
+	}, 5*time.Second, 20*time.Millisecond)
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// SelfHealthHandler serves the checker's own /healthz and /readyz. An instance running checks,
+// as told by checking, is live while its scheduler keeps dispatching and ready once its informer
+// caches synced and the scheduler dispatches. Standby replicas hold no caches and are always
+// ready, so they don't block rollouts.
+func SelfHealthHandler(ctrl *Controller, scheduler *Scheduler, checking func() bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		if checking() && scheduler.Stalled() {
+			http.Error(w, "scheduler stopped dispatching checks", http.StatusServiceUnavailable)
+			return
+		}
+		writeOK(w, r)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if checking() {
+			if !ctrl.HasSynced() {
+				http.Error(w, "informer caches not synced", http.StatusServiceUnavailable)
+				return
+			}
+			if !scheduler.Dispatching() {
+				http.Error(w, "scheduler not dispatching checks", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		writeOK(w, r)
+	})
+	return mux
+}
+
+// writeOK answers a passing self-health probe, a probe that went away before reading it is logged
+func writeOK(w http.ResponseWriter, r *http.Request) {
+	if _, err := w.Write([]byte("ok")); err != nil {
+		klog.Warningf("Failed to answer %s from %s: %v", r.URL.Path, r.RemoteAddr, err)
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfHealthHandler(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	podSet := NewPodSet()
+	ctrl := NewController(clientset, time.Minute, podSet, "")
+	scheduler := NewScheduler(clientset, podSet)
+
+	checking := false
+	handler := SelfHealthHandler(ctrl, scheduler, func() bool { return checking })
+	status := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// A standby replica is live and ready
+	assert.Equal(t, http.StatusOK, status("/healthz"))
+	assert.Equal(t, http.StatusOK, status("/readyz"))
+
+	// The leader is not ready before its caches synced and the scheduler runs
+	checking = true
+	assert.Equal(t, http.StatusOK, status("/healthz"))
+	assert.Equal(t, http.StatusServiceUnavailable, status("/readyz"))
+
+	// A scheduler that stopped ticking fails liveness
+	scheduler.running.Store(true)
+	scheduler.markTick(time.Second)
+	scheduler.lastTick.Store(time.Now().Add(-time.Minute).UnixNano())
+	assert.True(t, scheduler.Stalled())
+	assert.Equal(t, http.StatusServiceUnavailable, status("/healthz"))
+
+	scheduler.markTick(time.Second)
+	assert.Equal(t, http.StatusOK, status("/healthz"))
+	assert.True(t, scheduler.Dispatching())
+}
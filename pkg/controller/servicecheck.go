@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+const (
+	// serviceCheckAnnotation set to "true" on a Service probes its ClusterIP and verifies routing
+	serviceCheckAnnotation = "endpoint-health-checker.io/service-check"
+	// serviceCheckPortAnnotation selects the Service port by name or number, the first port by default
+	serviceCheckPortAnnotation = "endpoint-health-checker.io/service-check-port"
+	// serviceCheckPathAnnotation is the HTTP path requested through the ClusterIP, "/" by default
+	serviceCheckPathAnnotation = "endpoint-health-checker.io/service-check-path"
+	// serviceCheckBackendHeaderAnnotation names the response header carrying the serving pod's name or IP
+	serviceCheckBackendHeaderAnnotation = "endpoint-health-checker.io/service-check-backend-header"
+)
+
+// Service check outcomes, used as the result label of ehc_service_checks_total
+const (
+	ServiceCheckOK                = "ok"
+	ServiceCheckUnreachable       = "unreachable"
+	ServiceCheckUnhealthyBackend  = "unhealthy_backend"
+	ServiceCheckAffinityViolation = "affinity_violation"
+)
+
+// ServiceChecker periodically probes opted-in Services through their ClusterIP, verifying
+// that traffic reaches healthy backends and is spread according to the session affinity
+type ServiceChecker struct {
+	lister v1.ServiceLister
+	podSet *PodSet
+	probes int
+	// timeout returns the per-request timeout, read on every check so reloads apply
+	timeout func() time.Duration
+}
+
+// NewServiceChecker creates a checker sending probes requests per Service and check
+func NewServiceChecker(lister v1.ServiceLister, podSet *PodSet, probes int, timeout func() time.Duration) *ServiceChecker {
+	return &ServiceChecker{lister: lister, podSet: podSet, probes: probes, timeout: timeout}
+}
+
+// Run checks all opted-in Services every interval until ctx is done
+func (c *ServiceChecker) Run(ctx context.Context, interval time.Duration) {
+	klog.Infof("Service checker: probing opted-in Services every %v with %d requests each", interval, c.probes)
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		for _, svc := range listServices(c.lister) {
+			if svc.Annotations[serviceCheckAnnotation] != "true" {
+				continue
+			}
+			result, err := c.checkService(svc)
+			if err != nil {
+				klog.Warningf("Service %s/%s: %s: %v", svc.Namespace, svc.Name, result, err)
+			} else {
+				klog.V(4).Infof("Service %s/%s: routing verified", svc.Namespace, svc.Name)
+			}
+			metrics.RecordServiceCheck(svc.Namespace, svc.Name, result)
+		}
+	}, interval)
+}
+
+// checkService probes the Service's ClusterIP and returns the outcome with its cause
+func (c *ServiceChecker) checkService(svc *corev1.Service) (string, error) {
+	addr, err := resolveServiceTarget(svc, svc.Annotations[serviceCheckPortAnnotation])
+	if err != nil {
+		return ServiceCheckUnreachable, err
+	}
+	path := svc.Annotations[serviceCheckPathAnnotation]
+	if path == "" {
+		path = "/"
+	}
+	url := fmt.Sprintf("http://%s%s", addr, path)
+	header := svc.Annotations[serviceCheckBackendHeaderAnnotation]
+
+	var backends []string
+	timeout := c.timeout()
+	for i := 0; i < c.probes; i++ {
+		backend, err := serviceProbe(url, header, timeout)
+		if err != nil {
+			return ServiceCheckUnreachable, err
+		}
+		if header == "" {
+			continue
+		}
+		if !c.isHealthyBackend(svc.Namespace, backend) {
+			return ServiceCheckUnhealthyBackend, fmt.Errorf("routed to backend %q, which is not a healthy endpoint", backend)
+		}
+		backends = append(backends, backend)
+	}
+	if header == "" {
+		return ServiceCheckOK, nil
+	}
+	if err := verifyAffinity(svc.Spec.SessionAffinity, backends, c.healthyEndpoints(svc)); err != nil {
+		return ServiceCheckAffinityViolation, err
+	}
+	return ServiceCheckOK, nil
+}
+
+// isHealthyBackend reports whether a backend name or IP is a tracked pod not known to be unhealthy
+func (c *ServiceChecker) isHealthyBackend(namespace, backend string) bool {
+	healthy := false
+	c.podSet.ForEach(func(pod *PodInfo) {
+		if pod.Namespace != namespace || (pod.Name != backend && pod.IP != backend) {
+			return
+		}
+		last := pod.GetLastHealthStatus()
+		healthy = last == nil || *last
+	})
+	return healthy
+}
+
+// healthyEndpoints counts tracked pods selected by the Service that are not known to be unhealthy
+func (c *ServiceChecker) healthyEndpoints(svc *corev1.Service) int {
+	count := 0
+	c.podSet.ForEach(func(pod *PodInfo) {
+		if pod.Namespace != svc.Namespace || len(svc.Spec.Selector) == 0 {
+			return
+		}
+		if !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			return
+		}
+		if last := pod.GetLastHealthStatus(); last == nil || *last {
+			count++
+		}
+	})
+	return count
+}
+
+// resolveServiceTarget returns the ClusterIP address of the Service port selected by name or
+// number, the first port when empty
+func resolveServiceTarget(svc *corev1.Service, port string) (string, error) {
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == corev1.ClusterIPNone {
+		return "", fmt.Errorf("service has no ClusterIP")
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return "", fmt.Errorf("service has no ports")
+	}
+	selected := &svc.Spec.Ports[0]
+	if port != "" {
+		selected = nil
+		number, _ := strconv.Atoi(port)
+		for i := range svc.Spec.Ports {
+			if svc.Spec.Ports[i].Name == port || (number > 0 && svc.Spec.Ports[i].Port == int32(number)) {
+				selected = &svc.Spec.Ports[i]
+				break
+			}
+		}
+		if selected == nil {
+			return "", fmt.Errorf("service has no port %q", port)
+		}
+	}
+	if selected.Protocol != "" && selected.Protocol != corev1.ProtocolTCP {
+		return "", fmt.Errorf("service port %d is %s, only TCP can be checked", selected.Port, selected.Protocol)
+	}
+	return net.JoinHostPort(svc.Spec.ClusterIP, strconv.Itoa(int(selected.Port))), nil
+}
+
+// verifyAffinity checks the backends that served consecutive requests against the Service's
+// session affinity: ClientIP must stick to one backend, None must spread across several when
+// more than one healthy endpoint exists
+func verifyAffinity(affinity corev1.ServiceAffinity, backends []string, healthyEndpoints int) error {
+	distinct := make(map[string]struct{})
+	for _, backend := range backends {
+		distinct[backend] = struct{}{}
+	}
+	switch affinity {
+	case corev1.ServiceAffinityClientIP:
+		if len(distinct) > 1 {
+			return fmt.Errorf("ClientIP affinity, but %d requests were served by %d backends", len(backends), len(distinct))
+		}
+	default:
+		if healthyEndpoints > 1 && len(backends) > 1 && len(distinct) < 2 {
+			return fmt.Errorf("no affinity and %d healthy endpoints, but all %d requests were served by one backend",
+				healthyEndpoints, len(backends))
+		}
+	}
+	return nil
+}
+
+// serviceProbe sends one request on a fresh connection, so kube-proxy balances it anew, and
+// returns the backend named in the response header
+func serviceProbe(url, header string, timeout time.Duration) (string, error) {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("%w: service probe to %s returned status %d", errUnexpectedHTTPResponse, url, resp.StatusCode)
+	}
+	if header == "" {
+		return "", nil
+	}
+	backend := resp.Header.Get(header)
+	if backend == "" {
+		return "", fmt.Errorf("%w: service probe to %s: response header %s missing", errUnexpectedHTTPResponse, url, header)
+	}
+	return backend, nil
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResolveServiceTarget(t *testing.T) {
+	svc := &corev1.Service{Spec: corev1.ServiceSpec{
+		ClusterIP: "10.96.0.10",
+		Ports: []corev1.ServicePort{
+			{Name: "http", Port: 80},
+			{Name: "metrics", Port: 9090, Protocol: corev1.ProtocolTCP},
+			{Name: "dns", Port: 53, Protocol: corev1.ProtocolUDP},
+		},
+	}}
+
+	tests := []struct {
+		port     string
+		expected string
+		wantErr  bool
+	}{
+		{port: "", expected: "10.96.0.10:80"},
+		{port: "metrics", expected: "10.96.0.10:9090"},
+		{port: "9090", expected: "10.96.0.10:9090"},
+		{port: "grpc", wantErr: true},
+		{port: "dns", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			addr, err := resolveServiceTarget(svc, tt.port)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, addr)
+		})
+	}
+
+	headless := svc.DeepCopy()
+	headless.Spec.ClusterIP = corev1.ClusterIPNone
+	_, err := resolveServiceTarget(headless, "")
+	assert.Error(t, err)
+}
+
+func TestVerifyAffinity(t *testing.T) {
+	tests := []struct {
+		name     string
+		affinity corev1.ServiceAffinity
+		backends []string
+		healthy  int
+		wantErr  bool
+	}{
+		{name: "client ip sticks", affinity: corev1.ServiceAffinityClientIP, backends: []string{"a", "a", "a"}, healthy: 2},
+		{name: "client ip moves", affinity: corev1.ServiceAffinityClientIP, backends: []string{"a", "b", "a"}, healthy: 2, wantErr: true},
+		{name: "none spreads", affinity: corev1.ServiceAffinityNone, backends: []string{"a", "b", "a"}, healthy: 2},
+		{name: "none stuck", affinity: corev1.ServiceAffinityNone, backends: []string{"a", "a", "a"}, healthy: 2, wantErr: true},
+		{name: "none single endpoint", affinity: corev1.ServiceAffinityNone, backends: []string{"a", "a", "a"}, healthy: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyAffinity(tt.affinity, tt.backends, tt.healthy)
+			assert.Equal(t, tt.wantErr, err != nil, "err: %v", err)
+		})
+	}
+}
+
+// newStubService serves as a Service ClusterIP, naming backends from the given sequence
+func newStubService(t *testing.T, backends ...string) (*corev1.Service, listersv1.ServiceLister) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1) - 1
+		w.Header().Set("X-Backend", backends[int(n)%len(backends)])
+	}))
+	t.Cleanup(server.Close)
+
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "web",
+			Annotations: map[string]string{
+				serviceCheckAnnotation:              "true",
+				serviceCheckBackendHeaderAnnotation: "X-Backend",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: host,
+			Ports:     []corev1.ServicePort{{Name: "http", Port: int32(portNum)}},
+			Selector:  map[string]string{"app": "web"},
+		},
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(svc))
+	return svc, listersv1.NewServiceLister(indexer)
+}
+
+func TestServiceCheckerAffinity(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	podSet := NewPodSet()
+	for i, name := range []string{"web-0", "web-1"} {
+		pod := newReadyPod("default", name, fmt.Sprintf("10.0.0.%d", i+1), enabled)
+		pod.Labels = map[string]string{"app": "web"}
+		podSet.AddOrUpdate(pod)
+	}
+
+	tests := []struct {
+		name     string
+		affinity corev1.ServiceAffinity
+		backends []string
+		expected string
+	}{
+		{name: "none balanced", affinity: corev1.ServiceAffinityNone, backends: []string{"web-0", "web-1"}, expected: ServiceCheckOK},
+		{name: "none stuck", affinity: corev1.ServiceAffinityNone, backends: []string{"web-0"}, expected: ServiceCheckAffinityViolation},
+		{name: "client ip sticky", affinity: corev1.ServiceAffinityClientIP, backends: []string{"10.0.0.2"}, expected: ServiceCheckOK},
+		{name: "client ip moves", affinity: corev1.ServiceAffinityClientIP, backends: []string{"web-0", "web-1"}, expected: ServiceCheckAffinityViolation},
+		{name: "unknown backend", affinity: corev1.ServiceAffinityNone, backends: []string{"web-0", "stale-pod"}, expected: ServiceCheckUnhealthyBackend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, lister := newStubService(t, tt.backends...)
+			svc.Spec.SessionAffinity = tt.affinity
+			checker := NewServiceChecker(lister, podSet, 4, func() time.Duration { return time.Second })
+
+			result, err := checker.checkService(svc)
+			assert.Equal(t, tt.expected, result, "err: %v", err)
+		})
+	}
+
+	// A backend known to be unhealthy must not receive traffic
+	podSet.ForEach(func(pod *PodInfo) {
+		if pod.GetName() == "web-1" {
+			pod.SetLastHealthStatus(false)
+		}
+	})
+	svc, lister := newStubService(t, "web-0", "web-1")
+	result, _ := NewServiceChecker(lister, podSet, 4, func() time.Duration { return time.Second }).checkService(svc)
+	assert.Equal(t, ServiceCheckUnhealthyBackend, result)
+}
+
+func TestServiceCheckerUnreachable(t *testing.T) {
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: "127.0.0.1",
+			Ports:     []corev1.ServicePort{{Port: closedPort(t)}},
+		},
+	}
+	checker := NewServiceChecker(nil, NewPodSet(), 2, func() time.Duration { return 100 * time.Millisecond })
+	result, err := checker.checkService(svc)
+	assert.Equal(t, ServiceCheckUnreachable, result)
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// serviceDebouncer holds back unhealthy transitions while many endpoints of a Service fail
+// together. A shared dependency blip then gets time to resolve instead of flipping the whole
+// Service not ready at once.
+type serviceDebouncer struct {
+	mu        sync.Mutex
+	lister    v1.ServiceLister
+	threshold int           // Endpoints failing within window that make a synchronized failure
+	window    time.Duration // How recent a failure must be to count
+	delay     time.Duration // How long unhealthy transitions are held once detected
+	services  map[string]*serviceFailures
+}
+
+// serviceFailures tracks the failing endpoints of one Service
+type serviceFailures struct {
+	failing map[string]time.Time // Last failed check by pod name
+	since   time.Time            // When the synchronized failure was detected, zero if none
+}
+
+func newServiceDebouncer(lister v1.ServiceLister, threshold int, window, delay time.Duration) *serviceDebouncer {
+	return &serviceDebouncer{
+		lister:    lister,
+		threshold: threshold,
+		window:    window,
+		delay:     delay,
+		services:  make(map[string]*serviceFailures),
+	}
+}
+
+// observe records a probe result for every Service selecting the pod and returns the
+// Service whose synchronized failure holds back the pod's unhealthy transition, if any
+func (d *serviceDebouncer) observe(pod HealthCheckPodInfo, healthy bool, now time.Time) (string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	held := ""
+	for _, svc := range listServices(d.lister) {
+		if svc.Namespace != pod.GetNamespace() || len(svc.Spec.Selector) == 0 ||
+			!labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.GetLabels())) {
+			continue
+		}
+		key := svc.Namespace + "/" + svc.Name
+		failures := d.services[key]
+		if failures == nil {
+			if healthy {
+				continue
+			}
+			failures = &serviceFailures{failing: make(map[string]time.Time)}
+			d.services[key] = failures
+		}
+		if healthy {
+			delete(failures.failing, pod.GetName())
+		} else {
+			failures.failing[pod.GetName()] = now
+		}
+		for name, at := range failures.failing {
+			if now.Sub(at) > d.window {
+				delete(failures.failing, name)
+			}
+		}
+
+		if len(failures.failing) < d.threshold {
+			if !failures.since.IsZero() {
+				klog.Infof("Service %s: synchronized failure over, %d endpoints failing", key, len(failures.failing))
+			}
+			failures.since = time.Time{}
+			if len(failures.failing) == 0 {
+				delete(d.services, key)
+			}
+			continue
+		}
+		if failures.since.IsZero() {
+			failures.since = now
+			klog.Warningf("Service %s: %d endpoints failed within %v, holding unhealthy transitions for %v",
+				key, len(failures.failing), d.window, d.delay)
+		}
+		if !healthy && held == "" && now.Sub(failures.since) < d.delay {
+			held = svc.Name
+		}
+	}
+	return held, held != ""
+}
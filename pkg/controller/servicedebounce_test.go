@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"endpoint_health_checker/pkg/metrics"
+)
+
+// webServiceLister serves a "web" Service selecting app=web pods in the default namespace
+func webServiceLister(t *testing.T) listersv1.ServiceLister {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	assert.NoError(t, indexer.Add(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}))
+	return listersv1.NewServiceLister(indexer)
+}
+
+func TestServiceDebounceHoldsSynchronizedFailure(t *testing.T) {
+	deadPort := closedPort(t)
+	var k8sPods []runtime.Object
+	var pods []*PodInfo
+	for i := 0; i < 3; i++ {
+		name := fmt.Sprintf("web-%d", i)
+		k8sPod := newReadyPod("default", name, fmt.Sprintf("127.0.0.%d", i+1), nil)
+		k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+		k8sPods = append(k8sPods, k8sPod)
+		pods = append(pods, &PodInfo{Namespace: "default", Name: name, IP: "127.0.0.1", Ports: []int32{deadPort},
+			Labels: map[string]string{"app": "web"}})
+	}
+	clientset := fake.NewSimpleClientset(k8sPods...)
+
+	hc := newLocalHealthChecker()
+	hc.SetServiceDebounce(webServiceLister(t), 2, time.Minute, 200*time.Millisecond)
+	deferredBefore := testutil.ToFloat64(metrics.UnhealthyDeferredCounter("default", "web"))
+
+	// A lone failure flips at once, the second failing endpoint reveals a synchronized failure
+	for _, pod := range pods {
+		assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	}
+	assert.Equal(t, 1, countPatches(clientset))
+	assert.False(t, *pods[0].GetLastHealthStatus())
+	assert.Nil(t, pods[1].GetLastHealthStatus())
+	assert.Nil(t, pods[2].GetLastHealthStatus())
+	assert.Equal(t, deferredBefore+2, testutil.ToFloat64(metrics.UnhealthyDeferredCounter("default", "web")))
+
+	// Endpoints still failing once the delay is over flip
+	time.Sleep(250 * time.Millisecond)
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pods[1]))
+	assert.Equal(t, 2, countPatches(clientset))
+	assert.False(t, *pods[1].GetLastHealthStatus())
+}
+
+func TestServiceDebounceWindow(t *testing.T) {
+	debouncer := newServiceDebouncer(webServiceLister(t), 2, 10*time.Second, time.Minute)
+	web := func(name string) *PodInfo {
+		return &PodInfo{Namespace: "default", Name: name, Labels: map[string]string{"app": "web"}}
+	}
+	other := &PodInfo{Namespace: "default", Name: "db-0", Labels: map[string]string{"app": "db"}}
+	now := time.Now()
+
+	_, held := debouncer.observe(web("web-0"), false, now)
+	assert.False(t, held)
+	_, held = debouncer.observe(other, false, now)
+	assert.False(t, held, "pods outside the Service don't count")
+
+	// Failures further apart than the window are not synchronized
+	_, held = debouncer.observe(web("web-1"), false, now.Add(11*time.Second))
+	assert.False(t, held)
+
+	service, held := debouncer.observe(web("web-2"), false, now.Add(12*time.Second))
+	assert.True(t, held)
+	assert.Equal(t, "web", service)
+
+	// Recoveries end the synchronized failure
+	_, held = debouncer.observe(web("web-1"), true, now.Add(13*time.Second))
+	assert.False(t, held)
+	_, held = debouncer.observe(web("web-3"), false, now.Add(30*time.Second))
+	assert.False(t, held)
+}
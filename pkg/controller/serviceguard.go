@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/labels"
+	v1 "k8s.io/client-go/listers/core/v1"
+)
+
+// serviceGuard keeps a minimum number of healthy endpoints per Service, like a
+// PodDisruptionBudget for health: the checker never takes down the last replicas itself
+type serviceGuard struct {
+	// mu serializes reservations so concurrent failures can't both pass the guard
+	mu         sync.Mutex
+	lister     v1.ServiceLister
+	podSet     *PodSet
+	minHealthy int
+	// pending holds the pods whose unhealthy transition passed the guard but isn't written yet,
+	// counted as unhealthy already, key: namespace/name
+	pending map[string]bool
+}
+
+// reserve passes a pod's unhealthy transition through the guard and, unless a Service selecting
+// it would be left with too few healthy endpoints, counts the pod as unhealthy until release,
+// so the status can be written without holding the guard
+func (g *serviceGuard) reserve(pod HealthCheckPodInfo) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if service, blocked := g.blockingService(pod); blocked {
+		return service, false
+	}
+	if g.pending == nil {
+		g.pending = make(map[string]bool)
+	}
+	g.pending[podKey(pod.GetNamespace(), pod.GetName())] = true
+	return "", true
+}
+
+// release ends a reservation, once the status is written or the transition abandoned
+func (g *serviceGuard) release(pod HealthCheckPodInfo) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pending, podKey(pod.GetNamespace(), pod.GetName()))
+}
+
+// blockingService returns the name of a Service selecting the pod that would be left with
+// fewer than minHealthy healthy endpoints if the pod were marked unhealthy. Callers hold mu.
+func (g *serviceGuard) blockingService(pod HealthCheckPodInfo) (string, bool) {
+	for _, svc := range listServices(g.lister) {
+		if svc.Namespace != pod.GetNamespace() || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		if !selector.Matches(labels.Set(pod.GetLabels())) {
+			continue
+		}
+
+		// Tracked pods passed kubelet readiness, so an unknown status counts as healthy
+		healthy := 0
+		g.podSet.ForEach(func(other *PodInfo) {
+			if other.GetNamespace() != svc.Namespace || other.GetName() == pod.GetName() ||
+				!selector.Matches(labels.Set(other.Labels)) || g.pending[podKey(other.Namespace, other.Name)] {
+				return
+			}
+			if last := other.GetLastHealthStatus(); last == nil || *last {
+				healthy++
+			}
+		})
+		if healthy < g.minHealthy {
+			return svc.Name, true
+		}
+	}
+	return "", false
+}
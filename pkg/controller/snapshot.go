@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// statusSnapshot is the on-disk form of the PodSet's known health statuses, written
+// periodically so a restarted checker does not start cold
+type statusSnapshot struct {
+	SavedAt time.Time             `json:"savedAt"`
+	Pods    []statusSnapshotEntry `json:"pods"`
+}
+
+// signedStatusSnapshot wraps a snapshot with its HMAC-SHA256, so a forged snapshot claiming every
+// pod is healthy is not trusted by the next checker
+type signedStatusSnapshot struct {
+	Snapshot  json.RawMessage `json:"snapshot"`
+	Signature string          `json:"signature"`
+}
+
+var (
+	// errSnapshotUnsigned reports a snapshot without signature while a key is configured
+	errSnapshotUnsigned = stderrors.New("status snapshot is not signed")
+	// errSnapshotSignature reports a snapshot whose signature does not match its content
+	errSnapshotSignature = stderrors.New("status snapshot signature mismatch")
+)
+
+type statusSnapshotEntry struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	IP        string `json:"ip"`
+	UID       string `json:"uid,omitempty"`
+	Healthy   bool   `json:"healthy"`
+}
+
+// SaveSnapshot writes the known health statuses to path, replacing the file atomically
+func (ps *PodSet) SaveSnapshot(path string) error {
+	snapshot := statusSnapshot{SavedAt: time.Now()}
+	ps.ForEach(func(pod *PodInfo) {
+		if last := pod.GetLastHealthStatus(); last != nil {
+			snapshot.Pods = append(snapshot.Pods, statusSnapshotEntry{
+				Namespace: pod.Namespace, Name: pod.Name, IP: pod.IP, UID: string(pod.UID), Healthy: *last,
+			})
+		}
+	})
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if len(ps.snapshotKey) > 0 {
+		data, err = json.Marshal(signedStatusSnapshot{Snapshot: data, Signature: signSnapshot(ps.snapshotKey, data)})
+		if err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write status snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write status snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write status snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write status snapshot: %w", err)
+	}
+	klog.V(4).Infof("Saved status snapshot of %d pods to %s", len(snapshot.Pods), path)
+	return nil
+}
+
+// LoadSnapshot reads statuses saved by SaveSnapshot. They seed pods as they are added, as
+// long as the pod still has the same IP. A missing file is not an error. With a snapshot key
+// set, unsigned snapshots and snapshots whose signature doesn't match are rejected.
+func (ps *PodSet) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read status snapshot: %w", err)
+	}
+	if len(ps.snapshotKey) > 0 {
+		var signed signedStatusSnapshot
+		if err := json.Unmarshal(data, &signed); err != nil {
+			return fmt.Errorf("failed to parse status snapshot %s: %w", path, err)
+		}
+		if signed.Signature == "" || signed.Snapshot == nil {
+			return fmt.Errorf("%w: %s", errSnapshotUnsigned, path)
+		}
+		if !hmac.Equal([]byte(signed.Signature), []byte(signSnapshot(ps.snapshotKey, signed.Snapshot))) {
+			return fmt.Errorf("%w: %s", errSnapshotSignature, path)
+		}
+		data = signed.Snapshot
+	}
+	var snapshot statusSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to parse status snapshot %s: %w", path, err)
+	}
+
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.seeds = make(map[string]statusSnapshotEntry, len(snapshot.Pods))
+	for _, entry := range snapshot.Pods {
+		ps.seeds[entry.Namespace+"/"+entry.Name] = entry
+	}
+	klog.Infof("Loaded status snapshot of %d pods saved at %v", len(snapshot.Pods), snapshot.SavedAt)
+	return nil
+}
+
+// seedStatus applies and consumes a snapshot status for a newly added pod. Callers hold ps.mu.
+func (ps *PodSet) seedStatus(pod *PodInfo) {
+	key := pod.Namespace + "/" + pod.Name
+	entry, exists := ps.seeds[key]
+	if !exists {
+		return
+	}
+	delete(ps.seeds, key)
+	if entry.IP != pod.IP || (entry.UID != "" && entry.UID != string(pod.UID)) {
+		return
+	}
+	pod.SetLastHealthStatus(entry.Healthy)
+	klog.V(4).Infof("Seeded pod %s from status snapshot: healthy=%v", key, entry.Healthy)
+}
+
+// signSnapshot returns the hex HMAC-SHA256 of a serialized snapshot
+func signSnapshot(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
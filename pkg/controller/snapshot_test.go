@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartSeedsFromSnapshot(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	before := NewPodSet()
+	before.AddOrUpdate(newReadyPod("default", "healthy", "10.0.0.1", enabled))
+	before.AddOrUpdate(newReadyPod("default", "failing", "10.0.0.2", enabled))
+	before.AddOrUpdate(newReadyPod("default", "moved", "10.0.0.3", enabled))
+	before.AddOrUpdate(newReadyPod("default", "unchecked", "10.0.0.4", enabled))
+	before.ForEach(func(pod *PodInfo) {
+		switch pod.Name {
+		case "healthy", "moved":
+			pod.SetLastHealthStatus(true)
+		case "failing":
+			pod.SetLastHealthStatus(false)
+		}
+	})
+	require.NoError(t, before.SaveSnapshot(path))
+
+	// A restarted checker loads the snapshot before the informer adds pods
+	after := NewPodSet()
+	require.NoError(t, after.LoadSnapshot(path))
+	after.AddOrUpdate(newReadyPod("default", "healthy", "10.0.0.1", enabled))
+	after.AddOrUpdate(newReadyPod("default", "failing", "10.0.0.2", enabled))
+	after.AddOrUpdate(newReadyPod("default", "moved", "10.0.0.9", enabled))
+	after.AddOrUpdate(newReadyPod("default", "unchecked", "10.0.0.4", enabled))
+
+	statuses := make(map[string]*bool)
+	after.ForEach(func(pod *PodInfo) { statuses[pod.Name] = pod.GetLastHealthStatus() })
+	if assert.NotNil(t, statuses["healthy"]) {
+		assert.True(t, *statuses["healthy"])
+	}
+	if assert.NotNil(t, statuses["failing"]) {
+		assert.False(t, *statuses["failing"])
+	}
+	assert.Nil(t, statuses["moved"], "a pod with a new IP starts cold")
+	assert.Nil(t, statuses["unchecked"], "a pod never checked starts cold")
+}
+
+func TestSnapshotSkipsRecreatedPods(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	path := filepath.Join(t.TempDir(), "status.json")
+
+	pod := newReadyPod("default", "db-0", "10.0.0.5", enabled)
+	pod.UID = "uid-1"
+	before := NewPodSet()
+	before.AddOrUpdate(pod)
+	before.ForEach(func(pod *PodInfo) { pod.SetLastHealthStatus(false) })
+	require.NoError(t, before.SaveSnapshot(path))
+
+	// Recreated with the same IP while the checker was down
+	after := NewPodSet()
+	require.NoError(t, after.LoadSnapshot(path))
+	pod.UID = "uid-2"
+	after.AddOrUpdate(pod)
+	assert.Nil(t, after.GetPod("default", "db-0").GetLastHealthStatus(), "a recreated pod doesn't inherit the old status")
+}
+
+func TestLoadSnapshotMissingOrCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, NewPodSet().LoadSnapshot(filepath.Join(dir, "missing.json")))
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+	assert.Error(t, NewPodSet().LoadSnapshot(corrupt))
+}
+
+func TestSignedSnapshot(t *testing.T) {
+	enabled := map[string]string{"endpoint-health-checker.io/enabled": "true"}
+	dir := t.TempDir()
+	key := []byte("snapshot-key")
+
+	seeded := func(path string, key []byte) (*bool, error) {
+		ps := NewPodSet()
+		ps.SetSnapshotKey(key)
+		err := ps.LoadSnapshot(path)
+		ps.AddOrUpdate(newReadyPod("default", "web", "10.0.0.1", enabled))
+		var status *bool
+		ps.ForEach(func(pod *PodInfo) { status = pod.GetLastHealthStatus() })
+		return status, err
+	}
+
+	signed := filepath.Join(dir, "signed.json")
+	before := NewPodSet()
+	before.SetSnapshotKey(key)
+	before.AddOrUpdate(newReadyPod("default", "web", "10.0.0.1", enabled))
+	before.ForEach(func(pod *PodInfo) { pod.SetLastHealthStatus(false) })
+	require.NoError(t, before.SaveSnapshot(signed))
+
+	status, err := seeded(signed, key)
+	require.NoError(t, err)
+	if assert.NotNil(t, status) {
+		assert.False(t, *status)
+	}
+
+	status, err = seeded(signed, []byte("other-key"))
+	assert.ErrorIs(t, err, errSnapshotSignature)
+	assert.Nil(t, status, "a snapshot signed with another key starts cold")
+
+	// Flipping a pod to healthy in the file must not be trusted
+	data, err := os.ReadFile(signed)
+	require.NoError(t, err)
+	tampered := filepath.Join(dir, "tampered.json")
+	require.NoError(t, os.WriteFile(tampered, []byte(strings.Replace(string(data), `"healthy":false`, `"healthy":true`, 1)), 0o600))
+	status, err = seeded(tampered, key)
+	assert.ErrorIs(t, err, errSnapshotSignature)
+	assert.Nil(t, status)
+
+	unsigned := filepath.Join(dir, "unsigned.json")
+	plain := NewPodSet()
+	plain.AddOrUpdate(newReadyPod("default", "web", "10.0.0.1", enabled))
+	plain.ForEach(func(pod *PodInfo) { pod.SetLastHealthStatus(true) })
+	require.NoError(t, plain.SaveSnapshot(unsigned))
+	status, err = seeded(unsigned, key)
+	assert.ErrorIs(t, err, errSnapshotUnsigned)
+	assert.Nil(t, status)
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// apiUncertaintyHold is how long after a failed API call our failures are reported as Unknown
+const apiUncertaintyHold = time.Minute
+
+// uncertaintyTracker remembers when the API server was last unreachable. A checker that lost
+// the API server may itself be on the partitioned side, so its failures aren't trusted.
+type uncertaintyTracker struct {
+	mu             sync.Mutex
+	lastAPIFailure time.Time
+	now            func() time.Time
+}
+
+func newUncertaintyTracker() *uncertaintyTracker {
+	return &uncertaintyTracker{now: time.Now}
+}
+
+// observe records the outcome of an API call
+func (u *uncertaintyTracker) observe(err error) {
+	if !isAPIUnreachable(err) {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.lastAPIFailure = u.now()
+}
+
+// uncertain reports whether the API server was unreachable within the hold period
+func (u *uncertaintyTracker) uncertain() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return !u.lastAPIFailure.IsZero() && u.now().Sub(u.lastAPIFailure) < apiUncertaintyHold
+}
+
+// isContextError reports whether a request was cut short by our own context, such as a check
+// that used up its deadline probing a slow pod, which says nothing about the API server
+func isContextError(err error) bool {
+	return stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded)
+}
+
+// isAPIUnreachable reports whether err means the API server couldn't serve the request, as
+// opposed to answering it with a rejection such as NotFound or Conflict
+func isAPIUnreachable(err error) bool {
+	if err == nil || isContextError(err) {
+		return false
+	}
+	var status errors.APIStatus
+	if !stderrors.As(err, &status) {
+		// Transport errors: connection refused, timeouts, DNS
+		return true
+	}
+	return errors.IsServerTimeout(err) || errors.IsTimeout(err) || errors.IsServiceUnavailable(err) ||
+		errors.IsTooManyRequests(err) || errors.IsInternalError(err) || errors.IsUnexpectedServerError(err)
+}
+
+// markPodStatusUnknown sets the conditions we would have set False to Unknown, telling
+// consumers the pod failed our checks but the signal can't be trusted. reason is logged.
+func (hc *HealthChecker) markPodStatusUnknown(ctx context.Context, clientset kubernetes.Interface, pod HealthCheckPodInfo, reason string) error {
+	if !hc.patchTerminating && pod.IsTerminating() {
+		return nil
+	}
+	k8sPod, err := clientset.CoreV1().Pods(pod.GetNamespace()).Get(ctx, pod.GetName(), metav1.GetOptions{})
+	hc.observeAPI(err)
+	if err != nil {
+		return fmt.Errorf("failed to get pod %s/%s: %w", pod.GetNamespace(), pod.GetName(), err)
+	}
+	if !hc.patchTerminating && k8sPod.DeletionTimestamp != nil {
+		return nil
+	}
+
+	var touched []corev1.PodConditionType
+	gate := corev1.PodConditionType(hc.readinessGateType)
+	if hasReadinessGate(k8sPod, hc.readinessGateType) && conditionStatus(k8sPod, gate) != corev1.ConditionUnknown {
+		hc.updateReadinessGateCondition(&k8sPod.Status.Conditions, corev1.ConditionUnknown)
+		touched = append(touched, gate)
+	}
+	if managesReady(k8sPod) && conditionStatus(k8sPod, corev1.PodReady) != corev1.ConditionUnknown {
+		updateReadyCondition(&k8sPod.Status.Conditions, corev1.ConditionUnknown)
+		touched = append(touched, corev1.PodReady)
+	}
+	if len(touched) == 0 {
+		pod.ClearLastHealthStatus()
+		return nil
+	}
+
+	patchType, patchBytes, err := buildConditionsPatch(k8sPod.Status.Conditions, touched, hc.statusPatchType)
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().Pods(k8sPod.Namespace).Patch(ctx, k8sPod.Name, patchType, patchBytes, metav1.PatchOptions{}, "status")
+	hc.observeAPI(err)
+	if err != nil {
+		return fmt.Errorf("failed to patch pod %s/%s: %w", k8sPod.Namespace, k8sPod.Name, err)
+	}
+	// The cached status no longer matches the conditions, the next result must write them again
+	pod.ClearLastHealthStatus()
+	klog.Warningf("Pod %s/%s: %s, set conditions to Unknown", k8sPod.Namespace, k8sPod.Name, reason)
+	return nil
+}
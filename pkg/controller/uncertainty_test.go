@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestIsAPIUnreachable(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	assert.False(t, isAPIUnreachable(nil))
+	assert.False(t, isAPIUnreachable(apierrors.NewNotFound(pods, "web-0")))
+	assert.False(t, isAPIUnreachable(apierrors.NewConflict(pods, "web-0", fmt.Errorf("stale"))))
+	assert.True(t, isAPIUnreachable(apierrors.NewServiceUnavailable("down")))
+	assert.True(t, isAPIUnreachable(apierrors.NewTimeoutError("slow", 1)))
+	assert.True(t, isAPIUnreachable(fmt.Errorf("failed to get pod: %w", fmt.Errorf("dial tcp 10.96.0.1:443: connection refused"))))
+	assert.False(t, isAPIUnreachable(fmt.Errorf("failed to get pod: %w", context.DeadlineExceeded)))
+	assert.False(t, isAPIUnreachable(fmt.Errorf("failed to get pod: %w", context.Canceled)))
+}
+
+func TestUnknownOnUncertaintyAfterAPIUnreachable(t *testing.T) {
+	for _, unknown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("unknown=%v", unknown), func(t *testing.T) {
+			k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+			k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+			clientset := fake.NewSimpleClientset(k8sPod)
+
+			// The first status patch finds the API server unreachable
+			var patches atomic.Int32
+			clientset.PrependReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if patches.Add(1) == 1 {
+					return true, nil, apierrors.NewServiceUnavailable("apiserver unreachable")
+				}
+				return false, nil, nil
+			})
+
+			hc := newLocalHealthChecker()
+			hc.SetUnknownOnUncertainty(unknown)
+			pod := &PodInfo{Namespace: "default", Name: "web-0", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+			assert.Error(t, hc.CheckPod(context.Background(), clientset, pod))
+			assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+
+			got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+			assert.NoError(t, err)
+			want := corev1.ConditionFalse
+			if unknown {
+				want = corev1.ConditionUnknown
+			}
+			assert.Equal(t, want, conditionStatus(got, corev1.PodReady))
+			assert.Equal(t, want, conditionStatus(got, "endpointHealthCheckSuccess"))
+		})
+	}
+}
+
+func TestUnknownOnUncertaintyRecovers(t *testing.T) {
+	for _, gated := range []bool{false, true} {
+		t.Run(fmt.Sprintf("gated=%v", gated), func(t *testing.T) {
+			k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+			if gated {
+				k8sPod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: "endpointHealthCheckSuccess"}}
+				k8sPod.Status.Conditions = append(k8sPod.Status.Conditions,
+					corev1.PodCondition{Type: "endpointHealthCheckSuccess", Status: corev1.ConditionTrue})
+			}
+			clientset := fake.NewSimpleClientset(k8sPod)
+			status := func(condType corev1.PodConditionType) corev1.ConditionStatus {
+				got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+				assert.NoError(t, err)
+				return conditionStatus(got, condType)
+			}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			defer ln.Close()
+			openPort := int32(ln.Addr().(*net.TCPAddr).Port)
+
+			hc := newLocalHealthChecker()
+			hc.SetUnknownOnUncertainty(true)
+			hc.uncertainty.observe(apierrors.NewServiceUnavailable("apiserver unreachable"))
+			healthy := true
+			pod := &PodInfo{Namespace: "default", Name: "web-0", IP: "127.0.0.1", Ports: []int32{closedPort(t)}, LastHealthStatus: &healthy}
+
+			// A healthy pod failing while results are untrusted goes Unknown and forgets its status
+			assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+			assert.Equal(t, corev1.ConditionUnknown, status(corev1.PodReady))
+			assert.Nil(t, pod.GetLastHealthStatus())
+
+			// Its recovery writes the conditions again instead of being skipped as unchanged
+			pod.Ports = []int32{openPort}
+			assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+			if gated {
+				// The kubelet recomputes Ready from the gate
+				assert.Equal(t, corev1.ConditionTrue, status("endpointHealthCheckSuccess"))
+			} else {
+				assert.Equal(t, corev1.ConditionTrue, status(corev1.PodReady))
+			}
+			assert.True(t, *pod.GetLastHealthStatus())
+		})
+	}
+}
+
+func TestUnknownOnUncertaintyExpires(t *testing.T) {
+	now := time.Now()
+	tracker := newUncertaintyTracker()
+	tracker.now = func() time.Time { return now }
+
+	assert.False(t, tracker.uncertain())
+	tracker.observe(apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web-0"))
+	assert.False(t, tracker.uncertain())
+
+	tracker.observe(apierrors.NewServiceUnavailable("down"))
+	assert.True(t, tracker.uncertain())
+	now = now.Add(apiUncertaintyHold)
+	assert.False(t, tracker.uncertain())
+}
+
+func TestUnknownOnUncertaintyWhileBreakerOpen(t *testing.T) {
+	k8sPod := newReadyPod("default", "web-0", "127.0.0.1", map[string]string{"endpoint-health-checker.io/enabled": "true"})
+	clientset := fake.NewSimpleClientset(k8sPod)
+
+	hc := newLocalHealthChecker()
+	hc.SetUnknownOnUncertainty(true)
+	hc.SetFailureRateBreaker(NewFailureRateBreaker(0.5, time.Minute, 1))
+	pod := &PodInfo{Namespace: "default", Name: "web-0", IP: "127.0.0.1", Ports: []int32{closedPort(t)}}
+
+	assert.NoError(t, hc.CheckPod(context.Background(), clientset, pod))
+	got, err := clientset.CoreV1().Pods("default").Get(context.Background(), "web-0", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, corev1.ConditionUnknown, conditionStatus(got, corev1.PodReady))
+	assert.Nil(t, pod.GetLastHealthStatus())
+}
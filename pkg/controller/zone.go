@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// zoneTimeouts picks the probe timeout of a pod by whether its node is in the checker's zone,
+// so cross-zone round trips don't turn into false negatives while same-zone checks stay tight
+type zoneTimeouts struct {
+	lister    v1.NodeLister
+	localNode string
+	sameZone  time.Duration
+	crossZone time.Duration
+}
+
+// timeoutFor returns the timeout for a pod on nodeName, fallback when either zone is unknown
+// or no timeout is configured for the relation
+func (z *zoneTimeouts) timeoutFor(nodeName string, fallback time.Duration) time.Duration {
+	localZone := nodeZone(z.lister, z.localNode)
+	podZone := nodeZone(z.lister, nodeName)
+	if localZone == "" || podZone == "" {
+		return fallback
+	}
+	timeout := z.crossZone
+	if podZone == localZone {
+		timeout = z.sameZone
+	}
+	if timeout <= 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// nodeZone returns the topology zone label of a node, empty if unknown
+func nodeZone(lister v1.NodeLister, name string) string {
+	if name == "" {
+		return ""
+	}
+	node, err := lister.Get(name)
+	if err != nil {
+		klog.V(4).Infof("Zone of node %s unknown: %v", name, err)
+		return ""
+	}
+	return node.Labels[corev1.LabelTopologyZone]
+}
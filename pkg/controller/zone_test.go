@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestZoneTimeouts(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for name, zone := range map[string]string{"checker-node": "zone-a", "peer-node": "zone-a", "remote-node": "zone-b", "bare-node": ""} {
+		node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if zone != "" {
+			node.Labels = map[string]string{corev1.LabelTopologyZone: zone}
+		}
+		assert.NoError(t, indexer.Add(node))
+	}
+
+	hc := NewHealthChecker()
+	hc.SetHealthCheckTimeout(time.Second)
+	pod := func(node string) *PodInfo { return &PodInfo{Namespace: "default", Name: "web", NodeName: node} }
+	assert.Equal(t, time.Second, hc.probeTimeout(pod("remote-node")), "zone timeouts disabled")
+
+	hc.SetZoneTimeouts(listersv1.NewNodeLister(indexer), "checker-node", 200*time.Millisecond, 3*time.Second)
+	assert.Equal(t, 200*time.Millisecond, hc.probeTimeout(pod("peer-node")))
+	assert.Equal(t, 200*time.Millisecond, hc.probeTimeout(pod("checker-node")))
+	assert.Equal(t, 3*time.Second, hc.probeTimeout(pod("remote-node")))
+	assert.Equal(t, time.Second, hc.probeTimeout(pod("bare-node")), "unlabeled node")
+	assert.Equal(t, time.Second, hc.probeTimeout(pod("unknown-node")), "node not in the cache")
+	assert.Equal(t, time.Second, hc.probeTimeout(pod("")), "pod not scheduled")
+
+	// Only the cross-zone timeout configured, same-zone pods keep the default
+	hc.SetZoneTimeouts(listersv1.NewNodeLister(indexer), "checker-node", 0, 3*time.Second)
+	assert.Equal(t, time.Second, hc.probeTimeout(pod("peer-node")))
+	assert.Equal(t, 3*time.Second, hc.probeTimeout(pod("remote-node")))
+}
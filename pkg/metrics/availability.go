@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxAvailabilitySamples bounds the history kept per pod regardless of the window
+const maxAvailabilitySamples = 4096
+
+var (
+	podAvailabilityDesc = prometheus.NewDesc("ehc_pod_availability_ratio",
+		"Fraction of passing health checks of a pod over the rolling availability window",
+		[]string{"namespace", "pod"}, nil)
+	availabilityDesc = prometheus.NewDesc("ehc_availability_ratio",
+		"Fraction of passing health checks across all pods over the rolling availability window",
+		nil, nil)
+
+	availabilityOnce sync.Once
+	availability     *availabilityTracker
+)
+
+type availabilitySample struct {
+	at      time.Time
+	healthy bool
+}
+
+// availabilityTracker keeps a rolling history of check results per pod and computes
+// availability ratios when scraped
+type availabilityTracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	now     func() time.Time
+	history map[availabilityKey][]availabilitySample
+}
+
+type availabilityKey struct {
+	namespace string
+	pod       string
+}
+
+func newAvailabilityTracker(window time.Duration) *availabilityTracker {
+	return &availabilityTracker{
+		window:  window,
+		now:     time.Now,
+		history: make(map[availabilityKey][]availabilitySample),
+	}
+}
+
+// EnableAvailability starts recording check results and exposes availability ratios over
+// the given rolling window. Only the first call takes effect.
+func EnableAvailability(window time.Duration) {
+	availabilityOnce.Do(func() {
+		availability = newAvailabilityTracker(window)
+		prometheus.MustRegister(availability)
+	})
+}
+
+// RecordAvailability adds one check result to a pod's history, a no-op unless enabled
+func RecordAvailability(namespace, pod string, healthy bool) {
+	if availability != nil {
+		availability.record(namespace, pod, healthy)
+	}
+}
+
+func (a *availabilityTracker) record(namespace, pod string, healthy bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := availabilityKey{namespace: namespace, pod: pod}
+	samples := append(a.history[key], availabilitySample{at: a.now(), healthy: healthy})
+	if len(samples) > maxAvailabilitySamples {
+		samples = samples[len(samples)-maxAvailabilitySamples:]
+	}
+	a.history[key] = samples
+}
+
+// Describe implements prometheus.Collector
+func (a *availabilityTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- podAvailabilityDesc
+	ch <- availabilityDesc
+}
+
+// Collect implements prometheus.Collector. Samples older than the window are dropped
+// first, so pods that are no longer checked age out of the output.
+func (a *availabilityTracker) Collect(ch chan<- prometheus.Metric) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := a.now().Add(-a.window)
+	var total, passed int
+	for key, samples := range a.history {
+		start := 0
+		for start < len(samples) && samples[start].at.Before(cutoff) {
+			start++
+		}
+		samples = samples[start:]
+		if len(samples) == 0 {
+			delete(a.history, key)
+			continue
+		}
+		a.history[key] = samples
+
+		podPassed := 0
+		for _, sample := range samples {
+			if sample.healthy {
+				podPassed++
+			}
+		}
+		total += len(samples)
+		passed += podPassed
+		ch <- prometheus.MustNewConstMetric(podAvailabilityDesc, prometheus.GaugeValue,
+			float64(podPassed)/float64(len(samples)), key.namespace, key.pod)
+	}
+	if total > 0 {
+		ch <- prometheus.MustNewConstMetric(availabilityDesc, prometheus.GaugeValue, float64(passed)/float64(total))
+	}
+}
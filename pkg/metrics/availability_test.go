@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAvailabilityRatioOverWindow(t *testing.T) {
+	now := time.Now()
+	tracker := newAvailabilityTracker(5 * time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	// web: 3 of 4 pass, db: 1 of 2 pass
+	for _, healthy := range []bool{true, false, true, true} {
+		tracker.record("default", "web", healthy)
+	}
+	tracker.record("default", "db", true)
+	tracker.record("default", "db", false)
+
+	expected := `
+# HELP ehc_availability_ratio Fraction of passing health checks across all pods over the rolling availability window
+# TYPE ehc_availability_ratio gauge
+ehc_availability_ratio 0.6666666666666666
+# HELP ehc_pod_availability_ratio Fraction of passing health checks of a pod over the rolling availability window
+# TYPE ehc_pod_availability_ratio gauge
+ehc_pod_availability_ratio{namespace="default",pod="db"} 0.5
+ehc_pod_availability_ratio{namespace="default",pod="web"} 0.75
+`
+	assert.NoError(t, testutil.CollectAndCompare(tracker, strings.NewReader(expected)))
+
+	// Past the window only results recorded since count, db ages out entirely
+	now = now.Add(4 * time.Minute)
+	tracker.record("default", "web", true)
+	now = now.Add(2 * time.Minute)
+
+	expected = `
+# HELP ehc_availability_ratio Fraction of passing health checks across all pods over the rolling availability window
+# TYPE ehc_availability_ratio gauge
+ehc_availability_ratio 1
+# HELP ehc_pod_availability_ratio Fraction of passing health checks of a pod over the rolling availability window
+# TYPE ehc_pod_availability_ratio gauge
+ehc_pod_availability_ratio{namespace="default",pod="web"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(tracker, strings.NewReader(expected)))
+}
@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var (
+	leaderTransitions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ehc_leader_transitions_total",
+		Help: "Number of observed leader changes after the first leader was seen",
+	})
+
+	leaseRenewErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ehc_lease_renew_errors_total",
+		Help: "Number of failed lease updates, which includes renewals by the current leader",
+	})
+
+	leaderAcquireSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ehc_leader_acquire_duration_seconds",
+		Help: "Time this replica waited between starting leader election and acquiring leadership",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(leaderTransitions, leaseRenewErrors, leaderAcquireSeconds)
+}
+
+// InstrumentLeaderCallbacks wraps leader election callbacks to count leader transitions
+// and record how long acquisition took. Call it right before starting the election.
+func InstrumentLeaderCallbacks(callbacks leaderelection.LeaderCallbacks) leaderelection.LeaderCallbacks {
+	started := time.Now()
+	var mu sync.Mutex
+	var lastLeader string
+
+	wrapped := callbacks
+	wrapped.OnStartedLeading = func(ctx context.Context) {
+		leaderAcquireSeconds.Set(time.Since(started).Seconds())
+		if callbacks.OnStartedLeading != nil {
+			callbacks.OnStartedLeading(ctx)
+		}
+	}
+	wrapped.OnNewLeader = func(identity string) {
+		mu.Lock()
+		if lastLeader != "" && lastLeader != identity {
+			leaderTransitions.Inc()
+		}
+		lastLeader = identity
+		mu.Unlock()
+		if callbacks.OnNewLeader != nil {
+			callbacks.OnNewLeader(identity)
+		}
+	}
+	return wrapped
+}
+
+// instrumentedLock counts failed lease updates of the wrapped lock
+type instrumentedLock struct {
+	resourcelock.Interface
+}
+
+// InstrumentLock wraps a resource lock so renew failures are counted
+func InstrumentLock(lock resourcelock.Interface) resourcelock.Interface {
+	return &instrumentedLock{Interface: lock}
+}
+
+// Update renews or takes over the lease, counting failures
+func (l *instrumentedLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Update(ctx, ler)
+	if err != nil {
+		leaseRenewErrors.Inc()
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeLock is a resource lock whose updates fail on demand
+type fakeLock struct {
+	resourcelock.Interface
+	updateErr error
+}
+
+func (l *fakeLock) Update(context.Context, resourcelock.LeaderElectionRecord) error {
+	return l.updateErr
+}
+
+func TestInstrumentLeaderCallbacksCountsTransitions(t *testing.T) {
+	before := testutil.ToFloat64(leaderTransitions)
+
+	var observed []string
+	started := false
+	callbacks := InstrumentLeaderCallbacks(leaderelection.LeaderCallbacks{
+		OnStartedLeading: func(context.Context) { started = true },
+		OnStoppedLeading: func() {},
+		OnNewLeader:      func(identity string) { observed = append(observed, identity) },
+	})
+
+	// The first leader seen is not a transition, every change after it is
+	callbacks.OnNewLeader("replica-a")
+	callbacks.OnNewLeader("replica-b")
+	callbacks.OnNewLeader("replica-a")
+	assert.Equal(t, before+2, testutil.ToFloat64(leaderTransitions))
+	assert.Equal(t, []string{"replica-a", "replica-b", "replica-a"}, observed)
+
+	callbacks.OnStartedLeading(context.Background())
+	assert.True(t, started)
+	assert.Greater(t, testutil.ToFloat64(leaderAcquireSeconds), 0.0)
+}
+
+func TestInstrumentLockCountsRenewErrors(t *testing.T) {
+	before := testutil.ToFloat64(leaseRenewErrors)
+	lock := &fakeLock{}
+	instrumented := InstrumentLock(lock)
+
+	assert.NoError(t, instrumented.Update(context.Background(), resourcelock.LeaderElectionRecord{}))
+	assert.Equal(t, before, testutil.ToFloat64(leaseRenewErrors))
+
+	lock.updateErr = errors.New("etcdserver: request timed out")
+	assert.Error(t, instrumented.Update(context.Background(), resourcelock.LeaderElectionRecord{}))
+	assert.Equal(t, before+1, testutil.ToFloat64(leaseRenewErrors))
+}
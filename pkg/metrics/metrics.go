@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	checkDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ehc_health_check_duration_seconds",
+		Help:    "Duration of pod health checks, including retries",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 15),
+	}, []string{"protocol"})
+
+	checksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ehc_health_checks_total",
+		Help: "Number of pod health checks by protocol, outcome and failure class",
+	}, []string{"protocol", "healthy", "result"})
+
+	ownerHealthyRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ehc_owner_healthy_ratio",
+		Help: "Fraction of checked pods that are healthy, grouped by owning workload",
+	}, []string{"namespace", "owner_kind", "owner_name"})
+
+	effectiveInterval = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ehc_effective_interval_seconds",
+		Help: "Effective interval between scans, above the configured interval when stretched to achievable throughput",
+	})
+
+	serviceChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ehc_service_checks_total",
+		Help: "Number of Service ClusterIP routing checks by outcome",
+	}, []string{"namespace", "service", "result"})
+
+	transitionsSuppressed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ehc_transitions_suppressed_total",
+		Help: "Status transitions suppressed because the pod exceeded its hourly transition budget",
+	}, []string{"namespace"})
+
+	controlPlaneDegraded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ehc_control_plane_degraded",
+		Help: "1 while API errors are widespread and pod status updates are paused, 0 otherwise",
+	})
+
+	statusWritesPaused = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ehc_status_writes_paused_total",
+		Help: "Pod status updates skipped because the control plane was degraded",
+	})
+
+	unhealthyDeferred = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ehc_unhealthy_deferred_total",
+		Help: "Unhealthy transitions deferred because the Service would drop below its minimum healthy endpoints",
+	}, []string{"namespace", "service"})
+
+	timeoutEscalations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ehc_timeout_escalations_total",
+		Help: "Escalated-timeout probes of pods that kept timing out, by outcome: slow (answered) or dead (timed out at the cap)",
+	}, []string{"namespace", "outcome"})
+)
+
+func init() {
+	prometheus.MustRegister(checkDuration, checksTotal, ownerHealthyRatio, unhealthyDeferred, effectiveInterval, serviceChecks,
+		transitionsSuppressed, controlPlaneDegraded, statusWritesPaused, timeoutEscalations)
+}
+
+type traceIDKey struct{}
+
+// ContextWithTraceID attaches the trace ID of the current span, so metrics recorded
+// with this context carry it as an exemplar
+func ContextWithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// TraceIDFromContext returns the trace ID attached to ctx, if any
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey{}).(string)
+	return traceID
+}
+
+// ResultSuccess is the result of a passing check, failed checks are recorded with their failure class
+const ResultSuccess = "success"
+
+// ObserveCheck records the result and duration of one pod health check. When ctx
+// carries a trace ID it is attached to the duration sample as an exemplar.
+func ObserveCheck(ctx context.Context, protocol, result string, duration time.Duration) {
+	checksTotal.WithLabelValues(protocol, strconv.FormatBool(result == ResultSuccess), result).Inc()
+
+	observer := checkDuration.WithLabelValues(protocol)
+	if traceID := TraceIDFromContext(ctx); traceID != "" {
+		if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok {
+			exemplarObserver.ObserveWithExemplar(duration.Seconds(), prometheus.Labels{"trace_id": traceID})
+			return
+		}
+	}
+	observer.Observe(duration.Seconds())
+}
+
+// ChecksCounter returns the check counter of one protocol and result, for inspection
+func ChecksCounter(protocol, result string) prometheus.Counter {
+	return checksTotal.WithLabelValues(protocol, strconv.FormatBool(result == ResultSuccess), result)
+}
+
+// SetOwnerHealthyRatio records the healthy fraction of pods belonging to one workload
+func SetOwnerHealthyRatio(namespace, kind, name string, ratio float64) {
+	ownerHealthyRatio.WithLabelValues(namespace, kind, name).Set(ratio)
+}
+
+// OwnerHealthyRatioGauge returns the gauge of one workload, for inspection
+func OwnerHealthyRatioGauge(namespace, kind, name string) prometheus.Gauge {
+	return ownerHealthyRatio.WithLabelValues(namespace, kind, name)
+}
+
+// ResetOwnerHealthyRatio drops all owner series so workloads that disappeared stop being reported
+func ResetOwnerHealthyRatio() {
+	ownerHealthyRatio.Reset()
+}
+
+// SetEffectiveInterval records the interval the scheduler currently dispatches scans at
+func SetEffectiveInterval(interval time.Duration) {
+	effectiveInterval.Set(interval.Seconds())
+}
+
+// EffectiveIntervalGauge returns the effective interval gauge, for inspection
+func EffectiveIntervalGauge() prometheus.Gauge {
+	return effectiveInterval
+}
+
+// RecordTransitionSuppressed counts a status transition suppressed by the per-pod budget
+func RecordTransitionSuppressed(namespace string) {
+	transitionsSuppressed.WithLabelValues(namespace).Inc()
+}
+
+// TransitionsSuppressedCounter returns the suppression counter of one namespace, for inspection
+func TransitionsSuppressedCounter(namespace string) prometheus.Counter {
+	return transitionsSuppressed.WithLabelValues(namespace)
+}
+
+// SetControlPlaneDegraded records whether status updates are paused for a degraded control plane
+func SetControlPlaneDegraded(degraded bool) {
+	if degraded {
+		controlPlaneDegraded.Set(1)
+	} else {
+		controlPlaneDegraded.Set(0)
+	}
+}
+
+// ControlPlaneDegradedGauge returns the degraded mode gauge, for inspection
+func ControlPlaneDegradedGauge() prometheus.Gauge {
+	return controlPlaneDegraded
+}
+
+// RecordStatusWritePaused counts a status update skipped while the control plane was degraded
+func RecordStatusWritePaused() {
+	statusWritesPaused.Inc()
+}
+
+// StatusWritesPausedCounter returns the paused status update counter, for inspection
+func StatusWritesPausedCounter() prometheus.Counter {
+	return statusWritesPaused
+}
+
+// RecordServiceCheck counts one Service routing check by outcome
+func RecordServiceCheck(namespace, service, result string) {
+	serviceChecks.WithLabelValues(namespace, service, result).Inc()
+}
+
+// RecordUnhealthyDeferred counts an unhealthy transition held back to protect a Service
+func RecordUnhealthyDeferred(namespace, service string) {
+	unhealthyDeferred.WithLabelValues(namespace, service).Inc()
+}
+
+// UnhealthyDeferredCounter returns the deferral counter of one Service, for inspection
+func UnhealthyDeferredCounter(namespace, service string) prometheus.Counter {
+	return unhealthyDeferred.WithLabelValues(namespace, service)
+}
+
+// Outcomes of probes run with an escalated timeout
+const (
+	EscalationSlow = "slow"
+	EscalationDead = "dead"
+)
+
+// RecordTimeoutEscalation counts one escalated-timeout probe by outcome
+func RecordTimeoutEscalation(namespace, outcome string) {
+	timeoutEscalations.WithLabelValues(namespace, outcome).Inc()
+}
+
+// TimeoutEscalationsCounter returns the escalation counter of one namespace and outcome, for inspection
+func TimeoutEscalationsCounter(namespace, outcome string) prometheus.Counter {
+	return timeoutEscalations.WithLabelValues(namespace, outcome)
+}
+
+// Handler serves the registered metrics, negotiating OpenMetrics so exemplars are exposed
+func Handler() http.Handler {
+	return promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+}
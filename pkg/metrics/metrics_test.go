@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// histogramFor gathers the duration histogram of the given protocol
+func histogramFor(t *testing.T, protocol string) *dto.Histogram {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "ehc_health_check_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "protocol" && label.GetValue() == protocol {
+					return metric.GetHistogram()
+				}
+			}
+		}
+	}
+	t.Fatalf("no histogram for protocol %s", protocol)
+	return nil
+}
+
+func TestObserveCheckAttachesExemplar(t *testing.T) {
+	ctx := ContextWithTraceID(context.Background(), "4bf92f3577b34da6a3ce929d0e0e4736")
+	ObserveCheck(ctx, "exemplar-test", ResultSuccess, 3*time.Millisecond)
+
+	var exemplar *dto.Exemplar
+	for _, bucket := range histogramFor(t, "exemplar-test").GetBucket() {
+		if bucket.GetExemplar() != nil {
+			exemplar = bucket.GetExemplar()
+		}
+	}
+	if assert.NotNil(t, exemplar) {
+		assert.Equal(t, "trace_id", exemplar.GetLabel()[0].GetName())
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", exemplar.GetLabel()[0].GetValue())
+		assert.InDelta(t, 0.003, exemplar.GetValue(), 1e-9)
+	}
+}
+
+func TestObserveCheckWithoutTraceID(t *testing.T) {
+	ObserveCheck(context.Background(), "no-trace-test", "timeout", time.Millisecond)
+
+	histogram := histogramFor(t, "no-trace-test")
+	assert.Equal(t, uint64(1), histogram.GetSampleCount())
+	for _, bucket := range histogram.GetBucket() {
+		assert.Nil(t, bucket.GetExemplar())
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// pushJob is the Pushgateway job the checker's metrics are grouped under
+const pushJob = "endpoint-health-checker"
+
+// newPusher pushes the registered metrics to a Pushgateway, grouped by instance so each
+// replica replaces only its own metrics
+func newPusher(url, instance string, gatherer prometheus.Gatherer) *push.Pusher {
+	return push.New(url, pushJob).Gatherer(gatherer).Grouping("instance", instance)
+}
+
+// StartPusher pushes the registered metrics to the Pushgateway at url every interval until
+// ctx is done, for checkers that can't be scraped. Failed pushes are retried on the next round.
+func StartPusher(ctx context.Context, url, instance string, interval time.Duration) {
+	pusher := newPusher(url, instance, prometheus.DefaultGatherer)
+	klog.Infof("Pushing metrics to %s every %v as instance %s", url, interval, instance)
+	go wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := pusher.PushContext(ctx); err != nil {
+			klog.Warningf("Failed to push metrics to %s: %v", url, err)
+		}
+	}, interval)
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPusherPostsCheckMetrics(t *testing.T) {
+	type request struct {
+		method, path string
+		body         []byte
+	}
+	requests := make(chan request, 10)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{method: r.Method, path: r.URL.Path, body: body}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(checksTotal, checkDuration)
+	ObserveCheck(context.Background(), "tcp", ResultSuccess, 10*time.Millisecond)
+
+	assert.NoError(t, newPusher(gateway.URL, "checker-0", registry).Push())
+	got := <-requests
+	assert.Equal(t, http.MethodPut, got.method)
+	assert.Equal(t, "/metrics/job/endpoint-health-checker/instance/checker-0", got.path)
+	assert.Contains(t, string(got.body), "ehc_health_checks_total")
+	assert.Contains(t, string(got.body), "ehc_health_check_duration_seconds")
+
+	// The background pusher keeps pushing until its context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	StartPusher(ctx, gateway.URL, "checker-0", 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case got = <-requests:
+			assert.Equal(t, "/metrics/job/endpoint-health-checker/instance/checker-0", got.path)
+		case <-time.After(time.Second):
+			t.Fatal("no push from the background pusher")
+		}
+	}
+	cancel()
+}